@@ -57,6 +57,10 @@ go install fossul/src/engine/plugins/storage/basic/container-basic
 if [ $? != 0 ]; then exit 1; fi
 go build -buildmode=plugin -o $PLUGIN_DIR/storage/container-basic.so fossul/src/engine/plugins/storage/native/container-basic
 if [ $? != 0 ]; then exit 1; fi
+go build -buildmode=plugin -o $PLUGIN_DIR/storage/container-dedup.so fossul/src/engine/plugins/storage/native/container-dedup
+if [ $? != 0 ]; then exit 1; fi
+go build -buildmode=plugin -o $PLUGIN_DIR/storage/csi-snapshot.so fossul/src/engine/plugins/storage/native/csi-snapshot
+if [ $? != 0 ]; then exit 1; fi
 go build -buildmode=plugin -o $PLUGIN_DIR/app/mariadb.so fossul/src/engine/plugins/app/native/mariadb
 if [ $? != 0 ]; then exit 1; fi
 go build -buildmode=plugin -o $PLUGIN_DIR/app/mariadb-dump.so fossul/src/engine/plugins/app/native/mariadb-dump
@@ -75,6 +79,8 @@ if [ $? != 0 ]; then exit 1; fi
 echo "Building Services"
 go install fossul/src/engine/server
 if [ $? != 0 ]; then exit 1; fi
+go install fossul/src/engine/migrate-workflow-ids
+if [ $? != 0 ]; then exit 1; fi
 go install fossul/src/engine/app
 if [ $? != 0 ]; then exit 1; fi
 go install fossul/src/engine/storage
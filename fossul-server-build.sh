@@ -28,6 +28,8 @@ if [ $? != 0 ]; then exit 1; fi
 echo "Building Server Service"
 go install fossul/src/engine/server
 if [ $? != 0 ]; then exit 1; fi
+go install fossul/src/engine/migrate-workflow-ids
+if [ $? != 0 ]; then exit 1; fi
 
 echo "Copying startup script"
 cp $GOPATH/src/fossul/fossul-server-startup.sh $GOBIN
@@ -5,6 +5,7 @@ import (
 	"fossul/src/engine/util"
 	"github.com/pborman/getopt/v2"
 	"os"
+	"strings"
 )
 
 const version = "1.0.0"
@@ -24,16 +25,28 @@ func main() {
 	optCredentialFile := getopt.StringLong("credential-file", 'h', "", "Path to credential file")
 	optConfigFile := getopt.StringLong("config-file", 'f', "", "Path to config file")
 	optPolicy := getopt.StringLong("policy", 'i', "", "Backup policy as defined in config")
-	optAction := getopt.StringLong("action", 'a', "", "backup|restore|backupList|archiveList|listProfiles|listConfigs|listPluginConfigs|"+
-		"addProfile|addConfig|addPluginConfig|deleteProfile|deleteConfig|deleteConfigDir|"+
-		"deletePluginConfig|jobList|"+"addSchedule|deleteSchedule|jobStatus")
+	optAction := getopt.StringLong("action", 'a', "", "backup|restore|verify|backupList|backupBrowse|backupVerify|archiveList|listProfiles|listConfigs|listPluginConfigs|"+
+		"addProfile|addConfig|addPluginConfig|addProfileNotifiers|getProfileNotifiers|deleteProfile|deleteConfig|deleteConfigDir|"+
+		"deletePluginConfig|jobList|"+"addSchedule|deleteSchedule|jobStatus|cancel|releaseLock")
 	optPluginName := getopt.StringLong("plugin", 'l', "", "Name of plugin")
 	optPluginType := getopt.StringLong("plugin-type", 't', "", "Plugin type app|storage|archive")
 	optWorkflowId := getopt.StringLong("workflow-id", 'w', "", "Workflow Id")
 	optCronSchedule := getopt.StringLong("cron-schedule", 'r', "", "Cron Schedule Format - (min) (hour) (dayOfMOnth) (month) (dayOfWeek)")
+	optWorkflowType := getopt.StringLong("workflow-type", 0, "", "Workflow type started by schedule backup|verify, default is backup (addSchedule|deleteSchedule)")
 	optSetCredentials := getopt.BoolLong("set-credentials", 0, "Save credentials to a file")
 	optLocalConfig := getopt.BoolLong("local", 0, "Use a local configuration file")
 	optListSchedules := getopt.BoolLong("list-schedules", 0, "List schedules")
+	optListLocks := getopt.BoolLong("list-locks", 0, "List workflow locks")
+	optListQueue := getopt.BoolLong("list-queue", 0, "List running and queued workflows")
+	optAuditLog := getopt.BoolLong("audit-log", 0, "Show audit log of configuration, profile and schedule changes, optionally for --profile and --config")
+	optFollow := getopt.BoolLong("follow", 0, "Follow workflow until it ends, exit code reflects workflow status (backup|restore|verify|jobStatus)")
+	optTargetNamespace := getopt.StringLong("target-namespace", 0, "", "Restore into namespace instead of configured namespace (restore)")
+	optTargetService := getopt.StringLong("target-service", 0, "", "Restore into service instead of configured service (restore)")
+	optTargetContainer := getopt.StringLong("target-container", 0, "", "Restore into container instead of configured container (restore)")
+	optTargetPath := getopt.StringLong("target-path", 0, "", "Path restored data is copied to (restore)")
+	optTargetDatabase := getopt.StringLong("target-database", 0, "", "Restore into database instead of configured database (restore)")
+	optRestorePaths := getopt.StringLong("restore-paths", 0, "", "Paths within backup to restore separated by a comma, default is whole backup (restore)")
+	optDryRun := getopt.BoolLong("dry-run", 0, "Show workflow plan without executing it (backup|restore)")
 	optAppPluginList := getopt.BoolLong("list-app-plugins", 0, "List app plugins")
 	optStoragePluginList := getopt.BoolLong("list-storage-plugins", 0, "List storage plugins")
 	optArchivePluginList := getopt.BoolLong("list-archive-plugins", 0, "List archive plugins")
@@ -135,6 +148,18 @@ func main() {
 		ListSchedules(auth)
 	}
 
+	if *optListLocks {
+		ListWorkflowLocks(auth)
+	}
+
+	if *optListQueue {
+		ListWorkflowQueue(auth)
+	}
+
+	if *optAuditLog {
+		GetAuditLog(auth, string(*optProfile), string(*optConfig))
+	}
+
 	if *optGetDefaultPluginConfig {
 		if getopt.IsSet("plugin") != true {
 			fmt.Println("[ERROR] Missing parameter --plugin")
@@ -216,11 +241,28 @@ func main() {
 		DeleteProfile(auth, string(*optProfile))
 	}
 
+	if *optAction == "addProfileNotifiers" {
+		if getopt.IsSet("config-file") != true {
+			fmt.Println("[ERROR] Missing parameter --config-file")
+			os.Exit(1)
+		}
+
+		AddProfileNotifiers(auth, string(*optProfile), string(*optConfigFile))
+	}
+
+	if *optAction == "getProfileNotifiers" {
+		GetProfileNotifiers(auth, string(*optProfile))
+	}
+
 	if getopt.IsSet("config") != true {
 		fmt.Println("[ERROR] missing parameter --config")
 		os.Exit(1)
 	}
 
+	if *optAction == "releaseLock" {
+		ReleaseWorkflowLock(auth, string(*optProfile), string(*optConfig))
+	}
+
 	if *optAction == "listPluginConfigs" {
 		ListPluginConfigs(auth, string(*optProfile), string(*optConfig))
 	}
@@ -298,7 +340,7 @@ func main() {
 	}
 
 	// Check retention policy
-	if *optAction == "backup" || *optAction == "backupList" || *optAction == "restore" || *optAction == "archiveList" {
+	if *optAction == "backup" || *optAction == "backupList" || *optAction == "backupBrowse" || *optAction == "backupVerify" || *optAction == "restore" || *optAction == "verify" || *optAction == "archiveList" {
 		if getopt.IsSet("policy") != true {
 			fmt.Println("[ERROR] missing parameter --policy")
 			os.Exit(1)
@@ -311,11 +353,17 @@ func main() {
 
 	fmt.Println("########## Welcome to Fossul Framework ##########")
 
-	if *optAction == "backup" {
+	if *optAction == "backup" && *optDryRun {
+		if *optLocalConfig {
+			PlanBackupWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), config)
+		} else {
+			PlanBackup(auth, string(*optProfile), string(*optConfig), string(*optPolicy))
+		}
+	} else if *optAction == "backup" {
 		if *optLocalConfig {
-			BackupWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), config)
+			BackupWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), config, *optFollow)
 		} else {
-			Backup(auth, string(*optProfile), string(*optConfig), string(*optPolicy))
+			Backup(auth, string(*optProfile), string(*optConfig), string(*optPolicy), *optFollow)
 		}
 	} else if *optAction == "restore" {
 		if getopt.IsSet("workflow-id") != true {
@@ -323,13 +371,52 @@ func main() {
 			os.Exit(1)
 		}
 
-		if *optLocalConfig {
-			RestoreWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), config)
+		var restoreRequest util.RestoreRequest
+		restoreRequest.Namespace = *optTargetNamespace
+		restoreRequest.ServiceName = *optTargetService
+		restoreRequest.ContainerName = *optTargetContainer
+		restoreRequest.RestoreDestPath = *optTargetPath
+		restoreRequest.Database = *optTargetDatabase
+		if *optRestorePaths != "" {
+			restoreRequest.RestorePaths = strings.Split(*optRestorePaths, ",")
+		}
+
+		if util.IsRestoreTargetSet(restoreRequest.RestoreTarget) || len(restoreRequest.RestorePaths) > 0 {
+			if *optDryRun && !*optLocalConfig {
+				fmt.Println("[ERROR] Restore target or paths with --dry-run requires --local")
+				os.Exit(1)
+			}
+			config.RestoreTarget = restoreRequest.RestoreTarget
+			config.RestorePaths = restoreRequest.RestorePaths
+		}
+
+		if *optDryRun && *optLocalConfig {
+			PlanRestoreWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), config)
+		} else if *optDryRun {
+			PlanRestore(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId))
+		} else if *optLocalConfig {
+			RestoreWithLocalConfig(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), config, *optFollow)
 		} else {
-			Restore(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId))
+			Restore(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), restoreRequest, *optFollow)
 		}
+	} else if *optAction == "verify" {
+		Verify(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), *optFollow)
 	} else if *optAction == "backupList" {
 		BackupList(auth, string(*optProfile), string(*optConfig), string(*optPolicy), config)
+	} else if *optAction == "backupBrowse" {
+		if getopt.IsSet("workflow-id") != true {
+			fmt.Println("[ERROR] Missing parameter --workflow-id")
+			os.Exit(1)
+		}
+
+		BackupBrowse(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), config)
+	} else if *optAction == "backupVerify" {
+		if getopt.IsSet("workflow-id") != true {
+			fmt.Println("[ERROR] Missing parameter --workflow-id")
+			os.Exit(1)
+		}
+
+		BackupVerify(auth, string(*optProfile), string(*optConfig), string(*optPolicy), string(*optWorkflowId), config)
 	} else if *optAction == "archiveList" {
 		ArchiveList(auth, string(*optProfile), string(*optConfig), string(*optPolicy), config)
 	} else if *optAction == "jobList" {
@@ -340,7 +427,7 @@ func main() {
 			os.Exit(1)
 		}
 
-		JobStatus(auth, *optProfile, *optConfig, *optWorkflowId)
+		JobStatus(auth, *optProfile, *optConfig, *optWorkflowId, *optFollow)
 	} else if *optAction == "addSchedule" {
 		if getopt.IsSet("policy") != true {
 			fmt.Println("[ERROR] missing parameter --policy")
@@ -352,9 +439,16 @@ func main() {
 			os.Exit(1)
 		}
 
-		AddSchedule(auth, *optProfile, *optConfig, *optPolicy, *optCronSchedule)
+		AddSchedule(auth, *optProfile, *optConfig, *optPolicy, *optWorkflowType, *optCronSchedule)
 	} else if *optAction == "deleteSchedule" {
-		DeleteSchedule(auth, *optProfile, *optConfig, *optPolicy)
+		DeleteSchedule(auth, *optProfile, *optConfig, *optPolicy, *optWorkflowType)
+	} else if *optAction == "cancel" {
+		if getopt.IsSet("workflow-id") != true {
+			fmt.Println("[ERROR] Missing parameter --workflow-id")
+			os.Exit(1)
+		}
+
+		CancelWorkflow(auth, *optProfile, *optConfig, *optWorkflowId)
 	} else {
 		fmt.Println("[ERROR] incorrect parameter", *optAction)
 		os.Exit(1)
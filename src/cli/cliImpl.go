@@ -6,6 +6,7 @@ import (
 	"fossul/src/engine/client"
 	"fossul/src/engine/util"
 	"os"
+	"strings"
 	"text/tabwriter"
 	"time"
 )
@@ -70,9 +71,55 @@ func ListSchedules(auth client.Auth) {
 	// print friendly columns
 	tw := new(tabwriter.Writer)
 	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
-	fmt.Fprintln(tw, "CronSchedule\t ProfileName\t ConfigName\t Policy\t")
+	fmt.Fprintln(tw, "CronSchedule\t ProfileName\t ConfigName\t Policy\t Type\t")
 	for _, schedule := range jobScheduleResult.JobSchedules {
-		fmt.Fprintln(tw, schedule.CronSchedule+"\t", schedule.ProfileName+"\t", schedule.ConfigName+"\t", schedule.BackupPolicy+"\t")
+		fmt.Fprintln(tw, schedule.CronSchedule+"\t", schedule.ProfileName+"\t", schedule.ConfigName+"\t", schedule.BackupPolicy+"\t", util.GetScheduleWorkflowType(schedule.WorkflowType)+"\t")
+	}
+	tw.Flush()
+
+	os.Exit(0)
+}
+
+func ListWorkflowLocks(auth client.Auth) {
+	fmt.Println("### Workflow Locks ###")
+	workflowLockResult, err := client.ListWorkflowLocks(auth)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	checkResult(workflowLockResult.Result)
+
+	// print friendly columns
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
+	fmt.Fprintln(tw, "ProfileName\t ConfigName\t Policy\t WorkflowId\t Timestamp\t")
+	for _, lock := range workflowLockResult.Locks {
+		fmt.Fprintln(tw, lock.ProfileName+"\t", lock.ConfigName+"\t", lock.Policy+"\t", util.Int64ToString(lock.WorkflowId)+"\t", time.Unix(lock.Timestamp, 0).Format(time.RFC3339)+"\t")
+	}
+	tw.Flush()
+
+	os.Exit(0)
+}
+
+func ListWorkflowQueue(auth client.Auth) {
+	fmt.Println("### Workflow Queue ###")
+	workflowQueueResult, err := client.ListWorkflowQueue(auth)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	checkResult(workflowQueueResult.Result)
+
+	// print friendly columns
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
+	fmt.Fprintln(tw, "Position\t WorkflowId\t Status\t Type\t ProfileName\t ConfigName\t Policy\t StorageHost\t Timestamp\t")
+	for _, workflow := range workflowQueueResult.Workflows {
+		var position string
+		if workflow.Status == "QUEUED" {
+			position = util.IntToString(workflow.Position)
+		}
+		fmt.Fprintln(tw, position+"\t", util.Int64ToString(workflow.WorkflowId)+"\t", workflow.Status+"\t", workflow.Type+"\t", workflow.ProfileName+"\t", workflow.ConfigName+"\t", workflow.Policy+"\t", workflow.StorageHost+"\t", time.Unix(workflow.Timestamp, 0).Format(time.RFC3339)+"\t")
 	}
 	tw.Flush()
 
@@ -177,6 +224,70 @@ func DeleteProfile(auth client.Auth, profileName string) {
 	os.Exit(0)
 }
 
+func AddProfileNotifiers(auth client.Auth, profileName, configFile string) {
+	profileNotifiers, err := util.ReadProfileNotifiers(configFile)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	result, err := client.AddProfileNotifiers(auth, profileName, profileNotifiers)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	printResult(result)
+	os.Exit(0)
+}
+
+func GetProfileNotifiers(auth client.Auth, profileName string) {
+	fmt.Println("### Profile Notifiers ###")
+	profileNotifiersResult, err := client.GetProfileNotifiers(auth, profileName)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	checkResult(profileNotifiersResult.Result)
+
+	for _, notifier := range profileNotifiersResult.Notifiers {
+		events := notifier.Events
+		if len(events) == 0 {
+			events = []string{"failure"}
+		}
+		fmt.Println(notifier.Name + " " + notifier.Type + " [" + strings.Join(events, ",") + "]")
+	}
+	os.Exit(0)
+}
+
+func GetAuditLog(auth client.Auth, profileName, configName string) {
+	fmt.Println("### Audit Log ###")
+	auditLogResult, err := client.GetAuditLog(auth, profileName, configName)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	checkResult(auditLogResult.Result)
+
+	for _, entry := range auditLogResult.Entries {
+		target := entry.ProfileName
+		for _, name := range []string{entry.ConfigName, entry.PluginName, entry.Policy} {
+			if name != "" {
+				target = target + "/" + name
+			}
+		}
+
+		status := "OK"
+		if entry.ResultCode != 0 {
+			status = "FAILED"
+		}
+
+		fmt.Println(time.Unix(entry.Timestamp, 0).Format(time.RFC3339) + " " + entry.User + " " + entry.Action + " [" + target + "] " + status)
+		for _, line := range entry.Diff {
+			fmt.Println("    " + line)
+		}
+	}
+	os.Exit(0)
+}
+
 func ListPluginConfigs(auth client.Auth, profileName, configName string) {
 	fmt.Println("### Config List ###")
 	result, err := client.ListPluginConfigs(auth, profileName, configName)
@@ -327,7 +438,115 @@ func ImportServerConfig(auth client.Auth, profileName, configName string) (util.
 	return config, nil
 }
 
-func BackupWithLocalConfig(auth client.Auth, profileName, configName, policyName string, config util.Config) {
+func PlanBackup(auth client.Auth, profileName, configName, policyName string) {
+	workflowPlanResult, err := client.PlanBackupWorkflow(auth, profileName, configName, policyName)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	printWorkflowPlan(workflowPlanResult)
+}
+
+func PlanBackupWithLocalConfig(auth client.Auth, profileName, configName, policyName string, config util.Config) {
+	workflowPlanResult, err := client.PlanBackupWorkflowLocalConfig(auth, profileName, configName, policyName, config)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	printWorkflowPlan(workflowPlanResult)
+}
+
+func PlanRestore(auth client.Auth, profileName, configName, policyName, workflowId string) {
+	workflowPlanResult, err := client.PlanRestoreWorkflow(auth, profileName, configName, policyName, workflowId)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	printWorkflowPlan(workflowPlanResult)
+}
+
+func PlanRestoreWithLocalConfig(auth client.Auth, profileName, configName, policyName, workflowId string, config util.Config) {
+	workflowPlanResult, err := client.PlanRestoreWorkflowLocalConfig(auth, profileName, configName, policyName, workflowId, config)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	printWorkflowPlan(workflowPlanResult)
+}
+
+func printWorkflowPlan(workflowPlanResult util.WorkflowPlanResult) {
+	logger := util.GetLoggerInstance()
+	util.LogResult(logger, workflowPlanResult.Result)
+	if workflowPlanResult.Result.Code != 0 {
+		os.Exit(1)
+	}
+
+	plan := workflowPlanResult.Plan
+
+	fmt.Println("### Resolved Config ###")
+	buf, err := util.EncodeConfig(plan.Config)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	fmt.Println(buf.String())
+
+	fmt.Println("### Planned " + plan.Type + " Workflow Steps ###")
+
+	// print friendly columns
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
+	fmt.Fprintln(tw, "Step\t Kind\t Plugin\t Host\t Command\t Timeout\t Retries\t OnFailure\t")
+	for i, step := range plan.Steps {
+		if step.Kind == "comment" {
+			fmt.Fprintln(tw, util.IntToString(i)+"\t", step.Kind+"\t\t\t", step.Comment+"\t\t\t\t")
+			continue
+		}
+
+		kind := step.Kind
+		if step.Skipped {
+			kind = kind + " (skipped)"
+		}
+
+		command := step.Hook
+		if len(step.Command) > 0 {
+			command = strings.TrimSpace(command + " " + fmt.Sprintf("%q", step.Command))
+		}
+		if len(step.Notifiers) > 0 {
+			command = strings.TrimSpace(command + " notifiers " + fmt.Sprintf("%q", step.Notifiers))
+		}
+
+		var timeout string
+		if step.StepPolicy.Timeout > 0 {
+			timeout = util.IntToString(step.StepPolicy.Timeout) + "s"
+		}
+		fmt.Fprintln(tw, util.IntToString(i)+"\t", kind+"\t", step.Plugin+"\t", step.Host+"\t", command+"\t", timeout+"\t", util.IntToString(step.StepPolicy.Retries)+"\t", step.OnFailure+"\t")
+	}
+	tw.Flush()
+
+	if plan.RestoreBackup != nil {
+		fmt.Println("### Backup To Restore ###")
+		fmt.Println(plan.RestoreBackup.Name + "_" + plan.RestoreBackup.Policy + "_" + plan.RestoreBackup.WorkflowId + "_" + util.IntToString(plan.RestoreBackup.Epoch) + " " + plan.RestoreBackup.Timestamp)
+	}
+
+	fmt.Println("### Backups Deleted By Retention ###")
+	for _, backup := range plan.ExpiredBackups {
+		fmt.Println(backup.Name + "_" + backup.Policy + "_" + backup.WorkflowId + "_" + util.IntToString(backup.Epoch) + " " + backup.Timestamp)
+	}
+
+	fmt.Println("### Archives Deleted By Retention ###")
+	for _, archive := range plan.ExpiredArchives {
+		fmt.Println(archive.Name + "_" + archive.Policy + "_" + archive.WorkflowId + "_" + util.IntToString(archive.Epoch) + " " + archive.Timestamp)
+	}
+
+	os.Exit(0)
+}
+
+func BackupWithLocalConfig(auth client.Auth, profileName, configName, policyName string, config util.Config, follow bool) {
 	logger := util.GetLoggerInstance()
 
 	workflowResult, err := client.StartBackupWorkflowLocalConfig(auth, profileName, configName, policyName, config)
@@ -342,6 +561,10 @@ func BackupWithLocalConfig(auth client.Auth, profileName, configName, policyName
 	}
 
 	workflowId := workflowResult.Id
+	if follow {
+		FollowWorkflow(auth, profileName, configName, util.Int64ToString(workflowId))
+	}
+
 	var completedSteps []int
 	// loop and wait for all workflow steps to complete
 	for {
@@ -356,7 +579,7 @@ func BackupWithLocalConfig(auth client.Auth, profileName, configName, policyName
 
 		// Print results for a step only once
 		for _, step := range workflowStatusResult.Workflow.Steps {
-			if step.Status == "COMPLETE" || step.Status == "ERROR" {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
 				if !util.IntInSlice(step.Id, completedSteps) {
 					completedSteps = append(completedSteps, step.Id)
 					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowId, step.Id)
@@ -369,14 +592,14 @@ func BackupWithLocalConfig(auth client.Auth, profileName, configName, policyName
 			}
 		}
 
-		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" {
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
 			break
 		}
 		time.Sleep(4 * time.Second)
 	}
 }
 
-func Backup(auth client.Auth, profileName, configName, policyName string) {
+func Backup(auth client.Auth, profileName, configName, policyName string, follow bool) {
 	logger := util.GetLoggerInstance()
 
 	workflowResult, err := client.StartBackupWorkflow(auth, profileName, configName, policyName)
@@ -391,6 +614,10 @@ func Backup(auth client.Auth, profileName, configName, policyName string) {
 	}
 
 	workflowId := workflowResult.Id
+	if follow {
+		FollowWorkflow(auth, profileName, configName, util.Int64ToString(workflowId))
+	}
+
 	var completedSteps []int
 	// loop and wait for all workflow steps to complete
 	for {
@@ -405,7 +632,7 @@ func Backup(auth client.Auth, profileName, configName, policyName string) {
 
 		// Print results for a step only once
 		for _, step := range workflowStatusResult.Workflow.Steps {
-			if step.Status == "COMPLETE" || step.Status == "ERROR" {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
 				if !util.IntInSlice(step.Id, completedSteps) {
 					completedSteps = append(completedSteps, step.Id)
 					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowId, step.Id)
@@ -418,14 +645,14 @@ func Backup(auth client.Auth, profileName, configName, policyName string) {
 			}
 		}
 
-		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" {
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
 			break
 		}
 		time.Sleep(4 * time.Second)
 	}
 }
 
-func RestoreWithLocalConfig(auth client.Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config) {
+func RestoreWithLocalConfig(auth client.Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config, follow bool) {
 	logger := util.GetLoggerInstance()
 
 	workflowResult, err := client.StartRestoreWorkflowLocalConfig(auth, profileName, configName, policyName, selectedWorkflowId, config)
@@ -440,6 +667,10 @@ func RestoreWithLocalConfig(auth client.Auth, profileName, configName, policyNam
 	}
 
 	workflowId := workflowResult.Id
+	if follow {
+		FollowWorkflow(auth, profileName, configName, util.Int64ToString(workflowId))
+	}
+
 	var completedSteps []int
 	// loop and wait for all workflow steps to complete
 	for {
@@ -454,7 +685,7 @@ func RestoreWithLocalConfig(auth client.Auth, profileName, configName, policyNam
 
 		// Print results for a step only once
 		for _, step := range workflowStatusResult.Workflow.Steps {
-			if step.Status == "COMPLETE" || step.Status == "ERROR" {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
 				if !util.IntInSlice(step.Id, completedSteps) {
 					completedSteps = append(completedSteps, step.Id)
 					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowId, step.Id)
@@ -467,17 +698,17 @@ func RestoreWithLocalConfig(auth client.Auth, profileName, configName, policyNam
 			}
 		}
 
-		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" {
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
 			break
 		}
 		time.Sleep(4 * time.Second)
 	}
 }
 
-func Restore(auth client.Auth, profileName, configName, policyName, selectedWorkflowId string) {
+func Restore(auth client.Auth, profileName, configName, policyName, selectedWorkflowId string, restoreRequest util.RestoreRequest, follow bool) {
 	logger := util.GetLoggerInstance()
 
-	workflowResult, err := client.StartRestoreWorkflow(auth, profileName, configName, policyName, selectedWorkflowId)
+	workflowResult, err := client.StartRestoreWorkflow(auth, profileName, configName, policyName, selectedWorkflowId, restoreRequest)
 	if err != nil {
 		fmt.Println("[ERROR] " + err.Error())
 		os.Exit(1)
@@ -489,6 +720,10 @@ func Restore(auth client.Auth, profileName, configName, policyName, selectedWork
 	}
 
 	workflowId := workflowResult.Id
+	if follow {
+		FollowWorkflow(auth, profileName, configName, util.Int64ToString(workflowId))
+	}
+
 	var completedSteps []int
 	// loop and wait for all workflow steps to complete
 	for {
@@ -503,7 +738,7 @@ func Restore(auth client.Auth, profileName, configName, policyName, selectedWork
 
 		// Print results for a step only once
 		for _, step := range workflowStatusResult.Workflow.Steps {
-			if step.Status == "COMPLETE" || step.Status == "ERROR" {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
 				if !util.IntInSlice(step.Id, completedSteps) {
 					completedSteps = append(completedSteps, step.Id)
 					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowId, step.Id)
@@ -516,7 +751,64 @@ func Restore(auth client.Auth, profileName, configName, policyName, selectedWork
 			}
 		}
 
-		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" {
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
+			break
+		}
+		time.Sleep(4 * time.Second)
+	}
+}
+
+func Verify(auth client.Auth, profileName, configName, policyName, selectedWorkflowId string, follow bool) {
+	logger := util.GetLoggerInstance()
+
+	workflowResult, err := client.StartVerifyWorkflow(auth, profileName, configName, policyName, selectedWorkflowId)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	util.LogResult(logger, workflowResult.Result)
+	if workflowResult.Result.Code != 0 {
+		os.Exit(1)
+	}
+
+	workflowId := workflowResult.Id
+	if follow {
+		FollowWorkflow(auth, profileName, configName, util.Int64ToString(workflowId))
+	}
+
+	var completedSteps []int
+	// loop and wait for all workflow steps to complete
+	for {
+		time.Sleep(1 * time.Second)
+		workflowStatusResult, err := client.GetWorkflowStatus(auth, profileName, configName, workflowId)
+		if err != nil {
+			fmt.Println("[ERROR] " + err.Error())
+			os.Exit(1)
+		}
+
+		checkResult(workflowStatusResult.Result)
+
+		// Print results for a step only once
+		for _, step := range workflowStatusResult.Workflow.Steps {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
+				if !util.IntInSlice(step.Id, completedSteps) {
+					completedSteps = append(completedSteps, step.Id)
+					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowId, step.Id)
+					if err != nil {
+						fmt.Println("[ERROR] " + err.Error())
+						os.Exit(1)
+					}
+					util.LogResults(logger, results)
+				}
+			}
+		}
+
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
+			fmt.Println("Verify Result: " + workflowStatusResult.Workflow.VerifyResult)
+			if workflowStatusResult.Workflow.VerifyResult != "PASSED" {
+				os.Exit(1)
+			}
 			break
 		}
 		time.Sleep(4 * time.Second)
@@ -541,6 +833,48 @@ func BackupList(auth client.Auth, profileName, configName, policyName string, co
 	}
 }
 
+func BackupBrowse(auth client.Auth, profileName, configName, policyName, workflowId string, config util.Config) {
+	msg := fmt.Sprintf("### Contents of Backup [%s] for policy [%s] ###", workflowId, policyName)
+	fmt.Println(msg)
+
+	backupFiles, err := client.BackupBrowse(auth, profileName, configName, policyName, workflowId, config)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	checkResult(backupFiles.Result)
+
+	// print friendly columns
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
+	fmt.Fprintln(tw, "Path\t Size\t Modified\t")
+	for _, file := range backupFiles.Files {
+		path := file.Path
+		if file.IsDir {
+			path = path + "/"
+		}
+		fmt.Fprintln(tw, path+"\t", util.Int64ToString(file.Size)+"\t", time.Unix(file.ModTime, 0).Format(time.RFC3339)+"\t")
+	}
+	tw.Flush()
+}
+
+func BackupVerify(auth client.Auth, profileName, configName, policyName, workflowId string, config util.Config) {
+	msg := fmt.Sprintf("### Verify Backup [%s] for policy [%s] ###", workflowId, policyName)
+	fmt.Println(msg)
+
+	backupVerifyResult, err := client.BackupVerify(auth, profileName, configName, policyName, workflowId, config)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	logger := util.GetLoggerInstance()
+	util.LogResult(logger, backupVerifyResult.Result)
+	if !backupVerifyResult.Verification.IsValid() {
+		os.Exit(1)
+	}
+}
+
 func ArchiveList(auth client.Auth, profileName, configName, policyName string, config util.Config) {
 	msg := fmt.Sprintf("### List of Archives for policy [%s] ###", policyName)
 	fmt.Println(msg)
@@ -573,17 +907,22 @@ func JobList(auth client.Auth, profileName, configName string) {
 	// print friendly columns
 	tw := new(tabwriter.Writer)
 	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
-	fmt.Fprintln(tw, "WorkflowId\t Type\t Status\t Policy\t Start Time\t")
+	fmt.Fprintln(tw, "WorkflowId\t Type\t Status\t Policy\t Start Time\t Duration\t Verify Result\t")
 	for _, job := range jobs.Jobs {
-		fmt.Fprintln(tw, util.IntToString(job.Id)+"\t", job.Type+"\t", job.Status+"\t", job.Policy+"\t", job.Timestamp+"\t")
+		fmt.Fprintln(tw, util.Int64ToString(job.Id)+"\t", job.Type+"\t", job.Status+"\t", job.Policy+"\t", job.Timestamp+"\t", getDuration(job.EndTime, job.Duration)+"\t", job.VerifyResult+"\t")
 	}
 	tw.Flush()
 }
 
-func JobStatus(auth client.Auth, profileName, configName, workflowId string) {
+func JobStatus(auth client.Auth, profileName, configName, workflowId string, follow bool) {
 	logger := util.GetLoggerInstance()
 
-	workflowIdInt := util.StringToInt(workflowId)
+	if follow {
+		FollowWorkflow(auth, profileName, configName, workflowId)
+	}
+
+	workflowIdInt := util.StringToInt64(workflowId)
+	var workflow util.Workflow
 	var completedSteps []int
 	// loop and wait for all workflow steps to complete
 	for {
@@ -595,10 +934,11 @@ func JobStatus(auth client.Auth, profileName, configName, workflowId string) {
 		}
 
 		checkResult(workflowStatusResult.Result)
+		workflow = workflowStatusResult.Workflow
 
 		// Print results for a step only once
 		for _, step := range workflowStatusResult.Workflow.Steps {
-			if step.Status == "COMPLETE" || step.Status == "ERROR" {
+			if step.Status == "COMPLETE" || step.Status == "ERROR" || step.Status == "CANCELLED" {
 				if !util.IntInSlice(step.Id, completedSteps) {
 					completedSteps = append(completedSteps, step.Id)
 					results, err := client.GetWorkflowStepResults(auth, profileName, configName, workflowIdInt, step.Id)
@@ -612,11 +952,83 @@ func JobStatus(auth client.Auth, profileName, configName, workflowId string) {
 			}
 		}
 
-		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" {
+		if workflowStatusResult.Workflow.Status == "COMPLETE" || workflowStatusResult.Workflow.Status == "ERROR" || workflowStatusResult.Workflow.Status == "CANCELLED" || workflowStatusResult.Workflow.Status == "ABORTED" {
 			break
 		}
 		time.Sleep(4 * time.Second)
 	}
+
+	printWorkflowSteps(workflow)
+}
+
+// printWorkflowSteps prints the kind, plugin, host and timing of every step that isn't a comment
+func printWorkflowSteps(workflow util.Workflow) {
+	fmt.Println("### Workflow Steps ###")
+
+	// print friendly columns
+	tw := new(tabwriter.Writer)
+	tw.Init(os.Stdout, 10, 20, 5, ' ', 0)
+	fmt.Fprintln(tw, "Step\t Kind\t Plugin\t Host\t Status\t Start Time\t Duration\t")
+	for _, step := range workflow.Steps {
+		if step.Kind == "comment" {
+			continue
+		}
+
+		var startTime string
+		if step.StartTime != 0 {
+			startTime = time.Unix(step.StartTime, 0).Format(time.RFC3339)
+		}
+		fmt.Fprintln(tw, util.IntToString(step.Id)+"\t", step.Kind+"\t", step.Plugin+"\t", step.Host+"\t", step.Status+"\t", startTime+"\t", getDuration(step.EndTime, step.Duration)+"\t")
+	}
+	fmt.Fprintln(tw, "Total\t\t\t\t", workflow.Status+"\t", time.Unix(workflow.StartTime, 0).Format(time.RFC3339)+"\t", getDuration(workflow.EndTime, workflow.Duration)+"\t")
+	tw.Flush()
+}
+
+// getDuration formats a duration in seconds, nothing is shown until there is an end time
+func getDuration(endTime, duration int64) string {
+	if endTime == 0 {
+		return ""
+	}
+
+	return (time.Duration(duration) * time.Second).String()
+}
+
+// FollowWorkflow streams workflow events until the workflow ends, exits 0 only if the workflow completed
+func FollowWorkflow(auth client.Auth, profileName, configName, workflowId string) {
+	logger := util.GetLoggerInstance()
+
+	var status string
+	err := client.StreamWorkflow(auth, profileName, configName, workflowId, func(event util.WorkflowEvent) error {
+		switch event.Type {
+		case "message":
+			if event.Message != nil {
+				var result util.Result
+				result.Messages = append(result.Messages, *event.Message)
+				util.LogResult(logger, result)
+			}
+		case "workflow":
+			status = event.Status
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	if !util.IsWorkflowStatusTerminal(status) {
+		fmt.Println("[ERROR] Stream for workflow id [" + workflowId + "] ended before workflow completed")
+		os.Exit(1)
+	}
+
+	if status != "COMPLETE" {
+		fmt.Println("[ERROR] Workflow id [" + workflowId + "] ended with status [" + status + "]")
+		os.Exit(1)
+	}
+
+	os.Exit(0)
 }
 
 func AppPluginList(auth client.Auth) {
@@ -673,7 +1085,7 @@ func ArchivePluginList(auth client.Auth) {
 	os.Exit(0)
 }
 
-//func PluginInfo(auth client.Auth,config util.Config,pluginName,pluginType string) {
+// func PluginInfo(auth client.Auth,config util.Config,pluginName,pluginType string) {
 func PluginInfo(auth client.Auth, pluginName, pluginType string) {
 	var config util.Config
 	var pluginInfoResult util.PluginInfoResult
@@ -742,8 +1154,28 @@ func Status(auth client.Auth) {
 	os.Exit(0)
 }
 
-func AddSchedule(auth client.Auth, profileName, configName, policyName, cronSchedule string) {
-	result, err := client.AddSchedule(auth, profileName, configName, policyName, cronSchedule)
+func AddSchedule(auth client.Auth, profileName, configName, policyName, workflowType, cronSchedule string) {
+	result, err := client.AddSchedule(auth, profileName, configName, policyName, workflowType, cronSchedule)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	printResult(result)
+	os.Exit(0)
+}
+
+func DeleteSchedule(auth client.Auth, profileName, configName, policyName, workflowType string) {
+	result, err := client.DeleteSchedule(auth, profileName, configName, policyName, workflowType)
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+	printResult(result)
+	os.Exit(0)
+}
+
+func CancelWorkflow(auth client.Auth, profileName, configName, workflowId string) {
+	result, err := client.CancelWorkflow(auth, profileName, configName, workflowId)
 	if err != nil {
 		fmt.Println("[ERROR] " + err.Error())
 		os.Exit(1)
@@ -752,8 +1184,8 @@ func AddSchedule(auth client.Auth, profileName, configName, policyName, cronSche
 	os.Exit(0)
 }
 
-func DeleteSchedule(auth client.Auth, profileName, configName, policyName string) {
-	result, err := client.DeleteSchedule(auth, profileName, configName, policyName)
+func ReleaseWorkflowLock(auth client.Auth, profileName, configName string) {
+	result, err := client.ReleaseWorkflowLock(auth, profileName, configName)
 	if err != nil {
 		fmt.Println("[ERROR] " + err.Error())
 		os.Exit(1)
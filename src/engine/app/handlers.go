@@ -14,10 +14,10 @@ package main
 
 import (
 	"encoding/json"
+	"fossul/src/engine/client/k8s"
 	"fossul/src/engine/util"
 	"github.com/gorilla/mux"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -62,7 +62,7 @@ func PluginList(w http.ResponseWriter, r *http.Request) {
 	if pluginType == "app" {
 		appPluginDir = pluginDir + "/app"
 	} else {
-		log.Println("ERROR plugin type " + pluginType + " must be app")
+		util.RequestLogger(r).Error("Plugin type [" + pluginType + "] must be app")
 
 		_ = json.NewDecoder(r.Body).Decode(&plugins)
 		json.NewEncoder(w).Encode(plugins)
@@ -70,7 +70,7 @@ func PluginList(w http.ResponseWriter, r *http.Request) {
 
 	fileInfo, err := ioutil.ReadDir(appPluginDir)
 	if err != nil {
-		log.Println(err)
+		util.RequestLogger(r).Error(err.Error())
 	}
 
 	for _, file := range fileInfo {
@@ -107,7 +107,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -138,7 +138,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var resultSimple util.ResultSimple
-		resultSimple = util.ExecutePluginSimple(config, pluginType, plugin, "--info")
+		resultSimple = util.ExecutePluginSimple(r.Context(), config, pluginType, plugin, "--info")
 		if resultSimple.Code != 0 {
 			msg := util.SetMessage("ERROR", "Plugin Info failed!")
 			messages = append(messages, msg)
@@ -158,7 +158,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(pluginInfoResult)
 		}
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 
 		if err != nil {
 			msg := util.SetMessage("ERROR", err.Error())
@@ -179,3 +179,72 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// WorkflowCmd godoc
+// @Description Custom workflow step command
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /workflowCmd [post]
+func WorkflowCmd(w http.ResponseWriter, r *http.Request) {
+	var result util.Result
+	var messages []util.Message
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(r, config)
+
+	if err != nil {
+		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
+		messages = append(messages, message)
+
+		result = util.SetResult(1, messages)
+
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+
+		return
+	}
+
+	if config.WorkflowCmd != "" {
+		args := strings.Split(config.WorkflowCmd, ",")
+
+		if k8s.IsRemoteCommand(args[0]) {
+			args[0] = strings.Replace(args[0], ":", "", 1)
+			podName, err := k8s.GetPod(config.AppPluginParameters["Namespace"], config.AppPluginParameters["ServiceName"], config.AppPluginParameters["AccessWithinCluster"])
+			if err != nil {
+				msg := util.SetMessage("ERROR", err.Error())
+				messages = append(messages, msg)
+
+				result = util.SetResult(1, messages)
+				_ = json.NewDecoder(r.Body).Decode(&result)
+				json.NewEncoder(w).Encode(result)
+
+				return
+			}
+
+			message := util.SetMessage("INFO", "Performing remote workflow command ["+config.WorkflowCmd+"] on pod ["+podName+"]")
+			messages = append(messages, message)
+
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+
+			messages = util.PrependMessages(messages, cmdResult.Messages)
+			result = util.SetResult(cmdResult.Code, messages)
+
+			_ = json.NewDecoder(r.Body).Decode(&result)
+			json.NewEncoder(w).Encode(result)
+		} else {
+			message := util.SetMessage("INFO", "Performing workflow command ["+config.WorkflowCmd+"]")
+
+			result = util.ExecuteCommandContext(r.Context(), args...)
+			result.Messages = util.PrependMessage(message, result.Messages)
+
+			_ = json.NewDecoder(r.Body).Decode(&result)
+			json.NewEncoder(w).Encode(result)
+		}
+	}
+}
@@ -37,7 +37,7 @@ func Discover(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -69,14 +69,14 @@ func Discover(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewDecoder(r.Body).Decode(&discoverResult)
 			json.NewEncoder(w).Encode(discoverResult)
 		}
-		resultSimple := util.ExecutePluginSimple(config, "app", plugin, "--discover")
+		resultSimple := util.ExecutePluginSimple(r.Context(), config, "app", plugin, "--discover")
 		discoverResultString := strings.Join(resultSimple.Messages, " ")
 		json.Unmarshal([]byte(discoverResultString), &discoverResult)
 
 		_ = json.NewDecoder(r.Body).Decode(&discoverResult)
 		json.NewEncoder(w).Encode(discoverResult)
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
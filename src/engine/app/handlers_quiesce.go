@@ -37,7 +37,7 @@ func PreQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -70,7 +70,7 @@ func PreQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote pre quiesce command ["+config.PreAppQuiesceCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -89,7 +89,7 @@ func PreQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing pre quiesce command ["+config.PreAppQuiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
@@ -114,7 +114,7 @@ func QuiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -147,7 +147,7 @@ func QuiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote quiesce command ["+config.AppQuiesceCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -166,7 +166,7 @@ func QuiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing quiesce command ["+config.AppQuiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
@@ -191,7 +191,7 @@ func Quiesce(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -221,11 +221,11 @@ func Quiesce(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(result)
 		}
 
-		result = util.ExecutePlugin(config, "app", plugin, "--quiesce")
+		result = util.ExecutePlugin(r.Context(), config, "app", plugin, "--quiesce")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -266,7 +266,7 @@ func PostQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -299,7 +299,7 @@ func PostQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing post remote quiesce command ["+config.PostAppQuiesceCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -318,7 +318,7 @@ func PostQuiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing post quiesce command ["+config.PostAppQuiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
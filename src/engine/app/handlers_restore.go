@@ -37,7 +37,7 @@ func PreRestore(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -67,11 +67,11 @@ func PreRestore(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(result)
 		}
 
-		result = util.ExecutePlugin(config, "app", plugin, "--preRestore")
+		result = util.ExecutePlugin(r.Context(), config, "app", plugin, "--preRestore")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -112,7 +112,7 @@ func PostRestore(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -142,11 +142,11 @@ func PostRestore(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(result)
 		}
 
-		result = util.ExecutePlugin(config, "app", plugin, "--postRestore")
+		result = util.ExecutePlugin(r.Context(), config, "app", plugin, "--postRestore")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -187,7 +187,7 @@ func PreAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -220,7 +220,7 @@ func PreAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote pre restore app command ["+config.PreAppRestoreCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -239,12 +239,12 @@ func PreAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing pre restore app command ["+config.PreAppRestoreCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
 			json.NewEncoder(w).Encode(result)
-		}		
+		}
 	}
 }
 
@@ -264,7 +264,7 @@ func PostAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -297,7 +297,7 @@ func PostAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote post restore app command ["+config.PostAppRestoreCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -316,12 +316,12 @@ func PostAppRestoreCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing post restore app command ["+config.PostAppRestoreCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
 			json.NewEncoder(w).Encode(result)
-		}		
+		}
 	}
 
 }
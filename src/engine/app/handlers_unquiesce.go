@@ -37,7 +37,7 @@ func UnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -70,7 +70,7 @@ func UnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote unquiesce command ["+config.AppUnquiesceCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -89,7 +89,7 @@ func UnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing unquiesce command ["+config.AppUnquiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
@@ -114,7 +114,7 @@ func PreUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -147,7 +147,7 @@ func PreUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote pre unquiesce command ["+config.PreAppRestoreCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -166,7 +166,7 @@ func PreUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing pre unquiesce command ["+config.PreAppUnquiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
@@ -191,7 +191,7 @@ func Unquiesce(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -223,11 +223,11 @@ func Unquiesce(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		result = util.ExecutePlugin(config, "app", plugin, "--unquiesce")
+		result = util.ExecutePlugin(r.Context(), config, "app", plugin, "--unquiesce")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetAppInterface(pluginPath)
+		plugin, err := util.GetAppInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -268,7 +268,7 @@ func PostUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -301,7 +301,7 @@ func PostUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 			message := util.SetMessage("INFO", "Performing remote post unquiesce command ["+config.PostAppUnquiesceCmd+"] on pod ["+podName+"]")
 			messages = append(messages, message)
 
-			cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+			cmdResult := k8s.ExecuteCommandContext(r.Context(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 			if cmdResult.Code != 0 {
 				messages = util.PrependMessages(messages, cmdResult.Messages)
@@ -320,7 +320,7 @@ func PostUnquiesceCmd(w http.ResponseWriter, r *http.Request) {
 		} else {
 			message := util.SetMessage("INFO", "Performing post unquiesce command ["+config.PostAppUnquiesceCmd+"]")
 
-			result = util.ExecuteCommand(args...)
+			result = util.ExecuteCommandContext(r.Context(), args...)
 			result.Messages = util.PrependMessage(message, result.Messages)
 
 			_ = json.NewDecoder(r.Body).Decode(&result)
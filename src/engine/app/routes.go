@@ -35,6 +35,8 @@ func NewRouter() *mux.Router {
 
 		handler = route.HandlerFunc
 		handler = util.LogApi(handler, route.Name)
+		handler = util.InstrumentApi(handler, "app", route.Name)
+		handler = util.TraceApi(handler, "app", route.Name)
 
 		router.
 			Methods(route.Method).
@@ -53,6 +55,12 @@ var routes = Routes{
 		"/status",
 		GetStatus,
 	},
+	Route{
+		"GetMetrics",
+		"GET",
+		"/metrics",
+		util.MetricsHandler,
+	},
 	Route{
 		"PluginList",
 		"GET",
@@ -143,4 +151,10 @@ var routes = Routes{
 		"/postAppRestoreCmd",
 		PostAppRestoreCmd,
 	},
+	Route{
+		"WorkflowCmd",
+		"POST",
+		"/workflowCmd",
+		WorkflowCmd,
+	},
 }
@@ -13,12 +13,16 @@ limitations under the License.
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fossul/src/engine/util"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 )
 
 type Auth struct {
@@ -30,11 +34,97 @@ type Auth struct {
 	StoragePort     string `json:"storagePort,omitempty"`
 	Username        string `json:"username,omitempty"`
 	Password        string `json:"password,omitempty"`
+	ctx             context.Context
 }
 
-func GetWorkflowStatus(auth Auth, profileName, configName string, id int) (util.WorkflowStatusResult, error) {
+// WithContext returns a copy of auth whose requests are bound to ctx, cancelling ctx aborts any in-flight request
+func (auth Auth) WithContext(ctx context.Context) Auth {
+	auth.ctx = ctx
+	return auth
+}
+
+func (auth Auth) Context() context.Context {
+	if auth.ctx == nil {
+		return context.Background()
+	}
+
+	return auth.ctx
+}
+
+func doRequest(auth Auth, req *http.Request) (*http.Response, error) {
+	client := &http.Client{}
+
+	req = req.WithContext(auth.Context())
+	util.SetWorkflowStepHeaders(req)
+	util.SetTraceHeaders(req)
+
+	return client.Do(req)
+}
+
+// doResultStreamRequest reads the result of a long running call, onMessage is called for every message of the
+// result in order, as it happens if the service streams messages. If the stream breaks, the messages received
+// so far are returned with the error.
+func doResultStreamRequest(auth Auth, req *http.Request, onMessage func(util.Message)) (util.Result, error) {
+	var result util.Result
+	req.Header.Add("Accept", util.MessageStreamContentType)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), util.MessageStreamContentType) {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+
+		for _, message := range result.Messages {
+			onMessage(message)
+		}
+
+		return result, nil
+	}
+
+	var messages []util.Message
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line util.MessageStreamLine
+		if err := decoder.Decode(&line); err != nil {
+			if err == io.EOF {
+				err = errors.New("Message stream ended without a result")
+			}
+
+			result.Messages = messages
+			return result, err
+		}
+
+		if line.Message != nil {
+			messages = append(messages, *line.Message)
+			onMessage(*line.Message)
+		}
+
+		if line.Result != nil {
+			result = *line.Result
+
+			// streamed messages are the first messages of the result
+			for index := len(messages); index < len(result.Messages); index++ {
+				onMessage(result.Messages[index])
+			}
+
+			return result, nil
+		}
+	}
+}
+
+func GetWorkflowStatus(auth Auth, profileName, configName string, id int64) (util.WorkflowStatusResult, error) {
 	var workflowStatusResult util.WorkflowStatusResult
-	idToString := util.IntToString(id)
+	idToString := util.Int64ToString(id)
 	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/getWorkflowStatus/"+profileName+"/"+configName+"/"+idToString, nil)
 	if err != nil {
 		return workflowStatusResult, err
@@ -43,9 +133,7 @@ func GetWorkflowStatus(auth Auth, profileName, configName string, id int) (util.
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return workflowStatusResult, err
 	}
@@ -63,9 +151,9 @@ func GetWorkflowStatus(auth Auth, profileName, configName string, id int) (util.
 	return workflowStatusResult, nil
 }
 
-func GetWorkflowStepResults(auth Auth, profileName, configName string, workflowId int, step int) ([]util.Result, error) {
+func GetWorkflowStepResults(auth Auth, profileName, configName string, workflowId int64, step int) ([]util.Result, error) {
 	var results []util.Result
-	w := util.IntToString(workflowId)
+	w := util.Int64ToString(workflowId)
 	s := util.IntToString(step)
 	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/getWorkflowStepResults/"+profileName+"/"+configName+"/"+w+"/"+s, nil)
 	if err != nil {
@@ -75,9 +163,7 @@ func GetWorkflowStepResults(auth Auth, profileName, configName string, workflowI
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return results, err
 	}
@@ -95,6 +181,94 @@ func GetWorkflowStepResults(auth Auth, profileName, configName string, workflowI
 	return results, nil
 }
 
+// StreamWorkflow follows a workflow, eventHandler is called for every event until the workflow ends. Returning
+// an error from eventHandler stops the stream.
+func StreamWorkflow(auth Auth, profileName, configName string, workflowId string, eventHandler func(util.WorkflowEvent) error) error {
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/streamWorkflow/"+profileName+"/"+configName+"/"+workflowId, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Accept", "text/event-stream")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	// errors are returned as a result instead of a stream
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var result util.Result
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return err
+		}
+
+		for _, message := range result.Messages {
+			if message.Level == "ERROR" {
+				return errors.New(message.Message)
+			}
+		}
+
+		return errors.New("Stream for workflow id [" + workflowId + "] failed")
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event util.WorkflowEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return err
+		}
+
+		if err := eventHandler(event); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func CancelWorkflow(auth Auth, profileName, configName string, workflowId string) (util.Result, error) {
+	var result util.Result
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/cancelWorkflow/"+profileName+"/"+configName+"/"+workflowId, nil)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
 func DeleteWorkflowResults(auth Auth, profileName, configName string, workflowId string) (util.Result, error) {
 	var result util.Result
 
@@ -106,9 +280,7 @@ func DeleteWorkflowResults(auth Auth, profileName, configName string, workflowId
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -136,9 +308,7 @@ func GetServerServiceStatus(auth Auth) (util.Status, error) {
 
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return status, err
 	}
@@ -166,9 +336,7 @@ func GetAppServiceStatus(auth Auth) (util.Status, error) {
 
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return status, err
 	}
@@ -197,9 +365,7 @@ func GetStorageServiceStatus(auth Auth) (util.Status, error) {
 
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return status, err
 	}
@@ -233,9 +399,7 @@ func StartBackupWorkflowLocalConfig(auth Auth, profileName, configName, policyNa
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -265,9 +429,7 @@ func StartBackupWorkflow(auth Auth, profileName, configName, policyName string)
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -286,6 +448,40 @@ func StartBackupWorkflow(auth Auth, profileName, configName, policyName string)
 
 }
 
+func StartVerifyWorkflow(auth Auth, profileName, configName, policyName, selectedWorkflowId string) (util.WorkflowResult, error) {
+	var result util.WorkflowResult
+
+	url := "http://" + auth.ServerHostname + ":" + auth.ServerPort + "/startVerifyWorkflow/" + profileName + "/" + configName + "/" + policyName
+	if selectedWorkflowId != "" {
+		url = url + "/" + selectedWorkflowId
+	}
+
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
 func StartRestoreWorkflowLocalConfig(auth Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config) (util.WorkflowResult, error) {
 	var result util.WorkflowResult
 	config = SetAdditionalConfigParams(profileName, configName, policyName, config)
@@ -301,9 +497,7 @@ func StartRestoreWorkflowLocalConfig(auth Auth, profileName, configName, policyN
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -322,10 +516,13 @@ func StartRestoreWorkflowLocalConfig(auth Auth, profileName, configName, policyN
 
 }
 
-func StartRestoreWorkflow(auth Auth, profileName, configName, policyName, selectedWorkflowId string) (util.WorkflowResult, error) {
+func StartRestoreWorkflow(auth Auth, profileName, configName, policyName, selectedWorkflowId string, restoreRequest util.RestoreRequest) (util.WorkflowResult, error) {
 	var result util.WorkflowResult
 
-	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/startRestoreWorkflow/"+profileName+"/"+configName+"/"+policyName+"/"+selectedWorkflowId, nil)
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(restoreRequest)
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/startRestoreWorkflow/"+profileName+"/"+configName+"/"+policyName+"/"+selectedWorkflowId, b)
 	if err != nil {
 		return result, err
 	}
@@ -333,9 +530,70 @@ func StartRestoreWorkflow(auth Auth, profileName, configName, policyName, select
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+
+}
+
+func PlanBackupWorkflowLocalConfig(auth Auth, profileName, configName, policyName string, config util.Config) (util.WorkflowPlanResult, error) {
+	var result util.WorkflowPlanResult
+	config = SetAdditionalConfigParams(profileName, configName, policyName, config)
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/planBackupWorkflowLocalConfig", b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
+func PlanBackupWorkflow(auth Auth, profileName, configName, policyName string) (util.WorkflowPlanResult, error) {
+	var result util.WorkflowPlanResult
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/planBackupWorkflow/"+profileName+"/"+configName+"/"+policyName, nil)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -351,7 +609,68 @@ func StartRestoreWorkflow(auth Auth, profileName, configName, policyName, select
 	}
 
 	return result, nil
+}
+
+func PlanRestoreWorkflowLocalConfig(auth Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config) (util.WorkflowPlanResult, error) {
+	var result util.WorkflowPlanResult
+	config = SetAdditionalConfigParams(profileName, configName, policyName, config)
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/planRestoreWorkflowLocalConfig/"+selectedWorkflowId, b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
 
+	return result, nil
+}
+
+func PlanRestoreWorkflow(auth Auth, profileName, configName, policyName, selectedWorkflowId string) (util.WorkflowPlanResult, error) {
+	var result util.WorkflowPlanResult
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/planRestoreWorkflow/"+profileName+"/"+configName+"/"+policyName+"/"+selectedWorkflowId, nil)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
 }
 
 func SetAdditionalConfigParams(profileName, configName, policyName string, config util.Config) util.Config {
@@ -379,9 +698,7 @@ func GetJobList(auth Auth, profileName, configName string) (util.Jobs, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return jobs, err
 	}
@@ -398,3 +715,90 @@ func GetJobList(auth Auth, profileName, configName string) (util.Jobs, error) {
 
 	return jobs, nil
 }
+
+func ListWorkflowLocks(auth Auth) (util.WorkflowLockResult, error) {
+	var workflowLockResult util.WorkflowLockResult
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/listWorkflowLocks", nil)
+	if err != nil {
+		return workflowLockResult, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return workflowLockResult, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&workflowLockResult); err != nil {
+			return workflowLockResult, err
+		}
+	} else {
+		return workflowLockResult, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return workflowLockResult, nil
+}
+
+func ListWorkflowQueue(auth Auth) (util.WorkflowQueueResult, error) {
+	var workflowQueueResult util.WorkflowQueueResult
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/listWorkflowQueue", nil)
+	if err != nil {
+		return workflowQueueResult, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return workflowQueueResult, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&workflowQueueResult); err != nil {
+			return workflowQueueResult, err
+		}
+	} else {
+		return workflowQueueResult, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return workflowQueueResult, nil
+}
+
+func ReleaseWorkflowLock(auth Auth, profileName, configName string) (util.Result, error) {
+	var result util.Result
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/releaseWorkflowLock/"+profileName+"/"+configName, nil)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
@@ -32,9 +32,7 @@ func AppPluginList(auth Auth, pluginType string) ([]string, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return plugins, err
 	}
@@ -67,9 +65,7 @@ func AppPluginInfo(auth Auth, config util.Config, pluginName, pluginType string)
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return pluginInfoResult, err
 	}
@@ -101,9 +97,7 @@ func Discover(auth Auth, config util.Config) (util.DiscoverResult, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return discoverResult, err
 	}
@@ -135,9 +129,7 @@ func Quiesce(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -169,9 +161,7 @@ func Unquiesce(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -203,9 +193,7 @@ func PreRestore(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -237,9 +225,7 @@ func PostRestore(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
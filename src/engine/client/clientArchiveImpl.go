@@ -32,9 +32,7 @@ func ArchivePluginList(auth Auth, pluginType string) ([]string, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return plugins, err
 	}
@@ -67,9 +65,7 @@ func ArchivePluginInfo(auth Auth, config util.Config, pluginName, pluginType str
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return pluginInfoResult, err
 	}
@@ -87,9 +83,8 @@ func ArchivePluginInfo(auth Auth, config util.Config, pluginName, pluginType str
 	return pluginInfoResult, nil
 }
 
-func Archive(auth Auth, config util.Config) (util.Result, error) {
-	var result util.Result
-
+// Archive runs the archive plugin, onMessage is called for every message of the result as it happens
+func Archive(auth Auth, config util.Config, onMessage func(util.Message)) (util.Result, error) {
 	b := new(bytes.Buffer)
 	json.NewEncoder(b).Encode(config)
 
@@ -101,24 +96,7 @@ func Archive(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return result, err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return result, err
-		}
-	} else {
-		return result, errors.New("Http Status Error [" + resp.Status + "]")
-	}
-
-	return result, nil
+	return doResultStreamRequest(auth, req, onMessage)
 }
 
 func ArchiveList(auth Auth, profileName, configName, policyName string, config util.Config) (util.Archives, error) {
@@ -136,9 +114,7 @@ func ArchiveList(auth Auth, profileName, configName, policyName string, config u
 		return archives, err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return archives, err
 	}
@@ -170,9 +146,39 @@ func ArchiveDelete(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
+func ArchiveRestore(auth Auth, config util.Config) (util.Result, error) {
+	var result util.Result
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.StorageHostname+":"+auth.StoragePort+"/archiveRestore", b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -34,9 +34,7 @@ func PreQuiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -68,9 +66,7 @@ func QuiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -102,9 +98,7 @@ func PostQuiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -136,9 +130,7 @@ func PreUnquiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -170,9 +162,7 @@ func UnquiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -204,9 +194,7 @@ func BackupCreateCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -238,9 +226,7 @@ func BackupDeleteCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -272,9 +258,7 @@ func PostUnquiesceCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -306,9 +290,7 @@ func SendTrapSuccessCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -340,9 +322,7 @@ func SendTrapErrorCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -374,9 +354,7 @@ func ArchiveCreateCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -408,9 +386,7 @@ func ArchiveDeleteCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -442,9 +418,7 @@ func PreAppRestoreCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -476,9 +450,7 @@ func RestoreCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -510,9 +482,71 @@ func PostAppRestoreCmd(auth Auth, config util.Config) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
+func AppWorkflowCmd(auth Auth, config util.Config) (util.Result, error) {
+	var result util.Result
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.AppHostname+":"+auth.AppPort+"/workflowCmd", b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
+func StorageWorkflowCmd(auth Auth, config util.Config) (util.Result, error) {
+	var result util.Result
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.StorageHostname+":"+auth.StoragePort+"/workflowCmd", b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
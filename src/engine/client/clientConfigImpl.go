@@ -31,9 +31,7 @@ func GetConfig(auth Auth, profileName, configName string) (util.ConfigResult, er
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return configResult, err
 	}
@@ -62,9 +60,7 @@ func GetPluginConfig(auth Auth, profileName, configName, pluginName string) (uti
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return configMapResult, err
 	}
@@ -93,9 +89,7 @@ func GetDefaultConfig(auth Auth) (util.ConfigResult, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return configResult, err
 	}
@@ -124,9 +118,7 @@ func GetDefaultPluginConfig(auth Auth, pluginName string) (util.ConfigMapResult,
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return configMapResult, err
 	}
@@ -158,9 +150,7 @@ func AddConfig(auth Auth, profileName, configName string, config util.Config) (u
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -192,9 +182,7 @@ func AddPluginConfig(auth Auth, profileName, configName, pluginName string, conf
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -223,9 +211,7 @@ func DeletePluginConfig(auth Auth, profileName, configName, pluginName string) (
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -254,9 +240,7 @@ func DeleteConfig(auth Auth, profileName, configName string) (util.Result, error
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -285,9 +269,7 @@ func DeleteConfigDir(auth Auth, profileName, configName string) (util.Result, er
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -316,9 +298,7 @@ func AddProfile(auth Auth, profileName string) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -347,9 +327,39 @@ func DeleteProfile(auth Auth, profileName string) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return result, err
+	}
 
-	resp, err := client.Do(req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return result, err
+		}
+	} else {
+		return result, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return result, nil
+}
+
+func AddProfileNotifiers(auth Auth, profileName string, profileNotifiers util.ProfileNotifiers) (util.Result, error) {
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(profileNotifiers)
+
+	var result util.Result
+
+	req, err := http.NewRequest("POST", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/addProfileNotifiers/"+profileName, b)
+	if err != nil {
+		return result, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -367,6 +377,35 @@ func DeleteProfile(auth Auth, profileName string) (util.Result, error) {
 	return result, nil
 }
 
+func GetProfileNotifiers(auth Auth, profileName string) (util.ProfileNotifiersResult, error) {
+	var profileNotifiersResult util.ProfileNotifiersResult
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/getProfileNotifiers/"+profileName, nil)
+	if err != nil {
+		return profileNotifiersResult, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return profileNotifiersResult, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&profileNotifiersResult); err != nil {
+			return profileNotifiersResult, err
+		}
+	} else {
+		return profileNotifiersResult, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return profileNotifiersResult, nil
+}
+
 func ListProfiles(auth Auth) (util.Result, error) {
 	var result util.Result
 
@@ -378,9 +417,7 @@ func ListProfiles(auth Auth) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -409,9 +446,7 @@ func ListConfigs(auth Auth, profileName string) (util.Result, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -440,9 +475,7 @@ func ListPluginConfigs(auth Auth, profileName, configName string) (util.Result,
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -459,3 +492,40 @@ func ListPluginConfigs(auth Auth, profileName, configName string) (util.Result,
 
 	return result, nil
 }
+
+func GetAuditLog(auth Auth, profileName, configName string) (util.AuditLogResult, error) {
+	var auditLogResult util.AuditLogResult
+
+	path := "/getAuditLog"
+	if profileName != "" {
+		path = path + "/" + profileName
+		if configName != "" {
+			path = path + "/" + configName
+		}
+	}
+
+	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+path, nil)
+	if err != nil {
+		return auditLogResult, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return auditLogResult, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&auditLogResult); err != nil {
+			return auditLogResult, err
+		}
+	} else {
+		return auditLogResult, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return auditLogResult, nil
+}
@@ -20,9 +20,10 @@ import (
 	"net/http"
 )
 
-func AddSchedule(auth Auth, profileName, configName, policy, cronScheduleInput string) (util.Result, error) {
+func AddSchedule(auth Auth, profileName, configName, policy, workflowType, cronScheduleInput string) (util.Result, error) {
 	var cronSchedule util.CronSchedule
 	cronSchedule.Value = cronScheduleInput
+	cronSchedule.WorkflowType = workflowType
 
 	b := new(bytes.Buffer)
 	json.NewEncoder(b).Encode(cronSchedule)
@@ -37,9 +38,7 @@ func AddSchedule(auth Auth, profileName, configName, policy, cronScheduleInput s
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -57,10 +56,15 @@ func AddSchedule(auth Auth, profileName, configName, policy, cronScheduleInput s
 	return result, nil
 }
 
-func DeleteSchedule(auth Auth, profileName, configName, policy string) (util.Result, error) {
+func DeleteSchedule(auth Auth, profileName, configName, policy, workflowType string) (util.Result, error) {
 	var result util.Result
 
-	req, err := http.NewRequest("GET", "http://"+auth.ServerHostname+":"+auth.ServerPort+"/deleteSchedule/"+profileName+"/"+configName+"/"+policy, nil)
+	url := "http://" + auth.ServerHostname + ":" + auth.ServerPort + "/deleteSchedule/" + profileName + "/" + configName + "/" + policy
+	if workflowType != "" {
+		url = url + "/" + workflowType
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return result, err
 	}
@@ -68,9 +72,7 @@ func DeleteSchedule(auth Auth, profileName, configName, policy string) (util.Res
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -99,9 +101,7 @@ func ListSchedules(auth Auth) (util.JobScheduleResult, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return jobScheduleResult, err
 	}
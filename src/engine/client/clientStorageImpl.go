@@ -31,9 +31,7 @@ func StoragePluginList(auth Auth, pluginType string) ([]string, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return plugins, err
 	}
@@ -66,9 +64,7 @@ func StoragePluginInfo(auth Auth, config util.Config, pluginName, pluginType str
 	req.Header.Add("Content-Type", "application/json")
 	req.SetBasicAuth(auth.Username, auth.Password)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return pluginInfoResult, err
 	}
@@ -86,7 +82,8 @@ func StoragePluginInfo(auth Auth, config util.Config, pluginName, pluginType str
 	return pluginInfoResult, nil
 }
 
-func Backup(auth Auth, config util.Config) (util.Result, error) {
+// Backup runs the storage plugin backup, onMessage is called for every message of the result as it happens
+func Backup(auth Auth, config util.Config, onMessage func(util.Message)) (util.Result, error) {
 	var result util.Result
 
 	b := new(bytes.Buffer)
@@ -99,24 +96,7 @@ func Backup(auth Auth, config util.Config) (util.Result, error) {
 		return result, err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return result, err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 200 {
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return result, err
-		}
-	} else {
-		return result, errors.New("Http Status Error [" + resp.Status + "]")
-	}
-
-	return result, nil
+	return doResultStreamRequest(auth, req, onMessage)
 }
 
 func Restore(auth Auth, config util.Config) (util.Result, error) {
@@ -132,9 +112,7 @@ func Restore(auth Auth, config util.Config) (util.Result, error) {
 		return result, err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -167,9 +145,7 @@ func BackupList(auth Auth, profileName, configName, policyName string, config ut
 		return backups, err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return backups, err
 	}
@@ -187,6 +163,76 @@ func BackupList(auth Auth, profileName, configName, policyName string, config ut
 	return backups, nil
 }
 
+func BackupBrowse(auth Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config) (util.BackupFiles, error) {
+	var backupFiles util.BackupFiles
+
+	config = SetAdditionalConfigParams(profileName, configName, policyName, config)
+	config.SelectedWorkflowId = util.StringToInt64(selectedWorkflowId)
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.StorageHostname+":"+auth.StoragePort+"/backupBrowse", b)
+	if err != nil {
+		return backupFiles, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return backupFiles, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&backupFiles); err != nil {
+			return backupFiles, err
+		}
+	} else {
+		return backupFiles, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return backupFiles, nil
+}
+
+func BackupVerify(auth Auth, profileName, configName, policyName, selectedWorkflowId string, config util.Config) (util.BackupVerifyResult, error) {
+	var backupVerifyResult util.BackupVerifyResult
+
+	config = SetAdditionalConfigParams(profileName, configName, policyName, config)
+	config.SelectedWorkflowId = util.StringToInt64(selectedWorkflowId)
+
+	b := new(bytes.Buffer)
+	json.NewEncoder(b).Encode(config)
+
+	req, err := http.NewRequest("POST", "http://"+auth.StorageHostname+":"+auth.StoragePort+"/backupVerify", b)
+	if err != nil {
+		return backupVerifyResult, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(auth.Username, auth.Password)
+
+	resp, err := doRequest(auth, req)
+	if err != nil {
+		return backupVerifyResult, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 {
+		if err := json.NewDecoder(resp.Body).Decode(&backupVerifyResult); err != nil {
+			return backupVerifyResult, err
+		}
+	} else {
+		return backupVerifyResult, errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return backupVerifyResult, nil
+}
+
 func BackupDelete(auth Auth, config util.Config) (util.Result, error) {
 	var result util.Result
 
@@ -200,9 +246,7 @@ func BackupDelete(auth Auth, config util.Config) (util.Result, error) {
 		return result, err
 	}
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
+	resp, err := doRequest(auth, req)
 	if err != nil {
 		return result, err
 	}
@@ -14,6 +14,7 @@ package k8s
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"fossul/src/engine/util"
 	v1 "k8s.io/api/core/v1"
@@ -25,6 +26,11 @@ import (
 )
 
 func ExecuteCommand(podName, containerName, namespace, accessWithinCluster string, args ...string) util.Result {
+	return ExecuteCommandContext(context.Background(), podName, containerName, namespace, accessWithinCluster, args...)
+}
+
+// ExecuteCommandContext executes a command in a pod, the command is stopped when ctx is cancelled
+func ExecuteCommandContext(ctx context.Context, podName, containerName, namespace, accessWithinCluster string, args ...string) util.Result {
 	baseCmd := args[0]
 	cmdArgs := args[1:]
 
@@ -71,7 +77,7 @@ func ExecuteCommand(podName, containerName, namespace, accessWithinCluster strin
 		Stdin:     false,
 	}, scheme.ParameterCodec)
 
-	exec, err := remotecommand.NewSPDYExecutor(kubeConfig, "POST", req.URL())
+	exec, conn, err := newSPDYExecutor(kubeConfig, "POST", req.URL())
 	if err != nil {
 		message := util.SetMessage("ERROR", "Failed to init executor: "+err.Error())
 		messages = append(messages, message)
@@ -80,7 +86,7 @@ func ExecuteCommand(podName, containerName, namespace, accessWithinCluster strin
 		return result
 	}
 
-	err = exec.Stream(remotecommand.StreamOptions{
+	err = streamWithContext(ctx, exec, conn, remotecommand.StreamOptions{
 		Stdout: &execOut,
 		Stderr: &execErr,
 		Tty:    false,
@@ -0,0 +1,362 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"fossul/src/engine/util"
+	"io"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PodExecutor runs a command in a pod container and streams stdin, stdout and stderr. The default
+// executor uses the pod exec API, tests use a fake executor.
+type PodExecutor interface {
+	Exec(podName, containerName, namespace string, command []string, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+type remotePodExecutor struct {
+	ctx                 context.Context
+	accessWithinCluster string
+}
+
+// NewPodExecutor returns an executor using the pod exec API, commands are stopped when ctx is cancelled
+func NewPodExecutor(ctx context.Context, accessWithinCluster string) PodExecutor {
+	return remotePodExecutor{ctx: ctx, accessWithinCluster: accessWithinCluster}
+}
+
+func (e remotePodExecutor) Exec(podName, containerName, namespace string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	err, kubeConfig := getKubeConfig(e.accessWithinCluster)
+	if err != nil {
+		return err
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return errors.New("Couldn't create kube config: " + err.Error())
+	}
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec")
+	req.VersionedParams(&v1.PodExecOptions{
+		Container: containerName,
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+		Stdin:     stdin != nil,
+	}, scheme.ParameterCodec)
+
+	exec, conn, err := newSPDYExecutor(kubeConfig, "POST", req.URL())
+	if err != nil {
+		return errors.New("Failed to init executor: " + err.Error())
+	}
+
+	return streamWithContext(e.ctx, exec, conn, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+		Tty:    false,
+	})
+}
+
+// CopyFromPod copies srcPath from a pod into destDir by streaming tar over exec, like rsync the copy
+// is destDir/<base of srcPath>. Permissions, modification times and symlinks are preserved, the pod
+// only needs a tar binary.
+func CopyFromPod(executor PodExecutor, podName, containerName, namespace, srcPath, destDir string) util.Result {
+	var messages []util.Message
+
+	srcPath = path.Clean(srcPath)
+	command := []string{"tar", "cf", "-", "-C", path.Dir(srcPath), path.Base(srcPath)}
+	s0 := fmt.Sprintf("Copying [%s] from pod [%s] container [%s] to [%s]", srcPath, podName, containerName, destDir)
+	messages = append(messages, util.SetMessage("CMD", s0))
+
+	reader, writer := io.Pipe()
+	var execErr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		err := executor.Exec(podName, containerName, namespace, command, nil, writer, &execErr)
+		writer.CloseWithError(err)
+		done <- err
+	}()
+
+	fileCount, err := extractTar(reader, destDir)
+	if err == nil {
+		// tar pads the archive after the end marker, the padding is read so the exec can finish
+		_, err = io.Copy(ioutil.Discard, reader)
+	}
+	// stops the exec if extracting failed before the stream ended
+	reader.CloseWithError(err)
+	if doneErr := <-done; err == nil {
+		err = doneErr
+	}
+
+	if err != nil {
+		messages = append(messages, util.SetMessage("ERROR", "Copy of ["+srcPath+"] from pod ["+podName+"] failed! "+getCopyError(err, execErr)))
+		return util.SetResult(1, messages)
+	}
+
+	s1 := fmt.Sprintf("Copied [%d] files and directories of [%s] from pod [%s]", fileCount, srcPath, podName)
+	messages = append(messages, util.SetMessage("INFO", s1))
+	return util.SetResult(0, messages)
+}
+
+// CopyToPod copies srcPath into destDir of a pod by streaming tar over exec, like rsync the copy is
+// destDir/<base of srcPath>. destDir is created if it doesn't exist.
+func CopyToPod(executor PodExecutor, podName, containerName, namespace, srcPath, destDir string) util.Result {
+	var messages []util.Message
+
+	srcPath = filepath.Clean(srcPath)
+	s0 := fmt.Sprintf("Copying [%s] to pod [%s] container [%s] path [%s]", srcPath, podName, containerName, destDir)
+	messages = append(messages, util.SetMessage("CMD", s0))
+
+	var execOut, execErr bytes.Buffer
+	err := executor.Exec(podName, containerName, namespace, []string{"mkdir", "-p", destDir}, nil, &execOut, &execErr)
+	if err != nil {
+		messages = append(messages, util.SetMessage("ERROR", "Couldn't create ["+destDir+"] in pod ["+podName+"]! "+getCopyError(err, execErr)))
+		return util.SetResult(1, messages)
+	}
+
+	type tarResult struct {
+		fileCount int
+		err       error
+	}
+	reader, writer := io.Pipe()
+	done := make(chan tarResult, 1)
+	go func() {
+		fileCount, err := writeTar(writer, srcPath)
+		writer.CloseWithError(err)
+		done <- tarResult{fileCount, err}
+	}()
+
+	execErr.Reset()
+	command := []string{"tar", "xpf", "-", "-C", destDir}
+	err = executor.Exec(podName, containerName, namespace, command, reader, &execOut, &execErr)
+	// unblocks the tar writer if the exec ended before reading everything
+	reader.CloseWithError(io.ErrClosedPipe)
+
+	written := <-done
+	if err == nil {
+		err = written.err
+	}
+	fileCount := written.fileCount
+
+	if err != nil {
+		messages = append(messages, util.SetMessage("ERROR", "Copy of ["+srcPath+"] to pod ["+podName+"] failed! "+getCopyError(err, execErr)))
+		return util.SetResult(1, messages)
+	}
+
+	s1 := fmt.Sprintf("Copied [%d] files and directories of [%s] to pod [%s] path [%s]", fileCount, srcPath, podName, destDir)
+	messages = append(messages, util.SetMessage("INFO", s1))
+	return util.SetResult(0, messages)
+}
+
+// writeTar writes srcPath as a tar stream, entries are relative to the parent of srcPath and symlinks are not followed
+func writeTar(w io.Writer, srcPath string) (int, error) {
+	tarWriter := tar.NewWriter(w)
+	baseDir := filepath.Dir(srcPath)
+
+	var fileCount int
+	err := filepath.Walk(srcPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(filePath)
+			if err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			// sockets, pipes and devices can't be copied
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(baseDir, filePath)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			header.Name = header.Name + "/"
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		fileCount++
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+
+	if err != nil {
+		return fileCount, err
+	}
+
+	return fileCount, tarWriter.Close()
+}
+
+// extractTar extracts a tar stream into destDir. Entries must stay within destDir and are never written
+// through a symlink of the stream, directory permissions are set last so read-only directories can be filled.
+func extractTar(r io.Reader, destDir string) (int, error) {
+	tarReader := tar.NewReader(r)
+	type dirMode struct {
+		path   string
+		header *tar.Header
+	}
+	var dirs []dirMode
+	var fileCount int
+
+	err := os.MkdirAll(destDir, 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fileCount, err
+		}
+
+		destPath, err := getExtractPath(destDir, header.Name)
+		if err != nil {
+			return fileCount, err
+		}
+
+		mode := header.FileInfo().Mode()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(destPath, 0700); err != nil {
+				return fileCount, err
+			}
+			dirs = append(dirs, dirMode{destPath, header})
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+				return fileCount, err
+			}
+			if err := writeExtractFile(tarReader, destPath, mode.Perm()); err != nil {
+				return fileCount, err
+			}
+			if err := os.Chmod(destPath, mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+				return fileCount, err
+			}
+			os.Chtimes(destPath, header.ModTime, header.ModTime)
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+				return fileCount, err
+			}
+			os.Remove(destPath)
+			if err := os.Symlink(header.Linkname, destPath); err != nil {
+				return fileCount, err
+			}
+		default:
+			// hard links, devices and fifos are skipped
+			continue
+		}
+		fileCount++
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		mode := dirs[i].header.FileInfo().Mode()
+		if err := os.Chmod(dirs[i].path, mode&(os.ModePerm|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return fileCount, err
+		}
+		os.Chtimes(dirs[i].path, dirs[i].header.ModTime, dirs[i].header.ModTime)
+	}
+
+	return fileCount, nil
+}
+
+// getExtractPath returns where a tar entry is extracted, entries escaping destDir or passing through a symlink are rejected
+func getExtractPath(destDir, name string) (string, error) {
+	cleanName := path.Clean("/" + name)
+	if cleanName == "/" {
+		return "", errors.New("Tar entry [" + name + "] is invalid")
+	}
+
+	destPath := filepath.Join(destDir, filepath.FromSlash(cleanName))
+	parts := strings.Split(strings.TrimPrefix(cleanName, "/"), "/")
+	parent := destDir
+	for _, part := range parts[:len(parts)-1] {
+		parent = filepath.Join(parent, part)
+		info, err := os.Lstat(parent)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", errors.New("Tar entry [" + name + "] is inside symlink [" + parent + "]")
+		}
+	}
+
+	return destPath, nil
+}
+
+func writeExtractFile(r io.Reader, destPath string, mode os.FileMode) error {
+	// an existing symlink is replaced rather than written through
+	if info, err := os.Lstat(destPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(destPath); err != nil {
+			return err
+		}
+	}
+
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode|0200)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func getCopyError(err error, execErr bytes.Buffer) string {
+	if execErr.Len() > 0 {
+		return err.Error() + ", stderr: " + strings.TrimSpace(execErr.String())
+	}
+
+	return err.Error()
+}
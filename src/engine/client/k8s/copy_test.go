@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// fakePodExecutor runs pod commands locally, absolute paths are mapped into podRoot
+type fakePodExecutor struct {
+	podRoot string
+}
+
+func (e fakePodExecutor) Exec(podName, containerName, namespace string, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var args []string
+	for _, arg := range command {
+		if strings.HasPrefix(arg, "/") {
+			arg = e.podRoot + arg
+		}
+		args = append(args, arg)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	return cmd.Run()
+}
+
+func TestCopyFromToPod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	podRoot := dir + "/pod"
+	executor := fakePodExecutor{podRoot: podRoot}
+
+	srcDir := podRoot + "/var/lib/data"
+	os.MkdirAll(srcDir+"/sub", 0750)
+	ioutil.WriteFile(srcDir+"/a.txt", []byte("hello"), 0640)
+	ioutil.WriteFile(srcDir+"/sub/b.sh", []byte("#!/bin/sh"), 0755)
+	os.Symlink("a.txt", srcDir+"/link")
+	os.Symlink("/etc/passwd", srcDir+"/abs")
+
+	backupDir := dir + "/backup"
+	result := CopyFromPod(executor, "pod", "", "databases", "/var/lib/data", backupDir)
+	if result.Code != 0 {
+		t.Fail()
+		return
+	}
+
+	checkCopy(t, backupDir+"/data")
+
+	result = CopyToPod(executor, "pod", "", "databases", backupDir+"/data", "/tmp/restore")
+	if result.Code != 0 {
+		t.Fail()
+		return
+	}
+
+	checkCopy(t, podRoot+"/tmp/restore/data")
+
+	result = CopyFromPod(executor, "pod", "", "databases", "/var/lib/missing", backupDir)
+	if result.Code != 1 {
+		t.Fail()
+	}
+}
+
+func TestExtractTarSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// a file written through a symlink of the archive would end up outside the destination
+	var archive bytes.Buffer
+	tarWriter := tar.NewWriter(&archive)
+	tarWriter.WriteHeader(&tar.Header{Name: "data/escape", Typeflag: tar.TypeSymlink, Linkname: dir, Mode: 0777})
+	tarWriter.WriteHeader(&tar.Header{Name: "data/escape/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: 4})
+	tarWriter.Write([]byte("evil"))
+	tarWriter.Close()
+
+	_, err = extractTar(&archive, dir+"/dest")
+	if err == nil || existsPath(dir+"/evil") {
+		t.Fail()
+	}
+
+	destPath, err := getExtractPath(dir+"/dest", "../../evil")
+	if err != nil || destPath != dir+"/dest/evil" {
+		t.Fail()
+	}
+}
+
+func checkCopy(t *testing.T, path string) {
+	b, err := ioutil.ReadFile(path + "/a.txt")
+	if err != nil || string(b) != "hello" {
+		t.Fail()
+	}
+
+	for file, mode := range map[string]os.FileMode{"a.txt": 0640, "sub/b.sh": 0755, "sub": os.ModeDir | 0750} {
+		info, err := os.Lstat(path + "/" + file)
+		if err != nil || info.Mode() != mode {
+			t.Fail()
+		}
+	}
+
+	for link, target := range map[string]string{"link": "a.txt", "abs": "/etc/passwd"} {
+		linkTarget, err := os.Readlink(path + "/" + link)
+		if err != nil || linkTarget != target {
+			t.Fail()
+		}
+	}
+}
+
+func existsPath(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
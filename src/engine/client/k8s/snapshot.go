@@ -0,0 +1,329 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"errors"
+	"fossul/src/engine/util"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SnapshotGroup              = "snapshot.storage.k8s.io"
+	SnapshotLabelProfile       = "fossul.io/profile"
+	SnapshotLabelConfig        = "fossul.io/config"
+	SnapshotLabelPolicy        = "fossul.io/policy"
+	SnapshotLabelWorkflowId    = "fossul.io/workflow-id"
+	SnapshotAnnotationBackup   = "fossul.io/backup-name"
+	SnapshotAnnotationPvc      = "fossul.io/pvc"
+	SnapshotAnnotationSize     = "fossul.io/pvc-size"
+	SnapshotAnnotationClass    = "fossul.io/storage-class"
+	SnapshotAnnotationAccess   = "fossul.io/access-modes"
+	snapshotPollInterval       = 2 * time.Second
+	maxKubernetesResourceName  = 253
+	defaultSnapshotApiVersion  = "v1"
+	defaultSnapshotAccessModes = "ReadWriteOnce"
+)
+
+// SnapshotClient manages CSI VolumeSnapshots of the PVCs of a pod. VolumeSnapshots are used through the
+// dynamic client so no snapshot clientset is needed, both clients can be replaced by fakes in tests.
+type SnapshotClient struct {
+	Clientset  kubernetes.Interface
+	Dynamic    dynamic.Interface
+	ApiVersion string
+}
+
+// VolumeSnapshot is a snapshot of one PVC, a backup has a snapshot for every PVC of the pod
+type VolumeSnapshot struct {
+	Name         string
+	BackupName   string
+	PvcName      string
+	PvcSize      string
+	StorageClass string
+	AccessModes  string
+	ReadyToUse   bool
+	IsCut        bool
+	Error        string
+}
+
+// SnapshotBackup groups the snapshots of a backup
+type SnapshotBackup struct {
+	Backup    util.Backup
+	Snapshots []VolumeSnapshot
+}
+
+var invalidResourceNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+func NewSnapshotClient(accessWithinCluster, apiVersion string) (*SnapshotClient, error) {
+	err, kubeConfig := getKubeConfig(accessWithinCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotClient{Clientset: clientset, Dynamic: dynamicClient, ApiVersion: apiVersion}, nil
+}
+
+// GetPodPvcs returns the PVCs mounted by a pod
+func (c *SnapshotClient) GetPodPvcs(namespace, podName string) ([]v1.PersistentVolumeClaim, error) {
+	var pvcs []v1.PersistentVolumeClaim
+
+	pod, err := c.Clientset.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return pvcs, err
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		pvc, err := c.Clientset.CoreV1().PersistentVolumeClaims(namespace).Get(volume.PersistentVolumeClaim.ClaimName, metav1.GetOptions{})
+		if err != nil {
+			return pvcs, err
+		}
+		pvcs = append(pvcs, *pvc)
+	}
+
+	return pvcs, nil
+}
+
+// CreateVolumeSnapshot snapshots a PVC for a backup, the PVC size, storage class and access modes are kept
+// on the snapshot so a restore doesn't depend on the original PVC
+func (c *SnapshotClient) CreateVolumeSnapshot(namespace, snapshotClass string, pvc v1.PersistentVolumeClaim, backup util.Backup, profileName, configName string) (string, error) {
+	backupName := util.GetBackupName(backup.Name, backup.Policy, backup.WorkflowId, util.IntToString(backup.Epoch))
+	snapshotName := GetSnapshotName(backupName, pvc.Name)
+
+	var storageClass string
+	if pvc.Spec.StorageClassName != nil {
+		storageClass = *pvc.Spec.StorageClassName
+	}
+
+	var accessModes []string
+	for _, accessMode := range pvc.Spec.AccessModes {
+		accessModes = append(accessModes, string(accessMode))
+	}
+
+	size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetAPIVersion(SnapshotGroup + "/" + c.getApiVersion())
+	snapshot.SetKind("VolumeSnapshot")
+	snapshot.SetName(snapshotName)
+	snapshot.SetNamespace(namespace)
+	snapshot.SetLabels(map[string]string{
+		SnapshotLabelProfile:    profileName,
+		SnapshotLabelConfig:     configName,
+		SnapshotLabelPolicy:     backup.Policy,
+		SnapshotLabelWorkflowId: backup.WorkflowId,
+	})
+	snapshot.SetAnnotations(map[string]string{
+		SnapshotAnnotationBackup: backupName,
+		SnapshotAnnotationPvc:    pvc.Name,
+		SnapshotAnnotationSize:   size.String(),
+		SnapshotAnnotationClass:  storageClass,
+		SnapshotAnnotationAccess: strings.Join(accessModes, ","),
+	})
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvc.Name,
+		},
+	}
+	if snapshotClass != "" {
+		spec["volumeSnapshotClassName"] = snapshotClass
+	}
+	snapshot.Object["spec"] = spec
+
+	_, err := c.getResource().Namespace(namespace).Create(snapshot, metav1.CreateOptions{})
+	if err != nil {
+		return snapshotName, err
+	}
+
+	return snapshotName, nil
+}
+
+// WaitForVolumeSnapshot waits until the snapshot is cut, after that the application can be unquiesced.
+// If waitReady is true it also waits until the snapshot is ready to use.
+func (c *SnapshotClient) WaitForVolumeSnapshot(namespace, snapshotName string, waitReady bool, timeout time.Duration) (VolumeSnapshot, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		obj, err := c.getResource().Namespace(namespace).Get(snapshotName, metav1.GetOptions{})
+		if err != nil {
+			return VolumeSnapshot{}, err
+		}
+
+		snapshot := toVolumeSnapshot(obj)
+		if snapshot.Error != "" {
+			return snapshot, errors.New("Snapshot [" + snapshotName + "] failed! " + snapshot.Error)
+		}
+
+		if snapshot.ReadyToUse || (snapshot.IsCut && !waitReady) {
+			return snapshot, nil
+		}
+
+		if time.Now().After(deadline) {
+			return snapshot, errors.New("Timed out after [" + timeout.String() + "] waiting for snapshot [" + snapshotName + "]")
+		}
+
+		time.Sleep(snapshotPollInterval)
+	}
+}
+
+// ListSnapshotBackups returns the backups of a profile/config sorted by epoch, snapshots without a valid backup name are ignored
+func (c *SnapshotClient) ListSnapshotBackups(namespace, profileName, configName string) ([]SnapshotBackup, error) {
+	var backups []SnapshotBackup
+
+	selector := SnapshotLabelProfile + "=" + profileName + "," + SnapshotLabelConfig + "=" + configName
+	list, err := c.getResource().Namespace(namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return backups, err
+	}
+
+	backupIndex := make(map[string]int)
+	for i := range list.Items {
+		snapshot := toVolumeSnapshot(&list.Items[i])
+		backup, err := util.ParseBackupName(snapshot.BackupName)
+		if err != nil {
+			continue
+		}
+
+		index, ok := backupIndex[snapshot.BackupName]
+		if !ok {
+			backup.Timestamp = time.Unix(int64(backup.Epoch), 0).Format(time.RFC3339)
+			backups = append(backups, SnapshotBackup{Backup: backup})
+			index = len(backups) - 1
+			backupIndex[snapshot.BackupName] = index
+		}
+		backups[index].Snapshots = append(backups[index].Snapshots, snapshot)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Backup.Epoch < backups[j].Backup.Epoch
+	})
+
+	return backups, nil
+}
+
+func (c *SnapshotClient) DeleteVolumeSnapshot(namespace, snapshotName string) error {
+	return c.getResource().Namespace(namespace).Delete(snapshotName, &metav1.DeleteOptions{})
+}
+
+// CreatePvcFromSnapshot creates a PVC with the snapshot as data source, size, storage class and access
+// modes are those of the snapshotted PVC unless storageClass is set
+func (c *SnapshotClient) CreatePvcFromSnapshot(namespace, pvcName, storageClass string, snapshot VolumeSnapshot) error {
+	size, err := resource.ParseQuantity(snapshot.PvcSize)
+	if err != nil {
+		return errors.New("Snapshot [" + snapshot.Name + "] has invalid pvc size [" + snapshot.PvcSize + "]")
+	}
+
+	if storageClass == "" {
+		storageClass = snapshot.StorageClass
+	}
+
+	accessModes := snapshot.AccessModes
+	if accessModes == "" {
+		accessModes = defaultSnapshotAccessModes
+	}
+
+	apiGroup := SnapshotGroup
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = pvcName
+	pvc.Namespace = namespace
+	pvc.Annotations = map[string]string{SnapshotAnnotationBackup: snapshot.BackupName}
+	pvc.Spec.DataSource = &v1.TypedLocalObjectReference{APIGroup: &apiGroup, Kind: "VolumeSnapshot", Name: snapshot.Name}
+	pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: size}
+	if storageClass != "" {
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	for _, accessMode := range strings.Split(accessModes, ",") {
+		pvc.Spec.AccessModes = append(pvc.Spec.AccessModes, v1.PersistentVolumeAccessMode(accessMode))
+	}
+
+	_, err = c.Clientset.CoreV1().PersistentVolumeClaims(namespace).Create(pvc)
+	return err
+}
+
+// GetSnapshotName returns a valid kubernetes name for the snapshot of a pvc, backup names contain underscores
+func GetSnapshotName(backupName, pvcName string) string {
+	return GetResourceName(backupName + "-" + pvcName)
+}
+
+// GetResourceName converts a name to a valid kubernetes resource name
+func GetResourceName(name string) string {
+	name = invalidResourceNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > maxKubernetesResourceName {
+		name = strings.TrimRight(name[:maxKubernetesResourceName], "-.")
+	}
+
+	return name
+}
+
+func (c *SnapshotClient) getApiVersion() string {
+	if c.ApiVersion == "" {
+		return defaultSnapshotApiVersion
+	}
+
+	return c.ApiVersion
+}
+
+func (c *SnapshotClient) getResource() dynamic.NamespaceableResourceInterface {
+	return c.Dynamic.Resource(schema.GroupVersionResource{Group: SnapshotGroup, Version: c.getApiVersion(), Resource: "volumesnapshots"})
+}
+
+func toVolumeSnapshot(obj *unstructured.Unstructured) VolumeSnapshot {
+	var snapshot VolumeSnapshot
+	annotations := obj.GetAnnotations()
+
+	snapshot.Name = obj.GetName()
+	snapshot.BackupName = annotations[SnapshotAnnotationBackup]
+	snapshot.PvcName = annotations[SnapshotAnnotationPvc]
+	snapshot.PvcSize = annotations[SnapshotAnnotationSize]
+	snapshot.StorageClass = annotations[SnapshotAnnotationClass]
+	snapshot.AccessModes = annotations[SnapshotAnnotationAccess]
+
+	snapshot.ReadyToUse, _, _ = unstructured.NestedBool(obj.Object, "status", "readyToUse")
+	creationTime, _, _ := unstructured.NestedString(obj.Object, "status", "creationTime")
+	snapshot.IsCut = creationTime != "" || snapshot.ReadyToUse
+
+	// the restore size is the size of the snapshot, it is larger than the pvc request if the pvc was expanded
+	if restoreSize, ok, _ := unstructured.NestedString(obj.Object, "status", "restoreSize"); ok && restoreSize != "" {
+		snapshot.PvcSize = restoreSize
+	}
+
+	if message, ok, _ := unstructured.NestedString(obj.Object, "status", "error", "message"); ok {
+		snapshot.Error = message
+	}
+
+	return snapshot
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"fossul/src/engine/util"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newFakeSnapshotClient() *SnapshotClient {
+	storageClass := "csi-rbd"
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "mariadb-data"
+	pvc.Namespace = "fossul"
+	pvc.Spec.StorageClassName = &storageClass
+	pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}
+
+	pod := &v1.Pod{}
+	pod.Name = "mariadb-1-abcde"
+	pod.Namespace = "fossul"
+	pod.Spec.Volumes = []v1.Volume{
+		{Name: "data", VolumeSource: v1.VolumeSource{PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "mariadb-data"}}},
+		{Name: "tmp", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+	}
+
+	return &SnapshotClient{
+		Clientset: fake.NewSimpleClientset(pod, pvc),
+		Dynamic:   dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+}
+
+// setSnapshotStatus does what the csi snapshotter does once a snapshot is cut
+func setSnapshotStatus(client *SnapshotClient, name string, readyToUse bool) error {
+	obj, err := client.getResource().Namespace("fossul").Get(name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	unstructured.SetNestedField(obj.Object, time.Now().Format(time.RFC3339), "status", "creationTime")
+	unstructured.SetNestedField(obj.Object, readyToUse, "status", "readyToUse")
+	unstructured.SetNestedField(obj.Object, "12Gi", "status", "restoreSize")
+
+	_, err = client.getResource().Namespace("fossul").Update(obj, metav1.UpdateOptions{})
+	return err
+}
+
+func TestSnapshotBackup(t *testing.T) {
+	client := newFakeSnapshotClient()
+
+	pvcs, err := client.GetPodPvcs("fossul", "mariadb-1-abcde")
+	if err != nil || len(pvcs) != 1 || pvcs[0].Name != "mariadb-data" {
+		t.Logf("ERROR: expected pvc mariadb-data, got %v %v", pvcs, err)
+		t.Fail()
+		return
+	}
+
+	var names []string
+	for i, workflowId := range []string{"2", "1"} {
+		backup := util.Backup{Name: "mariadb", Policy: "daily", WorkflowId: workflowId, Epoch: 1570000000 - i}
+		name, err := client.CreateVolumeSnapshot("fossul", "csi-rbd-snapclass", pvcs[0], backup, "default", "mariadb")
+		if err != nil {
+			t.Logf("ERROR: create snapshot failed %v", err)
+			t.Fail()
+			return
+		}
+		names = append(names, name)
+	}
+
+	if names[0] != "mariadb-daily-2-1570000000-mariadb-data" {
+		t.Logf("ERROR: unexpected snapshot name %s", names[0])
+		t.Fail()
+	}
+
+	_, err = client.WaitForVolumeSnapshot("fossul", names[0], false, time.Millisecond)
+	if err == nil {
+		t.Logf("ERROR: expected timeout waiting for snapshot without status")
+		t.Fail()
+	}
+
+	if err := setSnapshotStatus(client, names[0], false); err != nil {
+		t.Logf("ERROR: update snapshot status failed %v", err)
+		t.Fail()
+		return
+	}
+
+	snapshot, err := client.WaitForVolumeSnapshot("fossul", names[0], false, time.Millisecond)
+	if err != nil || !snapshot.IsCut || snapshot.ReadyToUse || snapshot.PvcSize != "12Gi" {
+		t.Logf("ERROR: expected cut snapshot, got %v %v", snapshot, err)
+		t.Fail()
+	}
+
+	backups, err := client.ListSnapshotBackups("fossul", "default", "mariadb")
+	if err != nil || len(backups) != 2 {
+		t.Logf("ERROR: expected 2 backups, got %v %v", backups, err)
+		t.Fail()
+		return
+	}
+
+	if backups[0].Backup.WorkflowId != "1" || backups[1].Backup.WorkflowId != "2" || backups[1].Backup.Policy != "daily" {
+		t.Logf("ERROR: backups not sorted by epoch %v", backups)
+		t.Fail()
+	}
+
+	if len(backups[1].Snapshots) != 1 || backups[1].Snapshots[0].PvcName != "mariadb-data" || backups[1].Snapshots[0].StorageClass != "csi-rbd" {
+		t.Logf("ERROR: unexpected snapshots %v", backups[1].Snapshots)
+		t.Fail()
+	}
+
+	otherBackups, _ := client.ListSnapshotBackups("fossul", "default", "postgres")
+	if len(otherBackups) != 0 {
+		t.Logf("ERROR: expected no backups for other config, got %v", otherBackups)
+		t.Fail()
+	}
+
+	if err := client.CreatePvcFromSnapshot("fossul", "mariadb-data-restore-2", "", backups[1].Snapshots[0]); err != nil {
+		t.Logf("ERROR: create pvc from snapshot failed %v", err)
+		t.Fail()
+		return
+	}
+
+	pvc, err := client.Clientset.CoreV1().PersistentVolumeClaims("fossul").Get("mariadb-data-restore-2", metav1.GetOptions{})
+	if err != nil || pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Name != names[0] || *pvc.Spec.StorageClassName != "csi-rbd" {
+		t.Logf("ERROR: unexpected restore pvc %v %v", pvc, err)
+		t.Fail()
+		return
+	}
+
+	size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if size.String() != "12Gi" || len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != v1.ReadWriteOnce {
+		t.Logf("ERROR: unexpected restore pvc size %s or access modes %v", size.String(), pvc.Spec.AccessModes)
+		t.Fail()
+	}
+
+	if err := client.DeleteVolumeSnapshot("fossul", names[1]); err != nil {
+		t.Logf("ERROR: delete snapshot failed %v", err)
+		t.Fail()
+	}
+
+	backups, _ = client.ListSnapshotBackups("fossul", "default", "mariadb")
+	if len(backups) != 1 || backups[0].Backup.WorkflowId != "2" {
+		t.Logf("ERROR: expected 1 backup after delete, got %v", backups)
+		t.Fail()
+	}
+}
+
+func TestWaitForVolumeSnapshotError(t *testing.T) {
+	client := newFakeSnapshotClient()
+
+	pvcs, _ := client.GetPodPvcs("fossul", "mariadb-1-abcde")
+	backup := util.Backup{Name: "mariadb", Policy: "daily", WorkflowId: "1", Epoch: 1570000000}
+	name, err := client.CreateVolumeSnapshot("fossul", "", pvcs[0], backup, "default", "mariadb")
+	if err != nil {
+		t.Logf("ERROR: create snapshot failed %v", err)
+		t.Fail()
+		return
+	}
+
+	obj, _ := client.getResource().Namespace("fossul").Get(name, metav1.GetOptions{})
+	unstructured.SetNestedField(obj.Object, "snapshot class not found", "status", "error", "message")
+	client.getResource().Namespace("fossul").Update(obj, metav1.UpdateOptions{})
+
+	_, err = client.WaitForVolumeSnapshot("fossul", name, true, time.Minute)
+	if err == nil {
+		t.Logf("ERROR: expected snapshot error")
+		t.Fail()
+	}
+}
+
+func TestGetResourceName(t *testing.T) {
+	name := GetResourceName("MariaDB_daily_1_1570000000-data")
+	if name != "mariadb-daily-1-1570000000-data" {
+		t.Logf("ERROR: unexpected resource name %s", name)
+		t.Fail()
+	}
+
+	long := GetResourceName(string(make([]byte, 300)) + "a")
+	if long != "a" {
+		t.Logf("ERROR: unexpected resource name %s", long)
+		t.Fail()
+	}
+}
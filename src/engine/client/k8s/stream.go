@@ -0,0 +1,147 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"context"
+	"errors"
+	"io"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// contextWriter fails writes once ctx is cancelled, the mutex makes sure no write is in progress after cancel
+type contextWriter struct {
+	ctx   context.Context
+	mutex *sync.Mutex
+	w     io.Writer
+}
+
+func (w contextWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return w.w.Write(p)
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+// connectionCloser keeps the connection an exec stream was upgraded to so it can be closed from outside
+// the stream, a connection upgraded after close is closed right away
+type connectionCloser struct {
+	upgrader spdy.Upgrader
+	mutex    sync.Mutex
+	conn     httpstream.Connection
+	closed   bool
+}
+
+func (c *connectionCloser) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := c.upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		conn.Close()
+		return nil, errors.New("Exec connection closed")
+	}
+	c.conn = conn
+
+	return conn, nil
+}
+
+func (c *connectionCloser) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+
+	return nil
+}
+
+// newSPDYExecutor returns an exec executor and a closer of its connection
+func newSPDYExecutor(kubeConfig *rest.Config, method string, url *url.URL) (remotecommand.Executor, io.Closer, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(kubeConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	closer := &connectionCloser{upgrader: upgrader}
+	exec, err := remotecommand.NewSPDYExecutorForTransports(transport, closer, method, url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return exec, closer, nil
+}
+
+// streamWithContext runs an exec stream until it ends or ctx is cancelled. The exec stream of client-go
+// doesn't take a context, on cancel stdin and stdout fail and the connection of the stream is closed,
+// which ends the exec session in the pod even if the command neither reads nor writes. It only returns
+// once the stream has returned so nothing of the stream outlives the call.
+func streamWithContext(ctx context.Context, exec remotecommand.Executor, conn io.Closer, options remotecommand.StreamOptions) error {
+	var mutex sync.Mutex
+	if options.Stdin != nil {
+		options.Stdin = contextReader{ctx: ctx, r: options.Stdin}
+	}
+	if options.Stdout != nil {
+		options.Stdout = contextWriter{ctx: ctx, mutex: &mutex, w: options.Stdout}
+	}
+	if options.Stderr != nil {
+		options.Stderr = contextWriter{ctx: ctx, mutex: &mutex, w: options.Stderr}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Stream(options)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// waits for a write in progress, writes after this fail
+		mutex.Lock()
+		mutex.Unlock()
+
+		conn.Close()
+		<-done
+		return ctx.Err()
+	}
+}
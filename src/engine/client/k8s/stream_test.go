@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"k8s.io/client-go/tools/remotecommand"
+	"testing"
+	"time"
+)
+
+// fakeStreamExecutor writes to stdout until a write fails, like a tar that streams a large directory
+type fakeStreamExecutor struct {
+	stopped chan struct{}
+}
+
+func (e fakeStreamExecutor) Stream(options remotecommand.StreamOptions) error {
+	defer close(e.stopped)
+	for {
+		if _, err := options.Stdout.Write([]byte("data")); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakeHangingExecutor neither reads nor writes until its connection is closed, like a stuck dump
+type fakeHangingExecutor struct {
+	closed chan struct{}
+}
+
+func (e fakeHangingExecutor) Stream(options remotecommand.StreamOptions) error {
+	<-e.closed
+	return errors.New("connection closed")
+}
+
+func (e fakeHangingExecutor) Close() error {
+	close(e.closed)
+	return nil
+}
+
+type fakeCloser struct{}
+
+func (c fakeCloser) Close() error {
+	return nil
+}
+
+func TestStreamWithContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := fakeStreamExecutor{stopped: make(chan struct{})}
+
+	var stdout bytes.Buffer
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	err := streamWithContext(ctx, executor, fakeCloser{}, remotecommand.StreamOptions{Stdout: &stdout})
+	if err != context.Canceled {
+		t.Logf("ERROR: expected context canceled, got %v", err)
+		t.Fail()
+	}
+
+	size := stdout.Len()
+	select {
+	case <-executor.stopped:
+	case <-time.After(5 * time.Second):
+		t.Logf("ERROR: stream didn't stop after cancel")
+		t.Fail()
+	}
+
+	if stdout.Len() != size {
+		t.Logf("ERROR: stdout written after cancel")
+		t.Fail()
+	}
+}
+
+func TestStreamWithContextCancelHanging(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	executor := fakeHangingExecutor{closed: make(chan struct{})}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	err := streamWithContext(ctx, executor, executor, remotecommand.StreamOptions{})
+	if err != context.Canceled {
+		t.Logf("ERROR: expected context canceled, got %v", err)
+		t.Fail()
+	}
+
+	select {
+	case <-executor.closed:
+	default:
+		t.Logf("ERROR: connection not closed after cancel")
+		t.Fail()
+	}
+}
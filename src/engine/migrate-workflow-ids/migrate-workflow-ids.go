@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"fossul/src/engine/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// legacy workflow ids were random numbers between 0 and 9999
+const legacyWorkflowIdMax = 10000
+
+// One-time migration of job history created with legacy random workflow ids. Each legacy job directory
+// under the server data dir is renamed to a time based workflow id derived from the workflow start time. The
+// workflow keeps its legacy id, backups and archives are named with it and restores of the migrated job select
+// them by it. The server service must be stopped while migrating.
+func main() {
+	optDataDir := getopt.StringLong("data-dir", 'd', os.Getenv("FOSSUL_SERVER_DATA_DIR"), "Server data directory, defaults to FOSSUL_SERVER_DATA_DIR")
+	optDryRun := getopt.BoolLong("dry-run", 0, "Show what would be migrated without changing anything")
+	optHelp := getopt.BoolLong("help", 0, "Help")
+	getopt.Parse()
+
+	if *optHelp {
+		getopt.Usage()
+		os.Exit(0)
+	}
+
+	if *optDataDir == "" {
+		fmt.Println("[ERROR] Missing parameter --data-dir")
+		getopt.Usage()
+		os.Exit(1)
+	}
+
+	configDirs, err := filepath.Glob(*optDataDir + "/*/*")
+	if err != nil {
+		fmt.Println("[ERROR] " + err.Error())
+		os.Exit(1)
+	}
+
+	var migrated int
+	var failed int
+	for _, configDir := range configDirs {
+		info, err := os.Stat(configDir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		count, err := migrateConfigDir(configDir, *optDryRun)
+		migrated = migrated + count
+		if err != nil {
+			fmt.Println("[ERROR] Migration of [" + configDir + "] failed! " + err.Error())
+			failed = failed + 1
+		}
+	}
+
+	fmt.Println("[INFO] Migrated [" + util.IntToString(migrated) + "] jobs")
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func migrateConfigDir(configDir string, dryRun bool) (int, error) {
+	var count int
+	usedIds := make(map[int64]bool)
+	files, err := ioutil.ReadDir(configDir)
+	if err != nil {
+		return count, err
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			continue
+		}
+
+		legacyId, err := strconv.ParseInt(f.Name(), 10, 64)
+		if err != nil || legacyId >= legacyWorkflowIdMax {
+			continue
+		}
+
+		jobDir := configDir + "/" + f.Name()
+		workflow := &util.Workflow{}
+		err = util.ReadGob(jobDir+"/workflow", &workflow)
+		if err != nil {
+			return count, err
+		}
+
+		newId := getMigratedWorkflowId(configDir, workflow, f.ModTime(), usedIds)
+		usedIds[newId] = true
+		newJobDir := configDir + "/" + util.Int64ToString(newId)
+
+		fmt.Println("[INFO] Migrating job [" + jobDir + "] to [" + newJobDir + "]")
+		if dryRun {
+			count = count + 1
+			continue
+		}
+
+		err = migrateJob(configDir, jobDir, newJobDir, legacyId, newId, workflow)
+		if err != nil {
+			return count, err
+		}
+		count = count + 1
+	}
+
+	return count, nil
+}
+
+// getMigratedWorkflowId derives the workflow id from the workflow start time, the job dir
+// modification time is used if the workflow has no timestamp
+func getMigratedWorkflowId(configDir string, workflow *util.Workflow, modTime time.Time, usedIds map[int64]bool) int64 {
+	startTime, err := time.Parse(time.RFC3339, workflow.Timestamp)
+	if err != nil {
+		startTime = modTime
+	}
+
+	id := util.GetWorkflowIdFromTime(startTime)
+	for usedIds[id] || util.ExistsPath(configDir+"/"+util.Int64ToString(id)) {
+		id = id + 1
+	}
+
+	return id
+}
+
+func migrateJob(configDir, jobDir, newJobDir string, legacyId, newId int64, workflow *util.Workflow) error {
+	err := os.Rename(jobDir, newJobDir)
+	if err != nil {
+		return err
+	}
+
+	workflow.Id = newId
+	workflow.LegacyId = legacyId
+	err = util.WriteGob(newJobDir+"/workflow", workflow)
+	if err != nil {
+		return err
+	}
+
+	if util.ExistsPath(newJobDir + "/config") {
+		config, err := util.ReadWorkflowConfig(newJobDir)
+		if err != nil {
+			return err
+		}
+
+		config.WorkflowId = util.Int64ToString(newId)
+		util.SerializeWorkflowConfig(newJobDir, config)
+	}
+
+	// lock still held by a legacy workflow must follow the job
+	lockFile := configDir + "/workflowLock"
+	if util.ExistsPath(lockFile) {
+		var lock util.WorkflowLock
+		err := util.ReadGob(lockFile, &lock)
+		if err == nil && lock.WorkflowId == legacyId {
+			lock.WorkflowId = newId
+			err = util.WriteGob(lockFile, lock)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -78,7 +78,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, dumpPath)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -100,7 +100,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 			" "+config.AppPluginParameters["MysqlDb"]+" >"+dumpPath+"/mysql.sql")
 	}
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -132,7 +132,7 @@ func (a appPlugin) Unquiesce(config util.Config) util.Result {
 		return result
 	}
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -160,12 +160,12 @@ func (a appPlugin) PreRestore(config util.Config) util.Result {
 
 	//create tmp directory for storing dump
 	var mkdirArgs []string
-	restoreDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreDir := util.GetRestoreDestPath(config)
 	mkdirArgs = append(mkdirArgs, "mkdir")
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, restoreDir)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -190,11 +190,14 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		return result
 	}
 
+	restoreDestPath := util.GetRestoreDestPath(config)
+
 	var lsDirArgs []string
 	lsDirArgs = append(lsDirArgs, "ls")
-	lsDirArgs = append(lsDirArgs, "/tmp/"+util.IntToString(config.SelectedWorkflowId))
+	lsDirArgs = append(lsDirArgs, "-1")
+	lsDirArgs = append(lsDirArgs, restoreDestPath)
 
-	cmdResult, restoreDir := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
+	cmdResult, restoreDirList := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -202,7 +205,17 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		messages = util.PrependMessages(messages, cmdResult.Messages)
 	}
 
-	restorePath := "/tmp/" + util.IntToString(config.SelectedWorkflowId) + "/" + strings.TrimSpace(restoreDir) + "/" + util.IntToString(config.SelectedWorkflowId) + "/mysql.sql"
+	// restore path may hold other files, the restored backup is found by its name, policy and workflow id
+	restoreDir, err := util.GetRestoredBackupDir(config, strings.Fields(restoreDirList))
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	restorePath := util.ShellQuote(restoreDestPath + "/" + restoreDir + "/" + util.Int64ToString(config.SelectedWorkflowId) + "/mysql.sql")
 
 	// execute dump using pg_dump (requires ld_library_path)
 	var restoreArgs []string
@@ -212,14 +225,14 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 	if config.AppPluginParameters["MysqlPassword"] != "" {
 		restoreArgs = append(restoreArgs, config.AppPluginParameters["MysqlRestoreCmd"]+" -h "+config.AppPluginParameters["MysqlHost"]+
 			" -P "+config.AppPluginParameters["MysqlPort"]+" -u "+config.AppPluginParameters["MysqlUser"]+" -p "+
-			config.AppPluginParameters["MysqlPassword"]+" "+config.AppPluginParameters["MysqlDb"]+" "+restorePath)
+			config.AppPluginParameters["MysqlPassword"]+" "+util.ShellQuote(config.AppPluginParameters["MysqlDb"])+" "+restorePath)
 	} else {
 		restoreArgs = append(restoreArgs, config.AppPluginParameters["MysqlRestoreCmd"]+" -h "+config.AppPluginParameters["MysqlHost"]+
 			" -P "+config.AppPluginParameters["MysqlPort"]+" -u "+config.AppPluginParameters["MysqlUser"]+
-			" "+config.AppPluginParameters["MysqlDb"]+" <"+restorePath)
+			" "+util.ShellQuote(config.AppPluginParameters["MysqlDb"])+" <"+restorePath)
 	}
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -228,12 +241,16 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 	}
 
 	var rmDirArgs []string
-	restoreTmpDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreTmpDir := restoreDestPath
+	// a configured restore path is kept, only the restored dump is removed
+	if config.StoragePluginParameters["RestoreDestPath"] != "" {
+		restoreTmpDir = restoreDestPath + "/" + restoreDir
+	}
 	rmDirArgs = append(rmDirArgs, "rm")
 	rmDirArgs = append(rmDirArgs, "-rf")
 	rmDirArgs = append(rmDirArgs, restoreTmpDir)
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -78,7 +78,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, dumpPath)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -107,7 +107,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 
 	//args = append(args,"--quiet")
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -139,7 +139,7 @@ func (a appPlugin) Unquiesce(config util.Config) util.Result {
 		return result
 	}
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -167,12 +167,12 @@ func (a appPlugin) PreRestore(config util.Config) util.Result {
 
 	//create tmp directory for storing dump
 	var mkdirArgs []string
-	restoreDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreDir := util.GetRestoreDestPath(config)
 	mkdirArgs = append(mkdirArgs, "mkdir")
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, restoreDir)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -198,11 +198,14 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		return result
 	}
 
+	restoreDestPath := util.GetRestoreDestPath(config)
+
 	var lsDirArgs []string
 	lsDirArgs = append(lsDirArgs, "ls")
-	lsDirArgs = append(lsDirArgs, "/tmp/"+util.IntToString(config.SelectedWorkflowId))
+	lsDirArgs = append(lsDirArgs, "-1")
+	lsDirArgs = append(lsDirArgs, restoreDestPath)
 
-	cmdResult, restoreDir := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
+	cmdResult, restoreDirList := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -210,7 +213,23 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		messages = util.PrependMessages(messages, cmdResult.Messages)
 	}
 
-	restorePath := "/tmp/" + util.IntToString(config.SelectedWorkflowId) + "/" + strings.TrimSpace(restoreDir) + "/" + util.IntToString(config.SelectedWorkflowId) + "/" + config.AppPluginParameters["MongoDb"]
+	// restore path may hold other files, the restored backup is found by its name, policy and workflow id
+	restoreDir, err := util.GetRestoredBackupDir(config, strings.Fields(restoreDirList))
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	// dump directory is named after the backed up database, which differs when restoring into another database
+	sourceDb := config.AppPluginParameters["MongoDb"]
+	if config.AppPluginParameters["SourceDatabase"] != "" {
+		sourceDb = config.AppPluginParameters["SourceDatabase"]
+	}
+
+	restorePath := util.ShellQuote(restoreDestPath + "/" + restoreDir + "/" + util.Int64ToString(config.SelectedWorkflowId) + "/" + sourceDb)
 
 	//execute database restore
 	var restoreArgs []string
@@ -219,15 +238,15 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 
 	if config.AppPluginParameters["MongoPassword"] != "" {
 		restoreArgs = append(restoreArgs, config.AppPluginParameters["MongoRestoreCmd"]+" --host "+config.AppPluginParameters["MongoHost"]+
-			" --port "+config.AppPluginParameters["MongoPort"]+" --db "+config.AppPluginParameters["MongoDb"]+" --username "+
+			" --port "+config.AppPluginParameters["MongoPort"]+" --db "+util.ShellQuote(config.AppPluginParameters["MongoDb"])+" --username "+
 			config.AppPluginParameters["MongoUser"]+" --password "+config.AppPluginParameters["MongoPassword"]+" "+restorePath)
 	} else {
 		restoreArgs = append(restoreArgs, config.AppPluginParameters["MongoRestoreCmd"]+" --host "+config.AppPluginParameters["MongoHost"]+
-			" --port "+config.AppPluginParameters["MongoPort"]+" --db "+config.AppPluginParameters["MongoDb"]+" --username "+
+			" --port "+config.AppPluginParameters["MongoPort"]+" --db "+util.ShellQuote(config.AppPluginParameters["MongoDb"])+" --username "+
 			config.AppPluginParameters["MongoPassword"]+" "+restorePath)
 	}
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -236,12 +255,16 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 	}
 
 	var rmDirArgs []string
-	restoreTmpDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreTmpDir := restoreDestPath
+	// a configured restore path is kept, only the restored dump is removed
+	if config.StoragePluginParameters["RestoreDestPath"] != "" {
+		restoreTmpDir = restoreDestPath + "/" + restoreDir
+	}
 	rmDirArgs = append(rmDirArgs, "rm")
 	rmDirArgs = append(rmDirArgs, "-rf")
 	rmDirArgs = append(rmDirArgs, restoreTmpDir)
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -78,7 +78,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, dumpPath)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -103,7 +103,7 @@ func (a appPlugin) Quiesce(config util.Config) util.Result {
 			config.AppPluginParameters["PqHost"]+" --port "+config.AppPluginParameters["PqPort"]+" --file "+filePath)
 	}
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -135,7 +135,7 @@ func (a appPlugin) Unquiesce(config util.Config) util.Result {
 		return result
 	}
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], args...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -163,12 +163,12 @@ func (a appPlugin) PreRestore(config util.Config) util.Result {
 
 	//create tmp directory for storing dump
 	var mkdirArgs []string
-	restoreDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreDir := util.GetRestoreDestPath(config)
 	mkdirArgs = append(mkdirArgs, "mkdir")
 	mkdirArgs = append(mkdirArgs, "-p")
 	mkdirArgs = append(mkdirArgs, restoreDir)
 
-	cmdResult := k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
+	cmdResult := k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], mkdirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -194,11 +194,14 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		return result
 	}
 
+	restoreDestPath := util.GetRestoreDestPath(config)
+
 	var lsDirArgs []string
 	lsDirArgs = append(lsDirArgs, "ls")
-	lsDirArgs = append(lsDirArgs, "/tmp/"+util.IntToString(config.SelectedWorkflowId))
+	lsDirArgs = append(lsDirArgs, "-1")
+	lsDirArgs = append(lsDirArgs, restoreDestPath)
 
-	cmdResult, restoreDir := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
+	cmdResult, restoreDirList := k8s.ExecuteCommandWithStdout(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], lsDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -206,7 +209,17 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 		messages = util.PrependMessages(messages, cmdResult.Messages)
 	}
 
-	restorePath := "/tmp/" + util.IntToString(config.SelectedWorkflowId) + "/" + strings.TrimSpace(restoreDir) + "/" + util.IntToString(config.SelectedWorkflowId) + "/postgres.sql"
+	// restore path may hold other files, the restored backup is found by its name, policy and workflow id
+	restoreDir, err := util.GetRestoredBackupDir(config, strings.Fields(restoreDirList))
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	restorePath := util.ShellQuote(restoreDestPath + "/" + restoreDir + "/" + util.Int64ToString(config.SelectedWorkflowId) + "/postgres.sql")
 
 	// execute dump using pg_dump (requires ld_library_path)
 	var restoreArgs []string
@@ -215,16 +228,16 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 
 	if config.AppPluginParameters["PqPassword"] != "" {
 		restoreArgs = append(restoreArgs, "PGPASSWORD="+config.AppPluginParameters["PqPassword"]+" PGDATABASE="+
-			config.AppPluginParameters["PqDb"]+" LD_LIBRARY_PATH="+config.AppPluginParameters["PqLibraryPath"]+
+			util.ShellQuote(config.AppPluginParameters["PqDb"])+" LD_LIBRARY_PATH="+config.AppPluginParameters["PqLibraryPath"]+
 			" "+config.AppPluginParameters["PqRestoreCmd"]+" --host "+config.AppPluginParameters["PqHost"]+" --port "+
 			config.AppPluginParameters["PqPort"]+" --file "+restorePath)
 	} else {
-		restoreArgs = append(restoreArgs, " PGDATABASE="+config.AppPluginParameters["PqDb"]+" LD_LIBRARY_PATH="+
+		restoreArgs = append(restoreArgs, " PGDATABASE="+util.ShellQuote(config.AppPluginParameters["PqDb"])+" LD_LIBRARY_PATH="+
 			config.AppPluginParameters["PqLibraryPath"]+" "+config.AppPluginParameters["PqRestoreCmd"]+" --host "+
 			config.AppPluginParameters["PqHost"]+" --port "+config.AppPluginParameters["PqPort"]+" --file "+restorePath)
 	}
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], restoreArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
@@ -233,12 +246,16 @@ func (a appPlugin) PostRestore(config util.Config) util.Result {
 	}
 
 	var rmDirArgs []string
-	restoreTmpDir := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
+	restoreTmpDir := restoreDestPath
+	// a configured restore path is kept, only the restored dump is removed
+	if config.StoragePluginParameters["RestoreDestPath"] != "" {
+		restoreTmpDir = restoreDestPath + "/" + restoreDir
+	}
 	rmDirArgs = append(rmDirArgs, "rm")
 	rmDirArgs = append(rmDirArgs, "-rf")
 	rmDirArgs = append(rmDirArgs, restoreTmpDir)
 
-	cmdResult = k8s.ExecuteCommand(podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
+	cmdResult = k8s.ExecuteCommandContext(config.GetContext(), podName, config.AppPluginParameters["ContainerName"], config.AppPluginParameters["Namespace"], config.AppPluginParameters["AccessWithinCluster"], rmDirArgs...)
 
 	if cmdResult.Code != 0 {
 		return cmdResult
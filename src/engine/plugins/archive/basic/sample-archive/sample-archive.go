@@ -24,6 +24,7 @@ func main() {
 	optArchive := getopt.BoolLong("archive", 0, "Archive")
 	optArchiveList := getopt.BoolLong("archiveList", 0, "Archive List")
 	optArchiveDelete := getopt.BoolLong("archiveDelete", 0, "Archive Delete")
+	optArchiveRestore := getopt.BoolLong("archiveRestore", 0, "Archive Restore")
 	optInfo := getopt.BoolLong("info", 0, "Archive Plugin Information")
 	optHelp := getopt.BoolLong("help", 0, "Help")
 	getopt.Parse()
@@ -42,11 +43,13 @@ func main() {
 		archiveList(configMap)
 	} else if *optArchiveDelete {
 		archiveDelete(configMap)
+	} else if *optArchiveRestore {
+		archiveRestore(configMap)
 	} else if *optInfo {
 		info()
 	} else {
 		getopt.Usage()
-		os.Exit(0)		
+		os.Exit(0)
 	}
 }
 
@@ -65,6 +68,11 @@ func archiveDelete(configMap map[string]string) {
 	fmt.Println("INFO *** Archive delete ***")
 }
 
+func archiveRestore(configMap map[string]string) {
+	printEnv(configMap)
+	fmt.Println("INFO *** Archive restore ***")
+}
+
 func info() {
 	var plugin util.Plugin = setPlugin()
 
@@ -93,10 +101,13 @@ func setPlugin() (plugin util.Plugin) {
 	var archiveDeleteCap util.Capability
 	archiveDeleteCap.Name = "archiveDelete"
 
+	var archiveRestoreCap util.Capability
+	archiveRestoreCap.Name = "archiveRestore"
+
 	var infoCap util.Capability
 	infoCap.Name = "info"
 
-	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, infoCap)
+	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, archiveRestoreCap, infoCap)
 
 	plugin.Capabilities = capabilities
 
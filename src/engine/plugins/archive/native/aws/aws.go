@@ -13,7 +13,6 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
 	"fossul/src/engine/plugins/pluginUtil"
 	"fossul/src/engine/util"
 	"github.com/aws/aws-sdk-go/aws"
@@ -105,7 +104,6 @@ func (r archivePlugin) Archive(config util.Config) util.Result {
 func (r archivePlugin) ArchiveDelete(config util.Config) util.Result {
 	var result util.Result
 	var messages []util.Message
-	var resultCode int = 0
 
 	sess := session.Must(session.NewSession(&aws.Config{
 		Region: aws.String(config.ArchivePluginParameters["AwsRegion"]),
@@ -130,40 +128,19 @@ func (r archivePlugin) ArchiveDelete(config util.Config) util.Result {
 		return result
 	}
 
-	archivesByPolicy := util.GetArchivesByPolicy(config.SelectedBackupPolicy, archiveList)
-	archiveCount := len(archivesByPolicy)
-
-	if archiveCount > config.SelectedArchiveRetention {
-		count := 1
-		for archive := range pluginUtil.ReverseArchiveList(archivesByPolicy) {
-			if count > config.SelectedArchiveRetention {
-				msg := util.SetMessage("INFO", fmt.Sprintf("Number of archives [%d] greater than archive retention [%d]", archiveCount, config.SelectedArchiveRetention))
-				messages = append(messages, msg)
-				archiveCount = archiveCount - 1
-
-				archiveName := archive.Name + "_" + archive.Policy + "_" + archive.WorkflowId + "_" + util.IntToString(archive.Epoch)
-				msg = util.SetMessage("INFO", "Deleting archive "+archiveName)
-				messages = append(messages, msg)
-
-				archivePrefix := bucketPrefix + archiveName
-				err := DeleteFolder(s3svc, config.ArchivePluginParameters["BucketName"], archivePrefix)
-				if err != nil {
-					msg := util.SetMessage("ERROR", "Archive "+archiveName+" delete failed! "+err.Error())
-					messages = append(messages, msg)
-					result = util.SetResult(1, messages)
-					return result
-				}
-				msg = util.SetMessage("INFO", "Archive "+archiveName+" deleted successfully")
-				messages = append(messages, msg)
-			}
-			count = count + 1
+	retentionRule := util.GetArchiveRetentionRule(config)
+	deleteResult := pluginUtil.DeleteExpiredArchives(config.SelectedBackupPolicy, retentionRule, archiveList, func(archive util.Archive, archiveName string) (string, error) {
+		archivePrefix := bucketPrefix + archiveName
+		err := DeleteFolder(s3svc, config.ArchivePluginParameters["BucketName"], archivePrefix)
+		if err != nil {
+			return "", err
 		}
-	} else {
-		msg := util.SetMessage("INFO", fmt.Sprintf("Archive deletion skipped, there are [%d] archives but archive retention is [%d]", archiveCount, config.SelectedArchiveRetention))
-		messages = append(messages, msg)
-	}
 
-	result = util.SetResult(resultCode, messages)
+		return "", nil
+	})
+	messages = append(messages, deleteResult.Messages...)
+
+	result = util.SetResult(deleteResult.Code, messages)
 	return result
 }
 
@@ -210,6 +187,69 @@ func (r archivePlugin) ArchiveList(config util.Config) util.Archives {
 	return archives
 }
 
+func (r archivePlugin) ArchiveRestore(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+	var resultCode int = 0
+
+	bucketPrefix := config.ProfileName + "/" + config.ConfigName + "/"
+	workflowId := util.Int64ToString(config.SelectedWorkflowId)
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(config.ArchivePluginParameters["AwsRegion"]),
+	}))
+
+	s3svc := s3.New(sess)
+
+	objects, err := ListArchiveFolders(s3svc, config.ArchivePluginParameters["BucketName"], bucketPrefix)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	archiveList, err := pluginUtil.ListArchives(objects)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	archive, isFound := util.GetArchiveByWorkflowId(config.SelectedBackupPolicy, workflowId, archiveList)
+	if !isFound {
+		msg := util.SetMessage("ERROR", "Archive for workflow id ["+workflowId+"] policy ["+config.SelectedBackupPolicy+"] not found in AWS bucket ["+config.ArchivePluginParameters["BucketName"]+"]")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	// archives keep the path of the backup relative to the backup destination, downloading restores the backup in place
+	archiveName := archive.Name + "_" + archive.Policy + "_" + archive.WorkflowId + "_" + util.IntToString(archive.Epoch)
+	msg := util.SetMessage("INFO", "Restoring archive ["+archiveName+"] from AWS bucket ["+config.ArchivePluginParameters["BucketName"]+"] to ["+config.StoragePluginParameters["BackupDestPath"]+"]")
+	messages = append(messages, msg)
+
+	files, err := DownloadFolder(sess, s3svc, config.ArchivePluginParameters["BucketName"], bucketPrefix+archiveName+"/", config.StoragePluginParameters["BackupDestPath"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Archive "+archiveName+" restore failed! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	for _, file := range files {
+		msg := util.SetMessage("INFO", "Downloaded file ["+file+"] from bucket ["+config.ArchivePluginParameters["BucketName"]+"] successfully")
+		messages = append(messages, msg)
+	}
+
+	msg = util.SetMessage("INFO", "Archive restore ["+archiveName+"] from AWS bucket ["+config.ArchivePluginParameters["BucketName"]+"] completed successfully")
+	messages = append(messages, msg)
+
+	result = util.SetResult(resultCode, messages)
+	return result
+}
+
 func (r archivePlugin) Info() util.Plugin {
 	var plugin util.Plugin = setPlugin()
 	return plugin
@@ -231,10 +271,13 @@ func setPlugin() (plugin util.Plugin) {
 	var archiveDeleteCap util.Capability
 	archiveDeleteCap.Name = "archiveDelete"
 
+	var archiveRestoreCap util.Capability
+	archiveRestoreCap.Name = "archiveRestore"
+
 	var infoCap util.Capability
 	infoCap.Name = "info"
 
-	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, infoCap)
+	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, archiveRestoreCap, infoCap)
 
 	plugin.Capabilities = capabilities
 
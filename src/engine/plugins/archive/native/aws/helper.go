@@ -3,6 +3,7 @@ package main
 import (
 	"fossul/src/engine/util"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"os"
@@ -187,3 +188,63 @@ func DeleteFolder(s3svc *s3.S3, bucketName, bucketPrefix string) error {
 
 	return nil
 }
+
+func DownloadFolder(sess *session.Session, s3svc *s3.S3, bucketName, bucketPrefix, destPath string) ([]string, error) {
+	var files []string
+	var keys []string
+
+	inputparams := &s3.ListObjectsInput{
+		Bucket: aws.String(bucketName),
+		Prefix: aws.String(bucketPrefix),
+	}
+
+	err := s3svc.ListObjectsPages(inputparams, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, value := range page.Contents {
+			keys = append(keys, *value.Key)
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return files, err
+	}
+
+	downloader := s3manager.NewDownloader(sess)
+	for _, key := range keys {
+		filePath := filepath.Join(destPath, key)
+
+		// folders are empty objects ending with a slash
+		if strings.HasSuffix(key, "/") {
+			err = os.MkdirAll(filePath, 0755)
+			if err != nil {
+				return files, err
+			}
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(filePath), 0755)
+		if err != nil {
+			return files, err
+		}
+
+		file, err := os.Create(filePath)
+		if err != nil {
+			return files, err
+		}
+
+		_, err = downloader.Download(file, &s3.GetObjectInput{
+			Bucket: aws.String(bucketName),
+			Key:    aws.String(key),
+		})
+		file.Close()
+
+		if err != nil {
+			return files, err
+		}
+
+		files = append(files, filePath)
+	}
+
+	return files, nil
+}
@@ -60,6 +60,18 @@ func (r archivePlugin) ArchiveList(config util.Config) []util.Archive {
 	return archives
 }
 
+func (r archivePlugin) ArchiveRestore(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+	var resultCode int = 0
+
+	msg := util.SetMessage("INFO", "*** Archive Restore ***")
+	messages = append(messages, msg)
+
+	result = util.SetResult(resultCode, messages)
+	return result
+}
+
 func (r archivePlugin) Info() util.Plugin {
 	var plugin util.Plugin = setPlugin()
 	return plugin
@@ -81,10 +93,13 @@ func setPlugin() (plugin util.Plugin) {
 	var archiveDeleteCap util.Capability
 	archiveDeleteCap.Name = "archiveDelete"
 
+	var archiveRestoreCap util.Capability
+	archiveRestoreCap.Name = "archiveRestore"
+
 	var infoCap util.Capability
 	infoCap.Name = "info"
 
-	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, infoCap)
+	capabilities = append(capabilities, archiveCap, archiveListCap, archiveDeleteCap, archiveRestoreCap, infoCap)
 
 	plugin.Capabilities = capabilities
 
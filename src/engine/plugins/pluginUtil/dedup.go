@@ -0,0 +1,666 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package pluginUtil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fossul/src/engine/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DedupChunkDir       = ".chunks"
+	DedupSnapshotFile   = "snapshot.json"
+	DedupMinChunkSize   = 256 * 1024
+	DedupAvgChunkSize   = 1024 * 1024
+	DedupMaxChunkSize   = 4 * 1024 * 1024
+	dedupGearTableSeed  = 0x666f7373756c
+	dedupChunkTmpSuffix = ".tmp"
+)
+
+type DedupSnapshot struct {
+	BackupName   string      `json:"backupName"`
+	Timestamp    int64       `json:"timestamp"`
+	TotalSize    int64       `json:"totalSize"`
+	ChunkCount   int         `json:"chunkCount"`
+	NewChunks    int         `json:"newChunks"`
+	NewChunkSize int64       `json:"newChunkSize"`
+	Compression  string      `json:"compression,omitempty"`
+	Encryption   string      `json:"encryption,omitempty"`
+	KeyId        string      `json:"keyId,omitempty"`
+	ChunkIdKeyId string      `json:"chunkIdKeyId,omitempty"`
+	Files        []DedupFile `json:"files"`
+}
+
+type DedupFile struct {
+	Path   string      `json:"path"`
+	Mode   os.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	IsDir  bool        `json:"isDir,omitempty"`
+	Link   string      `json:"link,omitempty"`
+	Chunks []string    `json:"chunks,omitempty"`
+}
+
+// Chunker splits data into content defined chunks using a gear rolling hash, a boundary is set where the
+// top bits of the hash are zero so an insert only changes the chunks around it and the rest still deduplicate
+type Chunker struct {
+	MinSize int
+	MaxSize int
+	mask    uint64
+}
+
+// DedupRepository stores chunks once by their SHA-256 hash under the backup dir of a profile/config,
+// every backup is a snapshot index of files and their chunks. Workflows of a profile/config are
+// serialized by the workflow lock so backups and garbage collection never run against a repository
+// at the same time. If a sealer is set new chunks are compressed and encrypted. Encrypted chunks are
+// named by an HMAC-SHA256 of their plain data under the chunk id key of the encryption key, so names
+// don't reveal the content and never match a plain chunk. The snapshot records the key id its chunk
+// names are keyed with, every sealed chunk records its own encoding in its header.
+type DedupRepository struct {
+	Path    string
+	Chunker Chunker
+	Sealer  *util.BackupSealer
+}
+
+var gearTable [256]uint64
+
+// the gear table must never change, otherwise chunk boundaries move and existing chunks stop deduplicating
+func init() {
+	seed := uint64(dedupGearTableSeed)
+	for i := range gearTable {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gearTable[i] = z ^ (z >> 31)
+	}
+}
+
+// NewChunker returns a chunker, avgSize is rounded down to a power of two
+func NewChunker(minSize, avgSize, maxSize int) Chunker {
+	var chunker Chunker
+	chunker.MinSize = minSize
+	chunker.MaxSize = maxSize
+
+	bits := uint(0)
+	for (1 << (bits + 1)) <= avgSize {
+		bits++
+	}
+	chunker.mask = ^uint64(0) << (64 - bits)
+
+	return chunker
+}
+
+// Split reads r and calls fn for every chunk, the chunk data is only valid until fn returns
+func (c Chunker) Split(r io.Reader, fn func(chunk []byte) error) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	chunk := make([]byte, 0, c.MaxSize)
+	var hash uint64
+
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk = append(chunk, b)
+		hash = (hash << 1) + gearTable[b]
+
+		if len(chunk) >= c.MaxSize || (len(chunk) >= c.MinSize && hash&c.mask == 0) {
+			if err := fn(chunk); err != nil {
+				return err
+			}
+			chunk = chunk[:0]
+			hash = 0
+		}
+	}
+
+	if len(chunk) > 0 {
+		return fn(chunk)
+	}
+
+	return nil
+}
+
+func NewDedupRepository(backupDir string) DedupRepository {
+	var repository DedupRepository
+	repository.Path = backupDir + "/" + DedupChunkDir
+	repository.Chunker = NewChunker(DedupMinChunkSize, DedupAvgChunkSize, DedupMaxChunkSize)
+
+	return repository
+}
+
+func (r DedupRepository) chunkPath(hash string) string {
+	return r.Path + "/" + hash[:2] + "/" + hash
+}
+
+// getChunkIdKeyId returns the key id new chunks are named with, chunks are named by SHA-256 if it is empty
+func (r DedupRepository) getChunkIdKeyId() string {
+	if r.Sealer == nil {
+		return ""
+	}
+
+	return r.Sealer.Encoding().KeyId
+}
+
+// GetChunkId returns the name of a chunk, the SHA-256 hash of the data or its HMAC under the chunk id key of keyId
+func (r DedupRepository) GetChunkId(data []byte, keyId string) (string, error) {
+	if keyId == "" {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	if r.Sealer == nil {
+		return "", errors.New("Chunks are named with key id [" + keyId + "] but no sealer is set")
+	}
+
+	key, err := r.Sealer.GetChunkIdKey(keyId)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// WriteChunk stores a chunk unless it already exists, chunks are written to a temp file and renamed
+// so an interrupted backup never leaves a partial chunk behind. An existing chunk that isn't sealed
+// while new chunks are encrypted is sealed again, a plain chunk is never part of an encrypted backup.
+func (r DedupRepository) WriteChunk(data []byte) (string, bool, error) {
+	hash, err := r.GetChunkId(data, r.getChunkIdKeyId())
+	if err != nil {
+		return hash, false, err
+	}
+	chunkPath := r.chunkPath(hash)
+
+	isNew := !ExistsPath(chunkPath)
+	if !isNew {
+		if r.getChunkIdKeyId() == "" {
+			return hash, false, nil
+		}
+
+		sealed, err := util.IsSealedFile(chunkPath)
+		if err != nil || sealed {
+			return hash, false, err
+		}
+	}
+
+	err = CreateDir(filepath.Dir(chunkPath), 0755)
+	if err != nil {
+		return hash, false, err
+	}
+
+	if r.Sealer != nil {
+		var sealed bytes.Buffer
+		err := r.Sealer.Seal(&sealed, bytes.NewReader(data))
+		if err != nil {
+			return hash, false, err
+		}
+		data = sealed.Bytes()
+	}
+
+	tmpPath := chunkPath + dedupChunkTmpSuffix
+	err = ioutil.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return hash, false, err
+	}
+
+	err = os.Rename(tmpPath, chunkPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return hash, false, err
+	}
+
+	return hash, isNew, nil
+}
+
+// ReadChunk returns a chunk after checking its name, keyId is the chunk id key id of the snapshot. A corrupt
+// chunk is an error.
+func (r DedupRepository) ReadChunk(hash, keyId string) ([]byte, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, errors.New("Chunk hash [" + hash + "] is invalid")
+	}
+
+	data, err := ioutil.ReadFile(r.chunkPath(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if util.IsSealedData(data) {
+		if r.Sealer == nil {
+			return nil, errors.New("Chunk [" + hash + "] is sealed but no sealer is set")
+		}
+
+		var opened bytes.Buffer
+		err := r.Sealer.Open(&opened, bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.New("Couldn't open chunk [" + hash + "]! " + err.Error())
+		}
+		data = opened.Bytes()
+	}
+
+	chunkId, err := r.GetChunkId(data, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunkId != hash {
+		return nil, errors.New("Chunk [" + hash + "] is corrupt")
+	}
+
+	return data, nil
+}
+
+// DeleteChunks removes chunks, used to clean up the new chunks of a failed backup
+func (r DedupRepository) DeleteChunks(hashes []string) error {
+	for _, hash := range hashes {
+		err := os.Remove(r.chunkPath(hash))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Store chunks all files under srcDir into the repository and returns the snapshot index, paths are
+// relative to srcDir. The hashes of chunks that didn't exist before are returned as well.
+func (r DedupRepository) Store(srcDir string) (DedupSnapshot, []string, error) {
+	var snapshot DedupSnapshot
+	var newChunks []string
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == srcDir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		var file DedupFile
+		file.Path = relPath
+		file.Mode = info.Mode().Perm()
+
+		if info.IsDir() {
+			file.IsDir = true
+			snapshot.Files = append(snapshot.Files, file)
+			return nil
+		}
+
+		// links are kept in the index, sockets and devices can't be restored from chunks
+		if info.Mode()&os.ModeSymlink != 0 {
+			file.Link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			snapshot.Files = append(snapshot.Files, file)
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return errors.New("File [" + relPath + "] is not a regular file, directory or symlink")
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		err = r.Chunker.Split(f, func(chunk []byte) error {
+			hash, isNew, err := r.WriteChunk(chunk)
+			if err != nil {
+				return err
+			}
+
+			if isNew {
+				newChunks = append(newChunks, hash)
+				snapshot.NewChunkSize += int64(len(chunk))
+			}
+
+			file.Chunks = append(file.Chunks, hash)
+			file.Size += int64(len(chunk))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		snapshot.TotalSize += file.Size
+		snapshot.ChunkCount += len(file.Chunks)
+		snapshot.Files = append(snapshot.Files, file)
+		return nil
+	})
+
+	snapshot.NewChunks = len(newChunks)
+	snapshot.ChunkIdKeyId = r.getChunkIdKeyId()
+	if r.Sealer != nil {
+		encoding := r.Sealer.Encoding()
+		snapshot.Compression = encoding.Compression
+		snapshot.Encryption = encoding.Encryption
+		snapshot.KeyId = encoding.KeyId
+	}
+
+	return snapshot, newChunks, err
+}
+
+// Restore rebuilds the files of a snapshot under destDir, if paths are given only those files and
+// directories are restored
+func (r DedupRepository) Restore(snapshot DedupSnapshot, destDir string, paths []string) (int, error) {
+	err := util.ValidateRestorePaths(paths)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, path := range paths {
+		if !isDedupPathInSnapshot(snapshot, path) {
+			return 0, errors.New("Restore path [" + path + "] not found in backup")
+		}
+	}
+
+	err = CreateDir(destDir, 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	var links []string
+	for _, file := range snapshot.Files {
+		if len(paths) > 0 && !isDedupPathSelected(file.Path, paths) {
+			continue
+		}
+
+		// a restored link is never followed, files below it would be written outside of destDir
+		if isDedupPathSelected(file.Path, links) {
+			return count, errors.New("Restore of [" + file.Path + "] failed! Path resolves through symlink in backup")
+		}
+
+		destPath := filepath.Join(destDir, file.Path)
+		if file.Link != "" {
+			err := os.MkdirAll(filepath.Dir(destPath), 0755)
+			if err != nil {
+				return count, err
+			}
+
+			err = os.Symlink(file.Link, destPath)
+			if err != nil {
+				return count, errors.New("Restore of [" + file.Path + "] failed! " + err.Error())
+			}
+
+			links = append(links, file.Path)
+			count++
+			continue
+		}
+
+		if file.IsDir {
+			err := os.MkdirAll(destPath, file.Mode|0700)
+			if err != nil {
+				return count, err
+			}
+			continue
+		}
+
+		err := r.restoreFile(file, snapshot.ChunkIdKeyId, destPath)
+		if err != nil {
+			return count, errors.New("Restore of [" + file.Path + "] failed! " + err.Error())
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (r DedupRepository) restoreFile(file DedupFile, keyId, destPath string) error {
+	err := os.MkdirAll(filepath.Dir(destPath), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, file.Mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var size int64
+	for _, hash := range file.Chunks {
+		data, err := r.ReadChunk(hash, keyId)
+		if err != nil {
+			return err
+		}
+
+		n, err := io.Copy(f, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		size += n
+	}
+
+	if size != file.Size {
+		return errors.New("Restored size [" + util.Int64ToString(size) + "] doesn't match snapshot size [" + util.Int64ToString(file.Size) + "]")
+	}
+
+	return nil
+}
+
+// DeleteSnapshot deletes a backup and garbage collects its chunks. Reference counts are rebuilt from the
+// snapshot indexes of the remaining backups so they can't drift, chunks no other backup references are
+// deleted. Returns the number of chunks and bytes freed.
+func (r DedupRepository) DeleteSnapshot(backupDir, backupName string) (int, int64, error) {
+	backupPath := backupDir + "/" + backupName
+	snapshot, err := ReadDedupSnapshot(backupPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	refCounts, err := r.getRefCounts(backupDir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, file := range snapshot.Files {
+		for _, hash := range file.Chunks {
+			refCounts[hash]--
+		}
+	}
+
+	// the snapshot goes first, a failure after this only leaves unreferenced chunks and never a snapshot with missing chunks
+	err = RecursiveDirDelete(backupPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var freedChunks int
+	var freedSize int64
+	for hash, count := range refCounts {
+		if count > 0 {
+			continue
+		}
+
+		chunkPath := r.chunkPath(hash)
+		info, err := os.Stat(chunkPath)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return freedChunks, freedSize, err
+		}
+
+		err = os.Remove(chunkPath)
+		if err != nil {
+			return freedChunks, freedSize, err
+		}
+
+		freedChunks++
+		freedSize += info.Size()
+	}
+
+	return freedChunks, freedSize, nil
+}
+
+func (r DedupRepository) getRefCounts(backupDir string) (map[string]int, error) {
+	refCounts := make(map[string]int)
+
+	backups, err := ListBackups(backupDir)
+	if err != nil {
+		return refCounts, err
+	}
+
+	for _, backup := range backups {
+		backupName := util.GetBackupName(backup.Name, backup.Policy, backup.WorkflowId, util.IntToString(backup.Epoch))
+		snapshot, err := ReadDedupSnapshot(backupDir + "/" + backupName)
+		if err != nil {
+			return refCounts, errors.New("Couldn't read snapshot of backup [" + backupName + "]! " + err.Error())
+		}
+
+		for _, file := range snapshot.Files {
+			for _, hash := range file.Chunks {
+				refCounts[hash]++
+			}
+		}
+	}
+
+	return refCounts, nil
+}
+
+// IsDedupBackup is true if the backup is a snapshot index of a chunk repository
+func IsDedupBackup(backupPath string) bool {
+	return ExistsPath(backupPath + "/" + DedupSnapshotFile)
+}
+
+// ListDedupSnapshotFiles returns the files and directories of a snapshot, the backup dir only holds the index
+func ListDedupSnapshotFiles(snapshot DedupSnapshot) []util.BackupFile {
+	var files []util.BackupFile
+	for _, snapshotFile := range snapshot.Files {
+		var file util.BackupFile
+		file.Path = snapshotFile.Path
+		file.Size = snapshotFile.Size
+		file.IsDir = snapshotFile.IsDir
+		file.ModTime = snapshot.Timestamp
+		files = append(files, file)
+	}
+
+	return files
+}
+
+// VerifySnapshot reads every chunk of a snapshot and checks its hash, files with missing chunks are missing
+// and files with unreadable or corrupt chunks are corrupt. The snapshot index is the manifest of the backup.
+func (r DedupRepository) VerifySnapshot(snapshot DedupSnapshot) util.BackupVerification {
+	var verification util.BackupVerification
+	verification.BackupName = snapshot.BackupName
+	verification.IsManifest = true
+
+	chunkErrors := make(map[string]error)
+	for _, file := range snapshot.Files {
+		if file.IsDir || file.Link != "" {
+			continue
+		}
+
+		isMissing := false
+		isCorrupt := false
+		for _, hash := range file.Chunks {
+			err, ok := chunkErrors[hash]
+			if !ok {
+				_, err = r.ReadChunk(hash, snapshot.ChunkIdKeyId)
+				chunkErrors[hash] = err
+			}
+
+			if os.IsNotExist(err) {
+				isMissing = true
+			} else if err != nil {
+				isCorrupt = true
+			}
+		}
+
+		if isMissing {
+			verification.MissingFiles = append(verification.MissingFiles, file.Path)
+		} else if isCorrupt {
+			verification.CorruptFiles = append(verification.CorruptFiles, file.Path)
+		} else {
+			verification.VerifiedFiles++
+		}
+	}
+
+	return verification
+}
+
+func WriteDedupSnapshot(backupPath string, snapshot DedupSnapshot) error {
+	err := CreateDir(backupPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(backupPath+"/"+DedupSnapshotFile, b, 0644)
+}
+
+func ReadDedupSnapshot(backupPath string) (DedupSnapshot, error) {
+	var snapshot DedupSnapshot
+
+	b, err := ioutil.ReadFile(backupPath + "/" + DedupSnapshotFile)
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(b, &snapshot)
+	if err != nil {
+		return snapshot, err
+	}
+
+	return snapshot, nil
+}
+
+func isDedupPathInSnapshot(snapshot DedupSnapshot, path string) bool {
+	for _, file := range snapshot.Files {
+		if isDedupPathSelected(file.Path, []string{path}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDedupPathSelected(filePath string, paths []string) bool {
+	for _, path := range paths {
+		cleanPath := filepath.Clean(path)
+		if filePath == cleanPath || strings.HasPrefix(filePath, cleanPath+"/") {
+			return true
+		}
+	}
+
+	return false
+}
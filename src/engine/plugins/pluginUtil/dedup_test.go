@@ -0,0 +1,379 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package pluginUtil
+
+import (
+	"bytes"
+	"fossul/src/engine/util"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestChunkerSplit(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunker := NewChunker(1024, 4096, 16384)
+	chunks := splitChunks(chunker, data)
+
+	if !bytes.Equal(bytes.Join(chunks, nil), data) {
+		t.Fail()
+	}
+
+	for i, chunk := range chunks {
+		if len(chunk) > 16384 || (len(chunk) < 1024 && i != len(chunks)-1) {
+			t.Fail()
+		}
+	}
+
+	// an insert at the start only changes the first chunks, boundaries resync after it
+	shifted := append([]byte("inserted"), data...)
+	shiftedChunks := splitChunks(chunker, shifted)
+
+	known := make(map[string]bool)
+	for _, chunk := range chunks {
+		known[string(chunk)] = true
+	}
+
+	var shared int
+	for _, chunk := range shiftedChunks {
+		if known[string(chunk)] {
+			shared++
+		}
+	}
+
+	if shared < len(chunks)-2 {
+		t.Fail()
+	}
+}
+
+func TestDedupStoreRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+
+	srcDir := dir + "/src"
+	os.MkdirAll(srcDir+"/data", 0755)
+	ioutil.WriteFile(srcDir+"/data/a.dat", data, 0644)
+	ioutil.WriteFile(srcDir+"/data/b.dat", data, 0644)
+	ioutil.WriteFile(srcDir+"/log.txt", []byte("log"), 0644)
+
+	backupDir := dir + "/backups"
+	repository := NewDedupRepository(backupDir)
+	repository.Chunker = NewChunker(1024, 4096, 16384)
+
+	snapshot, newChunks, err := repository.Store(srcDir)
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	// b.dat is a copy of a.dat so all of its chunks are deduplicated
+	if snapshot.TotalSize != int64(2*len(data)+3) || snapshot.ChunkCount != 2*len(newChunks)-1 {
+		t.Fail()
+	}
+
+	err = WriteDedupSnapshot(backupDir+"/db_daily_1_100", snapshot)
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	restoreDir := dir + "/restore"
+	count, err := repository.Restore(snapshot, restoreDir, []string{"data/b.dat"})
+	if err != nil || count != 1 {
+		t.Fail()
+		return
+	}
+
+	restored, err := ioutil.ReadFile(restoreDir + "/data/b.dat")
+	if err != nil || !bytes.Equal(restored, data) || ExistsPath(restoreDir+"/data/a.dat") {
+		t.Fail()
+	}
+
+	_, err = repository.Restore(snapshot, restoreDir, []string{"missing"})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestDedupSealedChunks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	srcDir := dir + "/src"
+	os.MkdirAll(srcDir, 0755)
+	ioutil.WriteFile(srcDir+"/a.txt", []byte("sealed chunk data"), 0644)
+	ioutil.WriteFile(dir+"/key.key", []byte("passphrase"), 0600)
+
+	repository := NewDedupRepository(dir + "/backups")
+	repository.Sealer, err = util.NewBackupSealer(util.SealOptions{Compression: util.CompressionZstd, KeyFile: dir + "/key.key"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	snapshot, newChunks, err := repository.Store(srcDir)
+	if err != nil || len(newChunks) != 1 || snapshot.KeyId != "key" || snapshot.Compression != util.CompressionZstd {
+		t.Fail()
+		return
+	}
+
+	data, err := ioutil.ReadFile(repository.chunkPath(newChunks[0]))
+	if err != nil || !util.IsSealedData(data) {
+		t.Fail()
+	}
+
+	// a new sealer, as used by a restore, derives the key again from the key id and salt
+	repository.Sealer, _ = util.NewBackupSealer(util.SealOptions{KeyFile: dir + "/key.key"})
+	count, err := repository.Restore(snapshot, dir+"/restore", nil)
+	if err != nil || count != 1 {
+		t.Fail()
+	}
+
+	repository.Sealer = nil
+	_, err = repository.ReadChunk(newChunks[0], snapshot.ChunkIdKeyId)
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestDedupSealedChunkIds(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	data := []byte("sealed chunk data")
+	ioutil.WriteFile(dir+"/key.key", []byte("passphrase"), 0600)
+
+	plainRepository := NewDedupRepository(dir + "/backups")
+	plainId, _, err := plainRepository.WriteChunk(data)
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	repository := NewDedupRepository(dir + "/backups")
+	repository.Sealer, err = util.NewBackupSealer(util.SealOptions{KeyFile: dir + "/key.key"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	// encrypted chunks are named by an HMAC, the plain chunk of the same data is not reused
+	sealedId, isNew, err := repository.WriteChunk(data)
+	if err != nil || !isNew || sealedId == plainId {
+		t.Logf("ERROR: Sealed chunk should get a new id, got [%s] plain id [%s]", sealedId, plainId)
+		t.Fail()
+		return
+	}
+
+	// a new sealer derives the same chunk id key, so sealed chunks keep deduplicating
+	repository.Sealer, _ = util.NewBackupSealer(util.SealOptions{KeyFile: dir + "/key.key"})
+	chunkId, isNew, err := repository.WriteChunk(data)
+	if err != nil || isNew || chunkId != sealedId {
+		t.Logf("ERROR: Sealed chunk should be deduplicated, got [%s] expected [%s]", chunkId, sealedId)
+		t.Fail()
+	}
+
+	// a plain chunk under a sealed chunk name is sealed again
+	ioutil.WriteFile(repository.chunkPath(sealedId), data, 0644)
+	_, _, err = repository.WriteChunk(data)
+	sealed, _ := util.IsSealedFile(repository.chunkPath(sealedId))
+	if err != nil || !sealed {
+		t.Logf("ERROR: Plain chunk in encrypted backup should be sealed again")
+		t.Fail()
+	}
+
+	restored, err := repository.ReadChunk(sealedId, "key")
+	if err != nil || !bytes.Equal(restored, data) {
+		t.Fail()
+	}
+
+	_, err = repository.ReadChunk(sealedId, "")
+	if err == nil {
+		t.Logf("ERROR: Sealed chunk should not match a SHA-256 chunk id")
+		t.Fail()
+	}
+}
+
+func TestDedupDeleteSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	shared := make([]byte, 64*1024)
+	rand.New(rand.NewSource(3)).Read(shared)
+	unique := make([]byte, 64*1024)
+	rand.New(rand.NewSource(4)).Read(unique)
+
+	backupDir := dir + "/backups"
+	repository := NewDedupRepository(backupDir)
+	repository.Chunker = NewChunker(1024, 4096, 16384)
+
+	srcDir := dir + "/src"
+	os.MkdirAll(srcDir, 0755)
+	ioutil.WriteFile(srcDir+"/shared.dat", shared, 0644)
+	ioutil.WriteFile(srcDir+"/unique.dat", unique, 0644)
+
+	first, _, err := repository.Store(srcDir)
+	if err != nil {
+		t.Fail()
+		return
+	}
+	WriteDedupSnapshot(backupDir+"/db_daily_1_100", first)
+
+	os.Remove(srcDir + "/unique.dat")
+	second, newChunks, err := repository.Store(srcDir)
+	if err != nil || len(newChunks) != 0 {
+		t.Fail()
+		return
+	}
+	WriteDedupSnapshot(backupDir+"/db_daily_2_200", second)
+
+	freedChunks, freedSize, err := repository.DeleteSnapshot(backupDir, "db_daily_1_100")
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	// only the chunks of unique.dat are freed, the shared chunks are still referenced
+	if freedChunks != len(first.Files[1].Chunks) || freedSize != int64(len(unique)) || ExistsPath(backupDir+"/db_daily_1_100") {
+		t.Fail()
+	}
+
+	count, err := repository.Restore(second, dir+"/restore", nil)
+	if err != nil || count != 1 {
+		t.Fail()
+	}
+
+	freedChunks, _, err = repository.DeleteSnapshot(backupDir, "db_daily_2_200")
+	if err != nil || freedChunks != len(second.Files[0].Chunks) {
+		t.Fail()
+	}
+}
+
+func splitChunks(chunker Chunker, data []byte) [][]byte {
+	var chunks [][]byte
+	chunker.Split(bytes.NewReader(data), func(chunk []byte) error {
+		chunks = append(chunks, append([]byte(nil), chunk...))
+		return nil
+	})
+
+	return chunks
+}
+
+func TestDedupSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	srcDir := dir + "/src"
+	os.MkdirAll(srcDir+"/data", 0755)
+	ioutil.WriteFile(srcDir+"/data/a.dat", []byte("data"), 0644)
+	os.Symlink("data/a.dat", srcDir+"/current")
+	os.Symlink("/", srcDir+"/etc")
+
+	repository := NewDedupRepository(dir + "/backups")
+	snapshot, _, err := repository.Store(srcDir)
+	if err != nil {
+		t.Logf("ERROR: %s", err.Error())
+		t.Fail()
+		return
+	}
+
+	restoreDir := dir + "/restore"
+	_, err = repository.Restore(snapshot, restoreDir, nil)
+	if err != nil {
+		t.Logf("ERROR: %s", err.Error())
+		t.Fail()
+		return
+	}
+
+	link, err := os.Readlink(restoreDir + "/current")
+	if err != nil || link != "data/a.dat" {
+		t.Logf("ERROR: Symlink [current] should be restored as a link to [data/a.dat]")
+		t.Fail()
+	}
+
+	// a file below a link in the index would be written through the link
+	snapshot.Files = append(snapshot.Files, DedupFile{Path: "etc/passwd", Mode: 0644})
+	_, err = repository.Restore(snapshot, dir+"/restore2", nil)
+	if err == nil {
+		t.Logf("ERROR: Restore through a symlink in the index should fail")
+		t.Fail()
+	}
+}
+
+func TestDedupVerifySnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	srcDir := dir + "/src"
+	os.MkdirAll(srcDir, 0755)
+	ioutil.WriteFile(srcDir+"/a.txt", []byte("chunk a"), 0644)
+	ioutil.WriteFile(srcDir+"/b.txt", []byte("chunk b"), 0644)
+
+	repository := NewDedupRepository(dir + "/backups")
+	snapshot, _, err := repository.Store(srcDir)
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	verification := repository.VerifySnapshot(snapshot)
+	if !verification.IsValid() || verification.VerifiedFiles != 2 {
+		t.Logf("ERROR: Expected valid snapshot with [2] verified files, got %v", verification)
+		t.Fail()
+	}
+
+	os.Remove(repository.chunkPath(snapshot.Files[0].Chunks[0]))
+	ioutil.WriteFile(repository.chunkPath(snapshot.Files[1].Chunks[0]), []byte("corrupt"), 0644)
+
+	verification = repository.VerifySnapshot(snapshot)
+	if verification.IsValid() || len(verification.MissingFiles) != 1 || len(verification.CorruptFiles) != 1 {
+		t.Logf("ERROR: Expected one missing and one corrupt file, got %v", verification)
+		t.Fail()
+	}
+
+	files := ListDedupSnapshotFiles(snapshot)
+	if len(files) != 2 || files[0].Path != "a.txt" || files[0].Size != 7 {
+		t.Logf("ERROR: Snapshot files don't match the index, got %v", files)
+		t.Fail()
+	}
+}
@@ -40,6 +40,12 @@ func CreateDir(path string, mode os.FileMode) error {
 	return nil
 }
 
+// GetBackupStagePath returns the dir a backup is written and sealed in before it is renamed to the backup path,
+// a backup that fails or is killed half way is never listed as the stage name isn't a backup name
+func GetBackupStagePath(backupPath string) string {
+	return filepath.Dir(backupPath) + "/." + filepath.Base(backupPath) + ".partial"
+}
+
 func ListDir(path string) ([]os.FileInfo, error) {
 	files, err := ioutil.ReadDir(path)
 	if err != nil {
@@ -58,24 +64,13 @@ func ListBackups(path string) ([]util.Backup, error) {
 	var backups []util.Backup
 	type timeSlice []util.Backup
 
-	re := regexp.MustCompile(`(\S+)_(\S+)_(\S+)_(\S+)`)
 	for _, f := range files {
-		var backup util.Backup
-		match := re.FindStringSubmatch(f.Name())
-
-		if len(match) != 0 {
-			backup.Name = match[1]
-			backup.Policy = match[2]
-			backup.WorkflowId = match[3]
-
-			epoch := util.StringToInt(match[4])
-			backup.Epoch = epoch
-
-			timestamp := util.ConvertEpoch(match[4])
-			backup.Timestamp = timestamp
-
-			backups = append(backups, backup)
+		backup, err := util.ParseBackupName(f.Name())
+		if err != nil {
+			continue
 		}
+
+		backups = append(backups, backup)
 	}
 
 	sort.Sort(util.ByEpochBackup(backups))
@@ -88,24 +83,20 @@ func ListArchives(dirs []string) ([]util.Archive, error) {
 	var archives []util.Archive
 	type timeSlice []util.Archive
 
-	re := regexp.MustCompile(`(\S+)_(\S+)_(\S+)_(\S+)`)
 	for _, dir := range dirs {
 		var archive util.Archive
-		match := re.FindStringSubmatch(dir)
-
-		if len(match) != 0 {
-			archive.Name = match[1]
-			archive.Policy = match[2]
-			archive.WorkflowId = match[3]
-
-			epoch := util.StringToInt(match[4])
-			archive.Epoch = epoch
+		backup, err := util.ParseBackupName(dir)
+		if err != nil {
+			continue
+		}
 
-			timestamp := util.ConvertEpoch(match[4])
-			archive.Timestamp = timestamp
+		archive.Name = backup.Name
+		archive.Policy = backup.Policy
+		archive.WorkflowId = backup.WorkflowId
+		archive.Epoch = backup.Epoch
+		archive.Timestamp = backup.Timestamp
 
-			archives = append(archives, archive)
-		}
+		archives = append(archives, archive)
 	}
 
 	sort.Sort(util.ByEpochArchive(archives))
@@ -13,6 +13,8 @@ limitations under the License.
 package pluginUtil
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -35,3 +37,33 @@ func TestCreateDeleteDir(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetBackupStagePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/mariadb_daily_1570000000000001_1570000000"
+	stagePath := GetBackupStagePath(backupPath)
+	if stagePath != dir+"/.mariadb_daily_1570000000000001_1570000000.partial" {
+		t.Logf("ERROR: Unexpected stage path [%s]", stagePath)
+		t.Fail()
+	}
+
+	CreateDir(stagePath, 0755)
+	backups, err := ListBackups(dir)
+	if err != nil || len(backups) != 0 {
+		t.Logf("ERROR: Backup stage dir should not be listed as a backup, got %v", backups)
+		t.Fail()
+	}
+
+	os.Rename(stagePath, backupPath)
+	backups, err = ListBackups(dir)
+	if err != nil || len(backups) != 1 {
+		t.Logf("ERROR: Backup should be listed once renamed into place, got %v", backups)
+		t.Fail()
+	}
+}
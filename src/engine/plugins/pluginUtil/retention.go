@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package pluginUtil
+
+import (
+	"fmt"
+	"fossul/src/engine/util"
+)
+
+// DeleteExpiredBackups applies the retention rule to the backups of a policy and calls deleteBackup for every
+// expired backup. deleteBackup returns an optional detail appended to the success message, deletion stops
+// at the first error.
+func DeleteExpiredBackups(policy string, rule util.RetentionRule, backups []util.Backup, deleteBackup func(backup util.Backup, backupName string) (string, error)) util.Result {
+	var messages []util.Message
+
+	backupCount := len(util.GetBackupsByPolicy(policy, backups))
+	expiredBackups := util.GetExpiredBackupsByRule(policy, rule, backups, util.GetRetentionTime())
+
+	if len(expiredBackups) > 0 {
+		msg := util.SetMessage("INFO", fmt.Sprintf("Backup retention [%s] expires [%d] of [%d] backups", rule.String(), len(expiredBackups), backupCount))
+		messages = append(messages, msg)
+	} else {
+		msg := util.SetMessage("INFO", fmt.Sprintf("Backup deletion skipped, none of the [%d] backups expired by backup retention [%s]", backupCount, rule.String()))
+		messages = append(messages, msg)
+	}
+
+	for _, backup := range expiredBackups {
+		backupName := backup.Name + "_" + backup.Policy + "_" + backup.WorkflowId + "_" + util.IntToString(backup.Epoch)
+		msg := util.SetMessage("INFO", "Deleting backup "+backupName)
+		messages = append(messages, msg)
+
+		detail, err := deleteBackup(backup, backupName)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Backup "+backupName+" delete failed! "+err.Error())
+			messages = append(messages, msg)
+			return util.SetResult(1, messages)
+		}
+
+		msg = util.SetMessage("INFO", "Backup "+backupName+" deleted successfully"+detail)
+		messages = append(messages, msg)
+	}
+
+	return util.SetResult(0, messages)
+}
+
+// DeleteExpiredArchives applies the retention rule to the archives of a policy and calls deleteArchive for
+// every expired archive, the same way DeleteExpiredBackups does for backups.
+func DeleteExpiredArchives(policy string, rule util.RetentionRule, archives []util.Archive, deleteArchive func(archive util.Archive, archiveName string) (string, error)) util.Result {
+	var messages []util.Message
+
+	archiveCount := len(util.GetArchivesByPolicy(policy, archives))
+	expiredArchives := util.GetExpiredArchivesByRule(policy, rule, archives, util.GetRetentionTime())
+
+	if len(expiredArchives) > 0 {
+		msg := util.SetMessage("INFO", fmt.Sprintf("Archive retention [%s] expires [%d] of [%d] archives", rule.String(), len(expiredArchives), archiveCount))
+		messages = append(messages, msg)
+	} else {
+		msg := util.SetMessage("INFO", fmt.Sprintf("Archive deletion skipped, none of the [%d] archives expired by archive retention [%s]", archiveCount, rule.String()))
+		messages = append(messages, msg)
+	}
+
+	for _, archive := range expiredArchives {
+		archiveName := archive.Name + "_" + archive.Policy + "_" + archive.WorkflowId + "_" + util.IntToString(archive.Epoch)
+		msg := util.SetMessage("INFO", "Deleting archive "+archiveName)
+		messages = append(messages, msg)
+
+		detail, err := deleteArchive(archive, archiveName)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Archive "+archiveName+" delete failed! "+err.Error())
+			messages = append(messages, msg)
+			return util.SetResult(1, messages)
+		}
+
+		msg = util.SetMessage("INFO", "Archive "+archiveName+" deleted successfully"+detail)
+		messages = append(messages, msg)
+	}
+
+	return util.SetResult(0, messages)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package pluginUtil
+
+import (
+	"errors"
+	"fossul/src/engine/util"
+	"testing"
+)
+
+func TestDeleteExpiredBackups(t *testing.T) {
+	var backups []util.Backup
+	for i := 1; i <= 3; i++ {
+		backups = append(backups, util.Backup{Name: "mydb", Policy: "daily", WorkflowId: util.IntToString(i), Epoch: 1000 + i})
+	}
+
+	var deleted []string
+	result := DeleteExpiredBackups("daily", util.RetentionRule{RetentionNumber: 1}, backups, func(backup util.Backup, backupName string) (string, error) {
+		deleted = append(deleted, backupName)
+		return ", done", nil
+	})
+
+	if result.Code != 0 || len(deleted) != 2 {
+		t.Logf("ERROR: expected 2 deleted backups, got %v code %d", deleted, result.Code)
+		t.Fail()
+		return
+	}
+
+	if deleted[0] != "mydb_daily_2_1002" || deleted[1] != "mydb_daily_1_1001" {
+		t.Logf("ERROR: unexpected deleted backups %v", deleted)
+		t.Fail()
+	}
+
+	lastMsg := result.Messages[len(result.Messages)-1]
+	if lastMsg.Message != "Backup mydb_daily_1_1001 deleted successfully, done" {
+		t.Logf("ERROR: unexpected message %s", lastMsg.Message)
+		t.Fail()
+	}
+
+	deleted = nil
+	result = DeleteExpiredBackups("daily", util.RetentionRule{RetentionNumber: 1}, backups, func(backup util.Backup, backupName string) (string, error) {
+		deleted = append(deleted, backupName)
+		return "", errors.New("disk error")
+	})
+
+	if result.Code != 1 || len(deleted) != 1 {
+		t.Logf("ERROR: expected deletion to stop at the first error, got %v code %d", deleted, result.Code)
+		t.Fail()
+	}
+}
+
+func TestDeleteExpiredArchives(t *testing.T) {
+	archives := []util.Archive{
+		{Name: "mydb", Policy: "daily", WorkflowId: "1", Epoch: 1001},
+		{Name: "mydb", Policy: "weekly", WorkflowId: "2", Epoch: 1002},
+	}
+
+	var deleted []string
+	result := DeleteExpiredArchives("daily", util.RetentionRule{RetentionNumber: 0}, archives, func(archive util.Archive, archiveName string) (string, error) {
+		deleted = append(deleted, archiveName)
+		return "", nil
+	})
+
+	if result.Code != 0 || len(deleted) != 1 || deleted[0] != "mydb_daily_1_1001" {
+		t.Logf("ERROR: expected only the daily archive to be deleted, got %v code %d", deleted, result.Code)
+		t.Fail()
+	}
+}
@@ -13,12 +13,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"fossul/src/engine/client/k8s"
 	"fossul/src/engine/plugins/pluginUtil"
 	"fossul/src/engine/util"
 	"github.com/pborman/getopt/v2"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -73,34 +76,61 @@ func backup(configMap map[string]string) {
 	backupPath := util.GetBackupPathFromMap(configMap)
 	fmt.Println("INFO Backup name is " + backupName + ", Backup path is " + backupPath)
 
-	err = pluginUtil.CreateDir(backupPath, 0755)
+	// data is copied and sealed in a stage dir and only renamed to the backup path once complete
+	stagePath := pluginUtil.GetBackupStagePath(backupPath)
+	err = pluginUtil.CreateDir(stagePath, 0755)
 	checkError(err)
 
+	executor := k8s.NewPodExecutor(context.Background(), configMap["AccessWithinCluster"])
 	for _, backupSrcFilePath := range backupSrcFilePaths {
-		var args []string
-		args = append(args, configMap["CopyCmdPath"])
-		if configMap["ContainerPlatform"] == "openshift" {
-			args = append(args, "rsync")
-			args = append(args, "-n")
-			args = append(args, configMap["Namespace"])
-			args = append(args, podName+":"+backupSrcFilePath)
-		} else if configMap["ContainerPlatform"] == "kubernetes" {
-			args = append(args, "cp")
-			args = append(args, configMap["Namespace"]+"/"+podName+":"+backupSrcFilePath)
-		} else {
-			fmt.Println("ERROR incorrect parameter set for ContainerPlatform [" + configMap["ContainerPlatform"] + "]")
-			os.Exit(1)
-		}
-		args = append(args, backupPath)
-
-		result := util.ExecuteCommand(args...)
+		result := k8s.CopyFromPod(executor, podName, configMap["ContainerName"], configMap["Namespace"], backupSrcFilePath, stagePath)
 		for _, line := range result.Messages {
 			fmt.Println(line.Level, line.Message)
 		}
 
 		if result.Code != 0 {
+			os.RemoveAll(stagePath)
+			os.Exit(1)
+		}
+	}
+
+	var encoding util.BackupEncoding
+	sealOptions := util.GetSealOptionsFromMap(configMap)
+	if sealOptions.IsEnabled() {
+		sealer, err := util.NewBackupSealer(sealOptions)
+		if err != nil {
+			os.RemoveAll(stagePath)
+			checkError(err)
+		}
+
+		sealedCount, err := sealer.SealDir(stagePath)
+		if err != nil {
+			fmt.Println("ERROR Couldn't compress or encrypt backup! " + err.Error())
+			os.RemoveAll(stagePath)
 			os.Exit(1)
 		}
+
+		encoding = sealer.Encoding()
+		fmt.Println("INFO Sealed [" + util.IntToString(sealedCount) + "] backup files with " + util.GetBackupEncodingDescription(encoding))
+	}
+
+	// manifest is written before the backup is moved into place, a listed backup always has its manifest
+	manifest := util.SetBackupManifestFromMap(configMap, setPlugin(), podName, backupSrcFilePaths)
+	manifest = util.SetBackupManifestEncoding(manifest, encoding)
+	manifest, err = util.WriteStagedBackupManifest(stagePath, backupPath, manifest)
+	if err != nil {
+		fmt.Println("ERROR Couldn't write backup manifest! " + err.Error())
+		os.RemoveAll(stagePath)
+		os.Exit(1)
+	}
+	fmt.Println("INFO Backup manifest written for [" + util.IntToString(len(manifest.Files)) + "] files")
+
+	err = os.Rename(stagePath, backupPath)
+	if err != nil {
+		fmt.Println("ERROR Couldn't move backup into place! " + err.Error())
+		util.DeleteBackupManifest(backupPath)
+		os.RemoveAll(stagePath)
+		os.Exit(1)
 	}
 }
 
@@ -125,30 +155,68 @@ func restore(configMap map[string]string) {
 
 	fmt.Println("INFO Restore source path is [" + restorePath + "]")
 
-	restoreDestPath := "/tmp/" + configMap["SelectedWorkflowId"]
-
-	var args []string
-	args = append(args, configMap["CopyCmdPath"])
-	if configMap["ContainerPlatform"] == "openshift" {
-		args = append(args, "rsync")
-		args = append(args, "-n")
-		args = append(args, configMap["Namespace"])
-		args = append(args, restorePath)
-		args = append(args, podName+":"+restoreDestPath)
-	} else if configMap["ContainerPlatform"] == "kubernetes" {
-		args = append(args, "cp")
-		args = append(args, restorePath)
-		args = append(args, configMap["Namespace"]+"/"+podName+":"+restoreDestPath)
-	} else {
-		fmt.Println("ERROR incorrect parameter set for ContainerPlatform [" + configMap["ContainerPlatform"] + "]")
-		os.Exit(1)
+	sealed, err := util.IsSealedBackup(restorePath)
+	checkError(err)
+
+	// an encrypted backup is always opened so a file that isn't sealed and encrypted is rejected
+	encoding, err := util.GetBackupEncoding(restorePath)
+	checkError(err)
+	sealed = sealed || encoding.Encryption != ""
+
+	// partial and sealed restores are staged, the backup itself is never modified
+	var stageDir string
+	if configMap["RestorePaths"] != "" || sealed {
+		stageDir, err = ioutil.TempDir("", "fossul")
+		checkError(err)
+	}
+
+	// partial restore, only the selected paths are staged and copied to the pod
+	if configMap["RestorePaths"] != "" {
+		restorePath, err = util.StageRestorePaths(restorePath, stageDir, strings.Split(configMap["RestorePaths"], ","))
+		if err != nil {
+			os.RemoveAll(stageDir)
+		}
+		checkError(err)
+
+		fmt.Println("INFO Restoring selected paths [" + configMap["RestorePaths"] + "]")
+	} else if sealed {
+		restorePath, err = util.StageBackup(restorePath, stageDir)
+		if err != nil {
+			os.RemoveAll(stageDir)
+		}
+		checkError(err)
+	}
+
+	if sealed {
+		sealer, err := util.NewBackupSealer(util.GetSealOptionsFromMap(configMap))
+		if err != nil {
+			os.RemoveAll(stageDir)
+		}
+		checkError(err)
+
+		openedCount, err := sealer.OpenDir(restorePath, encoding)
+		if err != nil {
+			os.RemoveAll(stageDir)
+			fmt.Println("ERROR Couldn't decrypt or decompress backup! " + err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Println("INFO Opened [" + util.IntToString(openedCount) + "] sealed backup files")
 	}
 
-	result := util.ExecuteCommand(args...)
+	restoreDestPath := util.GetRestoreDestPathFromMap(configMap)
+	fmt.Println("INFO Restore destination path is [" + restoreDestPath + "]")
+
+	executor := k8s.NewPodExecutor(context.Background(), configMap["AccessWithinCluster"])
+	result := k8s.CopyToPod(executor, podName, configMap["ContainerName"], configMap["Namespace"], restorePath, restoreDestPath)
 	for _, line := range result.Messages {
 		fmt.Println(line.Level, line.Message)
 	}
 
+	if stageDir != "" {
+		os.RemoveAll(stageDir)
+	}
+
 	if result.Code != 0 {
 		os.Exit(1)
 	}
@@ -174,33 +242,28 @@ func backupDelete(configMap map[string]string) {
 	backups, err := pluginUtil.ListBackups(backupDir)
 	checkError(err)
 
-	backupsByPolicy := util.GetBackupsByPolicy(configMap["BackupPolicy"], backups)
-	backupCount := len(backupsByPolicy)
-	backupRetentionCount := util.StringToInt(configMap["BackupRetention"])
-
-	if backupCount > backupRetentionCount {
-		count := 1
-		for backup := range pluginUtil.ReverseBackupList(backupsByPolicy) {
-			if count > backupRetentionCount {
-				msg := fmt.Sprintf("Number of backups [%d] greater than backup retention [%d]", backupCount, backupRetentionCount)
-				fmt.Println(msg)
-				backupCount = backupCount - 1
-
-				backupName := backup.Name + "_" + backup.Policy + "_" + backup.WorkflowId + "_" + util.IntToString(backup.Epoch)
-				fmt.Println("INFO Deleting backup " + backupName)
-				backupPath := backupDir + "/" + backupName
-				err := pluginUtil.RecursiveDirDelete(backupPath)
-				if err != nil {
-					fmt.Println("ERROR Backup " + backupName + " delete failed! " + err.Error())
-					os.Exit(1)
-				}
-				fmt.Println("INFO Backup " + backupName + " deleted successfully")
-			}
-			count = count + 1
+	retentionRule := getRetentionRule(configMap)
+	result := pluginUtil.DeleteExpiredBackups(configMap["BackupPolicy"], retentionRule, backups, func(backup util.Backup, backupName string) (string, error) {
+		backupPath := backupDir + "/" + backupName
+		err := pluginUtil.RecursiveDirDelete(backupPath)
+		if err != nil {
+			return "", err
 		}
-	} else {
-		msg := fmt.Sprintf("INFO Backup deletion skipped, there are [%d] backups but backup retention is [%d]", backupCount, backupRetentionCount)
-		fmt.Println(msg)
+
+		err = util.DeleteBackupManifest(backupPath)
+		if err != nil {
+			return "", errors.New("Couldn't delete manifest! " + err.Error())
+		}
+
+		return "", nil
+	})
+
+	for _, msg := range result.Messages {
+		fmt.Println(msg.Level + " " + msg.Message)
+	}
+
+	if result.Code != 0 {
+		os.Exit(1)
 	}
 }
 
@@ -217,7 +280,7 @@ func info() {
 
 func setPlugin() (plugin util.Plugin) {
 	plugin.Name = "container-basic"
-	plugin.Description = "Container Backup Plugin that uses rsync to backup a pod"
+	plugin.Description = "Container Backup Plugin that copies the data of a pod with tar over the exec API"
 	plugin.Version = "1.0.0"
 	plugin.Type = "storage"
 
@@ -265,14 +328,18 @@ func getEnvParams() map[string]string {
 	configMap["LogFilePaths"] = os.Getenv("LogFilePaths")
 	configMap["BackupPolicy"] = os.Getenv("BackupPolicy")
 	configMap["BackupRetention"] = os.Getenv("BackupRetention")
+	configMap["BackupRetentionRule"] = os.Getenv("BackupRetentionRule")
 	configMap["BackupName"] = os.Getenv("BackupName")
-	configMap["ContainerPlatform"] = os.Getenv("ContainerPlatform")
 	configMap["AccessWithinCluster"] = os.Getenv("AccessWithinCluster")
 	configMap["Namespace"] = os.Getenv("Namespace")
 	configMap["ServiceName"] = os.Getenv("ServiceName")
-	configMap["CopyCmdPath"] = os.Getenv("CopyCmdPath")
+	configMap["ContainerName"] = os.Getenv("ContainerName")
 	configMap["BackupSrcPaths"] = os.Getenv("BackupSrcPaths")
 	configMap["BackupDestPath"] = os.Getenv("BackupDestPath")
+	configMap["RestoreDestPath"] = os.Getenv("RestoreDestPath")
+	configMap["RestorePaths"] = os.Getenv("RestorePaths")
+	configMap["BackupCompression"] = os.Getenv("BackupCompression")
+	configMap["BackupEncryptionKeyFile"] = os.Getenv("BackupEncryptionKeyFile")
 
 	return configMap
 }
@@ -290,6 +357,16 @@ func checkError(err error) {
 	}
 }
 
+// getRetentionRule reads the retention rule passed by the storage service, BackupRetention is used if it is missing
+func getRetentionRule(configMap map[string]string) util.RetentionRule {
+	rule, err := util.JsonToRetentionRule(configMap["BackupRetentionRule"])
+	if err != nil {
+		return util.RetentionRule{RetentionNumber: util.StringToInt(configMap["BackupRetention"])}
+	}
+
+	return rule
+}
+
 func getBackupSrcPaths(configMap map[string]string) []string {
 	var backupSrcFilePaths []string
 	if configMap["AutoDiscovery"] == "true" {
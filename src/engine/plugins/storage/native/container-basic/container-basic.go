@@ -13,10 +13,13 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"fossul/src/engine/client/k8s"
 	"fossul/src/engine/plugins/pluginUtil"
 	"fossul/src/engine/util"
+	"io/ioutil"
+	"os"
 	"strings"
 )
 
@@ -60,41 +63,72 @@ func (s storagePlugin) Backup(config util.Config) util.Result {
 	msg = util.SetMessage("INFO", "Backup name is "+backupName+", Backup path is "+backupPath)
 	messages = append(messages, msg)
 
-	err = pluginUtil.CreateDir(backupPath, 0755)
+	// data is copied and sealed in a stage dir and only renamed to the backup path once complete
+	stagePath := pluginUtil.GetBackupStagePath(backupPath)
+	err = pluginUtil.CreateDir(stagePath, 0755)
 	if err != nil {
 		msg = util.SetMessage("ERROR", err.Error())
 		messages = append(messages, msg)
 		result = util.SetResult(1, messages)
 		return result
 	}
+	defer os.RemoveAll(stagePath)
 
+	executor := k8s.NewPodExecutor(config.GetContext(), config.StoragePluginParameters["AccessWithinCluster"])
 	for _, backupSrcFilePath := range backupSrcFilePaths {
-		var args []string
-		args = append(args, config.StoragePluginParameters["CopyCmdPath"])
-		if config.StoragePluginParameters["ContainerPlatform"] == "openshift" {
-			args = append(args, "rsync")
-			args = append(args, "-n")
-			args = append(args, config.StoragePluginParameters["Namespace"])
-			args = append(args, podName+":"+backupSrcFilePath)
-		} else if config.StoragePluginParameters["ContainerPlatform"] == "kubernetes" {
-			args = append(args, "cp")
-			args = append(args, config.StoragePluginParameters["Namespace"]+"/"+podName+":"+config.StoragePluginParameters["BackupSrcPath"])
+		cmdResult := k8s.CopyFromPod(executor, podName, config.StoragePluginParameters["ContainerName"], config.StoragePluginParameters["Namespace"], backupSrcFilePath, stagePath)
+		if cmdResult.Code != 0 {
+			return cmdResult
 		} else {
-			msg = util.SetMessage("ERROR", "Incorrect parameter set for ContainerPlatform ["+config.StoragePluginParameters["ContainerPlatform"]+"]")
+			messages = util.PrependMessages(cmdResult.Messages, messages)
+		}
+	}
+
+	var encoding util.BackupEncoding
+	sealOptions := util.GetSealOptions(config)
+	if sealOptions.IsEnabled() {
+		sealer, err := util.NewBackupSealer(sealOptions)
+		if err != nil {
+			msg = util.SetMessage("ERROR", err.Error())
 			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
 
+		sealedCount, err := sealer.SealDir(stagePath)
+		if err != nil {
+			msg = util.SetMessage("ERROR", "Couldn't compress or encrypt backup! "+err.Error())
+			messages = append(messages, msg)
 			result = util.SetResult(1, messages)
 			return result
 		}
 
-		args = append(args, backupPath)
+		encoding = sealer.Encoding()
+		msg = util.SetMessage("INFO", "Sealed ["+util.IntToString(sealedCount)+"] backup files with "+util.GetBackupEncodingDescription(encoding))
+		messages = append(messages, msg)
+	}
 
-		cmdResult := util.ExecuteCommand(args...)
-		if cmdResult.Code != 0 {
-			return cmdResult
-		} else {
-			messages = util.PrependMessages(cmdResult.Messages, messages)
-		}
+	// manifest is written before the backup is moved into place, a listed backup always has its manifest
+	manifest := util.SetBackupManifest(config, setPlugin(), podName, backupSrcFilePaths)
+	manifest = util.SetBackupManifestEncoding(manifest, encoding)
+	manifest, err = util.WriteStagedBackupManifest(stagePath, backupPath, manifest)
+	if err != nil {
+		msg = util.SetMessage("ERROR", "Couldn't write backup manifest! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	msg = util.SetMessage("INFO", "Backup manifest written for ["+util.IntToString(len(manifest.Files))+"] files")
+	messages = append(messages, msg)
+
+	err = os.Rename(stagePath, backupPath)
+	if err != nil {
+		util.DeleteBackupManifest(backupPath)
+		msg = util.SetMessage("ERROR", "Couldn't move backup into place! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
 	}
 
 	result = util.SetResult(0, messages)
@@ -129,7 +163,7 @@ func (s storagePlugin) Restore(config util.Config) util.Result {
 	}
 
 	if restorePath == "" {
-		msg = util.SetMessage("ERROR", "Restore data no longer available for workflow id ["+util.IntToString(config.SelectedWorkflowId)+"], check retention policy")
+		msg = util.SetMessage("ERROR", "Restore data no longer available for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"], check retention policy")
 		messages = append(messages, msg)
 		result = util.SetResult(1, messages)
 		return result
@@ -138,29 +172,86 @@ func (s storagePlugin) Restore(config util.Config) util.Result {
 	msg = util.SetMessage("INFO", "Restore source path is ["+restorePath+"]")
 	messages = append(messages, msg)
 
-	restoreDestPath := "/tmp/" + util.IntToString(config.SelectedWorkflowId)
-
-	var args []string
-	args = append(args, config.StoragePluginParameters["CopyCmdPath"])
-	if config.StoragePluginParameters["ContainerPlatform"] == "openshift" {
-		args = append(args, "rsync")
-		args = append(args, "-n")
-		args = append(args, config.StoragePluginParameters["Namespace"])
-		args = append(args, restorePath)
-		args = append(args, podName+":"+restoreDestPath)
-	} else if config.StoragePluginParameters["ContainerPlatform"] == "kubernetes" {
-		args = append(args, "cp")
-		args = append(args, restorePath)
-		args = append(args, config.StoragePluginParameters["Namespace"]+"/"+podName+":"+restoreDestPath)
-	} else {
-		msg = util.SetMessage("ERROR", "Incorrect parameter set for ContainerPlatform ["+config.StoragePluginParameters["ContainerPlatform"]+"]")
+	sealed, err := util.IsSealedBackup(restorePath)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
 		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
 
+	// an encrypted backup is always opened so a file that isn't sealed and encrypted is rejected
+	encoding, err := util.GetBackupEncoding(restorePath)
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Couldn't read backup manifest! "+err.Error())
+		messages = append(messages, msg)
 		result = util.SetResult(1, messages)
 		return result
 	}
+	sealed = sealed || encoding.Encryption != ""
+
+	// partial and sealed restores are staged, the backup itself is never modified
+	var stageDir string
+	if len(config.RestorePaths) > 0 || sealed {
+		stageDir, err = ioutil.TempDir("", "fossul")
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+		defer os.RemoveAll(stageDir)
+	}
+
+	// partial restore, only the selected paths are staged and copied to the pod
+	if len(config.RestorePaths) > 0 {
+		restorePath, err = util.StageRestorePaths(restorePath, stageDir, config.RestorePaths)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		msg = util.SetMessage("INFO", "Restoring selected paths ["+strings.Join(config.RestorePaths, ",")+"]")
+		messages = append(messages, msg)
+	} else if sealed {
+		restorePath, err = util.StageBackup(restorePath, stageDir)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+	}
+
+	if sealed {
+		sealer, err := util.NewBackupSealer(util.GetSealOptions(config))
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
 
-	cmdResult := util.ExecuteCommand(args...)
+		openedCount, err := sealer.OpenDir(restorePath, encoding)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Couldn't decrypt or decompress backup! "+err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		msg = util.SetMessage("INFO", "Opened ["+util.IntToString(openedCount)+"] sealed backup files")
+		messages = append(messages, msg)
+	}
+
+	restoreDestPath := util.GetRestoreDestPath(config)
+	msg = util.SetMessage("INFO", "Restore destination path is ["+restoreDestPath+"]")
+	messages = append(messages, msg)
+
+	executor := k8s.NewPodExecutor(config.GetContext(), config.StoragePluginParameters["AccessWithinCluster"])
+	cmdResult := k8s.CopyToPod(executor, podName, config.StoragePluginParameters["ContainerName"], config.StoragePluginParameters["Namespace"], restorePath, restoreDestPath)
 	if cmdResult.Code != 0 {
 		return cmdResult
 	} else {
@@ -174,7 +265,6 @@ func (s storagePlugin) Restore(config util.Config) util.Result {
 func (s storagePlugin) BackupDelete(config util.Config) util.Result {
 	var result util.Result
 	var messages []util.Message
-	var resultCode int = 0
 
 	backupDir := util.GetBackupDirFromConfig(config)
 	backups, err := pluginUtil.ListBackups(backupDir)
@@ -185,40 +275,24 @@ func (s storagePlugin) BackupDelete(config util.Config) util.Result {
 		return result
 	}
 
-	backupsByPolicy := util.GetBackupsByPolicy(config.SelectedBackupPolicy, backups)
-	backupCount := len(backupsByPolicy)
-
-	if backupCount > config.SelectedBackupRetention {
-		count := 1
-		for backup := range pluginUtil.ReverseBackupList(backupsByPolicy) {
-			if count > config.SelectedBackupRetention {
-				msg := util.SetMessage("INFO", fmt.Sprintf("Number of backups [%d] greater than backup retention [%d]", backupCount, config.SelectedBackupRetention))
-				messages = append(messages, msg)
-				backupCount = backupCount - 1
-
-				backupName := backup.Name + "_" + backup.Policy + "_" + backup.WorkflowId + "_" + util.IntToString(backup.Epoch)
-				msg = util.SetMessage("INFO", "Deleting backup "+backupName)
-				messages = append(messages, msg)
-
-				backupPath := backupDir + "/" + backupName
-				err := pluginUtil.RecursiveDirDelete(backupPath)
-				if err != nil {
-					msg := util.SetMessage("ERROR", "Backup "+backupName+" delete failed! "+err.Error())
-					messages = append(messages, msg)
-					result = util.SetResult(1, messages)
-					return result
-				}
-				msg = util.SetMessage("INFO", "Backup "+backupName+" deleted successfully")
-				messages = append(messages, msg)
-			}
-			count = count + 1
+	retentionRule := util.GetBackupRetentionRule(config)
+	deleteResult := pluginUtil.DeleteExpiredBackups(config.SelectedBackupPolicy, retentionRule, backups, func(backup util.Backup, backupName string) (string, error) {
+		backupPath := backupDir + "/" + backupName
+		err := pluginUtil.RecursiveDirDelete(backupPath)
+		if err != nil {
+			return "", err
 		}
-	} else {
-		msg := util.SetMessage("INFO", fmt.Sprintf("Backup deletion skipped, there are [%d] backups but backup retention is [%d]", backupCount, config.SelectedBackupRetention))
-		messages = append(messages, msg)
-	}
 
-	result = util.SetResult(resultCode, messages)
+		err = util.DeleteBackupManifest(backupPath)
+		if err != nil {
+			return "", errors.New("Couldn't delete manifest! " + err.Error())
+		}
+
+		return "", nil
+	})
+	messages = append(messages, deleteResult.Messages...)
+
+	result = util.SetResult(deleteResult.Code, messages)
 	return result
 }
 
@@ -252,7 +326,7 @@ func (s storagePlugin) Info() util.Plugin {
 
 func setPlugin() (plugin util.Plugin) {
 	plugin.Name = "container-basic"
-	plugin.Description = "Container Backup Plugin that uses rsync to backup a pod"
+	plugin.Description = "Container Backup Plugin that copies the data of a pod with tar over the exec API"
 	plugin.Version = "1.0.0"
 	plugin.Type = "storage"
 
@@ -0,0 +1,356 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"fossul/src/engine/client/k8s"
+	"fossul/src/engine/plugins/pluginUtil"
+	"fossul/src/engine/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type storagePlugin string
+
+var StoragePlugin storagePlugin
+
+func (s storagePlugin) SetEnv(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+	var resultCode int = 0
+
+	result = util.SetResult(resultCode, messages)
+
+	return result
+}
+
+func (s storagePlugin) Backup(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+	backupSrcFilePaths := getBackupSrcPaths(config)
+
+	msg := util.SetMessage("INFO", "Performing container dedup backup")
+	messages = append(messages, msg)
+
+	podName, err := k8s.GetPod(config.StoragePluginParameters["Namespace"], config.StoragePluginParameters["ServiceName"], config.StoragePluginParameters["AccessWithinCluster"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	msg = util.SetMessage("INFO", "Performing backup for pod "+podName)
+	messages = append(messages, msg)
+
+	timestampToString := fmt.Sprintf("%d", config.WorkflowTimestamp)
+	backupName := util.GetBackupName(config.StoragePluginParameters["BackupName"], config.SelectedBackupPolicy, config.WorkflowId, timestampToString)
+	backupDir := util.GetBackupDirFromConfig(config)
+	backupPath := util.GetBackupPathFromConfig(config)
+	msg = util.SetMessage("INFO", "Backup name is "+backupName+", Backup path is "+backupPath)
+	messages = append(messages, msg)
+
+	// data is copied from the pod to a staging dir first and then chunked into the repository
+	stageDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+	defer os.RemoveAll(stageDir)
+
+	executor := k8s.NewPodExecutor(config.GetContext(), config.StoragePluginParameters["AccessWithinCluster"])
+	for _, backupSrcFilePath := range backupSrcFilePaths {
+		cmdResult := k8s.CopyFromPod(executor, podName, config.StoragePluginParameters["ContainerName"], config.StoragePluginParameters["Namespace"], backupSrcFilePath, stageDir)
+		if cmdResult.Code != 0 {
+			return cmdResult
+		} else {
+			messages = util.PrependMessages(cmdResult.Messages, messages)
+		}
+	}
+
+	repository := pluginUtil.NewDedupRepository(backupDir)
+	sealOptions := util.GetSealOptions(config)
+	if sealOptions.IsEnabled() {
+		repository.Sealer, err = util.NewBackupSealer(sealOptions)
+		if err != nil {
+			msg = util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		msg = util.SetMessage("INFO", "New chunks are sealed with "+util.GetBackupEncodingDescription(repository.Sealer.Encoding()))
+		messages = append(messages, msg)
+	}
+
+	snapshot, newChunks, err := repository.Store(stageDir)
+	if err != nil {
+		msg = util.SetMessage("ERROR", "Couldn't store backup in chunk repository ["+repository.Path+"]! "+err.Error())
+		messages = append(messages, msg)
+		messages = append(messages, cleanupChunks(repository, newChunks)...)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	snapshot.BackupName = backupName
+	snapshot.Timestamp = config.WorkflowTimestamp
+	err = pluginUtil.WriteDedupSnapshot(backupPath, snapshot)
+	if err != nil {
+		msg = util.SetMessage("ERROR", "Couldn't write snapshot for backup "+backupName+"! "+err.Error())
+		messages = append(messages, msg)
+		messages = append(messages, cleanupChunks(repository, newChunks)...)
+		pluginUtil.RecursiveDirDelete(backupPath)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	msg = util.SetMessage("INFO", fmt.Sprintf("Backup snapshot has [%d] files, [%d] bytes in [%d] chunks", len(snapshot.Files), snapshot.TotalSize, snapshot.ChunkCount))
+	messages = append(messages, msg)
+
+	msg = util.SetMessage("INFO", fmt.Sprintf("Stored [%d] new chunks with [%d] bytes, [%d] chunks were deduplicated", snapshot.NewChunks, snapshot.NewChunkSize, snapshot.ChunkCount-snapshot.NewChunks))
+	messages = append(messages, msg)
+
+	result = util.SetResult(0, messages)
+	return result
+}
+
+func (s storagePlugin) Restore(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+
+	msg := util.SetMessage("INFO", "Performing container dedup restore")
+	messages = append(messages, msg)
+
+	podName, err := k8s.GetPod(config.StoragePluginParameters["Namespace"], config.StoragePluginParameters["ServiceName"], config.StoragePluginParameters["AccessWithinCluster"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	msg = util.SetMessage("INFO", "Performing restore for pod "+podName)
+	messages = append(messages, msg)
+
+	restorePath, err := util.GetRestoreSrcPath(config)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	if restorePath == "" {
+		msg = util.SetMessage("ERROR", "Restore data no longer available for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"], check retention policy")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	snapshot, err := pluginUtil.ReadDedupSnapshot(restorePath)
+	if err != nil {
+		msg = util.SetMessage("ERROR", "Couldn't read snapshot of backup ["+restorePath+"]! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	// the backup is rebuilt from its chunks into a staging dir named like the backup, same layout as a container-basic restore
+	stageDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+	defer os.RemoveAll(stageDir)
+
+	stagePath := stageDir + "/" + filepath.Base(restorePath)
+	// sealed chunks are opened with the key of their key id, the sealer is needed even if sealing is now disabled
+	repository := pluginUtil.NewDedupRepository(util.GetBackupDirFromConfig(config))
+	repository.Sealer, err = util.NewBackupSealer(util.GetSealOptions(config))
+	if err != nil {
+		msg = util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	fileCount, err := repository.Restore(snapshot, stagePath, config.RestorePaths)
+	if err != nil {
+		msg = util.SetMessage("ERROR", "Couldn't rebuild backup ["+snapshot.BackupName+"] from chunk repository! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	if len(config.RestorePaths) > 0 {
+		msg = util.SetMessage("INFO", "Restoring selected paths ["+strings.Join(config.RestorePaths, ",")+"]")
+		messages = append(messages, msg)
+	}
+
+	msg = util.SetMessage("INFO", fmt.Sprintf("Rebuilt [%d] files of backup [%s] from chunk repository", fileCount, snapshot.BackupName))
+	messages = append(messages, msg)
+
+	restoreDestPath := util.GetRestoreDestPath(config)
+	msg = util.SetMessage("INFO", "Restore destination path is ["+restoreDestPath+"]")
+	messages = append(messages, msg)
+
+	executor := k8s.NewPodExecutor(config.GetContext(), config.StoragePluginParameters["AccessWithinCluster"])
+	cmdResult := k8s.CopyToPod(executor, podName, config.StoragePluginParameters["ContainerName"], config.StoragePluginParameters["Namespace"], stagePath, restoreDestPath)
+	if cmdResult.Code != 0 {
+		return cmdResult
+	} else {
+		messages = util.PrependMessages(cmdResult.Messages, messages)
+	}
+
+	result = util.SetResult(0, messages)
+	return result
+}
+
+func (s storagePlugin) BackupDelete(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+
+	backupDir := util.GetBackupDirFromConfig(config)
+	backups, err := pluginUtil.ListBackups(backupDir)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	retentionRule := util.GetBackupRetentionRule(config)
+	repository := pluginUtil.NewDedupRepository(backupDir)
+	deleteResult := pluginUtil.DeleteExpiredBackups(config.SelectedBackupPolicy, retentionRule, backups, func(backup util.Backup, backupName string) (string, error) {
+		freedChunks, freedSize, err := repository.DeleteSnapshot(backupDir, backupName)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf(", [%d] unreferenced chunks with [%d] bytes removed", freedChunks, freedSize), nil
+	})
+	messages = append(messages, deleteResult.Messages...)
+
+	result = util.SetResult(deleteResult.Code, messages)
+	return result
+}
+
+func (s storagePlugin) BackupList(config util.Config) util.Backups {
+	var backups util.Backups
+	var result util.Result
+	var messages []util.Message
+
+	backupDir := util.GetBackupDirFromConfig(config)
+	backupList, err := pluginUtil.ListBackups(backupDir)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		backups.Result = result
+
+		return backups
+	}
+
+	result = util.SetResult(0, messages)
+	backups.Result = result
+	backups.Backups = backupList
+
+	return backups
+}
+
+func (s storagePlugin) Info() util.Plugin {
+	var plugin util.Plugin = setPlugin()
+	return plugin
+}
+
+func setPlugin() (plugin util.Plugin) {
+	plugin.Name = "container-dedup"
+	plugin.Description = "Container Backup Plugin that stores deduplicated chunks of a pod's data"
+	plugin.Version = "1.0.0"
+	plugin.Type = "storage"
+
+	var capabilities []util.Capability
+	var backupCap util.Capability
+	backupCap.Name = "backup"
+
+	var backupListCap util.Capability
+	backupListCap.Name = "backupList"
+
+	var backupDeleteCap util.Capability
+	backupDeleteCap.Name = "backupDelete"
+
+	var restoreCap util.Capability
+	restoreCap.Name = "restore"
+
+	var infoCap util.Capability
+	infoCap.Name = "info"
+
+	capabilities = append(capabilities, backupCap, backupListCap, backupDeleteCap, restoreCap, infoCap)
+
+	plugin.Capabilities = capabilities
+
+	return plugin
+}
+
+// cleanupChunks removes the new chunks of a failed backup, no snapshot references them
+func cleanupChunks(repository pluginUtil.DedupRepository, newChunks []string) []util.Message {
+	var messages []util.Message
+
+	err := repository.DeleteChunks(newChunks)
+	if err != nil {
+		msg := util.SetMessage("WARN", "Couldn't remove chunks of failed backup! "+err.Error())
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
+
+func getBackupSrcPaths(config util.Config) []string {
+	var backupSrcFilePaths []string
+
+	if config.AutoDiscovery == true {
+		dataPaths := strings.Split(config.StoragePluginParameters["DataFilePaths"], ",")
+		logPaths := strings.Split(config.StoragePluginParameters["LogFilePaths"], ",")
+
+		for _, dataPath := range dataPaths {
+			if dataPath == "" {
+				continue
+			}
+
+			backupSrcFilePaths = append(backupSrcFilePaths, dataPath)
+		}
+
+		for _, logPath := range logPaths {
+			if logPath == "" {
+				continue
+			}
+
+			backupSrcFilePaths = append(backupSrcFilePaths, logPath)
+		}
+	} else {
+		backupSrcFilePaths = strings.Split(config.StoragePluginParameters["BackupSrcPaths"], ",")
+	}
+
+	return backupSrcFilePaths
+}
+
+func main() {}
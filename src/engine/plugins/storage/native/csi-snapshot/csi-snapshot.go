@@ -0,0 +1,377 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"fossul/src/engine/client/k8s"
+	"fossul/src/engine/plugins/pluginUtil"
+	"fossul/src/engine/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSnapshotTimeout = 300
+
+// app plugins restoring a dump the storage plugin copies into the pod
+var dumpAppPlugins = []string{
+	"mariadb-dump",
+	"postgres-dump",
+	"mongo-dump",
+}
+
+type storagePlugin string
+
+var StoragePlugin storagePlugin
+
+func (s storagePlugin) SetEnv(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+	var resultCode int = 0
+
+	result = util.SetResult(resultCode, messages)
+
+	return result
+}
+
+// Backup snapshots every PVC of the pod, it runs between quiesce and unquiesce and returns once all
+// snapshots are cut so the application is only quiesced as long as needed
+func (s storagePlugin) Backup(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+
+	msg := util.SetMessage("INFO", "Performing CSI volume snapshot backup")
+	messages = append(messages, msg)
+
+	namespace := config.StoragePluginParameters["Namespace"]
+	podName, err := k8s.GetPod(namespace, config.StoragePluginParameters["ServiceName"], config.StoragePluginParameters["AccessWithinCluster"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	msg = util.SetMessage("INFO", "Performing backup for pod "+podName)
+	messages = append(messages, msg)
+
+	client, err := k8s.NewSnapshotClient(config.StoragePluginParameters["AccessWithinCluster"], config.StoragePluginParameters["SnapshotApiVersion"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	pvcs, err := client.GetPodPvcs(namespace, podName)
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Couldn't get pvcs of pod ["+podName+"]! "+err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	if len(pvcs) == 0 {
+		msg := util.SetMessage("ERROR", "Pod ["+podName+"] has no persistent volume claims to snapshot")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	var backup util.Backup
+	backup.Name = config.StoragePluginParameters["BackupName"]
+	backup.Policy = config.SelectedBackupPolicy
+	backup.WorkflowId = config.WorkflowId
+	backup.Epoch = int(config.WorkflowTimestamp)
+
+	timestampToString := fmt.Sprintf("%d", config.WorkflowTimestamp)
+	backupName := util.GetBackupName(backup.Name, backup.Policy, backup.WorkflowId, timestampToString)
+	msg = util.SetMessage("INFO", "Backup name is "+backupName)
+	messages = append(messages, msg)
+
+	var snapshotNames []string
+	for _, pvc := range pvcs {
+		snapshotName, err := client.CreateVolumeSnapshot(namespace, config.StoragePluginParameters["SnapshotClassName"], pvc, backup, config.ProfileName, config.ConfigName)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Couldn't create snapshot of pvc ["+pvc.Name+"]! "+err.Error())
+			messages = append(messages, msg)
+			messages = append(messages, deleteSnapshots(client, namespace, snapshotNames)...)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		snapshotNames = append(snapshotNames, snapshotName)
+		msg = util.SetMessage("INFO", "Created snapshot ["+snapshotName+"] of pvc ["+pvc.Name+"]")
+		messages = append(messages, msg)
+	}
+
+	waitReady := config.StoragePluginParameters["WaitForReadyToUse"] == "true"
+	timeout := getSnapshotTimeout(config)
+	for _, snapshotName := range snapshotNames {
+		snapshot, err := client.WaitForVolumeSnapshot(namespace, snapshotName, waitReady, timeout)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, msg)
+			messages = append(messages, deleteSnapshots(client, namespace, snapshotNames)...)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		msg = util.SetMessage("INFO", "Snapshot ["+snapshotName+"] is cut, ready to use ["+util.BoolToString(snapshot.ReadyToUse)+"]")
+		messages = append(messages, msg)
+	}
+
+	result = util.SetResult(0, messages)
+	return result
+}
+
+// Restore creates a PVC from each snapshot of the backup, the application is pointed at the new PVCs by
+// the app plugin or the restore commands
+func (s storagePlugin) Restore(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+
+	msg := util.SetMessage("INFO", "Performing CSI volume snapshot restore")
+	messages = append(messages, msg)
+
+	if len(config.RestorePaths) > 0 {
+		msg := util.SetMessage("ERROR", "Partial restore is not supported for volume snapshots, a restore creates complete pvcs")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	// the application isn't switched to the restored pvcs, a dump is never copied into the pod
+	appPlugin := strings.TrimSuffix(config.AppPlugin, ".so")
+	if util.ExistsInArray(dumpAppPlugins, appPlugin) {
+		msg := util.SetMessage("ERROR", "Restore of volume snapshots only creates new pvcs, it can't be combined with app plugin ["+appPlugin+"] which restores a dump copied into the pod")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	// volume snapshots are namespaced, pvcs can only be created from them in the namespace of the application
+	namespace := config.StoragePluginParameters["Namespace"]
+	if sourceNamespace := config.StoragePluginParameters["SourceNamespace"]; sourceNamespace != "" && sourceNamespace != namespace {
+		msg := util.SetMessage("ERROR", "Volume snapshots of namespace ["+sourceNamespace+"] can't be restored into namespace ["+namespace+"]")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	client, err := k8s.NewSnapshotClient(config.StoragePluginParameters["AccessWithinCluster"], config.StoragePluginParameters["SnapshotApiVersion"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	backups, err := client.ListSnapshotBackups(namespace, config.ProfileName, config.ConfigName)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	workflowId := util.Int64ToString(config.SelectedWorkflowId)
+	snapshotBackup, found := getSnapshotBackup(backups, config.StoragePluginParameters["BackupName"], config.SelectedBackupPolicy, workflowId)
+	if !found {
+		msg = util.SetMessage("ERROR", "Restore data no longer available for workflow id ["+workflowId+"], check retention policy")
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	for _, snapshot := range snapshotBackup.Snapshots {
+		if !snapshot.ReadyToUse {
+			msg := util.SetMessage("ERROR", "Snapshot ["+snapshot.Name+"] is not ready to use")
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+	}
+
+	for _, snapshot := range snapshotBackup.Snapshots {
+		pvcName := k8s.GetResourceName(snapshot.PvcName + "-restore-" + workflowId)
+		err := client.CreatePvcFromSnapshot(namespace, pvcName, config.StoragePluginParameters["RestoreStorageClass"], snapshot)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Couldn't create pvc ["+pvcName+"] from snapshot ["+snapshot.Name+"]! "+err.Error())
+			messages = append(messages, msg)
+			result = util.SetResult(1, messages)
+			return result
+		}
+
+		msg = util.SetMessage("INFO", "Created pvc ["+pvcName+"] from snapshot ["+snapshot.Name+"] of pvc ["+snapshot.PvcName+"]")
+		messages = append(messages, msg)
+	}
+
+	result = util.SetResult(0, messages)
+	return result
+}
+
+func (s storagePlugin) BackupDelete(config util.Config) util.Result {
+	var result util.Result
+	var messages []util.Message
+
+	namespace := config.StoragePluginParameters["Namespace"]
+	client, err := k8s.NewSnapshotClient(config.StoragePluginParameters["AccessWithinCluster"], config.StoragePluginParameters["SnapshotApiVersion"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	snapshotBackups, err := client.ListSnapshotBackups(namespace, config.ProfileName, config.ConfigName)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		return result
+	}
+
+	backups := getBackups(snapshotBackups)
+	retentionRule := util.GetBackupRetentionRule(config)
+	deleteResult := pluginUtil.DeleteExpiredBackups(config.SelectedBackupPolicy, retentionRule, backups, func(backup util.Backup, backupName string) (string, error) {
+		snapshotBackup, _ := getSnapshotBackup(snapshotBackups, backup.Name, backup.Policy, backup.WorkflowId)
+		for _, snapshot := range snapshotBackup.Snapshots {
+			err := client.DeleteVolumeSnapshot(namespace, snapshot.Name)
+			if err != nil {
+				return "", errors.New("Snapshot [" + snapshot.Name + "] delete failed! " + err.Error())
+			}
+		}
+
+		return ", [" + util.IntToString(len(snapshotBackup.Snapshots)) + "] snapshots removed", nil
+	})
+	messages = append(messages, deleteResult.Messages...)
+
+	result = util.SetResult(deleteResult.Code, messages)
+	return result
+}
+
+func (s storagePlugin) BackupList(config util.Config) util.Backups {
+	var backups util.Backups
+	var result util.Result
+	var messages []util.Message
+
+	client, err := k8s.NewSnapshotClient(config.StoragePluginParameters["AccessWithinCluster"], config.StoragePluginParameters["SnapshotApiVersion"])
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		backups.Result = result
+
+		return backups
+	}
+
+	snapshotBackups, err := client.ListSnapshotBackups(config.StoragePluginParameters["Namespace"], config.ProfileName, config.ConfigName)
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		messages = append(messages, msg)
+		result = util.SetResult(1, messages)
+		backups.Result = result
+
+		return backups
+	}
+
+	result = util.SetResult(0, messages)
+	backups.Result = result
+	backups.Backups = getBackups(snapshotBackups)
+
+	return backups
+}
+
+func (s storagePlugin) Info() util.Plugin {
+	var plugin util.Plugin = setPlugin()
+	return plugin
+}
+
+func setPlugin() (plugin util.Plugin) {
+	plugin.Name = "csi-snapshot"
+	plugin.Description = "Storage Plugin that takes CSI volume snapshots of the persistent volume claims of a pod"
+	plugin.Version = "1.0.0"
+	plugin.Type = "storage"
+
+	var capabilities []util.Capability
+	var backupCap util.Capability
+	backupCap.Name = "backup"
+
+	var backupListCap util.Capability
+	backupListCap.Name = "backupList"
+
+	var backupDeleteCap util.Capability
+	backupDeleteCap.Name = "backupDelete"
+
+	var restoreCap util.Capability
+	restoreCap.Name = "restore"
+
+	var infoCap util.Capability
+	infoCap.Name = "info"
+
+	capabilities = append(capabilities, backupCap, backupListCap, backupDeleteCap, restoreCap, infoCap)
+
+	plugin.Capabilities = capabilities
+
+	return plugin
+}
+
+func getBackups(snapshotBackups []k8s.SnapshotBackup) []util.Backup {
+	var backups []util.Backup
+	for _, snapshotBackup := range snapshotBackups {
+		backups = append(backups, snapshotBackup.Backup)
+	}
+
+	return backups
+}
+
+func getSnapshotBackup(snapshotBackups []k8s.SnapshotBackup, name, policy, workflowId string) (k8s.SnapshotBackup, bool) {
+	for _, snapshotBackup := range snapshotBackups {
+		backup := snapshotBackup.Backup
+		if backup.Name == name && backup.Policy == policy && backup.WorkflowId == workflowId {
+			return snapshotBackup, true
+		}
+	}
+
+	return k8s.SnapshotBackup{}, false
+}
+
+// deleteSnapshots removes the snapshots of a failed backup
+func deleteSnapshots(client *k8s.SnapshotClient, namespace string, snapshotNames []string) []util.Message {
+	var messages []util.Message
+
+	for _, snapshotName := range snapshotNames {
+		err := client.DeleteVolumeSnapshot(namespace, snapshotName)
+		if err != nil {
+			msg := util.SetMessage("WARN", "Couldn't delete snapshot ["+snapshotName+"] of failed backup! "+err.Error())
+			messages = append(messages, msg)
+		}
+	}
+
+	return messages
+}
+
+func getSnapshotTimeout(config util.Config) time.Duration {
+	timeout, err := strconv.Atoi(config.StoragePluginParameters["SnapshotTimeout"])
+	if err != nil || timeout <= 0 {
+		timeout = defaultSnapshotTimeout
+	}
+
+	return time.Duration(timeout) * time.Second
+}
+
+func main() {}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fossul/src/engine/util"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditedRoutes change configurations, profiles or schedules, every request is recorded in the audit log
+var auditedRoutes = map[string]bool{
+	"AddConfig":                  true,
+	"AddPluginConfig":            true,
+	"DeleteConfig":               true,
+	"DeleteConfigDir":            true,
+	"DeletePluginConfig":         true,
+	"AddProfile":                 true,
+	"DeleteProfile":              true,
+	"AddProfileNotifiers":        true,
+	"AddSchedule":                true,
+	"DeleteSchedule":             true,
+	"DeleteWorkflowTypeSchedule": true,
+}
+
+type auditRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (a *auditRecorder) Write(b []byte) (int, error) {
+	a.body.Write(b)
+	return a.ResponseWriter.Write(b)
+}
+
+func getAuditLogPath() string {
+	return dataDir + "/audit.log"
+}
+
+// auditApi records who made a change, what it targeted and for files it changed, the lines before and after
+func auditApi(inner http.Handler, name string) http.Handler {
+	if !auditedRoutes[name] {
+		return inner
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		// schedule workflow type is part of the request body, keep the body for the handler
+		var workflowType string = params["workflowType"]
+		if name == "AddSchedule" {
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			var cronSchedule util.CronSchedule
+			_ = json.Unmarshal(body, &cronSchedule)
+			workflowType = cronSchedule.WorkflowType
+		}
+
+		before := getAuditSnapshot(name, params, workflowType)
+		recorder := &auditRecorder{ResponseWriter: w}
+		inner.ServeHTTP(recorder, r)
+		after := getAuditSnapshot(name, params, workflowType)
+
+		var result util.Result
+		_ = json.Unmarshal(recorder.body.Bytes(), &result)
+
+		var entry util.AuditEntry
+		entry.Timestamp = time.Now().Unix()
+		entry.User, _, _ = r.BasicAuth()
+		entry.Endpoint = r.Method + " " + r.URL.Path
+		entry.Action = name
+		entry.ProfileName = params["profileName"]
+		entry.ConfigName = params["configName"]
+		entry.PluginName = params["pluginName"]
+		entry.Policy = params["policy"]
+		if params["policy"] != "" {
+			entry.WorkflowType = util.GetScheduleWorkflowType(workflowType)
+		}
+		entry.ResultCode = result.Code
+		entry.Diff = util.DiffLines(before, after)
+
+		if err := util.AppendAuditEntry(getAuditLogPath(), entry); err != nil {
+			util.RequestLogger(r).With("action", name).Error("Couldn't write audit log entry! " + err.Error())
+		}
+	})
+}
+
+// getAuditSnapshot returns the contents of the file changed by the request with credentials masked, deleting a
+// config or profile snapshots every file under its directory. Requests such as adding a profile have no snapshot.
+func getAuditSnapshot(name string, params map[string]string, workflowType string) string {
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+
+	var path string
+	switch name {
+	case "DeleteConfigDir":
+		return getAuditDirSnapshot(configDir + "/" + profileName + "/" + configName)
+	case "DeleteProfile":
+		return getAuditDirSnapshot(configDir + "/" + profileName)
+	case "AddConfig", "DeleteConfig":
+		path = configDir + "/" + profileName + "/" + configName + "/" + configName + ".conf"
+	case "AddPluginConfig", "DeletePluginConfig":
+		path = configDir + "/" + profileName + "/" + configName + "/" + params["pluginName"] + ".conf"
+	case "AddProfileNotifiers":
+		path = getProfileNotifiersPath(profileName)
+	case "AddSchedule", "DeleteSchedule", "DeleteWorkflowTypeSchedule":
+		jobSchedule, err := ReadJobSchedule(getJobScheduleFile(profileName, configName, params["policy"], workflowType))
+		if err != nil {
+			return ""
+		}
+		return "CronSchedule = " + strconv.Quote(jobSchedule.CronSchedule)
+	default:
+		return ""
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return util.MaskSecrets(string(data))
+}
+
+// getAuditDirSnapshot returns the contents of every file under a directory with credentials masked, each file
+// starts with a line naming its path relative to the directory so the diff shows which file a line belonged to
+func getAuditDirSnapshot(dir string) string {
+	var snapshot strings.Builder
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(dir, path)
+		snapshot.WriteString("# " + relPath + "\n")
+		snapshot.WriteString(util.MaskSecrets(strings.TrimRight(string(data), "\n")) + "\n")
+
+		return nil
+	})
+
+	return snapshot.String()
+}
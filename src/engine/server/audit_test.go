@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/util"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestAuditDeleteProfile checks deleting a profile records every file under it, not only the config file
+func TestAuditDeleteProfile(t *testing.T) {
+	_, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	testConfigDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Logf("ERROR: " + err.Error())
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(testConfigDir)
+
+	savedConfigDir := configDir
+	configDir = testConfigDir
+	defer func() { configDir = savedConfigDir }()
+
+	files := map[string]string{
+		"/mariadb/notifiers.conf":          "SmtpPassword = \"secret\"\n",
+		"/mariadb/mariadb/mariadb.conf":    "AppPlugin = \"mariadb.so\"\n",
+		"/mariadb/mariadb/mariadb.so.conf": "MysqlUser = \"root\"\nMysqlPassword = \"secret\"\n",
+	}
+	for file, content := range files {
+		if err := util.CreateDir(configDir+file[:strings.LastIndex(file, "/")], 0755); err != nil {
+			t.Logf("ERROR: " + err.Error())
+			t.Fail()
+			return
+		}
+		if err := ioutil.WriteFile(configDir+file, []byte(content), 0644); err != nil {
+			t.Logf("ERROR: " + err.Error())
+			t.Fail()
+			return
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Handle("/deleteProfile/{profileName}", auditApi(http.HandlerFunc(DeleteProfile), "DeleteProfile"))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/deleteProfile/mariadb", nil))
+
+	entries, err := util.ReadAuditEntries(getAuditLogPath(), "mariadb", "")
+	if err != nil || len(entries) != 1 {
+		t.Logf("ERROR: expected one audit entry got %v %v", entries, err)
+		t.Fail()
+		return
+	}
+
+	diff := strings.Join(entries[0].Diff, "\n")
+	for _, line := range []string{"-# notifiers.conf", "-# mariadb/mariadb.conf", "-# mariadb/mariadb.so.conf", "-AppPlugin = \"mariadb.so\"", "-MysqlUser = \"root\""} {
+		if !strings.Contains(diff, line) {
+			t.Logf("ERROR: expected diff line [%s] got %v", line, entries[0].Diff)
+			t.Fail()
+		}
+	}
+
+	if strings.Contains(diff, "secret") {
+		t.Logf("ERROR: credentials not masked %v", entries[0].Diff)
+		t.Fail()
+	}
+}
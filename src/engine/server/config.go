@@ -33,6 +33,12 @@ func GetConsolidatedConfig(profileName, configName, policyName string) (util.Con
 	config.SelectedArchiveRetention = archiveRetention
 	config.SelectedBackupPolicy = policyName
 
+	profileNotifiers, err := util.ReadProfileNotifiers(getProfileNotifiersPath(profileName))
+	if err != nil {
+		return config, err
+	}
+	config.Notifiers = util.MergeNotifiers(config.Notifiers, profileNotifiers.Notifiers)
+
 	if config.AppPlugin != "" {
 		appConf := configDir + "/" + profileName + "/" + configName + "/" + config.AppPlugin + ".conf"
 		appConfigMap, err := util.ReadConfigToMap(appConf)
@@ -68,3 +74,8 @@ func GetConsolidatedConfig(profileName, configName, policyName string) (util.Con
 
 	return config, nil
 }
+
+// getProfileNotifiersPath is the notifiers file of a profile, it is optional
+func getProfileNotifiersPath(profileName string) string {
+	return configDir + "/" + profileName + "/notifiers.conf"
+}
@@ -72,7 +72,7 @@ func SendTrapSuccessCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.SendTrapSuccessCmd, ",")
 		message := util.SetMessage("INFO", "Performing send trap success command ["+config.SendTrapSuccessCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
@@ -114,7 +114,7 @@ func SendTrapErrorCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.SendTrapErrorCmd, ",")
 		message := util.SetMessage("INFO", "Performing send trap error command ["+config.SendTrapErrorCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
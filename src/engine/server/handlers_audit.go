@@ -0,0 +1,64 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fossul/src/engine/util"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// GetAuditLog godoc
+// @Description Get audit log of configuration, profile and schedule changes
+// @Param profileName path string false "name of profile"
+// @Param configName path string false "name of config"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.AuditLogResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /getAuditLog/{profileName}/{configName} [get]
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+
+	var result util.Result
+	var messages []util.Message
+	var auditLogResult util.AuditLogResult
+
+	entries, err := util.ReadAuditEntries(getAuditLogPath(), profileName, configName)
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Couldn't read audit log! "+err.Error())
+		messages = append(messages, msg)
+
+		result.Code = 1
+		result.Messages = messages
+		auditLogResult.Result = result
+
+		_ = json.NewDecoder(r.Body).Decode(&auditLogResult)
+		json.NewEncoder(w).Encode(auditLogResult)
+
+		return
+	}
+
+	result.Code = 0
+	auditLogResult.Result = result
+	auditLogResult.Entries = entries
+
+	_ = json.NewDecoder(r.Body).Decode(&auditLogResult)
+	json.NewEncoder(w).Encode(auditLogResult)
+}
@@ -256,6 +256,20 @@ func AddConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	err = util.ValidateNotifiers(config.Notifiers)
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Invalid notifiers! "+err.Error())
+		messages = append(messages, msg)
+
+		result.Code = 1
+		result.Messages = messages
+
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+
+		return
+	}
+
 	dir := configDir + "/" + profileName + "/" + configName
 	err = util.CreateDir(dir, 0755)
 	if err != nil {
@@ -678,6 +692,108 @@ func ListProfiles(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// AddProfileNotifiers godoc
+// @Description Add Profile Notifiers, they apply to every config of the profile
+// @Param notifiers body util.ProfileNotifiers true "profile notifiers struct"
+// @Param profileName path string true "name of profile"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /addProfileNotifiers/{profileName} [post]
+func AddProfileNotifiers(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+
+	var result util.Result
+	var messages []util.Message
+	var profileNotifiers util.ProfileNotifiers
+
+	err := json.NewDecoder(r.Body).Decode(&profileNotifiers)
+	if err == nil {
+		err = util.ValidateNotifiers(profileNotifiers.Notifiers)
+	}
+
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Invalid notifiers! "+err.Error())
+		messages = append(messages, msg)
+
+		result.Code = 1
+		result.Messages = messages
+
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	dir := configDir + "/" + profileName
+	if util.ExistsPath(dir) != true {
+		msg := util.SetMessage("ERROR", "Add profile notifiers failed! Profile ["+dir+"] does not exist.")
+		messages = append(messages, msg)
+
+		result.Code = 1
+		result.Messages = messages
+
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	conf := getProfileNotifiersPath(profileName)
+	err = util.WriteProfileNotifiers(conf, profileNotifiers)
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Add profile notifiers ["+conf+"] failed! "+err.Error())
+		messages = append(messages, msg)
+
+		result.Code = 1
+	} else {
+		msg := util.SetMessage("INFO", "Profile notifiers ["+conf+"] create completed successfully")
+		messages = append(messages, msg)
+
+		result.Code = 0
+	}
+	result.Messages = messages
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetProfileNotifiers godoc
+// @Description Get Profile Notifiers
+// @Param profileName path string true "name of profile"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.ProfileNotifiersResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /getProfileNotifiers/{profileName} [get]
+func GetProfileNotifiers(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+
+	var result util.Result
+	var messages []util.Message
+	var profileNotifiersResult util.ProfileNotifiersResult
+
+	profileNotifiers, err := util.ReadProfileNotifiers(getProfileNotifiersPath(profileName))
+	if err != nil {
+		msg := util.SetMessage("ERROR", "Couldn't get profile notifiers! "+err.Error())
+		messages = append(messages, msg)
+
+		result.Code = 1
+		result.Messages = messages
+	} else {
+		result.Code = 0
+		profileNotifiersResult.Notifiers = profileNotifiers.Notifiers
+	}
+	profileNotifiersResult.Result = result
+
+	_ = json.NewDecoder(r.Body).Decode(&profileNotifiersResult)
+	json.NewEncoder(w).Encode(profileNotifiersResult)
+}
+
 // ListConfigs godoc
 // @Description List Configurations
 // @Param profileName path string true "name of profile"
@@ -25,7 +25,7 @@ import (
 // @Param profileName path string true "name of profile"
 // @Param configName path string true "name of config"
 // @Param policy path string true "policy name"
-// @Param cronSchedule body util.CronSchedule true "value: min,hour,dayOfMonth,month,dayOfWeek"
+// @Param cronSchedule body util.CronSchedule true "value: min,hour,dayOfMonth,month,dayOfWeek workflowType: backup or verify"
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} util.Result
@@ -57,7 +57,7 @@ func AddSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := AddCronSchedule(profileName, configName, policy, cronSchedule.Value)
+	id, err := AddCronSchedule(profileName, configName, policy, cronSchedule.WorkflowType, cronSchedule.Value)
 	if err != nil {
 		msg := util.SetMessage("ERROR", "Add schedule failed! "+err.Error())
 		messages = append(messages, msg)
@@ -90,6 +90,7 @@ func AddSchedule(w http.ResponseWriter, r *http.Request) {
 // @Param profileName path string true "name of profile"
 // @Param configName path string true "name of config"
 // @Param policy path string true "policy name"
+// @Param workflowType path string false "backup or verify, default is backup"
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} util.Result
@@ -97,17 +98,18 @@ func AddSchedule(w http.ResponseWriter, r *http.Request) {
 // @Failure 400 {string} string
 // @Failure 404 {string} string
 // @Failure 500 {string} string
-// @Router /deleteSchedule/{profileName}/{configName}/{policy} [get]
+// @Router /deleteSchedule/{profileName}/{configName}/{policy}/{workflowType} [get]
 func DeleteSchedule(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	var profileName string = params["profileName"]
 	var configName string = params["configName"]
 	var policy string = params["policy"]
+	var workflowType string = params["workflowType"]
 
 	var result util.Result
 	var messages []util.Message
 
-	err := DeleteCronSchedule(profileName, configName, policy)
+	err := DeleteCronSchedule(profileName, configName, policy, workflowType)
 	if err != nil {
 		msg := util.SetMessage("ERROR", "Delete schedule failed! "+err.Error())
 		messages = append(messages, msg)
@@ -16,6 +16,7 @@ import (
 	"encoding/json"
 	"fossul/src/engine/util"
 	"github.com/gorilla/mux"
+	"io"
 	"net/http"
 	"time"
 )
@@ -62,28 +63,23 @@ func StartBackupWorkflowLocalConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config.WorkflowId = util.IntToString(workflow.Id)
+	config.WorkflowId = util.Int64ToString(workflow.Id)
 	config.WorkflowTimestamp = util.GetTimestamp()
 	workflow.Policy = config.SelectedBackupPolicy
 
-	_, ok := runningWorkflowMap[config.ProfileName+"-"+config.ConfigName]
-	if ok {
-		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Another workflow is running under profile ["+config.ProfileName+"] config ["+config.ConfigName+"]")
+	_, err = util.GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Invalid workflow definition! "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
-	} else {
-		runningWorkflowMap[config.ProfileName+"-"+config.ConfigName] = config.SelectedBackupPolicy
-
-		go func() {
-			startBackupWorkflowImpl(dataDir, config, workflow)
-		}()
 
-		result = util.SetResultMessage(0, "INFO", "Workflow id ["+util.IntToString(workflow.Id)+"] started successfully")
-		workflowResult.Result = result
-		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
-		json.NewEncoder(w).Encode(workflowResult)
+		return
 	}
+
+	workflowResult.Id, workflowResult.Result = launchWorkflow(config, workflow)
+	_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+	json.NewEncoder(w).Encode(workflowResult)
 }
 
 // StartBackupWorkflow godoc
@@ -121,33 +117,30 @@ func StartBackupWorkflow(w http.ResponseWriter, r *http.Request) {
 	printConfigDebug(config)
 
 	if err != nil {
-		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Couldn't read config using profile ["+profileName+"] config ["+configName+"] "+err.Error())
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Couldn't read config using profile ["+profileName+"] config ["+configName+"] "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
+
+		return
 	}
 
-	config.WorkflowId = util.IntToString(workflow.Id)
+	config.WorkflowId = util.Int64ToString(workflow.Id)
 	config.WorkflowTimestamp = util.GetTimestamp()
 
-	_, ok := runningWorkflowMap[config.ProfileName+"-"+config.ConfigName]
-	if ok {
-		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Another workflow is running under profile ["+config.ProfileName+"] config ["+config.ConfigName+"]")
+	_, err = util.GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Invalid workflow definition! "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
-	} else {
-		runningWorkflowMap[config.ProfileName+"-"+config.ConfigName] = config.SelectedBackupPolicy
-
-		go func() {
-			startBackupWorkflowImpl(dataDir, config, workflow)
-		}()
 
-		result := util.SetResultMessage(0, "INFO", "Workflow id ["+util.IntToString(workflow.Id)+"] started successfully")
-		workflowResult.Result = result
-		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
-		json.NewEncoder(w).Encode(workflowResult)
+		return
 	}
+
+	workflowResult.Id, workflowResult.Result = launchWorkflow(config, workflow)
+	_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+	json.NewEncoder(w).Encode(workflowResult)
 }
 
 // StartRestoreWorkflowLocalConfig godoc
@@ -195,29 +188,37 @@ func StartRestoreWorkflowLocalConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	config.WorkflowId = util.IntToString(workflow.Id)
+	config.WorkflowId = util.Int64ToString(workflow.Id)
 	config.WorkflowTimestamp = util.GetTimestamp()
-	config.SelectedWorkflowId = util.StringToInt(selectedWorkflowId)
+	config.SelectedWorkflowId = getSelectedWorkflowId(config, selectedWorkflowId)
 	workflow.Policy = config.SelectedBackupPolicy
 
-	_, ok := runningWorkflowMap[config.ProfileName+"-"+config.ConfigName]
-	if ok {
-		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Another workflow is running under profile ["+config.ProfileName+"] config ["+config.ConfigName+"]")
+	err = util.ValidateRestorePaths(config.RestorePaths)
+	if err == nil {
+		err = util.ValidateRestoreTarget(config.RestoreTarget)
+	}
+	if err != nil {
+		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
-	} else {
-		runningWorkflowMap[config.ProfileName+"-"+config.ConfigName] = config.SelectedBackupPolicy
 
-		go func() {
-			startRestoreWorkflowImpl(dataDir, config, workflow)
-		}()
+		return
+	}
 
-		result = util.SetResultMessage(0, "INFO", "Workflow id ["+util.IntToString(workflow.Id)+"] started successfully")
+	_, err = util.GetWorkflowDefinition(config, "restore")
+	if err != nil {
+		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Invalid workflow definition! "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
+
+		return
 	}
+
+	workflowResult.Id, workflowResult.Result = launchWorkflow(config, workflow)
+	_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+	json.NewEncoder(w).Encode(workflowResult)
 }
 
 // StartRestoreWorkflow godoc
@@ -226,6 +227,7 @@ func StartRestoreWorkflowLocalConfig(w http.ResponseWriter, r *http.Request) {
 // @Param configName path string true "name of config"
 // @Param policy path string true "name of backup policy"
 // @Param workflowId path string true "workflow id"
+// @Param restoreRequest body util.RestoreRequest false "optional alternate target: namespace, serviceName, containerName, restoreDestPath, database and restorePaths to restore selected paths only"
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} util.WorkflowResult
@@ -257,34 +259,277 @@ func StartRestoreWorkflow(w http.ResponseWriter, r *http.Request) {
 	printConfigDebug(config)
 
 	if err != nil {
-		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Couldn't read config using profile ["+profileName+"] config ["+configName+"]")
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Couldn't read config using profile ["+profileName+"] config ["+configName+"]")
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
+
+		return
+	}
+
+	// optional restore target and paths, an empty body restores the whole backup into the configured application
+	var restoreRequest util.RestoreRequest
+	err = json.NewDecoder(r.Body).Decode(&restoreRequest)
+	if err != nil && err != io.EOF {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Couldn't read restore request! "+err.Error())
+		workflowResult.Result = result
+		json.NewEncoder(w).Encode(workflowResult)
+
+		return
 	}
 
-	config.WorkflowId = util.IntToString(workflow.Id)
+	config.WorkflowId = util.Int64ToString(workflow.Id)
 	config.WorkflowTimestamp = util.GetTimestamp()
-	config.SelectedWorkflowId = util.StringToInt(selectedWorkflowId)
+	config.SelectedWorkflowId = getSelectedWorkflowId(config, selectedWorkflowId)
+	config.RestoreTarget = restoreRequest.RestoreTarget
+	config.RestorePaths = restoreRequest.RestorePaths
 
-	_, ok := runningWorkflowMap[config.ProfileName+"-"+config.ConfigName]
-	if ok {
-		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.IntToString(workflow.Id)+"] failed to start. Another workflow is running under profile ["+config.ProfileName+"] config ["+config.ConfigName+"]")
+	err = util.ValidateRestorePaths(config.RestorePaths)
+	if err == nil {
+		err = util.ValidateRestoreTarget(config.RestoreTarget)
+	}
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
-	} else {
-		runningWorkflowMap[config.ProfileName+"-"+config.ConfigName] = config.SelectedBackupPolicy
 
-		go func() {
-			startRestoreWorkflowImpl(dataDir, config, workflow)
-		}()
+		return
+	}
 
-		result := util.SetResultMessage(0, "INFO", "Workflow id ["+util.IntToString(workflow.Id)+"] started successfully")
+	_, err = util.GetWorkflowDefinition(config, "restore")
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Invalid workflow definition! "+err.Error())
 		workflowResult.Result = result
 		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
 		json.NewEncoder(w).Encode(workflowResult)
+
+		return
 	}
+
+	workflowResult.Id, workflowResult.Result = launchWorkflow(config, workflow)
+	_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+	json.NewEncoder(w).Encode(workflowResult)
+}
+
+// StartVerifyWorkflow godoc
+// @Description Start verify workflow, restores a backup into the verify target, checks it and tears the target down. The latest backup of the policy is verified if no workflow id is given.
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Param policy path string true "name of backup policy"
+// @Param workflowId path string false "workflow id of backup"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /startVerifyWorkflow/{profileName}/{configName}/{policy}/{workflowId} [post]
+func StartVerifyWorkflow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+	var policyName string = params["policy"]
+	var selectedWorkflowId string = params["workflowId"]
+
+	var workflowResult util.WorkflowResult
+	workflow := &util.Workflow{}
+	workflow.Id = util.GetWorkflowId()
+	workflow.Type = "verify"
+	workflow.Policy = policyName
+	workflow.Status = "RUNNING"
+
+	var timestamp string = time.Now().Format(time.RFC3339)
+	workflow.Timestamp = timestamp
+
+	workflowResult.Id = workflow.Id
+
+	config, err := GetConsolidatedConfig(profileName, configName, policyName)
+	printConfigDebug(config)
+
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Couldn't read config using profile ["+profileName+"] config ["+configName+"] "+err.Error())
+		workflowResult.Result = result
+		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+		json.NewEncoder(w).Encode(workflowResult)
+
+		return
+	}
+
+	config.WorkflowId = util.Int64ToString(workflow.Id)
+	config.WorkflowTimestamp = util.GetTimestamp()
+	if selectedWorkflowId != "" {
+		config.SelectedWorkflowId = getSelectedWorkflowId(config, selectedWorkflowId)
+	}
+
+	err = util.ValidateVerifyTarget(config)
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. "+err.Error())
+		workflowResult.Result = result
+		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+		json.NewEncoder(w).Encode(workflowResult)
+
+		return
+	}
+
+	_, err = util.GetWorkflowDefinition(config, "verify")
+	if err != nil {
+		result := util.SetResultMessage(1, "ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] failed to start. Invalid workflow definition! "+err.Error())
+		workflowResult.Result = result
+		_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+		json.NewEncoder(w).Encode(workflowResult)
+
+		return
+	}
+
+	workflowResult.Id, workflowResult.Result = launchWorkflow(config, workflow)
+	_ = json.NewDecoder(r.Body).Decode(&workflowResult)
+	json.NewEncoder(w).Encode(workflowResult)
+}
+
+// PlanBackupWorkflowLocalConfig godoc
+// @Description Plan backup workflow using local config, shows what the workflow would do without executing it
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowPlanResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /planBackupWorkflowLocalConfig [post]
+func PlanBackupWorkflowLocalConfig(w http.ResponseWriter, r *http.Request) {
+	var workflowPlanResult util.WorkflowPlanResult
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(config)
+
+	if err != nil {
+		workflowPlanResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+		json.NewEncoder(w).Encode(workflowPlanResult)
+
+		return
+	}
+
+	workflowPlanResult = getWorkflowPlan(config, "backup")
+	_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+	json.NewEncoder(w).Encode(workflowPlanResult)
+}
+
+// PlanBackupWorkflow godoc
+// @Description Plan backup workflow, shows what the workflow would do without executing it
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Param policy path string true "name of backup policy"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowPlanResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /planBackupWorkflow/{profileName}/{configName}/{policy} [get]
+func PlanBackupWorkflow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+	var policyName string = params["policy"]
+
+	var workflowPlanResult util.WorkflowPlanResult
+
+	config, err := GetConsolidatedConfig(profileName, configName, policyName)
+	printConfigDebug(config)
+
+	if err != nil {
+		workflowPlanResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config using profile ["+profileName+"] config ["+configName+"] "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+		json.NewEncoder(w).Encode(workflowPlanResult)
+
+		return
+	}
+
+	workflowPlanResult = getWorkflowPlan(config, "backup")
+	_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+	json.NewEncoder(w).Encode(workflowPlanResult)
+}
+
+// PlanRestoreWorkflowLocalConfig godoc
+// @Description Plan restore workflow using local config, shows what the workflow would do without executing it
+// @Param config body util.Config true "config struct"
+// @Param workflowId path string true "workflow id"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowPlanResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /planRestoreWorkflowLocalConfig/{workflowId} [post]
+func PlanRestoreWorkflowLocalConfig(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var selectedWorkflowId string = params["workflowId"]
+
+	var workflowPlanResult util.WorkflowPlanResult
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(config)
+
+	if err != nil {
+		workflowPlanResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+		json.NewEncoder(w).Encode(workflowPlanResult)
+
+		return
+	}
+
+	config.SelectedWorkflowId = getSelectedWorkflowId(config, selectedWorkflowId)
+
+	workflowPlanResult = getWorkflowPlan(config, "restore")
+	_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+	json.NewEncoder(w).Encode(workflowPlanResult)
+}
+
+// PlanRestoreWorkflow godoc
+// @Description Plan restore workflow, shows what the workflow would do without executing it
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Param policy path string true "name of backup policy"
+// @Param workflowId path string true "workflow id"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowPlanResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /planRestoreWorkflow/{profileName}/{configName}/{policy}/{workflowId} [get]
+func PlanRestoreWorkflow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+	var policyName string = params["policy"]
+	var selectedWorkflowId string = params["workflowId"]
+
+	var workflowPlanResult util.WorkflowPlanResult
+
+	config, err := GetConsolidatedConfig(profileName, configName, policyName)
+	printConfigDebug(config)
+
+	if err != nil {
+		workflowPlanResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config using profile ["+profileName+"] config ["+configName+"] "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+		json.NewEncoder(w).Encode(workflowPlanResult)
+
+		return
+	}
+
+	config.SelectedWorkflowId = getSelectedWorkflowId(config, selectedWorkflowId)
+
+	workflowPlanResult = getWorkflowPlan(config, "restore")
+	_ = json.NewDecoder(r.Body).Decode(&workflowPlanResult)
+	json.NewEncoder(w).Encode(workflowPlanResult)
 }
 
 // GetWorkflowStatus godoc
@@ -328,6 +573,158 @@ func GetWorkflowStatus(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StreamWorkflow godoc
+// @Description Stream workflow events as server-sent events, past events are replayed and the stream ends when the workflow ends
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Param id path string true "workflow id"
+// @Produce  text/event-stream
+// @Success 200 {object} util.WorkflowEvent
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /streamWorkflow/{profileName}/{configName}/{id} [get]
+func StreamWorkflow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+	var id string = params["id"]
+
+	resultsDir := dataDir + "/" + profileName + "/" + configName + "/" + id
+
+	var result util.Result
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		result = util.SetResultMessage(1, "ERROR", "Streaming is not supported")
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if !util.ExistsPath(resultsDir + "/workflow") {
+		result = util.SetResultMessage(1, "ERROR", "Couldn't stream workflow id ["+id+"], workflow doesn't exist")
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	streamWorkflowEvents(w, flusher, r, resultsDir)
+}
+
+// CancelWorkflow godoc
+// @Description Cancel a running or queued workflow, the in-flight step is aborted and the application unquiesced
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Param id path string true "workflow id"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /cancelWorkflow/{profileName}/{configName}/{id} [post]
+func CancelWorkflow(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+	var id string = params["id"]
+
+	var result util.Result
+	switch queuedWorkflows.cancel(profileName, configName, util.StringToInt64(id)) {
+	case "RUNNING":
+		result = util.SetResultMessage(0, "INFO", "Workflow id ["+id+"] cancel requested under profile ["+profileName+"] config ["+configName+"]")
+	case "QUEUED":
+		result = util.SetResultMessage(0, "INFO", "Queued workflow id ["+id+"] cancelled under profile ["+profileName+"] config ["+configName+"]")
+	default:
+		result = util.SetResultMessage(1, "ERROR", "Workflow id ["+id+"] is not running under profile ["+profileName+"] config ["+configName+"]")
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&result)
+	json.NewEncoder(w).Encode(result)
+}
+
+// ListWorkflowLocks godoc
+// @Description List workflow locks held for profile/configs
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowLockResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /listWorkflowLocks [get]
+func ListWorkflowLocks(w http.ResponseWriter, r *http.Request) {
+	var workflowLockResult util.WorkflowLockResult
+
+	locks, err := workflowLocks.List()
+	if err != nil {
+		workflowLockResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't list workflow locks! "+err.Error())
+	} else {
+		workflowLockResult.Locks = locks
+		workflowLockResult.Result = util.SetResult(0, nil)
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&workflowLockResult)
+	json.NewEncoder(w).Encode(workflowLockResult)
+}
+
+// ListWorkflowQueue godoc
+// @Description List running and queued workflows
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.WorkflowQueueResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /listWorkflowQueue [get]
+func ListWorkflowQueue(w http.ResponseWriter, r *http.Request) {
+	var workflowQueueResult util.WorkflowQueueResult
+
+	workflowQueueResult.Workflows = queuedWorkflows.list()
+	workflowQueueResult.Result = util.SetResult(0, nil)
+
+	_ = json.NewDecoder(r.Body).Decode(&workflowQueueResult)
+	json.NewEncoder(w).Encode(workflowQueueResult)
+}
+
+// ReleaseWorkflowLock godoc
+// @Description Force release of workflow lock for profile/config
+// @Param profileName path string true "name of profile"
+// @Param configName path string true "name of config"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /releaseWorkflowLock/{profileName}/{configName} [post]
+func ReleaseWorkflowLock(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var profileName string = params["profileName"]
+	var configName string = params["configName"]
+
+	var result util.Result
+	lock, err := workflowLocks.ForceRelease(profileName, configName)
+	if err != nil {
+		result = util.SetResultMessage(1, "ERROR", err.Error())
+	} else {
+		result = util.SetResultMessage(0, "INFO", "Workflow lock held by workflow id ["+util.Int64ToString(lock.WorkflowId)+"] released for profile ["+profileName+"] config ["+configName+"]")
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&result)
+	json.NewEncoder(w).Encode(result)
+}
+
 // GetWorkflowStepResults godoc
 // @Description Get workflow step results
 // @Param profileName path string true "name of profile"
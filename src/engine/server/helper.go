@@ -15,6 +15,7 @@ package main
 import (
 	"fossul/src/engine/client"
 	"fossul/src/engine/util"
+	"time"
 )
 
 func setComment(resultsDir, msg string, workflow *util.Workflow) {
@@ -22,159 +23,278 @@ func setComment(resultsDir, msg string, workflow *util.Workflow) {
 
 	step := util.CreateCommentStep(workflow)
 	util.SetWorkflowStep(workflow, step)
-	util.SerializeWorkflow(resultsDir, workflow)
-	util.SerializeWorkflowStepResults(resultsDir, step.Id, commentResult)
+	serializeWorkflow(resultsDir, workflow)
+	serializeWorkflowStepResults(resultsDir, step.Id, commentResult)
 }
 
-func StepErrorHandlerBackup(isQuiesce bool, resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) int {
-	auth := SetAuth()
-
+func StepErrorHandlerBackup(isQuiesce bool, quiesceAt time.Time, resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) int {
 	if result.Code != 0 {
 		util.SetStepError(workflow, step)
-		util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
 
 		if isQuiesce {
-			commentMsg := "Performing Application Unquiesce"
-			setComment(resultsDir, commentMsg, workflow)
-
-			if config.AppUnquiesceCmd != "" {
-				step := stepInit(resultsDir, workflow)
-				result, _ := client.UnquiesceCmd(auth, config)
-
-				util.SetStepError(workflow, step)
-				util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
-			}
-
-			if config.AppPlugin != "" {
-				step := stepInit(resultsDir, workflow)
-				result, _ := client.Unquiesce(auth, config)
+			unquiesceOnError(resultsDir, workflow, config, quiesceAt)
+		}
 
-				util.SetStepError(workflow, step)
-				util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
-			}
+		if workflow.TargetRestored {
+			teardownOnError(resultsDir, workflow, config)
 		}
 
 		sendErrorNotification(resultsDir, policy, step, workflow, result, config)
 		util.SetWorkflowStatusError(workflow)
-		util.SerializeWorkflow(resultsDir, workflow)
+		serializeWorkflow(resultsDir, workflow)
 
 		//remove workflow lock
-		delete(runningWorkflowMap, config.ProfileName+"-"+config.ConfigName)
+		workflowLocks.Release(config.ProfileName, config.ConfigName, workflow.Id)
 
 		return 1
 	} else {
 		util.SetStepComplete(workflow, step)
-		util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
-		util.SerializeWorkflow(resultsDir, workflow)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
+		serializeWorkflow(resultsDir, workflow)
 
 		return 0
 	}
 }
 
-func StepErrorHandler(resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) int {
+func HttpErrorHandlerBackup(err error, isQuiesce bool, quiesceAt time.Time, resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) {
+	msg := util.SetMessage("ERROR", err.Error())
+	result.Messages = append(result.Messages, msg)
+	result.Code = 1
 
-	if result.Code != 0 {
-		util.SetStepError(workflow, step)
-		util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+	util.SetStepError(workflow, step)
+	serializeWorkflowStepResults(resultsDir, step.Id, result)
 
-		sendErrorNotification(resultsDir, policy, step, workflow, result, config)
-		util.SetWorkflowStatusError(workflow)
-		util.SerializeWorkflow(resultsDir, workflow)
+	if isQuiesce {
+		unquiesceOnError(resultsDir, workflow, config, quiesceAt)
+	}
 
-		//remove workflow lock
-		delete(runningWorkflowMap, config.ProfileName+"-"+config.ConfigName)
+	if workflow.TargetRestored {
+		teardownOnError(resultsDir, workflow, config)
+	}
 
-		return 1
-	} else {
-		util.SetStepComplete(workflow, step)
-		util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
-		util.SerializeWorkflow(resultsDir, workflow)
+	sendErrorNotification(resultsDir, policy, step, workflow, result, config)
+	util.SetWorkflowStatusError(workflow)
+	serializeWorkflow(resultsDir, workflow)
 
-		return 0
-	}
+	//remove workflow lock
+	workflowLocks.Release(config.ProfileName, config.ConfigName, workflow.Id)
 }
 
-func HttpErrorHandlerBackup(err error, isQuiesce bool, resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) {
+// unquiesceOnError always runs all unquiesce steps, even if one fails, so the application isn't left quiesced.
+// The quiesce duration is observed unless quiesceAt is unknown, as it is after a server restart.
+func unquiesceOnError(resultsDir string, workflow *util.Workflow, config util.Config, quiesceAt time.Time) {
 	auth := SetAuth()
 
-	msg := util.SetMessage("ERROR", err.Error())
-	result.Messages = util.PrependMessage(msg, result.Messages)
-	result.Code = 1
+	commentMsg := "Performing Application Unquiesce"
+	setComment(resultsDir, commentMsg, workflow)
 
-	util.SetStepError(workflow, step)
-	util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+	if config.AppUnquiesceCmd != "" {
+		step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "command", Hook: "AppUnquiesceCmd"})
+		result, err := client.UnquiesceCmd(getStepAuth(auth, workflow, step), config)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			result.Messages = util.PrependMessage(msg, result.Messages)
+			result.Code = 1
+		}
 
-	if isQuiesce {
-		commentMsg := "Performing Application Unquiesce"
-		setComment(resultsDir, commentMsg, workflow)
+		setStepStatus(workflow, step, result)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
+		serializeWorkflow(resultsDir, workflow)
+	}
 
-		if config.AppUnquiesceCmd != "" {
-			step := stepInit(resultsDir, workflow)
-			result, _ := client.UnquiesceCmd(auth, config)
-			util.SetStepError(workflow, step)
-			util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+	if config.AppPlugin != "" {
+		step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "unquiesce"})
+		result, err := client.Unquiesce(getStepAuth(auth, workflow, step), config)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			result.Messages = util.PrependMessage(msg, result.Messages)
+			result.Code = 1
 		}
 
-		if config.AppPlugin != "" {
-			step := stepInit(resultsDir, workflow)
-			result, _ := client.Unquiesce(auth, config)
-			util.SetStepError(workflow, step)
-			util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+		setStepStatus(workflow, step, result)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
+		serializeWorkflow(resultsDir, workflow)
+	}
+
+	if !quiesceAt.IsZero() {
+		util.ObserveQuiesceDuration(config, time.Since(quiesceAt))
+	}
+
+	workflow.Quiesced = false
+	serializeWorkflow(resultsDir, workflow)
+}
+
+// teardownOnError removes the verify target so restored data isn't left behind when a verify workflow fails
+func teardownOnError(resultsDir string, workflow *util.Workflow, config util.Config) {
+	auth := SetAuth()
+
+	commentMsg := "Performing Verify Target Teardown"
+	setComment(resultsDir, commentMsg, workflow)
+
+	if config.VerifyTeardownCmd != "" {
+		step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "command", Hook: "VerifyTeardownCmd"})
+
+		config.WorkflowCmd = config.VerifyTeardownCmd
+		result, err := client.AppWorkflowCmd(getStepAuth(auth, workflow, step), config)
+		if err != nil {
+			msg := util.SetMessage("ERROR", err.Error())
+			result.Messages = util.PrependMessage(msg, result.Messages)
+			result.Code = 1
 		}
+
+		setStepStatus(workflow, step, result)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
+	} else {
+		commentMsg = "No VerifyTeardownCmd Configured, Verify Target Must Be Removed Manually"
+		setComment(resultsDir, commentMsg, workflow)
 	}
 
-	sendErrorNotification(resultsDir, policy, step, workflow, result, config)
-	util.SetWorkflowStatusError(workflow)
-	util.SerializeWorkflow(resultsDir, workflow)
+	workflow.TargetRestored = false
+	serializeWorkflow(resultsDir, workflow)
+}
+
+func cancelWorkflowHandler(run *workflowRun, step *util.Step, result util.Result) {
+	workflow := run.workflow
+	config := run.config
+
+	if step != nil {
+		msg := util.SetMessage("ERROR", "Workflow id ["+util.Int64ToString(workflow.Id)+"] cancelled, step aborted")
+		result.Messages = append(result.Messages, msg)
+		result.Code = 1
+
+		util.SetStepCancelled(workflow, *step)
+		serializeWorkflowStepResults(run.resultsDir, step.Id, result)
+	}
+
+	commentMsg := "Workflow Cancelled"
+	setComment(run.resultsDir, commentMsg, workflow)
+
+	if run.isQuiesce {
+		unquiesceOnError(run.resultsDir, workflow, config, run.quiesceAt)
+	}
+
+	if workflow.TargetRestored {
+		teardownOnError(run.resultsDir, workflow, config)
+	}
+
+	util.SetWorkflowStatusCancelled(workflow)
+	serializeWorkflow(run.resultsDir, workflow)
 
 	//remove workflow lock
-	delete(runningWorkflowMap, config.ProfileName+"-"+config.ConfigName)
+	workflowLocks.Release(config.ProfileName, config.ConfigName, workflow.Id)
+}
+
+func setStepStatus(workflow *util.Workflow, step util.Step, result util.Result) {
+	if result.Code != 0 {
+		util.SetStepError(workflow, step)
+	} else {
+		util.SetStepComplete(workflow, step)
+	}
 }
 
-func HttpErrorHandler(err error, resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) {
+func continueOnFailureHandler(err error, resultsDir string, step util.Step, workflow *util.Workflow, result util.Result) {
+	if err != nil {
+		msg := util.SetMessage("ERROR", err.Error())
+		result.Messages = append(result.Messages, msg)
+		result.Code = 1
+	}
 
-	msg := util.SetMessage("ERROR", err.Error())
-	result.Messages = util.PrependMessage(msg, result.Messages)
-	result.Code = 1
+	msg := util.SetMessage("WARN", "Step failed but workflow will continue, step on failure is set to continue")
+	result.Messages = append(result.Messages, msg)
 
 	util.SetStepError(workflow, step)
-	util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
+	serializeWorkflowStepResults(resultsDir, step.Id, result)
+	serializeWorkflow(resultsDir, workflow)
+}
+
+// getSelectedWorkflowId maps the workflow id selected for a restore to the id its backups are named with, jobs
+// migrated from legacy random workflow ids keep their backups under the legacy id
+func getSelectedWorkflowId(config util.Config, selectedWorkflowId string) int64 {
+	jobsDir := dataDir + "/" + config.ProfileName + "/" + config.ConfigName
+	return util.GetBackupWorkflowId(jobsDir, util.StringToInt64(selectedWorkflowId))
+}
+
+func workflowDefinitionErrorHandler(err error, dataDir string, config util.Config, workflow *util.Workflow) {
+	workflowInitErrorHandler("Invalid workflow definition! "+err.Error(), dataDir, config, workflow)
+}
+
+// workflowInitErrorHandler fails a workflow that couldn't start running its steps
+func workflowInitErrorHandler(msg, dataDir string, config util.Config, workflow *util.Workflow) {
+	resultsDir := dataDir + "/" + config.ProfileName + "/" + config.ConfigName + "/" + util.Int64ToString(workflow.Id)
+
+	step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{})
+	result := util.SetResultMessage(1, "ERROR", msg)
+
+	util.SetStepError(workflow, step)
+	serializeWorkflowStepResults(resultsDir, step.Id, result)
 
-	sendErrorNotification(resultsDir, policy, step, workflow, result, config)
 	util.SetWorkflowStatusError(workflow)
-	util.SerializeWorkflow(resultsDir, workflow)
+	serializeWorkflow(resultsDir, workflow)
 
 	//remove workflow lock
-	delete(runningWorkflowMap, config.ProfileName+"-"+config.ConfigName)
+	workflowLocks.Release(config.ProfileName, config.ConfigName, workflow.Id)
+}
+
+// getStepAuth binds auth to a workflow step, requests carry the workflow and step id so the app and storage
+// services can log them
+func getStepAuth(auth client.Auth, workflow *util.Workflow, step util.Step) client.Auth {
+	return auth.WithContext(util.WithWorkflowStep(auth.Context(), workflow.Id, step.Id))
 }
 
-func stepInit(resultsDir string, workflow *util.Workflow) util.Step {
+// stepInit adds a running step to the workflow, the step records the kind, plugin and service host from its definition
+func stepInit(resultsDir string, workflow *util.Workflow, config util.Config, stepDefinition util.WorkflowStepDefinition) util.Step {
 	step := util.CreateStep(workflow)
+	step.Kind = stepDefinition.Kind
+	step.Plugin = util.GetWorkflowStepPlugin(config, stepDefinition)
+	step.Host = getServiceHostname(util.GetWorkflowStepService(stepDefinition))
 	util.SetWorkflowStep(workflow, step)
-	util.SerializeWorkflow(resultsDir, workflow)
+	serializeWorkflow(resultsDir, workflow)
 
 	return step
 }
 
+// sendErrorNotification runs the deprecated SendTrapErrorCmd, if configured, and sends the failure notifications
+// with the failed step and its messages
 func sendErrorNotification(resultsDir, policy string, step util.Step, workflow *util.Workflow, result util.Result, config util.Config) {
 	auth := SetAuth()
 
+	if config.SendTrapErrorCmd == "" && !isNotifyEnabled(config, "failure") {
+		return
+	}
+
+	commentMsg := "Sending Error Notifications"
+	setComment(resultsDir, commentMsg, workflow)
+
+	notifyStep := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "notify"})
+
+	var notifyResult util.Result
 	if config.SendTrapErrorCmd != "" {
-		commentMsg := "Sending Error Notifications"
-		setComment(resultsDir, commentMsg, workflow)
+		notifyResult, _ = client.SendTrapErrorCmd(auth, config)
+	}
 
-		step := stepInit(resultsDir, workflow)
-		result, _ := client.SendTrapErrorCmd(auth, config)
+	if isNotifyEnabled(config, "failure") {
+		failedStep := workflow.Steps[step.Id]
 
-		if result.Code != 0 {
-			util.SetStepError(workflow, step)
-		} else {
-			util.SetStepComplete(workflow, step)
+		notification := util.SetNotification("failure", config, workflow)
+		notification.FailedStep = &failedStep
+		notification.Messages = result.Messages
+		failureResult := sendNotifications(config, notification)
+
+		notifyResult.Messages = append(notifyResult.Messages, failureResult.Messages...)
+		if failureResult.Code != 0 {
+			notifyResult.Code = failureResult.Code
 		}
+	}
 
-		util.SerializeWorkflowStepResults(resultsDir, step.Id, result)
-		util.SerializeWorkflow(resultsDir, workflow)
+	if notifyResult.Code != 0 {
+		util.SetStepError(workflow, notifyStep)
+	} else {
+		util.SetStepComplete(workflow, notifyStep)
 	}
+
+	serializeWorkflowStepResults(resultsDir, notifyStep.Id, notifyResult)
+	serializeWorkflow(resultsDir, workflow)
 }
 
 func setDiscoverFileList(config util.Config, discoverResult util.DiscoverResult) (dataFilePaths, logFilePaths []string) {
@@ -191,6 +311,19 @@ func setDiscoverFileList(config util.Config, discoverResult util.DiscoverResult)
 	return dataFilePaths, logFilePaths
 }
 
+func getServiceHostname(service string) string {
+	switch service {
+	case "app":
+		return appHostname
+	case "storage":
+		return storageHostname
+	case "server":
+		return serverHostname
+	}
+
+	return ""
+}
+
 func SetAuth() client.Auth {
 	var auth client.Auth
 	auth.ServerHostname = serverHostname
@@ -0,0 +1,199 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fossul/src/engine/util"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServices stands in for the app and storage services, every plugin call succeeds unless a
+// handler is set for its path. Handlers get the call number of the path starting at 1.
+type fakeServices struct {
+	server   *httptest.Server
+	mutex    sync.Mutex
+	calls    map[string]int
+	handlers map[string]func(w http.ResponseWriter, r *http.Request, call int)
+}
+
+// setupTestServer points the server at a temp data dir and fake services, the returned func stops the
+// fake services and removes the data dir
+func setupTestServer(t *testing.T) (*fakeServices, func()) {
+	testDataDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Logf("ERROR: " + err.Error())
+		t.FailNow()
+	}
+
+	services := &fakeServices{}
+	services.calls = make(map[string]int)
+	services.handlers = make(map[string]func(w http.ResponseWriter, r *http.Request, call int))
+	services.server = httptest.NewServer(http.HandlerFunc(services.serveHTTP))
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(services.server.URL, "http://"))
+	if err != nil {
+		t.Logf("ERROR: " + err.Error())
+		t.FailNow()
+	}
+
+	dataDir = testDataDir
+	appHostname = host
+	appPort = port
+	storageHostname = host
+	storagePort = port
+	workflowLocks = util.NewLockManager(dataDir, 0)
+	queuedWorkflows = newWorkflowQueue(0, 0)
+
+	cleanup := func() {
+		services.server.Close()
+		os.RemoveAll(testDataDir)
+	}
+
+	return services, cleanup
+}
+
+func (services *fakeServices) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// body is read like the services do, a request is only cancelled once its body is consumed
+	io.Copy(ioutil.Discard, r.Body)
+
+	services.mutex.Lock()
+	services.calls[r.URL.Path] = services.calls[r.URL.Path] + 1
+	call := services.calls[r.URL.Path]
+	handler := services.handlers[r.URL.Path]
+	services.mutex.Unlock()
+
+	if handler != nil {
+		handler(w, r, call)
+		return
+	}
+
+	writeTestResult(w, util.SetResultMessage(0, "INFO", "Called "+r.URL.Path))
+}
+
+func (services *fakeServices) handle(path string, handler func(w http.ResponseWriter, r *http.Request, call int)) {
+	services.mutex.Lock()
+	defer services.mutex.Unlock()
+
+	services.handlers[path] = handler
+}
+
+func (services *fakeServices) getCalls(path string) int {
+	services.mutex.Lock()
+	defer services.mutex.Unlock()
+
+	return services.calls[path]
+}
+
+// waitForCalls waits until the path was called at least count times
+func (services *fakeServices) waitForCalls(path string, count int) bool {
+	for i := 0; i < 500; i++ {
+		if services.getCalls(path) >= count {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func writeTestResult(w http.ResponseWriter, result util.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// blockUntil returns a handler that hangs until release is closed or the request is cancelled
+func blockUntil(release chan struct{}) func(w http.ResponseWriter, r *http.Request, call int) {
+	return func(w http.ResponseWriter, r *http.Request, call int) {
+		select {
+		case <-release:
+			writeTestResult(w, util.SetResultMessage(0, "INFO", "Released "+r.URL.Path))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func getTestConfig(profileName, configName string) util.Config {
+	var config util.Config
+	config.ProfileName = profileName
+	config.ConfigName = configName
+	config.StoragePlugin = "sample-storage"
+	config.SelectedBackupPolicy = "daily"
+
+	return config
+}
+
+func getTestWorkflow(workflowType string) *util.Workflow {
+	workflow := &util.Workflow{}
+	workflow.Id = util.GetWorkflowId()
+	workflow.Type = workflowType
+	workflow.Policy = "daily"
+	workflow.Status = "RUNNING"
+
+	return workflow
+}
+
+func readTestWorkflow(config util.Config, workflowId int64) (*util.Workflow, error) {
+	workflow := &util.Workflow{}
+	err := util.ReadGob(dataDir+"/"+config.ProfileName+"/"+config.ConfigName+"/"+util.Int64ToString(workflowId)+"/workflow", &workflow)
+
+	return workflow, err
+}
+
+// waitForWorkflowStatus polls the saved workflow until it has the status, the last status read is returned
+func waitForWorkflowStatus(config util.Config, workflowId int64, status string) string {
+	var lastStatus string
+	for i := 0; i < 500; i++ {
+		workflow, err := readTestWorkflow(config, workflowId)
+		if err == nil {
+			lastStatus = workflow.Status
+			if lastStatus == status {
+				return lastStatus
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return lastStatus
+}
+
+// waitForQueueEmpty waits until the queue has neither running nor queued workflows
+func waitForQueueEmpty(queue *workflowQueue) bool {
+	for i := 0; i < 500; i++ {
+		if len(queue.list()) == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+func hasTestMessage(messages []util.Message, level, text string) bool {
+	for _, message := range messages {
+		if message.Level == level && strings.Contains(message.Message, text) {
+			return true
+		}
+	}
+
+	return false
+}
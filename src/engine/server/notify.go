@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/client"
+	"fossul/src/engine/util"
+)
+
+// sendNotifications delivers the notification to every notifier subscribed to its event, a notifier that
+// still fails after its retries sets the result code
+func sendNotifications(config util.Config, notification util.Notification) util.Result {
+	var result util.Result
+	for _, notifier := range util.GetNotifiersForEvent(config.Notifiers, notification.Event) {
+		err := util.SendNotification(notifier, notification)
+		if err != nil {
+			msg := util.SetMessage("ERROR", "Sending "+notification.Event+" notification to ["+notifier.Name+"] failed! "+err.Error())
+			result.Messages = append(result.Messages, msg)
+			result.Code = 1
+		} else {
+			msg := util.SetMessage("INFO", "Sent "+notification.Event+" notification to ["+notifier.Name+"]")
+			result.Messages = append(result.Messages, msg)
+		}
+	}
+
+	return result
+}
+
+func isNotifyEnabled(config util.Config, event string) bool {
+	return len(util.GetNotifiersForEvent(config.Notifiers, event)) > 0
+}
+
+// notifyStep runs the deprecated SendTrapSuccessCmd, if configured, and sends the success notifications
+func notifyStep(auth client.Auth, run *workflowRun) (util.Result, error) {
+	var result util.Result
+	if run.config.SendTrapSuccessCmd != "" {
+		var err error
+		result, err = client.SendTrapSuccessCmd(auth, run.config)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if isNotifyEnabled(run.config, "success") {
+		notification := util.SetNotification("success", run.config, run.workflow)
+		notifyResult := sendNotifications(run.config, notification)
+
+		result.Messages = append(result.Messages, notifyResult.Messages...)
+		if notifyResult.Code != 0 {
+			result.Code = notifyResult.Code
+		}
+	}
+
+	return result, nil
+}
+
+// getRetentionNotifyMessages sends the retention notifications, failed deliveries are returned as warnings
+func getRetentionNotifyMessages(config util.Config, notification util.Notification) []util.Message {
+	notifyResult := sendNotifications(config, notification)
+
+	var messages []util.Message
+	for _, msg := range notifyResult.Messages {
+		if msg.Level == "ERROR" {
+			msg.Level = "WARN"
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages
+}
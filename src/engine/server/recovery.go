@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/util"
+	"time"
+)
+
+// RecoverWorkflows finds workflows left in RUNNING or QUEUED state by a server restart, unquiesces the
+// application if the workflow got past quiesce, tears down a restored verify target and marks the workflow ABORTED
+func RecoverWorkflows() error {
+	profiles, err := util.DirectoryList(dataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, profileName := range profiles {
+		configs, err := util.DirectoryList(dataDir + "/" + profileName)
+		if err != nil {
+			return err
+		}
+
+		for _, configName := range configs {
+			workflowIds, err := util.DirectoryList(dataDir + "/" + profileName + "/" + configName)
+			if err != nil {
+				return err
+			}
+
+			for _, workflowId := range workflowIds {
+				resultsDir := dataDir + "/" + profileName + "/" + configName + "/" + workflowId
+				workflowFile := resultsDir + "/workflow"
+				if !util.ExistsPath(workflowFile) {
+					continue
+				}
+
+				logger := util.GetServiceLogger().With("workflowId", workflowId).With("profile", profileName).With("config", configName)
+
+				workflow := &util.Workflow{}
+				err := util.ReadGob(workflowFile, &workflow)
+				if err != nil {
+					logger.Error("Couldn't read workflow [" + workflowFile + "] " + err.Error())
+					continue
+				}
+
+				// queue isn't persisted, queued workflows never started so there is nothing to recover
+				if workflow.Status == "QUEUED" {
+					logger.Warn("Aborting workflow left queued by server restart")
+					setComment(resultsDir, "Queued Workflow Dropped By Server Restart", workflow)
+					util.SetWorkflowStatusAborted(workflow)
+					serializeWorkflow(resultsDir, workflow)
+					continue
+				}
+
+				if workflow.Status != "RUNNING" {
+					continue
+				}
+
+				logger.Warn("Recovering workflow left running by server restart")
+				recoverWorkflow(resultsDir, workflow)
+
+				err = workflowLocks.Release(profileName, configName, workflow.Id)
+				if err != nil {
+					logger.Error("Couldn't release workflow lock! " + err.Error())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func recoverWorkflow(resultsDir string, workflow *util.Workflow) {
+	for _, step := range workflow.Steps {
+		if step.Status != "RUNNING" {
+			continue
+		}
+
+		var result util.Result
+		_ = util.ReadGob(resultsDir+"/"+util.IntToString(step.Id), &result)
+
+		msg := util.SetMessage("ERROR", "Step was interrupted by server restart")
+		result.Messages = append(result.Messages, msg)
+		result.Code = 1
+
+		util.SetStepError(workflow, step)
+		serializeWorkflowStepResults(resultsDir, step.Id, result)
+	}
+
+	commentMsg := "Recovering Workflow After Server Restart"
+	setComment(resultsDir, commentMsg, workflow)
+
+	if workflow.Quiesced {
+		config, err := util.ReadWorkflowConfig(resultsDir)
+		if err != nil {
+			step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "unquiesce"})
+			result := util.SetResultMessage(1, "ERROR", "Couldn't read workflow config, application may still be quiesced! "+err.Error())
+
+			util.SetStepError(workflow, step)
+			serializeWorkflowStepResults(resultsDir, step.Id, result)
+		} else {
+			unquiesceOnError(resultsDir, workflow, config, time.Time{})
+		}
+	}
+
+	if workflow.TargetRestored {
+		config, err := util.ReadWorkflowConfig(resultsDir)
+		if err != nil {
+			step := stepInit(resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "command", Hook: "VerifyTeardownCmd"})
+			result := util.SetResultMessage(1, "ERROR", "Couldn't read workflow config, verify target may still exist! "+err.Error())
+
+			util.SetStepError(workflow, step)
+			serializeWorkflowStepResults(resultsDir, step.Id, result)
+		} else {
+			teardownOnError(resultsDir, workflow, config)
+		}
+	}
+
+	commentMsg = "Workflow Aborted"
+	setComment(resultsDir, commentMsg, workflow)
+
+	util.SetWorkflowStatusAborted(workflow)
+	serializeWorkflow(resultsDir, workflow)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/util"
+	"testing"
+)
+
+func TestRecoverWorkflows(t *testing.T) {
+	tests := []struct {
+		name              string
+		status            string
+		isQuiesced        bool
+		expectedStatus    string
+		expectedUnquiesce int
+	}{
+		{name: "running quiesced", status: "RUNNING", isQuiesced: true, expectedStatus: "ABORTED", expectedUnquiesce: 1},
+		{name: "running", status: "RUNNING", expectedStatus: "ABORTED"},
+		{name: "queued", status: "QUEUED", expectedStatus: "ABORTED"},
+		{name: "complete", status: "COMPLETE", expectedStatus: "COMPLETE"},
+	}
+
+	for _, test := range tests {
+		services, cleanup := setupTestServer(t)
+
+		config := getTestConfig("default", "default")
+		config.AppPlugin = "sample-app"
+
+		workflow := getTestWorkflow("backup")
+		workflow.Status = test.status
+		workflow.Quiesced = test.isQuiesced
+		resultsDir := getResultsDir(config, workflow)
+
+		var runningStep util.Step
+		if test.status == "RUNNING" {
+			_, err := workflowLocks.Acquire(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, workflow.Id)
+			if err != nil {
+				t.Logf("ERROR: [%s] %s", test.name, err.Error())
+				t.Fail()
+			}
+
+			runningStep = util.CreateStep(workflow)
+			util.SetWorkflowStep(workflow, runningStep)
+			util.SerializeWorkflowStepResults(resultsDir, runningStep.Id, util.SetResultMessage(0, "INFO", "Backup started"))
+		}
+
+		util.SerializeWorkflowConfig(resultsDir, config)
+		util.SerializeWorkflow(resultsDir, workflow)
+
+		err := RecoverWorkflows()
+		if err != nil {
+			t.Logf("ERROR: [%s] %s", test.name, err.Error())
+			t.Fail()
+		}
+
+		recovered, err := readTestWorkflow(config, workflow.Id)
+		if err != nil || recovered.Status != test.expectedStatus {
+			t.Logf("ERROR: [%s] expected status [%s] got [%s]", test.name, test.expectedStatus, recovered.Status)
+			t.Fail()
+		}
+
+		if calls := services.getCalls("/unquiesce"); calls != test.expectedUnquiesce {
+			t.Logf("ERROR: [%s] expected [%d] unquiesce calls got [%d]", test.name, test.expectedUnquiesce, calls)
+			t.Fail()
+		}
+
+		if recovered.Quiesced {
+			t.Logf("ERROR: [%s] workflow still quiesced after recovery", test.name)
+			t.Fail()
+		}
+
+		if test.status == "RUNNING" {
+			var result util.Result
+			err := util.ReadGob(resultsDir+"/"+util.IntToString(runningStep.Id), &result)
+			if err != nil || recovered.Steps[runningStep.Id].Status != "ERROR" || result.Code != 1 || !hasTestMessage(result.Messages, "ERROR", "interrupted by server restart") {
+				t.Logf("ERROR: [%s] interrupted step not failed %v %v", test.name, recovered.Steps[runningStep.Id], result)
+				t.Fail()
+			}
+		}
+
+		locks, err := workflowLocks.List()
+		if err != nil || len(locks) != 0 {
+			t.Logf("ERROR: [%s] expected no workflow locks got %v", test.name, locks)
+			t.Fail()
+		}
+
+		cleanup()
+	}
+}
@@ -13,128 +13,28 @@ limitations under the License.
 package main
 
 import (
-	"fossul/src/engine/client"
+	"context"
 	"fossul/src/engine/util"
+	"strings"
 )
 
-func startRestoreWorkflowImpl(dataDir string, config util.Config, workflow *util.Workflow) int {
-	auth := SetAuth()
-	resultsDir := dataDir + "/" + config.ProfileName + "/" + config.ConfigName + "/" + util.IntToString(workflow.Id)
-	policy := config.SelectedBackupPolicy
-
-	commentMsg := "Performing Application Pre Restore"
-	setComment(resultsDir, commentMsg, workflow)
-
-	if config.PreAppRestoreCmd != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.PreAppRestoreCmd(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
-	}
-
-	if config.AppPlugin != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.PreRestore(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
-	}
-
-	commentMsg = "Performing Restore"
-	setComment(resultsDir, commentMsg, workflow)
-
-	if config.RestoreCmd != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.RestoreCmd(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
+func startRestoreWorkflowImpl(ctx context.Context, dataDir string, config util.Config, workflow *util.Workflow) int {
+	steps, err := util.GetWorkflowDefinition(config, "restore")
+	if err != nil {
+		workflowDefinitionErrorHandler(err, dataDir, config, workflow)
+		return 1
 	}
 
-	if config.StoragePlugin != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.Restore(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
-	}
-
-	commentMsg = "Performing Application Post Restore"
-	setComment(resultsDir, commentMsg, workflow)
-
-	if config.PostAppRestoreCmd != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.PostAppRestoreCmd(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
+	if len(config.RestorePaths) > 0 {
+		resultsDir := getResultsDir(config, workflow)
+		setComment(resultsDir, "Restoring Selected Paths ["+strings.Join(config.RestorePaths, ",")+"]", workflow)
 	}
 
-	if config.AppPlugin != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.PostRestore(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
+	if util.IsRestoreTargetSet(config.RestoreTarget) {
+		resultsDir := getResultsDir(config, workflow)
+		setComment(resultsDir, "Restoring Into Alternate Target ["+util.GetRestoreTargetDescription(config.RestoreTarget)+"]", workflow)
+		config = util.ApplyRestoreTarget(config, config.RestoreTarget)
 	}
 
-	if config.JobRetention != 0 {
-		step := stepInit(resultsDir, workflow)
-		result := util.DeleteJobs(dataDir, config.ProfileName, config.ConfigName, config.JobRetention)
-
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
-	}
-
-	commentMsg = "Sending Notifications"
-	setComment(resultsDir, commentMsg, workflow)
-
-	if config.SendTrapSuccessCmd != "" {
-		step := stepInit(resultsDir, workflow)
-		result, err := client.SendTrapSuccessCmd(auth, config)
-		if err != nil {
-			HttpErrorHandler(err, resultsDir, policy, step, workflow, result, config)
-			return 1
-		}
-		if resultCode := StepErrorHandler(resultsDir, policy, step, workflow, result, config); resultCode != 0 {
-			return resultCode
-		}
-	}
-
-	commentMsg = "Restore Completed Successfully"
-	setComment(resultsDir, commentMsg, workflow)
-
-	util.SetWorkflowStatusEnd(workflow)
-	util.SerializeWorkflow(resultsDir, workflow)
-
-	//remove workflow lock
-	delete(runningWorkflowMap, config.ProfileName+"-"+config.ConfigName)
-
-	return 0
+	return runWorkflowImpl(ctx, dataDir, config, workflow, steps, "Restore Completed Successfully")
 }
@@ -34,7 +34,10 @@ func NewRouter() *mux.Router {
 		var handler http.Handler
 
 		handler = route.HandlerFunc
+		handler = auditApi(handler, route.Name)
 		handler = util.LogApi(handler, route.Name)
+		handler = util.InstrumentApi(handler, "server", route.Name)
+		handler = util.TraceApi(handler, "server", route.Name)
 
 		handler = basicAuth(handler)
 
@@ -69,6 +72,12 @@ var routes = Routes{
 		"/status",
 		GetStatus,
 	},
+	Route{
+		"GetMetrics",
+		"GET",
+		"/metrics",
+		util.MetricsHandler,
+	},
 	Route{
 		"StartBackupWorkflowLocalConfig",
 		"POST",
@@ -93,6 +102,42 @@ var routes = Routes{
 		"/startRestoreWorkflow/{profileName}/{configName}/{policy}/{workflowId}",
 		StartRestoreWorkflow,
 	},
+	Route{
+		"StartVerifyWorkflow",
+		"POST",
+		"/startVerifyWorkflow/{profileName}/{configName}/{policy}",
+		StartVerifyWorkflow,
+	},
+	Route{
+		"StartVerifyWorkflowBackup",
+		"POST",
+		"/startVerifyWorkflow/{profileName}/{configName}/{policy}/{workflowId}",
+		StartVerifyWorkflow,
+	},
+	Route{
+		"PlanBackupWorkflowLocalConfig",
+		"POST",
+		"/planBackupWorkflowLocalConfig",
+		PlanBackupWorkflowLocalConfig,
+	},
+	Route{
+		"PlanBackupWorkflow",
+		"GET",
+		"/planBackupWorkflow/{profileName}/{configName}/{policy}",
+		PlanBackupWorkflow,
+	},
+	Route{
+		"PlanRestoreWorkflowLocalConfig",
+		"POST",
+		"/planRestoreWorkflowLocalConfig/{workflowId}",
+		PlanRestoreWorkflowLocalConfig,
+	},
+	Route{
+		"PlanRestoreWorkflow",
+		"GET",
+		"/planRestoreWorkflow/{profileName}/{configName}/{policy}/{workflowId}",
+		PlanRestoreWorkflow,
+	},
 	Route{
 		"SendTrapSuccessCmd",
 		"POST",
@@ -141,6 +186,36 @@ var routes = Routes{
 		"/getWorkflowStatus/{profileName}/{configName}/{id}",
 		GetWorkflowStatus,
 	},
+	Route{
+		"StreamWorkflow",
+		"GET",
+		"/streamWorkflow/{profileName}/{configName}/{id}",
+		StreamWorkflow,
+	},
+	Route{
+		"CancelWorkflow",
+		"POST",
+		"/cancelWorkflow/{profileName}/{configName}/{id}",
+		CancelWorkflow,
+	},
+	Route{
+		"ListWorkflowLocks",
+		"GET",
+		"/listWorkflowLocks",
+		ListWorkflowLocks,
+	},
+	Route{
+		"ListWorkflowQueue",
+		"GET",
+		"/listWorkflowQueue",
+		ListWorkflowQueue,
+	},
+	Route{
+		"ReleaseWorkflowLock",
+		"POST",
+		"/releaseWorkflowLock/{profileName}/{configName}",
+		ReleaseWorkflowLock,
+	},
 	Route{
 		"DeleteWorkflowResults",
 		"GET",
@@ -201,6 +276,18 @@ var routes = Routes{
 		"/listProfiles",
 		ListProfiles,
 	},
+	Route{
+		"AddProfileNotifiers",
+		"POST",
+		"/addProfileNotifiers/{profileName}",
+		AddProfileNotifiers,
+	},
+	Route{
+		"GetProfileNotifiers",
+		"GET",
+		"/getProfileNotifiers/{profileName}",
+		GetProfileNotifiers,
+	},
 	Route{
 		"ListConfigs",
 		"GET",
@@ -225,10 +312,34 @@ var routes = Routes{
 		"/deleteSchedule/{profileName}/{configName}/{policy}",
 		DeleteSchedule,
 	},
+	Route{
+		"DeleteWorkflowTypeSchedule",
+		"GET",
+		"/deleteSchedule/{profileName}/{configName}/{policy}/{workflowType}",
+		DeleteSchedule,
+	},
 	Route{
 		"ListSchedules",
 		"GET",
 		"/listSchedules",
 		ListSchedules,
 	},
+	Route{
+		"GetAuditLog",
+		"GET",
+		"/getAuditLog",
+		GetAuditLog,
+	},
+	Route{
+		"GetProfileAuditLog",
+		"GET",
+		"/getAuditLog/{profileName}",
+		GetAuditLog,
+	},
+	Route{
+		"GetConfigAuditLog",
+		"GET",
+		"/getAuditLog/{profileName}/{configName}",
+		GetAuditLog,
+	},
 }
@@ -31,21 +31,30 @@ func StartCron() {
 	c.cronScheduler.Start()
 }
 
-func AddCronSchedule(profileName, configName, policy, cronSchedule string) (cron.EntryID, error) {
+func AddCronSchedule(profileName, configName, policy, workflowType, cronSchedule string) (cron.EntryID, error) {
 	var id cron.EntryID
 	var err error
 
 	auth := SetAuth()
 
+	err = util.ValidateScheduleWorkflowType(workflowType)
+	if err != nil {
+		return id, err
+	}
+
 	id, err = c.cronScheduler.AddFunc(cronSchedule, func() {
-		client.StartBackupWorkflow(auth, profileName, configName, policy)
+		if util.GetScheduleWorkflowType(workflowType) == "verify" {
+			client.StartVerifyWorkflow(auth, profileName, configName, policy, "")
+		} else {
+			client.StartBackupWorkflow(auth, profileName, configName, policy)
+		}
 	})
 
 	if err != nil {
 		return id, err
 	}
 
-	err = writeJobSchedule(id, cronSchedule, profileName, configName, policy)
+	err = writeJobSchedule(id, cronSchedule, profileName, configName, policy, workflowType)
 	if err != nil {
 		return id, err
 	}
@@ -53,8 +62,8 @@ func AddCronSchedule(profileName, configName, policy, cronSchedule string) (cron
 	return id, nil
 }
 
-func DeleteCronSchedule(profileName, configName, policy string) error {
-	path := dataDir + "/" + profileName + "/" + configName + "/jobSchedule_" + policy
+func DeleteCronSchedule(profileName, configName, policy, workflowType string) error {
+	path := getJobScheduleFile(profileName, configName, policy, workflowType)
 	schedule, err := ReadJobSchedule(path)
 	if err != nil {
 		return err
@@ -86,12 +95,12 @@ func LoadCronSchedules() error {
 			return err
 		}
 
-		id, err := AddCronSchedule(schedule.ProfileName, schedule.ConfigName, schedule.BackupPolicy, schedule.CronSchedule)
+		id, err := AddCronSchedule(schedule.ProfileName, schedule.ConfigName, schedule.BackupPolicy, schedule.WorkflowType, schedule.CronSchedule)
 		if err != nil {
 			return err
 		}
 
-		err = writeJobSchedule(id, schedule.CronSchedule, schedule.ProfileName, schedule.ConfigName, schedule.BackupPolicy)
+		err = writeJobSchedule(id, schedule.CronSchedule, schedule.ProfileName, schedule.ConfigName, schedule.BackupPolicy, schedule.WorkflowType)
 		if err != nil {
 			return err
 		}
@@ -105,16 +114,17 @@ func getCron() *CronScheduler {
 	return c
 }
 
-func writeJobSchedule(id cron.EntryID, cronSchedule, profileName, configName, policy string) error {
+func writeJobSchedule(id cron.EntryID, cronSchedule, profileName, configName, policy, workflowType string) error {
 	var jobSchedule util.JobSchedule
 	jobSchedule.CronId = id
 	jobSchedule.CronSchedule = cronSchedule
 	jobSchedule.ProfileName = profileName
 	jobSchedule.ConfigName = configName
 	jobSchedule.BackupPolicy = policy
+	jobSchedule.WorkflowType = util.GetScheduleWorkflowType(workflowType)
 
 	scheduleFileDir := dataDir + "/" + profileName + "/" + configName
-	scheduleFile := dataDir + "/" + profileName + "/" + configName + "/jobSchedule_" + policy
+	scheduleFile := getJobScheduleFile(profileName, configName, policy, workflowType)
 	err := util.CreateDir(scheduleFileDir, 0755)
 	if err != nil {
 		return err
@@ -128,6 +138,16 @@ func writeJobSchedule(id cron.EntryID, cronSchedule, profileName, configName, po
 	return nil
 }
 
+// getJobScheduleFile returns the schedule file, backup schedules keep the original jobSchedule_<policy> name
+func getJobScheduleFile(profileName, configName, policy, workflowType string) string {
+	workflowType = util.GetScheduleWorkflowType(workflowType)
+	if workflowType == "backup" {
+		return dataDir + "/" + profileName + "/" + configName + "/jobSchedule_" + policy
+	}
+
+	return dataDir + "/" + profileName + "/" + configName + "/jobSchedule_" + workflowType + "_" + policy
+}
+
 func ReadJobSchedule(scheduleFile string) (util.JobSchedule, error) {
 	jobSchedule := &util.JobSchedule{}
 	err := util.ReadGob(scheduleFile, &jobSchedule)
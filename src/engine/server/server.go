@@ -21,7 +21,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 )
 
 const version = "1.0.0"
@@ -38,8 +40,11 @@ var appPort string = os.Getenv("FOSSUL_APP_CLIENT_PORT")
 var storageHostname string = os.Getenv("FOSSUL_STORAGE_CLIENT_HOSTNAME")
 var storagePort string = os.Getenv("FOSSUL_STORAGE_CLIENT_PORT")
 var debug string = os.Getenv("FOSSUL_SERVER_DEBUG")
+var lockTimeout string = os.Getenv("FOSSUL_SERVER_LOCK_TIMEOUT")
+var maxWorkflows string = os.Getenv("FOSSUL_SERVER_MAX_WORKFLOWS")
+var maxWorkflowsPerStorageHost string = os.Getenv("FOSSUL_SERVER_MAX_WORKFLOWS_PER_STORAGE_HOST")
 
-var runningWorkflowMap map[string]string = make(map[string]string)
+var workflowLocks *util.LockManager
 
 // @title Fossul Framework Server API
 // @version 1.0
@@ -54,9 +59,19 @@ var runningWorkflowMap map[string]string = make(map[string]string)
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 
 func main() {
+	serviceLogger, err := util.NewServiceLogger("server", util.GetLogSettings("FOSSUL_SERVER", debug == "true"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	util.SetServiceLogger(serviceLogger)
+
+	shutdownTracing, err := util.InitTracing("server", util.GetTraceSettings("FOSSUL_SERVER"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("Configs directory [" + configDir + "] Data directory [" + dataDir + "]")
-	err := util.CreateDir(configDir, 0755)
+	err = util.CreateDir(configDir, 0755)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -69,6 +84,15 @@ func main() {
 	router := NewRouter()
 	router.PathPrefix("/api/v1").Handler(httpSwagger.WrapHandler)
 
+	workflowLocks = util.NewLockManager(dataDir, getLockTimeout())
+	queuedWorkflows = newWorkflowQueue(getWorkflowLimit(maxWorkflows), getWorkflowLimit(maxWorkflowsPerStorageHost))
+
+	err = RecoverWorkflows()
+	if err != nil {
+		log.Fatal(err)
+	}
+	go queuedWorkflows.run()
+
 	StartCron()
 	err = LoadCronSchedules()
 	if err != nil {
@@ -80,6 +104,9 @@ func main() {
 		Handler: router,
 	}
 
+	// workflow event streams are long lived, end them so shutdown doesn't wait on them
+	srv.RegisterOnShutdown(workflowEvents.close)
+
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sigint := make(chan os.Signal, 1)
@@ -95,6 +122,11 @@ func main() {
 			log.Println("Storage service shutdown failed! %v", err)
 		}
 
+		// flush spans of finished requests to the exporter
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Println("Tracing shutdown failed [" + err.Error() + "]")
+		}
+
 		log.Println("Stopping server service on port [" + port + "]")
 		close(idleConnsClosed)
 	}()
@@ -107,6 +139,34 @@ func main() {
 	<-idleConnsClosed
 }
 
+// getLockTimeout returns how long a workflow lock isn't refreshed before it is considered stale, default is 24 hours
+func getLockTimeout() time.Duration {
+	if lockTimeout == "" {
+		return 24 * time.Hour
+	}
+
+	timeout, err := time.ParseDuration(lockTimeout)
+	if err != nil {
+		log.Fatal("Invalid lock timeout [" + lockTimeout + "] " + err.Error())
+	}
+
+	return timeout
+}
+
+// getWorkflowLimit returns the maximum number of concurrent workflows, 0 means no limit
+func getWorkflowLimit(limit string) int {
+	if limit == "" {
+		return 0
+	}
+
+	max, err := strconv.Atoi(limit)
+	if err != nil || max < 0 {
+		log.Fatal("Invalid maximum concurrent workflows [" + limit + "]")
+	}
+
+	return max
+}
+
 func printConfigDebug(config util.Config) {
 	if debug == "true" {
 		log.Println("[DEBUG]", config)
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fossul/src/engine/client"
+	"fossul/src/engine/util"
+)
+
+// startVerifyWorkflowImpl restores the selected or latest backup into the verify target, checks and tears it down
+func startVerifyWorkflowImpl(ctx context.Context, dataDir string, config util.Config, workflow *util.Workflow) int {
+	steps, err := util.GetWorkflowDefinition(config, "verify")
+	if err != nil {
+		workflowDefinitionErrorHandler(err, dataDir, config, workflow)
+		return 1
+	}
+
+	if config.SelectedWorkflowId == 0 {
+		backup, err := getLatestBackup(config)
+		if err != nil {
+			workflowInitErrorHandler("Couldn't select backup to verify! "+err.Error(), dataDir, config, workflow)
+			return 1
+		}
+
+		config.SelectedWorkflowId = util.StringToInt64(backup.WorkflowId)
+		resultsDir := getResultsDir(config, workflow)
+		setComment(resultsDir, "Verifying Latest Backup ["+backup.Name+"_"+backup.Policy+"_"+backup.WorkflowId+"_"+util.IntToString(backup.Epoch)+"]", workflow)
+	}
+
+	config = util.ApplyRestoreTarget(config, config.VerifyTarget)
+
+	return runWorkflowImpl(ctx, dataDir, config, workflow, steps, "Verify Completed Successfully, Backup Restore Passed")
+}
+
+func getLatestBackup(config util.Config) (util.Backup, error) {
+	auth := SetAuth()
+
+	backups, err := client.BackupList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+	if err != nil {
+		return util.Backup{}, err
+	}
+
+	if backups.Result.Code != 0 {
+		return util.Backup{}, errors.New("Backup list failed with code [" + util.IntToString(backups.Result.Code) + "]")
+	}
+
+	backup, isFound := util.GetLatestBackup(config.SelectedBackupPolicy, backups.Backups)
+	if !isFound {
+		return backup, errors.New("No backups found for policy [" + config.SelectedBackupPolicy + "]")
+	}
+
+	return backup, nil
+}
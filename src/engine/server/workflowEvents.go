@@ -0,0 +1,353 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"fossul/src/engine/util"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const workflowEventBufferSize = 256
+const workflowEventKeepAlive = 15 * time.Second
+
+// workflowEventHub publishes workflow changes to clients streaming a workflow. Workflows are keyed by their
+// results dir. The last published state of each running workflow is kept so only changes are published.
+type workflowEventHub struct {
+	mutex       sync.Mutex
+	subscribers map[string]map[chan util.WorkflowEvent]bool
+	states      map[string]*workflowEventState
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// workflowEventState tracks what was published for a workflow, it is used by the hub and by each stream
+// to drop events that were already sent
+type workflowEventState struct {
+	workflowId   int64
+	status       string
+	stepStatus   map[int]string
+	messageCount map[int]int
+}
+
+var workflowEvents = newWorkflowEventHub()
+
+func newWorkflowEventHub() *workflowEventHub {
+	hub := &workflowEventHub{}
+	hub.subscribers = make(map[string]map[chan util.WorkflowEvent]bool)
+	hub.states = make(map[string]*workflowEventState)
+	hub.done = make(chan struct{})
+
+	return hub
+}
+
+func newWorkflowEventState() *workflowEventState {
+	state := &workflowEventState{}
+	state.stepStatus = make(map[int]string)
+	state.messageCount = make(map[int]int)
+
+	return state
+}
+
+// serializeWorkflow persists the workflow and publishes the changes to clients following it
+func serializeWorkflow(resultsDir string, workflow *util.Workflow) {
+	util.SerializeWorkflow(resultsDir, workflow)
+	workflowEvents.publishWorkflow(resultsDir, workflow)
+}
+
+// serializeWorkflowStepResults persists the step results and publishes new messages to clients following the workflow
+func serializeWorkflowStepResults(resultsDir string, stepId int, result util.Result) {
+	util.SerializeWorkflowStepResults(resultsDir, stepId, result)
+	workflowEvents.publishStepResults(resultsDir, stepId, result)
+}
+
+// streamWorkflowEvents replays the workflow from the results dir and then streams events as they are published,
+// until the workflow ends, the client disconnects or the server shuts down
+func streamWorkflowEvents(w http.ResponseWriter, flusher http.Flusher, r *http.Request, resultsDir string) {
+	sent := newWorkflowEventState()
+	keepAlive := time.NewTicker(workflowEventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		// subscribe before replay so no event is missed, events already replayed are dropped
+		events := workflowEvents.subscribe(resultsDir)
+
+		isTerminal, err := replayWorkflowEvents(w, resultsDir, sent)
+		flusher.Flush()
+		if err != nil || isTerminal {
+			workflowEvents.unsubscribe(resultsDir, events)
+			return
+		}
+
+		isLagging := false
+		for !isLagging {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					isLagging = true
+					break
+				}
+
+				if !sent.isNew(event) {
+					continue
+				}
+
+				err := writeWorkflowEvent(w, event)
+				flusher.Flush()
+				if err != nil || (event.Type == "workflow" && util.IsWorkflowStatusTerminal(event.Status)) {
+					workflowEvents.unsubscribe(resultsDir, events)
+					return
+				}
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				workflowEvents.unsubscribe(resultsDir, events)
+				return
+			case <-workflowEvents.done:
+				workflowEvents.unsubscribe(resultsDir, events)
+				return
+			}
+		}
+	}
+}
+
+// replayWorkflowEvents sends the workflow state found in the results dir that wasn't sent yet
+func replayWorkflowEvents(w http.ResponseWriter, resultsDir string, sent *workflowEventState) (bool, error) {
+	workflow := &util.Workflow{}
+	err := util.ReadGob(resultsDir+"/workflow", &workflow)
+	if err != nil {
+		return false, err
+	}
+
+	var events []util.WorkflowEvent
+	var workflowEvent *util.WorkflowEvent
+	stepEvents := make(map[int]util.WorkflowEvent)
+	for _, event := range sent.diffWorkflow(workflow) {
+		if event.Type == "workflow" {
+			statusEvent := event
+			workflowEvent = &statusEvent
+			continue
+		}
+		stepEvents[event.StepId] = event
+	}
+
+	// messages can be written to a step without changing its status so results of all steps are checked
+	for _, step := range workflow.Steps {
+		if event, ok := stepEvents[step.Id]; ok {
+			events = append(events, event)
+		}
+
+		var result util.Result
+		err := util.ReadGob(resultsDir+"/"+util.IntToString(step.Id), &result)
+		if err == nil {
+			events = append(events, sent.diffStepResults(step.Id, result)...)
+		}
+	}
+
+	if workflowEvent != nil {
+		events = append(events, *workflowEvent)
+	}
+
+	for _, event := range events {
+		err := writeWorkflowEvent(w, event)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return util.IsWorkflowStatusTerminal(workflow.Status), nil
+}
+
+func writeWorkflowEvent(w http.ResponseWriter, event util.WorkflowEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// subscribe returns a channel receiving events of the workflow. The channel is closed if the
+// subscriber can't keep up, the subscriber should then resync from the results dir.
+func (hub *workflowEventHub) subscribe(resultsDir string) chan util.WorkflowEvent {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	events := make(chan util.WorkflowEvent, workflowEventBufferSize)
+	if hub.subscribers[resultsDir] == nil {
+		hub.subscribers[resultsDir] = make(map[chan util.WorkflowEvent]bool)
+	}
+	hub.subscribers[resultsDir][events] = true
+
+	return events
+}
+
+func (hub *workflowEventHub) unsubscribe(resultsDir string, events chan util.WorkflowEvent) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	hub.remove(resultsDir, events)
+}
+
+func (hub *workflowEventHub) publishWorkflow(resultsDir string, workflow *util.Workflow) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	state := hub.getState(resultsDir)
+	events := state.diffWorkflow(workflow)
+
+	if util.IsWorkflowStatusTerminal(workflow.Status) {
+		delete(hub.states, resultsDir)
+	}
+
+	hub.send(resultsDir, events)
+}
+
+func (hub *workflowEventHub) publishStepResults(resultsDir string, stepId int, result util.Result) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	state := hub.getState(resultsDir)
+	events := state.diffStepResults(stepId, result)
+
+	hub.send(resultsDir, events)
+}
+
+// close ends all streams, it is called when the server shuts down
+func (hub *workflowEventHub) close() {
+	hub.closeOnce.Do(func() {
+		close(hub.done)
+	})
+}
+
+func (hub *workflowEventHub) getState(resultsDir string) *workflowEventState {
+	state, ok := hub.states[resultsDir]
+	if !ok {
+		state = newWorkflowEventState()
+		state.workflowId = util.StringToInt64(filepath.Base(resultsDir))
+		hub.states[resultsDir] = state
+	}
+
+	return state
+}
+
+func (hub *workflowEventHub) send(resultsDir string, events []util.WorkflowEvent) {
+	for subscriber := range hub.subscribers[resultsDir] {
+		for _, event := range events {
+			select {
+			case subscriber <- event:
+			default:
+				hub.remove(resultsDir, subscriber)
+			}
+
+			if _, ok := hub.subscribers[resultsDir][subscriber]; !ok {
+				break
+			}
+		}
+	}
+}
+
+func (hub *workflowEventHub) remove(resultsDir string, events chan util.WorkflowEvent) {
+	if _, ok := hub.subscribers[resultsDir][events]; !ok {
+		return
+	}
+
+	delete(hub.subscribers[resultsDir], events)
+	close(events)
+
+	if len(hub.subscribers[resultsDir]) == 0 {
+		delete(hub.subscribers, resultsDir)
+	}
+}
+
+func (state *workflowEventState) diffWorkflow(workflow *util.Workflow) []util.WorkflowEvent {
+	var events []util.WorkflowEvent
+	state.workflowId = workflow.Id
+
+	for _, step := range workflow.Steps {
+		if status, ok := state.stepStatus[step.Id]; ok && status == step.Status {
+			continue
+		}
+		state.stepStatus[step.Id] = step.Status
+
+		var event util.WorkflowEvent
+		event.Type = "step"
+		event.WorkflowId = workflow.Id
+		event.StepId = step.Id
+		event.Status = step.Status
+		event.Label = step.Label
+		events = append(events, event)
+	}
+
+	if workflow.Status != state.status {
+		state.status = workflow.Status
+
+		var event util.WorkflowEvent
+		event.Type = "workflow"
+		event.WorkflowId = workflow.Id
+		event.Status = workflow.Status
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func (state *workflowEventState) diffStepResults(stepId int, result util.Result) []util.WorkflowEvent {
+	var events []util.WorkflowEvent
+
+	for index := state.messageCount[stepId]; index < len(result.Messages); index++ {
+		var event util.WorkflowEvent
+		event.Type = "message"
+		event.WorkflowId = state.workflowId
+		event.StepId = stepId
+		event.Index = index
+		message := result.Messages[index]
+		event.Message = &message
+		events = append(events, event)
+	}
+
+	if len(result.Messages) > state.messageCount[stepId] {
+		state.messageCount[stepId] = len(result.Messages)
+	}
+
+	return events
+}
+
+// isNew records an event received from the hub and reports if it wasn't already sent
+func (state *workflowEventState) isNew(event util.WorkflowEvent) bool {
+	switch event.Type {
+	case "workflow":
+		if event.Status == state.status {
+			return false
+		}
+		state.status = event.Status
+	case "step":
+		if status, ok := state.stepStatus[event.StepId]; ok && status == event.Status {
+			return false
+		}
+		state.stepStatus[event.StepId] = event.Status
+	case "message":
+		if event.Index < state.messageCount[event.StepId] {
+			return false
+		}
+		state.messageCount[event.StepId] = event.Index + 1
+	}
+
+	return true
+}
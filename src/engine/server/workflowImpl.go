@@ -0,0 +1,583 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fossul/src/engine/client"
+	"fossul/src/engine/util"
+	"strings"
+	"time"
+)
+
+type workflowRun struct {
+	ctx          context.Context
+	auth         client.Auth
+	dataDir      string
+	resultsDir   string
+	policy       string
+	isQuiesce    bool
+	quiesceAt    time.Time
+	config       util.Config
+	workflow     *util.Workflow
+	stepId       int
+	stepMessages []util.Message
+	logger       *util.Logger
+}
+
+func runWorkflowImpl(workflowCtx context.Context, dataDir string, config util.Config, workflow *util.Workflow, steps []util.WorkflowStepDefinition, completeMsg string) (resultCode int) {
+	run := &workflowRun{}
+	run.dataDir = dataDir
+	run.resultsDir = dataDir + "/" + config.ProfileName + "/" + config.ConfigName + "/" + util.Int64ToString(workflow.Id)
+	run.policy = config.SelectedBackupPolicy
+	run.config = config
+	run.workflow = workflow
+	run.logger = util.GetServiceLogger().With("workflowId", util.Int64ToString(workflow.Id)).With("profile", config.ProfileName).With("config", config.ConfigName)
+
+	ctx, span := util.StartWorkflowSpan(workflowCtx, config, workflow)
+	defer func() { util.EndSpan(span, resultCode, nil) }()
+	run.ctx = ctx
+	run.auth = SetAuth().WithContext(run.ctx)
+	run.logger = run.logger.With("traceId", util.GetTraceId(run.ctx))
+
+	stopLockRefresh := refreshWorkflowLock(run)
+	defer stopLockRefresh()
+
+	util.SerializeWorkflowConfig(run.resultsDir, config)
+	run.logger.Info("Starting " + workflow.Type + " workflow")
+
+	for _, stepDefinition := range steps {
+		if run.ctx.Err() != nil {
+			cancelWorkflowHandler(run, nil, util.Result{})
+			return 1
+		}
+
+		if stepDefinition.Kind == "comment" {
+			if isWorkflowCommentEnabled(run.config, stepDefinition) {
+				setComment(run.resultsDir, stepDefinition.Comment, workflow)
+			}
+			continue
+		}
+
+		if !isWorkflowStepEnabled(run.config, stepDefinition) {
+			continue
+		}
+
+		// application is considered quiesced as soon as the plugin quiesce starts so a failure unquiesces it
+		if stepDefinition.Kind == "quiesce" {
+			setQuiesced(run, true)
+		}
+
+		// verify target holds restored data as soon as restore starts, teardown is only attempted once
+		if workflow.Type == "verify" && stepDefinition.Kind == "restore" {
+			setTargetRestored(run, true)
+		} else if stepDefinition.Kind == "command" && stepDefinition.Hook == "VerifyTeardownCmd" {
+			setTargetRestored(run, false)
+		}
+
+		step := stepInit(run.resultsDir, workflow, run.config, stepDefinition)
+		run.stepId = step.Id
+		stepStart := time.Now()
+		stepCtx, stepSpan := util.StartStepSpan(run.ctx, step)
+		result, err := executeWorkflowStepWithPolicy(run, stepCtx, stepDefinition)
+		stepStatus := getStepStatus(run, result, err)
+		util.ObserveStepDuration(workflow.Type, stepDefinition.Kind, stepStatus, stepStart)
+		util.EndStepSpan(stepSpan, stepStatus, result.Code, err)
+		logStep(run, step, stepStatus, err)
+
+		if run.ctx.Err() != nil {
+			cancelWorkflowHandler(run, &step, result)
+			return 1
+		}
+
+		if stepDefinition.OnFailure == "continue" && (err != nil || result.Code != 0) {
+			continueOnFailureHandler(err, run.resultsDir, step, workflow, result)
+			continue
+		}
+
+		if err != nil {
+			HttpErrorHandlerBackup(err, run.isQuiesce, run.quiesceAt, run.resultsDir, run.policy, step, workflow, result, run.config)
+			return 1
+		}
+		if resultCode := StepErrorHandlerBackup(run.isQuiesce, run.quiesceAt, run.resultsDir, run.policy, step, workflow, result, run.config); resultCode != 0 {
+			return resultCode
+		}
+
+		setQuiesceState(run, stepDefinition)
+	}
+
+	setComment(run.resultsDir, completeMsg, workflow)
+
+	util.SetWorkflowStatusEnd(workflow)
+	serializeWorkflow(run.resultsDir, workflow)
+	run.logger.Info("Workflow " + workflow.Type + " completed successfully")
+
+	if workflow.Type == "backup" {
+		util.SetLastSuccessfulBackup(config)
+	}
+
+	//remove workflow lock
+	workflowLocks.Release(config.ProfileName, config.ConfigName, workflow.Id)
+
+	return 0
+}
+
+// refreshWorkflowLock keeps the lock of a long running workflow from becoming stale until the returned func is called
+func refreshWorkflowLock(run *workflowRun) func() {
+	interval := workflowLocks.GetRefreshInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := workflowLocks.Refresh(run.config.ProfileName, run.config.ConfigName, run.workflow.Id)
+				if err != nil {
+					run.logger.Warn("Couldn't refresh workflow lock! " + err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+func getStepStatus(run *workflowRun, result util.Result, err error) string {
+	switch {
+	case run.ctx.Err() != nil:
+		return "CANCELLED"
+	case err != nil || result.Code != 0:
+		return "ERROR"
+	}
+
+	return "COMPLETE"
+}
+
+func logStep(run *workflowRun, step util.Step, status string, err error) {
+	stepLogger := run.logger.With("stepId", util.IntToString(step.Id))
+	msg := "Step [" + step.Kind + "] " + status
+	if err != nil {
+		msg = msg + " " + err.Error()
+	}
+
+	if status == "COMPLETE" {
+		stepLogger.Info(msg)
+	} else {
+		stepLogger.Error(msg)
+	}
+}
+
+// isWorkflowCommentEnabled determines if a comment is written, a comment with onlyIf is only written if that step kind is enabled
+func isWorkflowCommentEnabled(config util.Config, stepDefinition util.WorkflowStepDefinition) bool {
+	if stepDefinition.OnlyIf == "" {
+		return true
+	}
+
+	return isWorkflowStepEnabled(config, util.WorkflowStepDefinition{Kind: stepDefinition.OnlyIf})
+}
+
+// isWorkflowStepEnabled determines if a step applies to the config, ex: plugin steps are skipped if no plugin is configured
+func isWorkflowStepEnabled(config util.Config, stepDefinition util.WorkflowStepDefinition) bool {
+	switch stepDefinition.Kind {
+	case "discover":
+		return config.AppPlugin != "" && config.AutoDiscovery == true
+	case "command":
+		if stepDefinition.Hook != "" {
+			return util.GetWorkflowHookCmd(config, stepDefinition.Hook) != ""
+		}
+		return true
+	case "quiesce", "unquiesce", "preRestore", "postRestore":
+		return config.AppPlugin != ""
+	case "backup", "backupRetention", "restore":
+		return config.StoragePlugin != ""
+	case "backupVerify":
+		return config.StoragePlugin != "" && config.VerifyBackupBeforeRestore
+	case "archive", "archiveRetention", "archiveRestore":
+		return config.ArchivePlugin != ""
+	case "jobRetention":
+		return config.JobRetention != 0
+	case "notify":
+		return config.SendTrapSuccessCmd != "" || isNotifyEnabled(config, "success")
+	}
+
+	return false
+}
+
+// executeWorkflowStepWithPolicy enforces the step timeout and retries, each attempt is recorded in the step results.
+// Messages are only appended to the step results so messages streamed while the step runs stay in place.
+// An aborted attempt cancels its request, the service then kills the plugin process and stops pod copies. Only
+// idempotent step kinds are retried after a timeout since the service may still be finishing the aborted attempt.
+// While the application is quiesced a step is also bound by the maximum quiesce time, when it is exceeded the
+// step is aborted which causes the application to be unquiesced.
+func executeWorkflowStepWithPolicy(run *workflowRun, stepCtx context.Context, stepDefinition util.WorkflowStepDefinition) (util.Result, error) {
+	stepPolicy := util.GetStepPolicy(stepDefinition.Kind, run.config.StepPolicies)
+	attempts := stepPolicy.Retries + 1
+
+	var messages []util.Message
+	var result util.Result
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		run.stepMessages = append([]util.Message{}, messages...)
+		ctx, cancel, isQuiesceDeadline := getStepContext(run, stepCtx, stepDefinition, stepPolicy)
+		result, err = executeWorkflowStep(run, run.auth.WithContext(ctx), stepDefinition)
+		isTimeout := ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if err == nil && result.Code == 0 {
+			break
+		}
+
+		if isTimeout && isQuiesceDeadline {
+			msg := util.SetMessage("ERROR", "Maximum quiesce time of ["+util.IntToString(run.config.MaxQuiesceTime)+"] seconds exceeded, forcing unquiesce")
+			result.Messages = append(result.Messages, msg)
+			break
+		} else if isTimeout {
+			msg := util.SetMessage("ERROR", "Step timed out after ["+util.IntToString(stepPolicy.Timeout)+"] seconds")
+			result.Messages = append(result.Messages, msg)
+		}
+
+		if attempt == attempts || run.ctx.Err() != nil {
+			break
+		}
+
+		// after a timeout or connection error the aborted attempt may still be running on the service
+		if (isTimeout || err != nil) && !util.IsStepIdempotent(stepDefinition.Kind) {
+			msg := util.SetMessage("WARN", "Step kind ["+stepDefinition.Kind+"] is only retried if the service reports the failure, not after a timeout or connection error")
+			result.Messages = append(result.Messages, msg)
+			break
+		}
+
+		backoff := util.GetStepRetryBackoff(stepPolicy, attempt)
+		msg := util.SetMessage("WARN", "Attempt ["+util.IntToString(attempt)+"] of ["+util.IntToString(attempts)+"] failed, retrying in ["+backoff.String()+"]")
+		messages = append(messages, result.Messages...)
+		if err != nil {
+			messages = append(messages, util.SetMessage("ERROR", err.Error()))
+		}
+		messages = append(messages, msg)
+
+		select {
+		case <-time.After(backoff):
+		case <-run.ctx.Done():
+		}
+	}
+
+	if len(messages) > 0 {
+		result.Messages = util.PrependMessages(messages, result.Messages)
+	}
+
+	return result, err
+}
+
+// appendStepMessage adds a message streamed by the service to the results of the running step, the results
+// are saved and the message is published right away so long steps can be followed
+func appendStepMessage(run *workflowRun, message util.Message) {
+	run.stepMessages = append(run.stepMessages, message)
+	serializeWorkflowStepResults(run.resultsDir, run.stepId, util.SetResult(0, run.stepMessages))
+}
+
+func getStepContext(run *workflowRun, stepCtx context.Context, stepDefinition util.WorkflowStepDefinition, stepPolicy util.StepPolicy) (context.Context, context.CancelFunc, bool) {
+	ctx := util.WithWorkflowStep(stepCtx, run.workflow.Id, run.stepId)
+	cancelFuncs := []context.CancelFunc{}
+
+	if stepPolicy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, util.GetStepTimeout(stepPolicy))
+		cancelFuncs = append(cancelFuncs, cancel)
+	}
+
+	isQuiesceDeadline := false
+	if run.isQuiesce && run.config.MaxQuiesceTime > 0 && !isUnquiesceStep(stepDefinition) {
+		quiesceDeadline := run.quiesceAt.Add(time.Duration(run.config.MaxQuiesceTime) * time.Second)
+		if deadline, ok := ctx.Deadline(); !ok || quiesceDeadline.Before(deadline) {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, quiesceDeadline)
+			cancelFuncs = append(cancelFuncs, cancel)
+			isQuiesceDeadline = true
+		}
+	}
+
+	cancel := func() {
+		for _, cancel := range cancelFuncs {
+			cancel()
+		}
+	}
+
+	return ctx, cancel, isQuiesceDeadline
+}
+
+func isUnquiesceStep(stepDefinition util.WorkflowStepDefinition) bool {
+	if stepDefinition.Kind == "unquiesce" {
+		return true
+	}
+
+	if stepDefinition.Kind == "command" && util.ExistsInArray([]string{"PreAppUnquiesceCmd", "AppUnquiesceCmd", "PostAppUnquiesceCmd"}, stepDefinition.Hook) {
+		return true
+	}
+
+	return false
+}
+
+func executeWorkflowStep(run *workflowRun, auth client.Auth, stepDefinition util.WorkflowStepDefinition) (util.Result, error) {
+	config := run.config
+
+	switch stepDefinition.Kind {
+	case "discover":
+		return discoverStep(auth, run)
+	case "command":
+		return commandStep(auth, config, stepDefinition)
+	case "quiesce":
+		setQuiesced(run, true)
+		return client.Quiesce(auth, config)
+	case "unquiesce":
+		return client.Unquiesce(auth, config)
+	case "backup":
+		return client.Backup(auth, config, func(message util.Message) { appendStepMessage(run, message) })
+	case "backupRetention":
+		return backupRetentionStep(auth, run)
+	case "archive":
+		return client.Archive(auth, config, func(message util.Message) { appendStepMessage(run, message) })
+	case "archiveRetention":
+		return archiveRetentionStep(auth, run)
+	case "archiveRestore":
+		return archiveRestoreStep(auth, config)
+	case "backupVerify":
+		return backupVerifyStep(auth, config)
+	case "preRestore":
+		return client.PreRestore(auth, config)
+	case "restore":
+		return client.Restore(auth, config)
+	case "postRestore":
+		return client.PostRestore(auth, config)
+	case "jobRetention":
+		return util.DeleteJobs(run.dataDir, config.ProfileName, config.ConfigName, config.JobRetention), nil
+	case "notify":
+		return notifyStep(auth, run)
+	}
+
+	return util.SetResultMessage(1, "ERROR", "Workflow step kind ["+stepDefinition.Kind+"] is not supported"), nil
+}
+
+func discoverStep(auth client.Auth, run *workflowRun) (util.Result, error) {
+	discoverResult, err := client.Discover(auth, run.config)
+	if err != nil || discoverResult.Result.Code != 0 {
+		return discoverResult.Result, err
+	}
+
+	// save discovered files in config struct
+	if len(run.config.StoragePluginParameters) == 0 {
+		run.config.StoragePluginParameters = map[string]string{}
+	}
+
+	dataFilePaths, logFilePaths := setDiscoverFileList(run.config, discoverResult)
+
+	dataFilePathsToString := strings.Join(dataFilePaths, ",")
+	if len(dataFilePathsToString) != 0 {
+		run.config.StoragePluginParameters["DataFilePaths"] = dataFilePathsToString
+	}
+
+	logFilePathsToString := strings.Join(logFilePaths, ",")
+	if len(logFilePathsToString) != 0 {
+		run.config.StoragePluginParameters["LogFilePaths"] = logFilePathsToString
+	}
+
+	return discoverResult.Result, nil
+}
+
+// backupRetentionStep deletes expired backups and records the remaining backups of the policy. When notifiers
+// subscribe to retention the backups are also listed before so the deleted backups can be reported, a failed
+// retention notification is logged as a warning and doesn't fail the step.
+func backupRetentionStep(auth client.Auth, run *workflowRun) (util.Result, error) {
+	config := run.config
+	isNotify := isNotifyEnabled(config, "retention")
+
+	var before util.Backups
+	var err error
+	if isNotify {
+		before, err = client.BackupList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+		if err != nil || before.Result.Code != 0 {
+			return before.Result, err
+		}
+	}
+
+	result, err := client.BackupDelete(auth, config)
+	if err != nil || result.Code != 0 {
+		return result, err
+	}
+
+	after, err := client.BackupList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+	if err != nil || after.Result.Code != 0 {
+		msg := util.SetMessage("WARN", "Couldn't list backups after retention")
+		result.Messages = append(result.Messages, msg)
+		return result, nil
+	}
+	util.SetBackupCount(config, len(util.GetBackupsByPolicy(config.SelectedBackupPolicy, after.Backups)))
+
+	deletedBackups := util.GetDeletedBackups(before.Backups, after.Backups)
+	if isNotify && len(deletedBackups) > 0 {
+		notification := util.SetNotification("retention", config, run.workflow)
+		notification.DeletedBackups = deletedBackups
+		result.Messages = append(result.Messages, getRetentionNotifyMessages(config, notification)...)
+	}
+
+	return result, nil
+}
+
+func archiveRetentionStep(auth client.Auth, run *workflowRun) (util.Result, error) {
+	config := run.config
+	isNotify := isNotifyEnabled(config, "retention")
+
+	var before util.Archives
+	var err error
+	if isNotify {
+		before, err = client.ArchiveList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+		if err != nil || before.Result.Code != 0 {
+			return before.Result, err
+		}
+	}
+
+	result, err := client.ArchiveDelete(auth, config)
+	if err != nil || result.Code != 0 {
+		return result, err
+	}
+
+	after, err := client.ArchiveList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+	if err != nil || after.Result.Code != 0 {
+		msg := util.SetMessage("WARN", "Couldn't list archives after retention")
+		result.Messages = append(result.Messages, msg)
+		return result, nil
+	}
+	util.SetArchiveCount(config, len(util.GetArchivesByPolicy(config.SelectedBackupPolicy, after.Archives)))
+
+	deletedArchives := util.GetDeletedArchives(before.Archives, after.Archives)
+	if isNotify && len(deletedArchives) > 0 {
+		notification := util.SetNotification("retention", config, run.workflow)
+		notification.DeletedArchives = deletedArchives
+		result.Messages = append(result.Messages, getRetentionNotifyMessages(config, notification)...)
+	}
+
+	return result, nil
+}
+
+// archiveRestoreStep stages the archive back to the backup destination when the backup to restore is no longer local
+func archiveRestoreStep(auth client.Auth, config util.Config) (util.Result, error) {
+	workflowId := util.Int64ToString(config.SelectedWorkflowId)
+
+	backups, err := client.BackupList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+	if err != nil || backups.Result.Code != 0 {
+		return backups.Result, err
+	}
+
+	_, isFound := util.GetBackupByWorkflowId(config.SelectedBackupPolicy, workflowId, backups.Backups)
+	if isFound {
+		return util.SetResultMessage(0, "INFO", "Backup for workflow id ["+workflowId+"] is available locally, archive restore skipped"), nil
+	}
+
+	result, err := client.ArchiveRestore(auth, config)
+	if err != nil {
+		return result, err
+	}
+
+	msg := util.SetMessage("INFO", "Backup for workflow id ["+workflowId+"] no longer available locally, restoring from archive")
+	result.Messages = util.PrependMessage(msg, result.Messages)
+
+	return result, nil
+}
+
+// backupVerifyStep checks the backup against its manifest before it is restored, a corrupt backup fails the restore
+func backupVerifyStep(auth client.Auth, config util.Config) (util.Result, error) {
+	backupVerifyResult, err := client.BackupVerify(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, util.Int64ToString(config.SelectedWorkflowId), config)
+	return backupVerifyResult.Result, err
+}
+
+func commandStep(auth client.Auth, config util.Config, stepDefinition util.WorkflowStepDefinition) (util.Result, error) {
+	switch stepDefinition.Hook {
+	case "PreAppQuiesceCmd":
+		return client.PreQuiesceCmd(auth, config)
+	case "AppQuiesceCmd":
+		return client.QuiesceCmd(auth, config)
+	case "PostAppQuiesceCmd":
+		return client.PostQuiesceCmd(auth, config)
+	case "BackupCreateCmd":
+		return client.BackupCreateCmd(auth, config)
+	case "PreAppUnquiesceCmd":
+		return client.PreUnquiesceCmd(auth, config)
+	case "AppUnquiesceCmd":
+		return client.UnquiesceCmd(auth, config)
+	case "PostAppUnquiesceCmd":
+		return client.PostUnquiesceCmd(auth, config)
+	case "BackupDeleteCmd":
+		return client.BackupDeleteCmd(auth, config)
+	case "ArchiveCreateCmd":
+		return client.ArchiveCreateCmd(auth, config)
+	case "ArchiveDeleteCmd":
+		return client.ArchiveDeleteCmd(auth, config)
+	case "PreAppRestoreCmd":
+		return client.PreAppRestoreCmd(auth, config)
+	case "RestoreCmd":
+		return client.RestoreCmd(auth, config)
+	case "PostAppRestoreCmd":
+		return client.PostAppRestoreCmd(auth, config)
+	case "VerifyCheckCmd":
+		config.WorkflowCmd = config.VerifyCheckCmd
+		return client.AppWorkflowCmd(auth, config)
+	case "VerifyTeardownCmd":
+		config.WorkflowCmd = config.VerifyTeardownCmd
+		return client.AppWorkflowCmd(auth, config)
+	}
+
+	config.WorkflowCmd = stepDefinition.Cmd
+	if stepDefinition.Service == "storage" {
+		return client.StorageWorkflowCmd(auth, config)
+	}
+
+	return client.AppWorkflowCmd(auth, config)
+}
+
+// setQuiesceState tracks if the application is quiesced so it can be unquiesced on error
+func setQuiesceState(run *workflowRun, stepDefinition util.WorkflowStepDefinition) {
+	switch {
+	case stepDefinition.Kind == "command" && stepDefinition.Hook == "AppQuiesceCmd":
+		setQuiesced(run, true)
+	case stepDefinition.Kind == "command" && stepDefinition.Hook == "AppUnquiesceCmd":
+		setQuiesced(run, false)
+	case stepDefinition.Kind == "unquiesce":
+		setQuiesced(run, false)
+	}
+}
+
+// setQuiesced persists the quiesce state with the workflow so it can be unquiesced after a server restart
+func setQuiesced(run *workflowRun, isQuiesce bool) {
+	if isQuiesce && !run.isQuiesce {
+		run.quiesceAt = time.Now()
+	} else if !isQuiesce && run.isQuiesce {
+		util.ObserveQuiesceDuration(run.config, time.Since(run.quiesceAt))
+	}
+
+	run.isQuiesce = isQuiesce
+	run.workflow.Quiesced = isQuiesce
+	serializeWorkflow(run.resultsDir, run.workflow)
+}
+
+// setTargetRestored persists that the verify target holds restored data so it can be torn down after a server restart
+func setTargetRestored(run *workflowRun, isTargetRestored bool) {
+	run.workflow.TargetRestored = isTargetRestored
+	serializeWorkflow(run.resultsDir, run.workflow)
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fossul/src/engine/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExecuteWorkflowStepWithPolicy runs a step against a fake service that answers each call with the
+// next response of the test, "ok", "fail" or "hang" until the request is aborted. The last response repeats.
+func TestExecuteWorkflowStepWithPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		kind           string
+		path           string
+		stepPolicy     util.StepPolicy
+		isQuiesce      bool
+		maxQuiesceTime int
+		responses      []string
+		expectedCalls  int
+		expectedCode   int
+		expectedErr    bool
+		expectedLevel  string
+		expectedMsg    string
+	}{
+		{
+			name:          "no policy",
+			kind:          "backup",
+			path:          "/backup",
+			responses:     []string{"fail"},
+			expectedCalls: 1,
+			expectedCode:  1,
+		},
+		{
+			name:          "retried until success",
+			kind:          "backup",
+			path:          "/backup",
+			stepPolicy:    util.StepPolicy{Kind: "backup", Retries: 2},
+			responses:     []string{"fail", "ok"},
+			expectedCalls: 2,
+			expectedLevel: "WARN",
+			expectedMsg:   "Attempt [1] of [3] failed",
+		},
+		{
+			name:          "retries exhausted",
+			kind:          "backup",
+			path:          "/backup",
+			stepPolicy:    util.StepPolicy{Kind: "backup", Retries: 1},
+			responses:     []string{"fail"},
+			expectedCalls: 2,
+			expectedCode:  1,
+			expectedLevel: "WARN",
+			expectedMsg:   "Attempt [1] of [2] failed",
+		},
+		{
+			name:          "timeout of backup isn't retried",
+			kind:          "backup",
+			path:          "/backup",
+			stepPolicy:    util.StepPolicy{Kind: "backup", Timeout: 1, Retries: 2},
+			responses:     []string{"hang"},
+			expectedCalls: 1,
+			expectedErr:   true,
+			expectedLevel: "WARN",
+			expectedMsg:   "is only retried if the service reports the failure",
+		},
+		{
+			name:          "timeout of backup retention is retried",
+			kind:          "backupRetention",
+			path:          "/backupDelete",
+			stepPolicy:    util.StepPolicy{Kind: "backupRetention", Timeout: 1, Retries: 1},
+			responses:     []string{"hang", "ok"},
+			expectedCalls: 2,
+			expectedLevel: "ERROR",
+			expectedMsg:   "Step timed out after [1] seconds",
+		},
+		{
+			name:           "quiesce deadline aborts step",
+			kind:           "backup",
+			path:           "/backup",
+			stepPolicy:     util.StepPolicy{Kind: "backup", Retries: 2},
+			isQuiesce:      true,
+			maxQuiesceTime: 1,
+			responses:      []string{"hang"},
+			expectedCalls:  1,
+			expectedErr:    true,
+			expectedLevel:  "ERROR",
+			expectedMsg:    "Maximum quiesce time of [1] seconds exceeded",
+		},
+		{
+			name:           "unquiesce isn't bound by quiesce deadline",
+			kind:           "unquiesce",
+			path:           "/unquiesce",
+			isQuiesce:      true,
+			maxQuiesceTime: 1,
+			responses:      []string{"ok"},
+			expectedCalls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		services, cleanup := setupTestServer(t)
+
+		responses := test.responses
+		services.handle(test.path, func(w http.ResponseWriter, r *http.Request, call int) {
+			response := responses[len(responses)-1]
+			if call <= len(responses) {
+				response = responses[call-1]
+			}
+
+			switch response {
+			case "ok":
+				writeTestResult(w, util.SetResultMessage(0, "INFO", "Step succeeded"))
+			case "fail":
+				writeTestResult(w, util.SetResultMessage(1, "ERROR", "Step failed"))
+			case "hang":
+				<-r.Context().Done()
+			}
+		})
+
+		config := getTestConfig("default", "default")
+		config.AppPlugin = "sample-app"
+		config.MaxQuiesceTime = test.maxQuiesceTime
+		if test.stepPolicy.Kind != "" {
+			config.StepPolicies = []util.StepPolicy{test.stepPolicy}
+		}
+
+		run := getTestWorkflowRun(config, getTestWorkflow("backup"))
+		if test.isQuiesce {
+			run.isQuiesce = true
+			run.quiesceAt = time.Now()
+		}
+
+		result, err := executeWorkflowStepWithPolicy(run, context.Background(), util.WorkflowStepDefinition{Kind: test.kind})
+
+		if calls := services.getCalls(test.path); calls != test.expectedCalls {
+			t.Logf("ERROR: [%s] expected [%d] calls got [%d]", test.name, test.expectedCalls, calls)
+			t.Fail()
+		}
+
+		if (err != nil) != test.expectedErr {
+			t.Logf("ERROR: [%s] expected error [%t] got %v", test.name, test.expectedErr, err)
+			t.Fail()
+		}
+
+		if !test.expectedErr && result.Code != test.expectedCode {
+			t.Logf("ERROR: [%s] expected code [%d] got [%d]", test.name, test.expectedCode, result.Code)
+			t.Fail()
+		}
+
+		if test.expectedMsg != "" && !hasTestMessage(result.Messages, test.expectedLevel, test.expectedMsg) {
+			t.Logf("ERROR: [%s] expected %s message [%s] got %v", test.name, test.expectedLevel, test.expectedMsg, result.Messages)
+			t.Fail()
+		}
+
+		cleanup()
+	}
+}
+
+// TestStepErrorHandlerBackupQuiesceDuration checks a failed step of a quiesced workflow unquiesces the
+// application and records how long it stayed quiesced
+func TestStepErrorHandlerBackupQuiesceDuration(t *testing.T) {
+	services, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	config := getTestConfig("default", "quiesceOnError")
+	config.AppPlugin = "sample-app"
+
+	workflow := getTestWorkflow("backup")
+	run := getTestWorkflowRun(config, workflow)
+	setQuiesced(run, true)
+
+	step := stepInit(run.resultsDir, workflow, config, util.WorkflowStepDefinition{Kind: "backup"})
+	result := util.SetResultMessage(1, "ERROR", "Step failed")
+	if StepErrorHandlerBackup(run.isQuiesce, run.quiesceAt, run.resultsDir, run.policy, step, workflow, result, config) != 1 {
+		t.Logf("ERROR: expected failed step to fail the workflow")
+		t.Fail()
+	}
+
+	if calls := services.getCalls("/unquiesce"); calls != 1 || workflow.Quiesced {
+		t.Logf("ERROR: expected application to be unquiesced, got [%d] unquiesce calls", calls)
+		t.Fail()
+	}
+
+	recorder := httptest.NewRecorder()
+	util.MetricsHandler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), `fossul_quiesce_duration_seconds_count{config="quiesceOnError",profile="default"} 1`) {
+		t.Logf("ERROR: quiesce duration not observed on error")
+		t.Fail()
+	}
+}
+
+// TestRefreshWorkflowLock checks the heartbeat keeps the lock of a running workflow past its maximum
+// age and that the lock expires once the heartbeat stops
+func TestRefreshWorkflowLock(t *testing.T) {
+	_, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	workflowLocks = util.NewLockManager(dataDir, 2*time.Second)
+
+	config := getTestConfig("default", "default")
+	workflow := getTestWorkflow("backup")
+	run := getTestWorkflowRun(config, workflow)
+	util.SerializeWorkflow(run.resultsDir, workflow)
+
+	_, err := workflowLocks.Acquire(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, workflow.Id)
+	if err != nil {
+		t.Logf("ERROR: " + err.Error())
+		t.Fail()
+		return
+	}
+
+	stopLockRefresh := refreshWorkflowLock(run)
+	time.Sleep(2500 * time.Millisecond)
+
+	_, err = workflowLocks.Acquire(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, util.GetWorkflowId())
+	if err == nil {
+		t.Logf("ERROR: lock of running workflow expired while it was refreshed")
+		t.Fail()
+	}
+
+	stopLockRefresh()
+	time.Sleep(3 * time.Second)
+
+	_, err = workflowLocks.Acquire(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, util.GetWorkflowId())
+	if err != nil {
+		t.Logf("ERROR: lock didn't expire after refresh stopped " + err.Error())
+		t.Fail()
+	}
+}
+
+func getTestWorkflowRun(config util.Config, workflow *util.Workflow) *workflowRun {
+	run := &workflowRun{}
+	run.ctx = context.Background()
+	run.auth = SetAuth()
+	run.dataDir = dataDir
+	run.resultsDir = getResultsDir(config, workflow)
+	run.policy = config.SelectedBackupPolicy
+	run.config = config
+	run.workflow = workflow
+	run.logger = util.GetServiceLogger()
+
+	return run
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/client"
+	"fossul/src/engine/util"
+	"strings"
+	"time"
+)
+
+// getWorkflowPlan resolves what a workflow would do for the config without executing any step. Retention
+// is previewed by listing backups and archives, the only calls made to the storage service.
+func getWorkflowPlan(config util.Config, workflowType string) util.WorkflowPlanResult {
+	var workflowPlanResult util.WorkflowPlanResult
+	var messages []util.Message
+
+	if workflowType == "restore" && util.IsRestoreTargetSet(config.RestoreTarget) {
+		msg := util.SetMessage("INFO", "Restore into alternate target ["+util.GetRestoreTargetDescription(config.RestoreTarget)+"]")
+		messages = append(messages, msg)
+		config = util.ApplyRestoreTarget(config, config.RestoreTarget)
+	}
+
+	plan := &workflowPlanResult.Plan
+	plan.Type = workflowType
+	plan.Policy = config.SelectedBackupPolicy
+	plan.Config = config
+
+	steps, err := util.GetWorkflowDefinition(config, workflowType)
+	if err != nil {
+		workflowPlanResult.Result = util.SetResultMessage(1, "ERROR", "Invalid workflow definition! "+err.Error())
+		return workflowPlanResult
+	}
+
+	var isBackupRetention, isArchiveRetention, isBackupPending, isArchivePending, isArchiveRestore bool
+	for _, stepDefinition := range steps {
+		plannedStep := getPlannedStep(config, stepDefinition)
+		plan.Steps = append(plan.Steps, plannedStep)
+
+		if plannedStep.Skipped {
+			continue
+		}
+
+		switch stepDefinition.Kind {
+		case "backup":
+			isBackupPending = !isBackupRetention
+		case "archive":
+			isArchivePending = !isArchiveRetention
+		case "backupRetention":
+			isBackupRetention = true
+		case "archiveRetention":
+			isArchiveRetention = true
+		case "archiveRestore":
+			isArchiveRestore = true
+		}
+	}
+
+	auth := SetAuth()
+	if isBackupRetention || (workflowType == "restore" && config.StoragePlugin != "") {
+		backups, err := client.BackupList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+		if err != nil || backups.Result.Code != 0 {
+			msg := util.SetMessage("WARN", "Couldn't list backups, backups deleted by retention or restored are unknown")
+			messages = append(messages, msg)
+			messages = append(messages, backups.Result.Messages...)
+			if err != nil {
+				messages = append(messages, util.SetMessage("ERROR", err.Error()))
+			}
+		} else {
+			if isBackupRetention {
+				plan.ExpiredBackups = getPlannedExpiredBackups(config, backups.Backups, isBackupPending, util.GetRetentionTime())
+			}
+
+			if workflowType == "restore" {
+				plan.RestoreBackup = getRestoreBackup(config, backups.Backups)
+				if plan.RestoreBackup == nil && isArchiveRestore {
+					msg := util.SetMessage("INFO", "No local backup found for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"] policy ["+config.SelectedBackupPolicy+"], it will be restored from archive")
+					messages = append(messages, msg)
+				} else if plan.RestoreBackup == nil {
+					msg := util.SetMessage("WARN", "No backup found for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"] policy ["+config.SelectedBackupPolicy+"]")
+					messages = append(messages, msg)
+				}
+			}
+		}
+	}
+
+	if isArchiveRetention {
+		archives, err := client.ArchiveList(auth, config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, config)
+		if err != nil || archives.Result.Code != 0 {
+			msg := util.SetMessage("WARN", "Couldn't list archives, archives deleted by retention are unknown")
+			messages = append(messages, msg)
+			messages = append(messages, archives.Result.Messages...)
+			if err != nil {
+				messages = append(messages, util.SetMessage("ERROR", err.Error()))
+			}
+		} else {
+			plan.ExpiredArchives = getPlannedExpiredArchives(config, archives.Archives, isArchivePending, util.GetRetentionTime())
+		}
+	}
+
+	msg := util.SetMessage("INFO", "Planned "+workflowType+" workflow for profile ["+config.ProfileName+"] config ["+config.ConfigName+"] policy ["+config.SelectedBackupPolicy+"], nothing was executed")
+	messages = util.PrependMessage(msg, messages)
+	workflowPlanResult.Result = util.SetResult(0, messages)
+
+	return workflowPlanResult
+}
+
+func getPlannedStep(config util.Config, stepDefinition util.WorkflowStepDefinition) util.PlannedStep {
+	var plannedStep util.PlannedStep
+	plannedStep.Kind = stepDefinition.Kind
+	plannedStep.Hook = stepDefinition.Hook
+	plannedStep.Comment = stepDefinition.Comment
+	plannedStep.OnFailure = stepDefinition.OnFailure
+
+	if stepDefinition.Kind == "comment" {
+		plannedStep.Skipped = !isWorkflowCommentEnabled(config, stepDefinition)
+		return plannedStep
+	}
+
+	plannedStep.Service = util.GetWorkflowStepService(stepDefinition)
+	plannedStep.Host = getServiceHostname(plannedStep.Service)
+	plannedStep.Plugin = util.GetWorkflowStepPlugin(config, stepDefinition)
+	plannedStep.StepPolicy = util.GetStepPolicy(stepDefinition.Kind, config.StepPolicies)
+	plannedStep.Skipped = !isWorkflowStepEnabled(config, stepDefinition)
+
+	var cmd string
+	switch {
+	case stepDefinition.Kind == "command" && stepDefinition.Hook != "":
+		cmd = util.GetWorkflowHookCmd(config, stepDefinition.Hook)
+	case stepDefinition.Kind == "command":
+		cmd = stepDefinition.Cmd
+	case stepDefinition.Kind == "notify":
+		cmd = config.SendTrapSuccessCmd
+	}
+
+	if cmd != "" {
+		plannedStep.Command = strings.Split(cmd, ",")
+	}
+
+	var event string
+	switch stepDefinition.Kind {
+	case "notify":
+		event = "success"
+	case "backupRetention", "archiveRetention":
+		event = "retention"
+	}
+
+	for _, notifier := range util.GetNotifiersForEvent(config.Notifiers, event) {
+		plannedStep.Notifiers = append(plannedStep.Notifiers, notifier.Name)
+	}
+
+	return plannedStep
+}
+
+// getPlannedExpiredBackups applies backup retention as if the backup the workflow creates before retention
+// runs already existed, it takes a slot of the retention rule but is never reported as expired
+func getPlannedExpiredBackups(config util.Config, backups []util.Backup, isPending bool, now time.Time) []util.Backup {
+	var pendingBackup util.Backup
+	if isPending {
+		pendingBackup = util.Backup{Name: "pending", Policy: config.SelectedBackupPolicy, Epoch: int(now.Unix())}
+		backups = append(append([]util.Backup{}, backups...), pendingBackup)
+	}
+
+	var expiredBackups []util.Backup
+	for _, backup := range util.GetExpiredBackupsByRule(config.SelectedBackupPolicy, util.GetBackupRetentionRule(config), backups, now) {
+		if isPending && backup == pendingBackup {
+			continue
+		}
+		expiredBackups = append(expiredBackups, backup)
+	}
+
+	return expiredBackups
+}
+
+// getPlannedExpiredArchives applies archive retention as if the archive the workflow creates already existed
+func getPlannedExpiredArchives(config util.Config, archives []util.Archive, isPending bool, now time.Time) []util.Archive {
+	var pendingArchive util.Archive
+	if isPending {
+		pendingArchive = util.Archive{Name: "pending", Policy: config.SelectedBackupPolicy, Epoch: int(now.Unix())}
+		archives = append(append([]util.Archive{}, archives...), pendingArchive)
+	}
+
+	var expiredArchives []util.Archive
+	for _, archive := range util.GetExpiredArchivesByRule(config.SelectedBackupPolicy, util.GetArchiveRetentionRule(config), archives, now) {
+		if isPending && archive == pendingArchive {
+			continue
+		}
+		expiredArchives = append(expiredArchives, archive)
+	}
+
+	return expiredArchives
+}
+
+func getRestoreBackup(config util.Config, backups []util.Backup) *util.Backup {
+	restoreBackup, isFound := util.GetBackupByWorkflowId(config.SelectedBackupPolicy, util.Int64ToString(config.SelectedWorkflowId), backups)
+	if !isFound {
+		return nil
+	}
+
+	return &restoreBackup
+}
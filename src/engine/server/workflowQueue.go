@@ -0,0 +1,308 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/util"
+	"sync"
+	"time"
+)
+
+const workflowQueueInterval = 30 * time.Second
+
+type queuedWorkflow struct {
+	config      util.Config
+	workflow    *util.Workflow
+	storageHost string
+	timestamp   int64
+}
+
+// workflowQueue admits workflows, a workflow starts if its profile/config isn't busy and the concurrent
+// workflow limits aren't reached, otherwise it is queued if the config allows it. Queued workflows of a
+// profile/config start in the order they were queued.
+type workflowQueue struct {
+	mutex                    sync.Mutex
+	queued                   []*queuedWorkflow
+	running                  map[string]*queuedWorkflow
+	maxRunning               int
+	maxRunningPerStorageHost int
+}
+
+var queuedWorkflows *workflowQueue
+
+func newWorkflowQueue(maxRunning, maxRunningPerStorageHost int) *workflowQueue {
+	queue := &workflowQueue{}
+	queue.running = make(map[string]*queuedWorkflow)
+	queue.maxRunning = maxRunning
+	queue.maxRunningPerStorageHost = maxRunningPerStorageHost
+
+	return queue
+}
+
+func getQueueKey(profileName, configName string) string {
+	return profileName + "-" + configName
+}
+
+// getStorageHost returns the storage host a config writes backups to, defaults to the storage service host
+func getStorageHost(config util.Config) string {
+	if config.StorageHost != "" {
+		return config.StorageHost
+	}
+
+	return storageHostname
+}
+
+// launchWorkflow starts or queues a workflow, the id returned is the id of an already queued workflow
+// if the workflow was coalesced
+func launchWorkflow(config util.Config, workflow *util.Workflow) (int64, util.Result) {
+	return queuedWorkflows.submit(config, workflow)
+}
+
+func (queue *workflowQueue) submit(config util.Config, workflow *util.Workflow) (int64, util.Result) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	entry := &queuedWorkflow{}
+	entry.config = config
+	entry.workflow = workflow
+	entry.storageHost = getStorageHost(config)
+	entry.timestamp = util.GetTimestamp()
+
+	id := util.Int64ToString(workflow.Id)
+	key := getQueueKey(config.ProfileName, config.ConfigName)
+
+	reason := queue.isBlocked(entry)
+	if reason == "" && queue.isQueued(key) {
+		reason = "Workflows are already queued under profile [" + config.ProfileName + "] config [" + config.ConfigName + "]"
+	}
+
+	if reason == "" {
+		_, err := workflowLocks.Acquire(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy, workflow.Id)
+		if err == nil {
+			queue.start(entry)
+			return workflow.Id, util.SetResultMessage(0, "INFO", "Workflow id ["+id+"] started successfully")
+		}
+		reason = err.Error()
+	}
+
+	if config.MaxQueuedWorkflows <= 0 {
+		return workflow.Id, util.SetResultMessage(1, "ERROR", "Workflow id ["+id+"] failed to start. "+reason)
+	}
+
+	var queuedCount int
+	for _, queuedEntry := range queue.queued {
+		if getQueueKey(queuedEntry.config.ProfileName, queuedEntry.config.ConfigName) != key {
+			continue
+		}
+
+		if config.CoalesceQueuedWorkflows && queuedEntry.workflow.Type == workflow.Type && queuedEntry.workflow.Policy == workflow.Policy {
+			queuedId := util.Int64ToString(queuedEntry.workflow.Id)
+			return queuedEntry.workflow.Id, util.SetResultMessage(0, "INFO", "Workflow id ["+id+"] coalesced into queued workflow id ["+queuedId+"]")
+		}
+		queuedCount = queuedCount + 1
+	}
+
+	if queuedCount >= config.MaxQueuedWorkflows {
+		return workflow.Id, util.SetResultMessage(1, "ERROR", "Workflow id ["+id+"] failed to start. "+reason+", queue is full with ["+util.IntToString(queuedCount)+"] workflows")
+	}
+
+	util.SetWorkflowStatusQueued(workflow)
+	serializeWorkflow(getResultsDir(config, workflow), workflow)
+	queue.queued = append(queue.queued, entry)
+
+	return workflow.Id, util.SetResultMessage(0, "INFO", "Workflow id ["+id+"] queued at position ["+util.IntToString(queuedCount+1)+"]. "+reason)
+}
+
+// cancel removes a queued workflow or cancels a running one through the workflow registry and returns
+// QUEUED or RUNNING, or an empty string if the workflow is neither. Both are checked under the queue mutex
+// as a workflow moves from queued to running under it.
+func (queue *workflowQueue) cancel(profileName, configName string, workflowId int64) string {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	if runningWorkflows.cancel(getWorkflowKey(profileName, configName, util.Int64ToString(workflowId))) {
+		return "RUNNING"
+	}
+
+	for i, entry := range queue.queued {
+		if entry.config.ProfileName != profileName || entry.config.ConfigName != configName || entry.workflow.Id != workflowId {
+			continue
+		}
+
+		queue.queued = append(queue.queued[:i], queue.queued[i+1:]...)
+
+		resultsDir := getResultsDir(entry.config, entry.workflow)
+		setComment(resultsDir, "Workflow Cancelled While Queued", entry.workflow)
+		util.SetWorkflowStatusCancelled(entry.workflow)
+		serializeWorkflow(resultsDir, entry.workflow)
+
+		return "QUEUED"
+	}
+
+	return ""
+}
+
+func (queue *workflowQueue) list() []util.QueuedWorkflow {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	var workflows []util.QueuedWorkflow
+	for _, entry := range queue.running {
+		workflows = append(workflows, getQueuedWorkflow(entry, "RUNNING", 0))
+	}
+
+	// position is per profile/config, same as reported when the workflow was queued
+	positions := make(map[string]int)
+	for _, entry := range queue.queued {
+		key := getQueueKey(entry.config.ProfileName, entry.config.ConfigName)
+		positions[key] = positions[key] + 1
+		workflows = append(workflows, getQueuedWorkflow(entry, "QUEUED", positions[key]))
+	}
+
+	return workflows
+}
+
+// run periodically starts queued workflows, locks can also be released outside of the queue
+func (queue *workflowQueue) run() {
+	ticker := time.NewTicker(workflowQueueInterval)
+	for range ticker.C {
+		queue.mutex.Lock()
+		queue.dispatch()
+		queue.mutex.Unlock()
+	}
+}
+
+func (queue *workflowQueue) finished(entry *queuedWorkflow) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	key := getQueueKey(entry.config.ProfileName, entry.config.ConfigName)
+	if queue.running[key] == entry {
+		delete(queue.running, key)
+	}
+
+	queue.dispatch()
+}
+
+// dispatch starts queued workflows that are no longer blocked, queue mutex must be held
+func (queue *workflowQueue) dispatch() {
+	var queued []*queuedWorkflow
+	blockedKeys := make(map[string]bool)
+
+	for _, entry := range queue.queued {
+		key := getQueueKey(entry.config.ProfileName, entry.config.ConfigName)
+		if blockedKeys[key] || queue.isBlocked(entry) != "" {
+			blockedKeys[key] = true
+			queued = append(queued, entry)
+			continue
+		}
+
+		_, err := workflowLocks.Acquire(entry.config.ProfileName, entry.config.ConfigName, entry.config.SelectedBackupPolicy, entry.workflow.Id)
+		if err != nil {
+			blockedKeys[key] = true
+			queued = append(queued, entry)
+			continue
+		}
+
+		queue.start(entry)
+	}
+
+	queue.queued = queued
+}
+
+// isBlocked returns why a workflow can't start now, queue mutex must be held
+func (queue *workflowQueue) isBlocked(entry *queuedWorkflow) string {
+	key := getQueueKey(entry.config.ProfileName, entry.config.ConfigName)
+	if running, ok := queue.running[key]; ok {
+		return "Another workflow id [" + util.Int64ToString(running.workflow.Id) + "] is running under profile [" + entry.config.ProfileName + "] config [" + entry.config.ConfigName + "]"
+	}
+
+	if queue.maxRunning > 0 && len(queue.running) >= queue.maxRunning {
+		return "Maximum of [" + util.IntToString(queue.maxRunning) + "] concurrent workflows reached"
+	}
+
+	if queue.maxRunningPerStorageHost > 0 {
+		var count int
+		for _, running := range queue.running {
+			if running.storageHost == entry.storageHost {
+				count = count + 1
+			}
+		}
+
+		if count >= queue.maxRunningPerStorageHost {
+			return "Maximum of [" + util.IntToString(queue.maxRunningPerStorageHost) + "] concurrent workflows reached for storage host [" + entry.storageHost + "]"
+		}
+	}
+
+	return ""
+}
+
+func (queue *workflowQueue) isQueued(key string) bool {
+	for _, entry := range queue.queued {
+		if getQueueKey(entry.config.ProfileName, entry.config.ConfigName) == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// start runs the workflow, the profile/config lock must already be acquired and queue mutex held. The workflow
+// is registered before the queue mutex is released so a cancel never misses it between queued and running.
+func (queue *workflowQueue) start(entry *queuedWorkflow) {
+	key := getQueueKey(entry.config.ProfileName, entry.config.ConfigName)
+	queue.running[key] = entry
+	entry.timestamp = util.GetTimestamp()
+
+	workflowKey := getWorkflowKey(entry.config.ProfileName, entry.config.ConfigName, util.Int64ToString(entry.workflow.Id))
+	ctx := runningWorkflows.register(workflowKey)
+
+	// workflow is saved before responding so it can be followed right away
+	util.SetWorkflowStatusStart(entry.workflow)
+	serializeWorkflow(getResultsDir(entry.config, entry.workflow), entry.workflow)
+
+	go func() {
+		switch entry.workflow.Type {
+		case "backup":
+			startBackupWorkflowImpl(ctx, dataDir, entry.config, entry.workflow)
+		case "restore":
+			startRestoreWorkflowImpl(ctx, dataDir, entry.config, entry.workflow)
+		case "verify":
+			startVerifyWorkflowImpl(ctx, dataDir, entry.config, entry.workflow)
+		default:
+			util.GetServiceLogger().With("workflowId", util.Int64ToString(entry.workflow.Id)).With("profile", entry.config.ProfileName).With("config", entry.config.ConfigName).Error("Workflow type [" + entry.workflow.Type + "] is not supported")
+		}
+
+		runningWorkflows.unregister(workflowKey)
+		queue.finished(entry)
+	}()
+}
+
+func getQueuedWorkflow(entry *queuedWorkflow, status string, position int) util.QueuedWorkflow {
+	var queuedWorkflow util.QueuedWorkflow
+	queuedWorkflow.WorkflowId = entry.workflow.Id
+	queuedWorkflow.ProfileName = entry.config.ProfileName
+	queuedWorkflow.ConfigName = entry.config.ConfigName
+	queuedWorkflow.Type = entry.workflow.Type
+	queuedWorkflow.Policy = entry.workflow.Policy
+	queuedWorkflow.Status = status
+	queuedWorkflow.StorageHost = entry.storageHost
+	queuedWorkflow.Position = position
+	queuedWorkflow.Timestamp = entry.timestamp
+
+	return queuedWorkflow
+}
+
+func getResultsDir(config util.Config, workflow *util.Workflow) string {
+	return dataDir + "/" + config.ProfileName + "/" + config.ConfigName + "/" + util.Int64ToString(workflow.Id)
+}
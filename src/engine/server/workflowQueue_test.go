@@ -0,0 +1,214 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fossul/src/engine/util"
+	"testing"
+)
+
+// TestWorkflowQueueSubmit submits workflows while a backup of default/default is running, the expected
+// outcome is RUNNING, QUEUED, COALESCED into the previous submission or REJECTED. Once the running backup is
+// released every started or queued workflow must complete.
+func TestWorkflowQueueSubmit(t *testing.T) {
+	tests := []struct {
+		name                     string
+		maxRunning               int
+		maxRunningPerStorageHost int
+		configs                  []util.Config
+		expected                 []string
+	}{
+		{
+			name:     "queue disabled rejects busy config",
+			configs:  []util.Config{getTestQueueConfig("default", "default", "", 0, false)},
+			expected: []string{"REJECTED"},
+		},
+		{
+			name:     "busy config is queued until queue is full",
+			configs:  []util.Config{getTestQueueConfig("default", "default", "", 1, false), getTestQueueConfig("default", "default", "", 1, false)},
+			expected: []string{"QUEUED", "REJECTED"},
+		},
+		{
+			name:     "queued workflows are coalesced",
+			configs:  []util.Config{getTestQueueConfig("default", "default", "", 1, true), getTestQueueConfig("default", "default", "", 1, true)},
+			expected: []string{"QUEUED", "COALESCED"},
+		},
+		{
+			name:     "other config starts",
+			configs:  []util.Config{getTestQueueConfig("default", "other", "", 0, false)},
+			expected: []string{"RUNNING"},
+		},
+		{
+			name:       "maximum running workflows",
+			maxRunning: 1,
+			configs:    []util.Config{getTestQueueConfig("default", "other", "", 1, false), getTestQueueConfig("default", "third", "", 0, false)},
+			expected:   []string{"QUEUED", "REJECTED"},
+		},
+		{
+			name:                     "maximum running workflows per storage host",
+			maxRunningPerStorageHost: 1,
+			configs:                  []util.Config{getTestQueueConfig("default", "other", "", 1, false), getTestQueueConfig("default", "third", "storage2", 0, false)},
+			expected:                 []string{"QUEUED", "RUNNING"},
+		},
+	}
+
+	for _, test := range tests {
+		services, cleanup := setupTestServer(t)
+
+		release := make(chan struct{})
+		services.handle("/backup", blockUntil(release))
+
+		queue := newWorkflowQueue(test.maxRunning, test.maxRunningPerStorageHost)
+
+		runningConfig := getTestQueueConfig("default", "default", "", 0, false)
+		runningWorkflow := getTestWorkflow("backup")
+		_, result := queue.submit(runningConfig, runningWorkflow)
+		if result.Code != 0 || waitForWorkflowStatus(runningConfig, runningWorkflow.Id, "RUNNING") != "RUNNING" {
+			t.Logf("ERROR: [%s] first workflow didn't start", test.name)
+			t.Fail()
+		}
+
+		var pendingConfigs []util.Config
+		var pendingIds []int64
+		var lastId int64
+		for i, config := range test.configs {
+			workflow := getTestWorkflow("backup")
+			id, result := queue.submit(config, workflow)
+
+			var outcome string
+			switch {
+			case result.Code != 0:
+				outcome = "REJECTED"
+			case id != workflow.Id && id == lastId:
+				outcome = "COALESCED"
+			default:
+				saved, err := readTestWorkflow(config, id)
+				if err == nil {
+					outcome = saved.Status
+				}
+				pendingConfigs = append(pendingConfigs, config)
+				pendingIds = append(pendingIds, id)
+			}
+
+			if outcome != test.expected[i] {
+				t.Logf("ERROR: [%s] workflow [%d] expected [%s] got [%s] %v", test.name, i, test.expected[i], outcome, result.Messages)
+				t.Fail()
+			}
+			lastId = id
+		}
+
+		close(release)
+
+		if !waitForQueueEmpty(queue) {
+			t.Logf("ERROR: [%s] queue didn't drain %v", test.name, queue.list())
+			t.Fail()
+		}
+
+		pendingConfigs = append(pendingConfigs, runningConfig)
+		pendingIds = append(pendingIds, runningWorkflow.Id)
+		for i, config := range pendingConfigs {
+			status := waitForWorkflowStatus(config, pendingIds[i], "COMPLETE")
+			if status != "COMPLETE" {
+				t.Logf("ERROR: [%s] workflow [%d] expected [COMPLETE] got [%s]", test.name, pendingIds[i], status)
+				t.Fail()
+			}
+		}
+
+		cleanup()
+	}
+}
+
+func TestWorkflowQueueCancel(t *testing.T) {
+	tests := []struct {
+		name     string
+		isQueued bool
+		expected string
+	}{
+		{name: "running", expected: "RUNNING"},
+		{name: "queued", isQueued: true, expected: "QUEUED"},
+	}
+
+	for _, test := range tests {
+		services, cleanup := setupTestServer(t)
+
+		release := make(chan struct{})
+		services.handle("/backup", blockUntil(release))
+
+		queue := newWorkflowQueue(0, 0)
+		config := getTestQueueConfig("default", "default", "", 1, false)
+
+		runningWorkflow := getTestWorkflow("backup")
+		queue.submit(config, runningWorkflow)
+		if waitForWorkflowStatus(config, runningWorkflow.Id, "RUNNING") != "RUNNING" {
+			t.Logf("ERROR: [%s] workflow didn't start", test.name)
+			t.Fail()
+		}
+
+		// wait for the backup step so cancel aborts a step in progress
+		if !services.waitForCalls("/backup", 1) {
+			t.Logf("ERROR: [%s] backup wasn't called", test.name)
+			t.Fail()
+		}
+
+		workflow := runningWorkflow
+		if test.isQueued {
+			workflow = getTestWorkflow("backup")
+			queue.submit(config, workflow)
+		}
+
+		status := queue.cancel(config.ProfileName, config.ConfigName, workflow.Id)
+		if status != test.expected {
+			t.Logf("ERROR: [%s] expected cancel of [%s] got [%s]", test.name, test.expected, status)
+			t.Fail()
+		}
+
+		if status := waitForWorkflowStatus(config, workflow.Id, "CANCELLED"); status != "CANCELLED" {
+			t.Logf("ERROR: [%s] expected status [CANCELLED] got [%s]", test.name, status)
+			t.Fail()
+		}
+
+		close(release)
+
+		if !waitForQueueEmpty(queue) {
+			t.Logf("ERROR: [%s] queue didn't drain %v", test.name, queue.list())
+			t.Fail()
+		}
+
+		if status := queue.cancel(config.ProfileName, config.ConfigName, workflow.Id); status != "" {
+			t.Logf("ERROR: [%s] cancelled workflow cancelled again [%s]", test.name, status)
+			t.Fail()
+		}
+
+		if test.isQueued && waitForWorkflowStatus(config, runningWorkflow.Id, "COMPLETE") != "COMPLETE" {
+			t.Logf("ERROR: [%s] running workflow didn't complete", test.name)
+			t.Fail()
+		}
+
+		locks, err := workflowLocks.List()
+		if err != nil || len(locks) != 0 {
+			t.Logf("ERROR: [%s] expected no workflow locks got %v", test.name, locks)
+			t.Fail()
+		}
+
+		cleanup()
+	}
+}
+
+func getTestQueueConfig(profileName, configName, storageHost string, maxQueuedWorkflows int, isCoalesce bool) util.Config {
+	config := getTestConfig(profileName, configName)
+	config.StorageHost = storageHost
+	config.MaxQueuedWorkflows = maxQueuedWorkflows
+	config.CoalesceQueuedWorkflows = isCoalesce
+
+	return config
+}
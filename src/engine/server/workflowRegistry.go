@@ -0,0 +1,63 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// workflowRegistry tracks workflows executing in this server so they can be cancelled
+type workflowRegistry struct {
+	mutex       sync.Mutex
+	cancelFuncs map[string]context.CancelFunc
+}
+
+var runningWorkflows = &workflowRegistry{cancelFuncs: make(map[string]context.CancelFunc)}
+
+func getWorkflowKey(profileName, configName, workflowId string) string {
+	return profileName + "-" + configName + "-" + workflowId
+}
+
+func (registry *workflowRegistry) register(key string) context.Context {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	registry.cancelFuncs[key] = cancel
+
+	return ctx
+}
+
+func (registry *workflowRegistry) unregister(key string) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if cancel, ok := registry.cancelFuncs[key]; ok {
+		cancel()
+		delete(registry.cancelFuncs, key)
+	}
+}
+
+func (registry *workflowRegistry) cancel(key string) bool {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	cancel, ok := registry.cancelFuncs[key]
+	if !ok {
+		return false
+	}
+
+	cancel()
+	return true
+}
@@ -17,7 +17,6 @@ import (
 	"fossul/src/engine/util"
 	"github.com/gorilla/mux"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -64,7 +63,7 @@ func PluginList(w http.ResponseWriter, r *http.Request) {
 	} else if pluginType == "archive" {
 		storagePluginDir = pluginDir + "/archive"
 	} else {
-		log.Println("ERROR plugin type " + pluginType + " must be storage|archive")
+		util.RequestLogger(r).Error("Plugin type [" + pluginType + "] must be storage|archive")
 
 		_ = json.NewDecoder(r.Body).Decode(&plugins)
 		json.NewEncoder(w).Encode(plugins)
@@ -72,7 +71,7 @@ func PluginList(w http.ResponseWriter, r *http.Request) {
 
 	fileInfo, err := ioutil.ReadDir(storagePluginDir)
 	if err != nil {
-		log.Println(err)
+		util.RequestLogger(r).Error(err.Error())
 	}
 
 	for _, file := range fileInfo {
@@ -107,7 +106,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -138,7 +137,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var resultSimple util.ResultSimple
-		resultSimple = util.ExecutePluginSimple(config, pluginType, plugin, "--info")
+		resultSimple = util.ExecutePluginSimple(r.Context(), config, pluginType, plugin, "--info")
 		if resultSimple.Code != 0 {
 			msg := util.SetMessage("ERROR", "Plugin Info failed!")
 			messages = append(messages, msg)
@@ -159,7 +158,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		if pluginType == "storage" {
-			plugin, err := util.GetStorageInterface(pluginPath)
+			plugin, err := util.GetStorageInterface(r.Context(), pluginPath)
 
 			if err != nil {
 				msg := util.SetMessage("ERROR", err.Error())
@@ -179,7 +178,7 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 				json.NewEncoder(w).Encode(pluginInfoResult)
 			}
 		} else if pluginType == "archive" {
-			plugin, err := util.GetArchiveInterface(pluginPath)
+			plugin, err := util.GetArchiveInterface(r.Context(), pluginPath)
 			if err != nil {
 				msg := util.SetMessage("ERROR", err.Error())
 				messages = append(messages, msg)
@@ -208,3 +207,45 @@ func PluginInfo(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// WorkflowCmd godoc
+// @Description Custom workflow step command
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /workflowCmd [post]
+func WorkflowCmd(w http.ResponseWriter, r *http.Request) {
+	var result util.Result
+	var messages []util.Message
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(r, config)
+
+	if err != nil {
+		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
+		messages = append(messages, message)
+
+		result = util.SetResult(1, messages)
+
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+
+		return
+	}
+
+	if config.WorkflowCmd != "" {
+		args := strings.Split(config.WorkflowCmd, ",")
+		message := util.SetMessage("INFO", "Performing workflow command ["+config.WorkflowCmd+"]")
+
+		result = util.ExecuteCommandContext(r.Context(), args...)
+		result.Messages = util.PrependMessage(message, result.Messages)
+
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		json.NewEncoder(w).Encode(result)
+	}
+}
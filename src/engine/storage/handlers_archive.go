@@ -21,7 +21,7 @@ import (
 )
 
 // Archive godoc
-// @Description Archive backup
+// @Description Archive backup, messages are streamed as they happen if the client accepts application/x-ndjson
 // @Param config body util.Config true "config struct"
 // @Accept  json
 // @Produce  json
@@ -34,9 +34,86 @@ import (
 func Archive(w http.ResponseWriter, r *http.Request) {
 	var result util.Result
 	var messages []util.Message
+	resultWriter := util.NewResultWriter(w, r)
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
+
+	if err != nil {
+		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
+		messages = append(messages, message)
+
+		result = util.SetResult(1, messages)
+
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		resultWriter.Write(result)
+
+		return
+	}
+
+	pluginPath := util.GetPluginPath(config.ArchivePlugin)
+
+	if pluginPath == "" {
+		var plugin string = pluginDir + "/archive/" + config.ArchivePlugin
+
+		if _, err := os.Stat(plugin); os.IsNotExist(err) {
+			var errMsg string = "Archive plugin does not exist"
+
+			message := util.SetMessage("ERROR", errMsg+" "+err.Error())
+			messages = append(messages, message)
+
+			result = util.SetResult(1, messages)
+
+			_ = json.NewDecoder(r.Body).Decode(&result)
+			resultWriter.Write(result)
+		}
+
+		result = util.ExecutePlugin(resultWriter.Context(), config, "archive", plugin, "--archive")
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		resultWriter.Write(result)
+	} else {
+		plugin, err := util.GetArchiveInterface(resultWriter.Context(), pluginPath)
+		if err != nil {
+			message := util.SetMessage("ERROR", err.Error())
+			messages = append(messages, message)
+
+			result = util.SetResult(1, messages)
+			_ = json.NewDecoder(r.Body).Decode(&result)
+			resultWriter.Write(result)
+		} else {
+			setEnvResult := plugin.SetEnv(config)
+			if setEnvResult.Code != 0 {
+				_ = json.NewDecoder(r.Body).Decode(&setEnvResult)
+				resultWriter.Write(setEnvResult)
+			} else {
+				result = plugin.Archive(config)
+				messages = util.PrependMessages(setEnvResult.Messages, result.Messages)
+				result.Messages = messages
+
+				_ = json.NewDecoder(r.Body).Decode(&result)
+				resultWriter.Write(result)
+			}
+		}
+	}
+}
+
+// ArchiveRestore godoc
+// @Description Restore archive of the selected workflow id back to the backup destination
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.Result
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /archiveRestore [post]
+func ArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	var result util.Result
+	var messages []util.Message
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -67,11 +144,11 @@ func Archive(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(result)
 		}
 
-		result = util.ExecutePlugin(config, "archive", plugin, "--archive")
+		result = util.ExecutePlugin(r.Context(), config, "archive", plugin, "--archiveRestore")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetArchiveInterface(pluginPath)
+		plugin, err := util.GetArchiveInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -85,7 +162,7 @@ func Archive(w http.ResponseWriter, r *http.Request) {
 				_ = json.NewDecoder(r.Body).Decode(&setEnvResult)
 				json.NewEncoder(w).Encode(setEnvResult)
 			} else {
-				result = plugin.Archive(config)
+				result = plugin.ArchiveRestore(config)
 				messages = util.PrependMessages(setEnvResult.Messages, result.Messages)
 				result.Messages = messages
 
@@ -114,7 +191,7 @@ func ArchiveList(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -145,7 +222,7 @@ func ArchiveList(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(archives)
 		}
 
-		resultSimple := util.ExecutePluginSimple(config, "archive", plugin, "--archiveList")
+		resultSimple := util.ExecutePluginSimple(r.Context(), config, "archive", plugin, "--archiveList")
 		if resultSimple.Code != 0 {
 			msg := util.SetMessage("ERROR", "ArchiveList failed")
 			messages = append(messages, msg)
@@ -165,7 +242,7 @@ func ArchiveList(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(archives)
 		}
 	} else {
-		plugin, err := util.GetArchiveInterface(pluginPath)
+		plugin, err := util.GetArchiveInterface(r.Context(), pluginPath)
 		if err != nil {
 			msg := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, msg)
@@ -205,7 +282,7 @@ func ArchiveDelete(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -234,11 +311,11 @@ func ArchiveDelete(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(result)
 		}
 
-		result = util.ExecutePlugin(config, "archive", plugin, "--archiveDelete")
+		result = util.ExecutePlugin(r.Context(), config, "archive", plugin, "--archiveDelete")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetArchiveInterface(pluginPath)
+		plugin, err := util.GetArchiveInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -279,7 +356,7 @@ func ArchiveCreateCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -297,7 +374,7 @@ func ArchiveCreateCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.ArchiveCreateCmd, ",")
 		message := util.SetMessage("INFO", "Performing archive create command ["+config.ArchiveCreateCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
@@ -321,7 +398,7 @@ func ArchiveDeleteCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -339,7 +416,7 @@ func ArchiveDeleteCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.ArchiveDeleteCmd, ",")
 		message := util.SetMessage("INFO", "Performing archive delete command ["+config.ArchiveDeleteCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
@@ -14,6 +14,7 @@ package main
 
 import (
 	"encoding/json"
+	"fossul/src/engine/plugins/pluginUtil"
 	"fossul/src/engine/util"
 	"net/http"
 	"os"
@@ -21,7 +22,7 @@ import (
 )
 
 // Backup godoc
-// @Description Backup data
+// @Description Backup data, messages are streamed as they happen if the client accepts application/x-ndjson
 // @Param config body util.Config true "config struct"
 // @Accept  json
 // @Produce  json
@@ -34,9 +35,10 @@ import (
 func Backup(w http.ResponseWriter, r *http.Request) {
 	var result util.Result
 	var messages []util.Message
+	resultWriter := util.NewResultWriter(w, r)
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -45,7 +47,7 @@ func Backup(w http.ResponseWriter, r *http.Request) {
 		result = util.SetResult(1, messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
-		json.NewEncoder(w).Encode(result)
+		resultWriter.Write(result)
 
 		return
 	}
@@ -63,32 +65,32 @@ func Backup(w http.ResponseWriter, r *http.Request) {
 
 			result = util.SetResult(1, messages)
 			_ = json.NewDecoder(r.Body).Decode(&result)
-			json.NewEncoder(w).Encode(result)
+			resultWriter.Write(result)
 		}
-		result = util.ExecutePlugin(config, "storage", plugin, "--backup")
+		result = util.ExecutePlugin(resultWriter.Context(), config, "storage", plugin, "--backup")
 		_ = json.NewDecoder(r.Body).Decode(&result)
-		json.NewEncoder(w).Encode(result)
+		resultWriter.Write(result)
 	} else {
-		plugin, err := util.GetStorageInterface(pluginPath)
+		plugin, err := util.GetStorageInterface(resultWriter.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
 
 			result = util.SetResult(1, messages)
 			_ = json.NewDecoder(r.Body).Decode(&result)
-			json.NewEncoder(w).Encode(result)
+			resultWriter.Write(result)
 		} else {
 			setEnvResult := plugin.SetEnv(config)
 			if setEnvResult.Code != 0 {
 				_ = json.NewDecoder(r.Body).Decode(&setEnvResult)
-				json.NewEncoder(w).Encode(setEnvResult)
+				resultWriter.Write(setEnvResult)
 			} else {
 				result = plugin.Backup(config)
 				messages = util.PrependMessages(setEnvResult.Messages, result.Messages)
 				result.Messages = messages
 
 				_ = json.NewDecoder(r.Body).Decode(&result)
-				json.NewEncoder(w).Encode(result)
+				resultWriter.Write(result)
 			}
 		}
 	}
@@ -112,7 +114,7 @@ func BackupList(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -143,7 +145,7 @@ func BackupList(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(backups)
 		}
 
-		resultSimple := util.ExecutePluginSimple(config, "storage", plugin, "--backupList")
+		resultSimple := util.ExecutePluginSimple(r.Context(), config, "storage", plugin, "--backupList")
 		if resultSimple.Code != 0 {
 			msg := util.SetMessage("ERROR", "BackupList failed")
 			messages = append(messages, msg)
@@ -163,7 +165,7 @@ func BackupList(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(backups)
 		}
 	} else {
-		plugin, err := util.GetStorageInterface(pluginPath)
+		plugin, err := util.GetStorageInterface(r.Context(), pluginPath)
 		if err != nil {
 			msg := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, msg)
@@ -187,6 +189,126 @@ func BackupList(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// BackupBrowse godoc
+// @Description List files of the backup selected by workflow id, only available for backups stored on the storage service under BackupDestPath
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.BackupFiles
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /backupBrowse [post]
+func BackupBrowse(w http.ResponseWriter, r *http.Request) {
+	var backupFiles util.BackupFiles
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(r, config)
+
+	if err != nil {
+		backupFiles.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupFiles)
+		json.NewEncoder(w).Encode(backupFiles)
+
+		return
+	}
+
+	restorePath, err := util.GetRestoreSrcPath(config)
+	if err != nil {
+		backupFiles.Result = util.SetResultMessage(1, "ERROR", "Couldn't find backup! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupFiles)
+		json.NewEncoder(w).Encode(backupFiles)
+
+		return
+	}
+
+	if restorePath == "" {
+		backupFiles.Result = util.SetResultMessage(1, "ERROR", "Backup for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"] policy ["+config.SelectedBackupPolicy+"] not found")
+		_ = json.NewDecoder(r.Body).Decode(&backupFiles)
+		json.NewEncoder(w).Encode(backupFiles)
+
+		return
+	}
+
+	files, err := listBackupFiles(restorePath)
+	if err != nil {
+		backupFiles.Result = util.SetResultMessage(1, "ERROR", "Couldn't list backup ["+restorePath+"]! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupFiles)
+		json.NewEncoder(w).Encode(backupFiles)
+
+		return
+	}
+
+	backupFiles.Files = files
+	backupFiles.Result = util.SetResultMessage(0, "INFO", "Backup ["+restorePath+"] contains ["+util.IntToString(len(files))+"] files and directories")
+	_ = json.NewDecoder(r.Body).Decode(&backupFiles)
+	json.NewEncoder(w).Encode(backupFiles)
+}
+
+// BackupVerify godoc
+// @Description Verify the backup selected by workflow id against its manifest, only available for backups stored on the storage service under BackupDestPath
+// @Param config body util.Config true "config struct"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.BackupVerifyResult
+// @Header 200 {string} string
+// @Failure 400 {string} string
+// @Failure 404 {string} string
+// @Failure 500 {string} string
+// @Router /backupVerify [post]
+func BackupVerify(w http.ResponseWriter, r *http.Request) {
+	var backupVerifyResult util.BackupVerifyResult
+
+	config, err := util.GetConfig(w, r)
+	printConfigDebug(r, config)
+
+	if err != nil {
+		backupVerifyResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't read config! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupVerifyResult)
+		json.NewEncoder(w).Encode(backupVerifyResult)
+
+		return
+	}
+
+	restorePath, err := util.GetRestoreSrcPath(config)
+	if err != nil {
+		backupVerifyResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't find backup! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupVerifyResult)
+		json.NewEncoder(w).Encode(backupVerifyResult)
+
+		return
+	}
+
+	if restorePath == "" {
+		backupVerifyResult.Result = util.SetResultMessage(1, "ERROR", "Backup for workflow id ["+util.Int64ToString(config.SelectedWorkflowId)+"] policy ["+config.SelectedBackupPolicy+"] not found")
+		_ = json.NewDecoder(r.Body).Decode(&backupVerifyResult)
+		json.NewEncoder(w).Encode(backupVerifyResult)
+
+		return
+	}
+
+	verification, err := verifyBackup(config, restorePath)
+	if err != nil {
+		backupVerifyResult.Result = util.SetResultMessage(1, "ERROR", "Couldn't verify backup ["+restorePath+"]! "+err.Error())
+		_ = json.NewDecoder(r.Body).Decode(&backupVerifyResult)
+		json.NewEncoder(w).Encode(backupVerifyResult)
+
+		return
+	}
+
+	// backups taken before manifests existed can't be verified, that is a warning rather than a failure
+	var resultCode int = 0
+	if verification.IsManifest && !verification.IsValid() {
+		resultCode = 1
+	}
+
+	backupVerifyResult.Verification = verification
+	backupVerifyResult.Result = util.SetResult(resultCode, util.GetBackupVerifyMessages(verification))
+	_ = json.NewDecoder(r.Body).Decode(&backupVerifyResult)
+	json.NewEncoder(w).Encode(backupVerifyResult)
+}
+
 // BackupDelete godoc
 // @Description Delete backups according to retention
 // @Param config body util.Config true "config struct"
@@ -203,7 +325,7 @@ func BackupDelete(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -231,11 +353,11 @@ func BackupDelete(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewDecoder(r.Body).Decode(&result)
 			json.NewEncoder(w).Encode(result)
 		}
-		result = util.ExecutePlugin(config, "storage", plugin, "--backupDelete")
+		result = util.ExecutePlugin(r.Context(), config, "storage", plugin, "--backupDelete")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetStorageInterface(pluginPath)
+		plugin, err := util.GetStorageInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -276,7 +398,7 @@ func BackupCreateCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -294,7 +416,7 @@ func BackupCreateCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.BackupCreateCmd, ",")
 		message := util.SetMessage("INFO", "Performing backup create command ["+config.BackupCreateCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
@@ -318,7 +440,7 @@ func BackupDeleteCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -336,10 +458,44 @@ func BackupDeleteCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.BackupDeleteCmd, ",")
 		message := util.SetMessage("INFO", "Performing backup delete command ["+config.BackupDeleteCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	}
 }
+
+// listBackupFiles lists the files of a backup, a dedup backup dir only holds the snapshot index of its files
+func listBackupFiles(backupPath string) ([]util.BackupFile, error) {
+	if !pluginUtil.IsDedupBackup(backupPath) {
+		return util.ListBackupFiles(backupPath)
+	}
+
+	snapshot, err := pluginUtil.ReadDedupSnapshot(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return pluginUtil.ListDedupSnapshotFiles(snapshot), nil
+}
+
+// verifyBackup checks a backup against its manifest, a dedup backup is checked against the chunks in its snapshot index
+func verifyBackup(config util.Config, backupPath string) (util.BackupVerification, error) {
+	if !pluginUtil.IsDedupBackup(backupPath) {
+		return util.VerifyBackup(backupPath)
+	}
+
+	snapshot, err := pluginUtil.ReadDedupSnapshot(backupPath)
+	if err != nil {
+		return util.BackupVerification{}, err
+	}
+
+	repository := pluginUtil.NewDedupRepository(util.GetBackupDirFromConfig(config))
+	repository.Sealer, err = util.NewBackupSealer(util.GetSealOptions(config))
+	if err != nil {
+		return util.BackupVerification{}, err
+	}
+
+	return repository.VerifySnapshot(snapshot), nil
+}
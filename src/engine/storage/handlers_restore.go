@@ -36,7 +36,7 @@ func Restore(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -65,11 +65,11 @@ func Restore(w http.ResponseWriter, r *http.Request) {
 			_ = json.NewDecoder(r.Body).Decode(&result)
 			json.NewEncoder(w).Encode(result)
 		}
-		result = util.ExecutePlugin(config, "storage", plugin, "--restore")
+		result = util.ExecutePlugin(r.Context(), config, "storage", plugin, "--restore")
 		_ = json.NewDecoder(r.Body).Decode(&result)
 		json.NewEncoder(w).Encode(result)
 	} else {
-		plugin, err := util.GetStorageInterface(pluginPath)
+		plugin, err := util.GetStorageInterface(r.Context(), pluginPath)
 		if err != nil {
 			message := util.SetMessage("ERROR", err.Error())
 			messages = append(messages, message)
@@ -110,7 +110,7 @@ func RestoreCmd(w http.ResponseWriter, r *http.Request) {
 	var messages []util.Message
 
 	config, err := util.GetConfig(w, r)
-	printConfigDebug(config)
+	printConfigDebug(r, config)
 
 	if err != nil {
 		message := util.SetMessage("ERROR", "Couldn't read config! "+err.Error())
@@ -128,7 +128,7 @@ func RestoreCmd(w http.ResponseWriter, r *http.Request) {
 		args := strings.Split(config.RestoreCmd, ",")
 		message := util.SetMessage("INFO", "Performing restore command ["+config.RestoreCmd+"]")
 
-		result = util.ExecuteCommand(args...)
+		result = util.ExecuteCommandContext(r.Context(), args...)
 		result.Messages = util.PrependMessage(message, result.Messages)
 
 		_ = json.NewDecoder(r.Body).Decode(&result)
@@ -35,6 +35,8 @@ func NewRouter() *mux.Router {
 
 		handler = route.HandlerFunc
 		handler = util.LogApi(handler, route.Name)
+		handler = util.InstrumentApi(handler, "storage", route.Name)
+		handler = util.TraceApi(handler, "storage", route.Name)
 
 		router.
 			Methods(route.Method).
@@ -53,6 +55,12 @@ var routes = Routes{
 		"/status",
 		GetStatus,
 	},
+	Route{
+		"GetMetrics",
+		"GET",
+		"/metrics",
+		util.MetricsHandler,
+	},
 	Route{
 		"PluginList",
 		"GET",
@@ -77,6 +85,18 @@ var routes = Routes{
 		"/backupList",
 		BackupList,
 	},
+	Route{
+		"BackupBrowse",
+		"POST",
+		"/backupBrowse",
+		BackupBrowse,
+	},
+	Route{
+		"BackupVerify",
+		"POST",
+		"/backupVerify",
+		BackupVerify,
+	},
 	Route{
 		"BackupDelete",
 		"POST",
@@ -113,6 +133,12 @@ var routes = Routes{
 		"/archiveDelete",
 		ArchiveDelete,
 	},
+	Route{
+		"ArchiveRestore",
+		"POST",
+		"/archiveRestore",
+		ArchiveRestore,
+	},
 	Route{
 		"ArchiveCreateCmd",
 		"POST",
@@ -137,4 +163,10 @@ var routes = Routes{
 		"/restoreCmd",
 		RestoreCmd,
 	},
+	Route{
+		"WorkflowCmd",
+		"POST",
+		"/workflowCmd",
+		WorkflowCmd,
+	},
 }
@@ -14,6 +14,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	_ "fossul/src/engine/storage/docs"
 	"fossul/src/engine/util"
 	"github.com/swaggo/http-swagger"
@@ -45,8 +46,19 @@ var debug string = os.Getenv("FOSSUL_STORAGE_DEBUG")
 // @license.url http://www.apache.org/licenses/LICENSE-2.0.html
 
 func main() {
+	serviceLogger, err := util.NewServiceLogger("storage", util.GetLogSettings("FOSSUL_STORAGE", debug == "true"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	util.SetServiceLogger(serviceLogger)
+
+	shutdownTracing, err := util.InitTracing("storage", util.GetTraceSettings("FOSSUL_STORAGE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Plugin directory [" + pluginDir + "]")
-	err := util.CreateDir(pluginDir, 0755)
+	err = util.CreateDir(pluginDir, 0755)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -77,6 +89,11 @@ func main() {
 			log.Println("Storage service shutdown failed! %v", err)
 		}
 
+		// flush spans of finished requests to the exporter
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Println("Tracing shutdown failed [" + err.Error() + "]")
+		}
+
 		log.Println("Stopping storage service on port [" + port + "]")
 		close(idleConnsClosed)
 	}()
@@ -89,8 +106,8 @@ func main() {
 	<-idleConnsClosed
 }
 
-func printConfigDebug(config util.Config) {
+func printConfigDebug(r *http.Request, config util.Config) {
 	if debug == "true" {
-		log.Println("[DEBUG]", config)
+		util.RequestLogger(r).Debug(fmt.Sprint(config))
 	}
 }
@@ -12,6 +12,8 @@ limitations under the License.
 */
 package util
 
+import ()
+
 type Archives struct {
 	Archives []Archive `json:"archive,omitempty"`
 	Result   Result    `json:"result,omitempty"`
@@ -41,3 +43,35 @@ func GetArchivesByPolicy(policy string, archives []Archive) []Archive {
 
 	return archivesByPolicy
 }
+
+// GetArchiveByWorkflowId returns the archive of a policy created by a workflow, false if it doesn't exist
+func GetArchiveByWorkflowId(policy, workflowId string, archives []Archive) (Archive, bool) {
+	for _, archive := range GetArchivesByPolicy(policy, archives) {
+		if archive.WorkflowId == workflowId {
+			return archive, true
+		}
+	}
+
+	return Archive{}, false
+}
+
+// GetDeletedArchives returns the archives listed before retention that no longer exist after it, identified by
+// policy, workflow id and epoch like backups
+func GetDeletedArchives(before, after []Archive) []Archive {
+	var deletedArchives []Archive
+	for _, archive := range before {
+		isFound := false
+		for _, remaining := range after {
+			if remaining.Policy == archive.Policy && remaining.WorkflowId == archive.WorkflowId && remaining.Epoch == archive.Epoch {
+				isFound = true
+				break
+			}
+		}
+
+		if !isFound {
+			deletedArchives = append(deletedArchives, archive)
+		}
+	}
+
+	return deletedArchives
+}
@@ -32,3 +32,38 @@ func TestGetArchiveBackupsByPolicy(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetArchiveByWorkflowId(t *testing.T) {
+	var archives []Archive
+	archives = append(archives, Archive{Name: "test", Policy: "daily", WorkflowId: "1", Epoch: 1})
+	archives = append(archives, Archive{Name: "test", Policy: "weekly", WorkflowId: "2", Epoch: 2})
+
+	archive, isFound := GetArchiveByWorkflowId("daily", "1", archives)
+	if !isFound || archive.Epoch != 1 {
+		t.Fail()
+	}
+
+	_, isFound = GetArchiveByWorkflowId("daily", "2", archives)
+	if isFound {
+		t.Fail()
+	}
+}
+
+func TestGetDeletedArchives(t *testing.T) {
+	var before []Archive
+	for _, archiveName := range []string{"mariadb_daily_1570000000000001_1570000000", "mariadb_daily_1570086400000001_1570086400"} {
+		backup, err := ParseBackupName(archiveName)
+		if err != nil {
+			t.Logf("ERROR: %s", err.Error())
+			t.Fail()
+			return
+		}
+		before = append(before, Archive{Name: backup.Name, Policy: backup.Policy, WorkflowId: backup.WorkflowId, Epoch: backup.Epoch})
+	}
+
+	deletedArchives := GetDeletedArchives(before, before[1:])
+	if len(deletedArchives) != 1 || deletedArchives[0].WorkflowId != "1570000000000001" {
+		t.Logf("ERROR: Expected only archive of workflow [1570000000000001] deleted, got %v", deletedArchives)
+		t.Fail()
+	}
+}
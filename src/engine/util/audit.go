@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// AuditEntry records a change made through the api, Diff holds the lines removed (-) and added (+) by the change
+type AuditEntry struct {
+	Timestamp    int64    `json:"timestamp"`
+	User         string   `json:"user"`
+	Endpoint     string   `json:"endpoint"`
+	Action       string   `json:"action"`
+	ProfileName  string   `json:"profileName,omitempty"`
+	ConfigName   string   `json:"configName,omitempty"`
+	PluginName   string   `json:"pluginName,omitempty"`
+	Policy       string   `json:"policy,omitempty"`
+	WorkflowType string   `json:"workflowType,omitempty"`
+	ResultCode   int      `json:"resultCode"`
+	Diff         []string `json:"diff,omitempty"`
+}
+
+type AuditLogResult struct {
+	Entries []AuditEntry `json:"entries,omitempty"`
+	Result  Result       `json:"result,omitempty"`
+}
+
+var auditLogMutex sync.Mutex
+
+// secretLine matches configuration lines holding credentials, ex: SmtpPassword = "secret"
+var secretLine = regexp.MustCompile(`(?i)^(\s*"?[\w.-]*(password|secret|token|authorization|accesskey|apikey)[\w.-]*"?\s*=\s*).*$`)
+
+// AppendAuditEntry appends the entry to the audit log, one JSON document per line. Entries are never
+// rewritten or removed.
+func AppendAuditEntry(auditLog string, entry AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+
+	file, err := os.OpenFile(auditLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// ReadAuditEntries returns the audit log entries of a profile and config, oldest first. An empty profile or
+// config name matches all of them.
+func ReadAuditEntries(auditLog, profileName, configName string) ([]AuditEntry, error) {
+	var entries []AuditEntry
+
+	auditLogMutex.Lock()
+	defer auditLogMutex.Unlock()
+
+	file, err := os.Open(auditLog)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return entries, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, err
+		}
+
+		if profileName != "" && entry.ProfileName != profileName {
+			continue
+		}
+		if configName != "" && entry.ConfigName != configName {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// DiffLines compares two versions of a file line by line, removed lines are prefixed with - and added lines with +
+func DiffLines(before, after string) []string {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "-"+a[i])
+			i++
+		default:
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+
+	return diff
+}
+
+// MaskSecrets replaces the values of credentials in a configuration file so they are not written to the audit log
+func MaskSecrets(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = secretLine.ReplaceAllString(line, "${1}\"********\"")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
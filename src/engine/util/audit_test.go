@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	before := "AppPlugin = \"sample-app\"\nAutoDiscovery = true\nJobRetention = 50\n"
+	after := "AppPlugin = \"sample-app\"\nAutoDiscovery = false\nJobRetention = 50\nMaxQuiesceTime = 60\n"
+
+	diff := DiffLines(before, after)
+	if strings.Join(diff, "|") != "-AutoDiscovery = true|+AutoDiscovery = false|+MaxQuiesceTime = 60" {
+		t.Fail()
+	}
+
+	if len(DiffLines(before, before)) != 0 {
+		t.Fail()
+	}
+
+	if len(DiffLines(before, "")) != 3 {
+		t.Fail()
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	content := "SmtpUser = \"fossul\"\nSmtpPassword = \"redhat123\"\n  Authorization = \"Bearer abc\"\nAccessKeyId = \"AKIA\""
+	masked := MaskSecrets(content)
+	if strings.Contains(masked, "redhat123") || strings.Contains(masked, "Bearer") || !strings.Contains(masked, "SmtpUser = \"fossul\"") {
+		t.Fail()
+	}
+
+	if !strings.Contains(masked, "SmtpPassword = \"********\"") || !strings.Contains(masked, "AccessKeyId = \"********\"") {
+		t.Fail()
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	auditLog := dir + "/audit.log"
+	entries, err := ReadAuditEntries(auditLog, "", "")
+	if err != nil || len(entries) != 0 {
+		t.Fail()
+	}
+
+	_ = AppendAuditEntry(auditLog, AuditEntry{User: "admin", Action: "AddProfile", ProfileName: "p1"})
+	_ = AppendAuditEntry(auditLog, AuditEntry{User: "admin", Action: "AddConfig", ProfileName: "p1", ConfigName: "c1", Diff: []string{"+JobRetention = 50"}})
+	_ = AppendAuditEntry(auditLog, AuditEntry{User: "ops", Action: "AddConfig", ProfileName: "p2", ConfigName: "c1"})
+
+	entries, err = ReadAuditEntries(auditLog, "", "")
+	if err != nil || len(entries) != 3 || entries[0].Action != "AddProfile" {
+		t.Fail()
+	}
+
+	entries, _ = ReadAuditEntries(auditLog, "p1", "")
+	if len(entries) != 2 {
+		t.Fail()
+	}
+
+	entries, _ = ReadAuditEntries(auditLog, "p1", "c1")
+	if len(entries) != 1 || entries[0].Diff[0] != "+JobRetention = 50" {
+		t.Fail()
+	}
+}
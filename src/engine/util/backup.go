@@ -12,6 +12,8 @@ limitations under the License.
 */
 package util
 
+import ()
+
 type Backups struct {
 	Backups []Backup `json:"backup,omitempty"`
 	Result  Result   `json:"result,omitempty"`
@@ -41,3 +43,49 @@ func GetBackupsByPolicy(policy string, backups []Backup) []Backup {
 
 	return backupsByPolicy
 }
+
+// GetLatestBackup returns the newest backup of a policy, false if the policy has no backups
+func GetLatestBackup(policy string, backups []Backup) (Backup, bool) {
+	var latestBackup Backup
+	var isFound bool
+	for _, backup := range GetBackupsByPolicy(policy, backups) {
+		if !isFound || backup.Epoch > latestBackup.Epoch {
+			latestBackup = backup
+			isFound = true
+		}
+	}
+
+	return latestBackup, isFound
+}
+
+// GetBackupByWorkflowId returns the backup of a policy created by a workflow, false if it no longer exists
+func GetBackupByWorkflowId(policy, workflowId string, backups []Backup) (Backup, bool) {
+	for _, backup := range GetBackupsByPolicy(policy, backups) {
+		if backup.WorkflowId == workflowId {
+			return backup, true
+		}
+	}
+
+	return Backup{}, false
+}
+
+// GetDeletedBackups returns the backups listed before retention that no longer exist after it, all backups of a
+// config share the name so a backup is identified by its policy, workflow id and epoch
+func GetDeletedBackups(before, after []Backup) []Backup {
+	var deletedBackups []Backup
+	for _, backup := range before {
+		isFound := false
+		for _, remaining := range after {
+			if remaining.Policy == backup.Policy && remaining.WorkflowId == backup.WorkflowId && remaining.Epoch == backup.Epoch {
+				isFound = true
+				break
+			}
+		}
+
+		if !isFound {
+			deletedBackups = append(deletedBackups, backup)
+		}
+	}
+
+	return deletedBackups
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+type BackupFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	IsDir   bool   `json:"isDir"`
+	ModTime int64  `json:"modTime"`
+}
+
+type BackupFiles struct {
+	Files  []BackupFile `json:"files,omitempty"`
+	Result Result       `json:"result,omitempty"`
+}
+
+// ListBackupFiles returns all files and directories of a backup, paths are relative to the backup
+func ListBackupFiles(backupPath string) ([]BackupFile, error) {
+	var files []BackupFile
+	err := filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == backupPath {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(backupPath, path)
+		if err != nil {
+			return err
+		}
+
+		var file BackupFile
+		file.Path = relPath
+		file.Size = info.Size()
+		file.IsDir = info.IsDir()
+		file.ModTime = info.ModTime().Unix()
+		files = append(files, file)
+
+		return nil
+	})
+
+	if err != nil {
+		return files, err
+	}
+
+	return files, nil
+}
+
+// ValidateRestorePaths makes sure selected paths stay within the backup
+func ValidateRestorePaths(paths []string) error {
+	for _, path := range paths {
+		cleanPath := filepath.Clean(path)
+		if path == "" || filepath.IsAbs(cleanPath) || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+			return errors.New("Restore path [" + path + "] must be relative to the backup")
+		}
+	}
+
+	return nil
+}
+
+// StageRestorePaths copies the selected paths of a backup into stageDir keeping the backup directory name and
+// layout, restoring the staged backup copies only the selected paths to the same place as a full restore
+func StageRestorePaths(restorePath, stageDir string, paths []string) (string, error) {
+	err := ValidateRestorePaths(paths)
+	if err != nil {
+		return "", err
+	}
+
+	stagePath := stageDir + "/" + filepath.Base(restorePath)
+	for _, path := range paths {
+		err := validateRestorePathLinks(restorePath, path)
+		if err != nil {
+			return "", err
+		}
+
+		srcPath := filepath.Join(restorePath, path)
+		if _, err := os.Lstat(srcPath); err != nil {
+			return "", errors.New("Restore path [" + path + "] not found in backup")
+		}
+
+		err = copyPath(srcPath, filepath.Join(stagePath, path))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return stagePath, nil
+}
+
+// StageBackup copies a whole backup into stageDir keeping the backup directory name
+func StageBackup(restorePath, stageDir string) (string, error) {
+	stagePath := stageDir + "/" + filepath.Base(restorePath)
+	err := copyPath(restorePath, stagePath)
+	if err != nil {
+		return "", err
+	}
+
+	return stagePath, nil
+}
+
+// validateRestorePathLinks makes sure a restore path doesn't resolve through a symlink within the backup, which
+// could point anywhere on the storage host. The selected path itself may be a symlink, it is copied as a link.
+func validateRestorePathLinks(restorePath, path string) error {
+	parentPath := restorePath
+	parts := strings.Split(filepath.Clean(path), string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		parentPath = filepath.Join(parentPath, part)
+		info, err := os.Lstat(parentPath)
+		if err != nil {
+			return errors.New("Restore path [" + path + "] not found in backup")
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.New("Restore path [" + path + "] resolves through symlink [" + part + "] in backup")
+		}
+	}
+
+	return nil
+}
+
+// copyPath copies files and directories, symlinks are recreated as links and never followed
+func copyPath(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		dstPath := filepath.Join(dst, relPath)
+		if info.IsDir() {
+			return os.MkdirAll(dstPath, info.Mode())
+		}
+
+		err = os.MkdirAll(filepath.Dir(dstPath), 0755)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+
+			return os.Symlink(link, dstPath)
+		}
+
+		if !info.Mode().IsRegular() {
+			return errors.New("Backup file [" + relPath + "] is not a regular file, directory or symlink")
+		}
+
+		return copyFile(path, dstPath, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	srcFile, err := os.OpenFile(src, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestListBackupFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/mariadb_daily_1234_1557240122"
+	os.MkdirAll(backupPath+"/data/sampledb", 0755)
+	ioutil.WriteFile(backupPath+"/data/sampledb/t1.ibd", []byte("foo"), 0644)
+
+	files, err := ListBackupFiles(backupPath)
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(files) != 3 || files[2].Path != "data/sampledb/t1.ibd" || files[2].Size != 3 || files[2].IsDir {
+		t.Fail()
+	}
+}
+
+func TestValidateRestorePaths(t *testing.T) {
+	if ValidateRestorePaths([]string{"data/sampledb", "t1.ibd"}) != nil {
+		t.Fail()
+	}
+
+	if ValidateRestorePaths([]string{"/etc/passwd"}) == nil {
+		t.Fail()
+	}
+
+	if ValidateRestorePaths([]string{"data/../../etc"}) == nil {
+		t.Fail()
+	}
+}
+
+func TestStageRestorePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/backups/mariadb_daily_1234_1557240122"
+	os.MkdirAll(backupPath+"/data/sampledb", 0755)
+	os.MkdirAll(backupPath+"/data/test", 0755)
+	ioutil.WriteFile(backupPath+"/data/sampledb/t1.ibd", []byte("foo"), 0644)
+	ioutil.WriteFile(backupPath+"/data/test/t2.ibd", []byte("bar"), 0644)
+
+	stagePath, err := StageRestorePaths(backupPath, dir+"/stage", []string{"data/sampledb"})
+	if err != nil {
+		t.Fail()
+	}
+
+	if stagePath != dir+"/stage/mariadb_daily_1234_1557240122" {
+		t.Fail()
+	}
+
+	if !ExistsPath(stagePath+"/data/sampledb/t1.ibd") || ExistsPath(stagePath+"/data/test") {
+		t.Fail()
+	}
+
+	_, err = StageRestorePaths(backupPath, dir+"/stage", []string{"data/missing"})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestStageRestorePathsSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/backups/mariadb_daily_1234_1557240122"
+	os.MkdirAll(backupPath+"/data", 0755)
+	ioutil.WriteFile(backupPath+"/data/t1.ibd", []byte("foo"), 0644)
+	os.Symlink("/", backupPath+"/etc")
+
+	_, err = StageRestorePaths(backupPath, dir+"/stage", []string{"etc/passwd"})
+	if err == nil {
+		t.Logf("ERROR: Restore path through a symlink should be rejected")
+		t.Fail()
+	}
+
+	stagePath, err := StageRestorePaths(backupPath, dir+"/stage", []string{"etc"})
+	if err != nil {
+		t.Logf("ERROR: %s", err.Error())
+		t.Fail()
+		return
+	}
+
+	info, err := os.Lstat(stagePath + "/etc")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Logf("ERROR: Symlink [etc] should be staged as a link")
+		t.Fail()
+	}
+
+	stagePath, err = StageBackup(backupPath, dir+"/full")
+	if err != nil {
+		t.Logf("ERROR: %s", err.Error())
+		t.Fail()
+		return
+	}
+
+	info, err = os.Lstat(stagePath + "/etc")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 || !ExistsPath(stagePath+"/data/t1.ibd") {
+		t.Logf("ERROR: Staged backup should keep symlink [etc] as a link")
+		t.Fail()
+	}
+}
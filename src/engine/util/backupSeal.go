@@ -0,0 +1,799 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	CompressionNone       = "none"
+	CompressionGzip       = "gzip"
+	CompressionZstd       = "zstd"
+	EncryptionAes256Gcm   = "aes-256-gcm"
+	KdfNone               = "none"
+	KdfScrypt             = "scrypt"
+	sealMagic             = "FOSSULSEALED1\n"
+	sealSegmentSize       = 64 * 1024
+	sealMaxHeaderSize     = 64 * 1024
+	sealTmpSuffix         = ".fossul-seal"
+	scryptN               = 32768
+	scryptR               = 8
+	scryptP               = 1
+	backupKeySize         = 32
+	backupKeySaltSize     = 16
+	backupSegmentFinal    = 1
+	backupSegmentNotFinal = 0
+	chunkIdKeyLabel       = "fossul dedup chunk id"
+)
+
+// SealOptions configure compression and encryption of backups at rest, KeyFile holds either a hex encoded
+// 256 bit key or a passphrase. The key id is the name of the key file without extension, other key files
+// in the same directory are used to open backups sealed with an older key.
+type SealOptions struct {
+	Compression string
+	KeyFile     string
+}
+
+// SealHeader is written in front of every sealed file so it can be opened without any other metadata
+type SealHeader struct {
+	Compression string `json:"compression"`
+	Encryption  string `json:"encryption,omitempty"`
+	KeyId       string `json:"keyId,omitempty"`
+	Kdf         string `json:"kdf,omitempty"`
+	Salt        []byte `json:"salt,omitempty"`
+	Nonce       []byte `json:"nonce,omitempty"`
+}
+
+// BackupEncoding records how a backup was sealed
+type BackupEncoding struct {
+	Compression string `json:"compression,omitempty"`
+	Encryption  string `json:"encryption,omitempty"`
+	KeyId       string `json:"keyId,omitempty"`
+}
+
+type BackupSealer struct {
+	options SealOptions
+	key     backupKey
+	mutex   sync.Mutex
+	keys    map[string][]byte
+}
+
+var errSealedFileFound = errors.New("sealed file found")
+
+type backupKey struct {
+	id   string
+	kdf  string
+	salt []byte
+	key  []byte
+}
+
+func GetSealOptions(config Config) SealOptions {
+	var options SealOptions
+	options.Compression = config.BackupCompression
+	options.KeyFile = config.BackupEncryptionKeyFile
+
+	return options
+}
+
+func GetSealOptionsFromMap(configMap map[string]string) SealOptions {
+	var options SealOptions
+	options.Compression = configMap["BackupCompression"]
+	options.KeyFile = configMap["BackupEncryptionKeyFile"]
+
+	return options
+}
+
+func (options SealOptions) IsEnabled() bool {
+	return (options.Compression != "" && options.Compression != CompressionNone) || options.KeyFile != ""
+}
+
+// NewBackupSealer validates the options and loads the key, the key is derived once and shared by all files of a backup
+func NewBackupSealer(options SealOptions) (*BackupSealer, error) {
+	sealer := &BackupSealer{options: options, keys: make(map[string][]byte)}
+
+	switch options.Compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+	default:
+		return nil, errors.New("Backup compression [" + options.Compression + "] is not supported, use none, gzip or zstd")
+	}
+
+	if options.KeyFile != "" {
+		key, err := loadBackupKey(options.KeyFile, nil)
+		if err != nil {
+			return nil, err
+		}
+		sealer.key = key
+	}
+
+	return sealer, nil
+}
+
+func (sealer *BackupSealer) Encoding() BackupEncoding {
+	var encoding BackupEncoding
+	encoding.Compression = sealer.getCompression()
+	if sealer.key.key != nil {
+		encoding.Encryption = EncryptionAes256Gcm
+		encoding.KeyId = sealer.key.id
+	}
+
+	return encoding
+}
+
+// Seal compresses and encrypts r into w
+func (sealer *BackupSealer) Seal(w io.Writer, r io.Reader) error {
+	var header SealHeader
+	header.Compression = sealer.getCompression()
+
+	var aead cipher.AEAD
+	if sealer.key.key != nil {
+		var err error
+		aead, err = newBackupAead(sealer.key.key)
+		if err != nil {
+			return err
+		}
+
+		header.Encryption = EncryptionAes256Gcm
+		header.KeyId = sealer.key.id
+		header.Kdf = sealer.key.kdf
+		header.Salt = sealer.key.salt
+		header.Nonce = make([]byte, aead.NonceSize())
+		if _, err := rand.Read(header.Nonce); err != nil {
+			return err
+		}
+	}
+
+	headerBytes, err := writeSealHeader(w, header)
+	if err != nil {
+		return err
+	}
+
+	var body io.WriteCloser = nopWriteCloser{w}
+	if aead != nil {
+		body = &segmentWriter{w: w, aead: aead, nonce: header.Nonce, header: headerBytes}
+	}
+
+	compressor, err := newCompressor(body, header.Compression)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(compressor, r); err != nil {
+		return err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	return body.Close()
+}
+
+// Open decrypts and decompresses a sealed r into w, the key is looked up by the key id of the header
+func (sealer *BackupSealer) Open(w io.Writer, r io.Reader) error {
+	return sealer.open(w, r, false)
+}
+
+// openEncrypted opens a sealed r that must be encrypted, a file that is only compressed isn't authenticated
+func (sealer *BackupSealer) openEncrypted(w io.Writer, r io.Reader) error {
+	return sealer.open(w, r, true)
+}
+
+func (sealer *BackupSealer) open(w io.Writer, r io.Reader, isEncryptionRequired bool) error {
+	reader := bufio.NewReader(r)
+	header, headerBytes, err := readSealHeader(reader)
+	if err != nil {
+		return err
+	}
+
+	if isEncryptionRequired && header.Encryption == "" {
+		return errors.New("File is sealed without encryption")
+	}
+
+	var body io.Reader = reader
+	var segments *segmentReader
+	if header.Encryption != "" {
+		if header.Encryption != EncryptionAes256Gcm {
+			return errors.New("Backup encryption [" + header.Encryption + "] is not supported")
+		}
+
+		key, err := sealer.getKey(header)
+		if err != nil {
+			return err
+		}
+
+		aead, err := newBackupAead(key)
+		if err != nil {
+			return err
+		}
+
+		segments = &segmentReader{r: reader, aead: aead, nonce: header.Nonce, header: headerBytes}
+		body = segments
+	}
+
+	decompressor, err := newDecompressor(body, header.Compression)
+	if err != nil {
+		return err
+	}
+	defer decompressor.Close()
+
+	if _, err := io.Copy(w, decompressor); err != nil {
+		return err
+	}
+
+	// the final segment authenticates the end of the backup, a truncated file fails here
+	if segments != nil {
+		if _, err := io.Copy(ioutil.Discard, segments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SealFile seals a file in place, the sealed file is written next to it and renamed
+func (sealer *BackupSealer) SealFile(path string) error {
+	return replaceFile(path, sealer.Seal)
+}
+
+// OpenFile opens a sealed file in place, returns false if the file isn't sealed
+func (sealer *BackupSealer) OpenFile(path string) (bool, error) {
+	sealed, err := IsSealedFile(path)
+	if err != nil || !sealed {
+		return false, err
+	}
+
+	err = replaceFile(path, sealer.Open)
+	if err != nil {
+		return false, errors.New("Couldn't open sealed file [" + path + "]! " + err.Error())
+	}
+
+	return true, nil
+}
+
+// openEncryptedFile opens a sealed file in place, a file that isn't sealed and encrypted is rejected
+func (sealer *BackupSealer) openEncryptedFile(path string) error {
+	sealed, err := IsSealedFile(path)
+	if err != nil {
+		return err
+	} else if !sealed {
+		return errors.New("File [" + path + "] of encrypted backup isn't sealed")
+	}
+
+	err = replaceFile(path, sealer.openEncrypted)
+	if err != nil {
+		return errors.New("Couldn't open sealed file [" + path + "]! " + err.Error())
+	}
+
+	return nil
+}
+
+// SealDir seals all regular files under dir and returns the number of files sealed
+func (sealer *BackupSealer) SealDir(dir string) (int, error) {
+	var count int
+	err := walkRegularFiles(dir, func(path string) error {
+		sealed, err := IsSealedFile(path)
+		if err != nil || sealed {
+			return err
+		}
+
+		err = sealer.SealFile(path)
+		if err != nil {
+			return err
+		}
+
+		count++
+		return nil
+	})
+
+	return count, err
+}
+
+// OpenDir opens all sealed files under dir. Files that aren't sealed are left as they are unless the encoding,
+// read from the backup manifest, records encryption, then every file must be sealed and encrypted so a file
+// swapped in the backup tree can't be restored unauthenticated.
+func (sealer *BackupSealer) OpenDir(dir string, encoding BackupEncoding) (int, error) {
+	var count int
+	err := walkRegularFiles(dir, func(path string) error {
+		if encoding.Encryption != "" {
+			err := sealer.openEncryptedFile(path)
+			if err != nil {
+				return err
+			}
+
+			count++
+			return nil
+		}
+
+		opened, err := sealer.OpenFile(path)
+		if err != nil {
+			return err
+		}
+
+		if opened {
+			count++
+		}
+		return nil
+	})
+
+	return count, err
+}
+
+// GetBackupEncodingDescription describes how a backup was sealed for messages
+func GetBackupEncodingDescription(encoding BackupEncoding) string {
+	description := "compression [" + encoding.Compression + "]"
+	if encoding.Encryption != "" {
+		description = description + " encryption [" + encoding.Encryption + "] key id [" + encoding.KeyId + "]"
+	}
+
+	return description
+}
+
+func IsSealedData(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealMagic))
+}
+
+func IsSealedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(sealMagic))
+	_, err = io.ReadFull(file, magic)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return string(magic) == sealMagic, nil
+}
+
+// IsSealedBackup returns true if any file of a backup is sealed
+func IsSealedBackup(backupPath string) (bool, error) {
+	var sealed bool
+	err := walkRegularFiles(backupPath, func(path string) error {
+		isSealed, err := IsSealedFile(path)
+		if err != nil {
+			return err
+		}
+
+		if isSealed {
+			sealed = true
+			return errSealedFileFound
+		}
+		return nil
+	})
+
+	if err == errSealedFileFound {
+		err = nil
+	}
+
+	return sealed, err
+}
+
+func (sealer *BackupSealer) getCompression() string {
+	if sealer.options.Compression == "" {
+		return CompressionNone
+	}
+
+	return sealer.options.Compression
+}
+
+// getKey returns the key for a sealed file, derived keys are cached by key id and salt
+func (sealer *BackupSealer) getKey(header SealHeader) ([]byte, error) {
+	sealer.mutex.Lock()
+	defer sealer.mutex.Unlock()
+
+	cacheKey := header.KeyId + "/" + hex.EncodeToString(header.Salt)
+	if key, ok := sealer.keys[cacheKey]; ok {
+		return key, nil
+	}
+
+	if sealer.options.KeyFile == "" {
+		return nil, errors.New("Backup is encrypted with key id [" + header.KeyId + "] but BackupEncryptionKeyFile is not set")
+	}
+
+	keyFile, err := findBackupKeyFile(filepath.Dir(sealer.options.KeyFile), header.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := loadBackupKey(keyFile, header.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.kdf != header.Kdf {
+		return nil, errors.New("Key file [" + keyFile + "] for key id [" + header.KeyId + "] doesn't match the key the backup was encrypted with")
+	}
+
+	sealer.keys[cacheKey] = key.key
+	return key.key, nil
+}
+
+// GetChunkIdKey returns the key content addressed chunks of encrypted backups are named with, so chunk names don't
+// reveal the hash of the plain data. The key is derived from the key file of the key id with a fixed salt and a
+// separate label so it stays the same for every backup and is never the encryption key itself.
+func (sealer *BackupSealer) GetChunkIdKey(keyId string) ([]byte, error) {
+	sealer.mutex.Lock()
+	defer sealer.mutex.Unlock()
+
+	cacheKey := "chunkId/" + keyId
+	if key, ok := sealer.keys[cacheKey]; ok {
+		return key, nil
+	}
+
+	if sealer.options.KeyFile == "" {
+		return nil, errors.New("Chunks are named with key id [" + keyId + "] but BackupEncryptionKeyFile is not set")
+	}
+
+	keyFile, err := findBackupKeyFile(filepath.Dir(sealer.options.KeyFile), keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := loadBackupKey(keyFile, []byte(chunkIdKeyLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key.key)
+	mac.Write([]byte(chunkIdKeyLabel))
+	chunkIdKey := mac.Sum(nil)
+
+	sealer.keys[cacheKey] = chunkIdKey
+	return chunkIdKey, nil
+}
+
+// loadBackupKey reads a key file, a 64 character hex string is used as key otherwise the content is a
+// passphrase and the key is derived with scrypt. A new salt is generated if salt is nil.
+func loadBackupKey(keyFile string, salt []byte) (backupKey, error) {
+	var key backupKey
+	key.id = getBackupKeyId(keyFile)
+
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return key, errors.New("Couldn't read backup encryption key file! " + err.Error())
+	}
+
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return key, errors.New("Backup encryption key file [" + keyFile + "] is empty")
+	}
+
+	if rawKey, err := hex.DecodeString(secret); err == nil && len(rawKey) == backupKeySize {
+		key.kdf = KdfNone
+		key.key = rawKey
+		return key, nil
+	}
+
+	if salt == nil {
+		salt = make([]byte, backupKeySaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return key, err
+		}
+	}
+
+	derivedKey, err := scrypt.Key([]byte(secret), salt, scryptN, scryptR, scryptP, backupKeySize)
+	if err != nil {
+		return key, err
+	}
+
+	key.kdf = KdfScrypt
+	key.salt = salt
+	key.key = derivedKey
+	return key, nil
+}
+
+func getBackupKeyId(keyFile string) string {
+	name := filepath.Base(keyFile)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func findBackupKeyFile(keyDir, keyId string) (string, error) {
+	files, err := ioutil.ReadDir(keyDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range files {
+		if !f.IsDir() && getBackupKeyId(f.Name()) == keyId {
+			return keyDir + "/" + f.Name(), nil
+		}
+	}
+
+	return "", errors.New("Key file for key id [" + keyId + "] not found in [" + keyDir + "]")
+}
+
+func newBackupAead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func writeSealHeader(w io.Writer, header SealHeader) ([]byte, error) {
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, sealMagic); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(headerBytes))); err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(headerBytes)
+	return headerBytes, err
+}
+
+func readSealHeader(r io.Reader) (SealHeader, []byte, error) {
+	var header SealHeader
+
+	magic := make([]byte, len(sealMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != sealMagic {
+		return header, nil, errors.New("File is not a sealed backup file")
+	}
+
+	var length uint32
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return header, nil, err
+	}
+
+	if length > sealMaxHeaderSize {
+		return header, nil, errors.New("Sealed backup file header is too large")
+	}
+
+	headerBytes := make([]byte, length)
+	if _, err := io.ReadFull(r, headerBytes); err != nil {
+		return header, nil, err
+	}
+
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return header, nil, err
+	}
+
+	return header, headerBytes, nil
+}
+
+func newCompressor(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	}
+
+	return nil, errors.New("Backup compression [" + compression + "] is not supported")
+}
+
+func newDecompressor(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionNone:
+		return ioutil.NopCloser(r), nil
+	}
+
+	return nil, errors.New("Backup compression [" + compression + "] is not supported")
+}
+
+// segmentWriter encrypts a stream in segments, each segment has its own nonce and authenticates its position
+// and whether it is the last one so segments can't be reordered, dropped or the stream truncated
+type segmentWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	header  []byte
+	buffer  []byte
+	counter uint64
+}
+
+func (s *segmentWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		// a full segment is only written once more data follows, the last segment is written by Close
+		if len(s.buffer) == sealSegmentSize {
+			if err := s.writeSegment(backupSegmentNotFinal); err != nil {
+				return 0, err
+			}
+		}
+
+		n := sealSegmentSize - len(s.buffer)
+		if n > len(p) {
+			n = len(p)
+		}
+		s.buffer = append(s.buffer, p[:n]...)
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func (s *segmentWriter) Close() error {
+	return s.writeSegment(backupSegmentFinal)
+}
+
+func (s *segmentWriter) writeSegment(final byte) error {
+	nonce, aad := getSegmentNonce(s.nonce, s.header, s.counter, final)
+	sealed := s.aead.Seal(nil, nonce, s.buffer, aad)
+
+	if err := binary.Write(s.w, binary.BigEndian, uint32(len(sealed))); err != nil {
+		return err
+	}
+
+	if _, err := s.w.Write(sealed); err != nil {
+		return err
+	}
+
+	s.buffer = s.buffer[:0]
+	s.counter++
+	return nil
+}
+
+type segmentReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	header  []byte
+	buffer  *bytes.Reader
+	counter uint64
+	final   bool
+}
+
+func (s *segmentReader) Read(p []byte) (int, error) {
+	for s.buffer == nil || s.buffer.Len() == 0 {
+		if s.final {
+			return 0, io.EOF
+		}
+
+		if err := s.readSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	return s.buffer.Read(p)
+}
+
+func (s *segmentReader) readSegment() error {
+	var length uint32
+	if err := binary.Read(s.r, binary.BigEndian, &length); err != nil {
+		if err == io.EOF {
+			return errors.New("Sealed backup file is truncated")
+		}
+		return err
+	}
+
+	if length > sealSegmentSize+uint32(s.aead.Overhead()) {
+		return errors.New("Sealed backup file segment is too large")
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(s.r, sealed); err != nil {
+		return errors.New("Sealed backup file is truncated")
+	}
+
+	// a segment opens with either flag, only the final one is allowed to end the stream
+	for _, final := range []byte{backupSegmentNotFinal, backupSegmentFinal} {
+		nonce, aad := getSegmentNonce(s.nonce, s.header, s.counter, final)
+		plain, err := s.aead.Open(nil, nonce, sealed, aad)
+		if err == nil {
+			s.buffer = bytes.NewReader(plain)
+			s.final = final == backupSegmentFinal
+			s.counter++
+			return nil
+		}
+	}
+
+	return errors.New("Couldn't decrypt sealed backup file, wrong key or corrupt data")
+}
+
+func getSegmentNonce(baseNonce, header []byte, counter uint64, final byte) ([]byte, []byte) {
+	nonce := make([]byte, len(baseNonce))
+	copy(nonce, baseNonce)
+
+	var counterBytes [8]byte
+	binary.BigEndian.PutUint64(counterBytes[:], counter)
+	for i := range counterBytes {
+		nonce[len(nonce)-8+i] ^= counterBytes[i]
+	}
+
+	aad := append(append([]byte(nil), header...), final)
+	return nonce, aad
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func replaceFile(path string, transform func(w io.Writer, r io.Reader) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := path + sealTmpSuffix
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+
+	err = transform(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func walkRegularFiles(dir string, fn func(path string) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return fn(path)
+	})
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(dir+"/hexkey.key", []byte(strings.Repeat("ab", 32)+"\n"), 0600)
+	ioutil.WriteFile(dir+"/passkey.key", []byte("correct horse battery staple\n"), 0600)
+
+	data := []byte(strings.Repeat("fossul backup data ", 10000))
+
+	for _, options := range []SealOptions{
+		SealOptions{Compression: CompressionGzip},
+		SealOptions{Compression: CompressionZstd, KeyFile: dir + "/hexkey.key"},
+		SealOptions{KeyFile: dir + "/passkey.key"},
+	} {
+		sealer, err := NewBackupSealer(options)
+		if err != nil {
+			t.Fail()
+			continue
+		}
+
+		var sealed bytes.Buffer
+		err = sealer.Seal(&sealed, bytes.NewReader(data))
+		if err != nil || !IsSealedData(sealed.Bytes()) || bytes.Contains(sealed.Bytes(), []byte("fossul backup data fossul")) {
+			t.Fail()
+			continue
+		}
+
+		if options.Compression != "" && sealed.Len() >= len(data) {
+			t.Fail()
+		}
+
+		var opened bytes.Buffer
+		err = sealer.Open(&opened, bytes.NewReader(sealed.Bytes()))
+		if err != nil || !bytes.Equal(opened.Bytes(), data) {
+			t.Fail()
+		}
+	}
+}
+
+func TestSealKeyRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(dir+"/2019-01.key", []byte("old passphrase"), 0600)
+	ioutil.WriteFile(dir+"/2019-02.key", []byte(strings.Repeat("cd", 32)), 0600)
+
+	oldSealer, err := NewBackupSealer(SealOptions{Compression: CompressionGzip, KeyFile: dir + "/2019-01.key"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	if encoding := oldSealer.Encoding(); encoding.KeyId != "2019-01" || encoding.Encryption != EncryptionAes256Gcm {
+		t.Fail()
+	}
+
+	var sealed bytes.Buffer
+	oldSealer.Seal(&sealed, strings.NewReader("data sealed with the old key"))
+
+	// the current key is 2019-02, the old key is found by its key id in the same directory
+	newSealer, err := NewBackupSealer(SealOptions{KeyFile: dir + "/2019-02.key"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	var opened bytes.Buffer
+	err = newSealer.Open(&opened, bytes.NewReader(sealed.Bytes()))
+	if err != nil || opened.String() != "data sealed with the old key" {
+		t.Fail()
+	}
+
+	os.Remove(dir + "/2019-01.key")
+	newSealer, _ = NewBackupSealer(SealOptions{KeyFile: dir + "/2019-02.key"})
+	err = newSealer.Open(&opened, bytes.NewReader(sealed.Bytes()))
+	if err == nil {
+		t.Fail()
+	}
+
+	plainSealer, _ := NewBackupSealer(SealOptions{})
+	err = plainSealer.Open(&opened, bytes.NewReader(sealed.Bytes()))
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestSealTamper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(dir+"/key.key", []byte(strings.Repeat("ef", 32)), 0600)
+	sealer, err := NewBackupSealer(SealOptions{KeyFile: dir + "/key.key"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	data := make([]byte, 3*sealSegmentSize)
+	var sealed bytes.Buffer
+	sealer.Seal(&sealed, bytes.NewReader(data))
+
+	// a truncated backup must not open, even when it ends on a segment boundary
+	segmentLength := 4 + sealSegmentSize + 16
+	for _, length := range []int{sealed.Len() - 1, sealed.Len() - segmentLength} {
+		err = sealer.Open(ioutil.Discard, bytes.NewReader(sealed.Bytes()[:length]))
+		if err == nil {
+			t.Fail()
+		}
+	}
+
+	corrupt := append([]byte(nil), sealed.Bytes()...)
+	corrupt[len(corrupt)-segmentLength-1] ^= 1
+	err = sealer.Open(ioutil.Discard, bytes.NewReader(corrupt))
+	if err == nil {
+		t.Fail()
+	}
+
+	_, err = NewBackupSealer(SealOptions{Compression: "lz4"})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestSealDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/db_daily_1_100"
+	os.MkdirAll(backupPath+"/data", 0755)
+	ioutil.WriteFile(backupPath+"/data/a.txt", []byte("hello"), 0640)
+	ioutil.WriteFile(backupPath+"/b.txt", []byte(""), 0644)
+
+	sealed, err := IsSealedBackup(backupPath)
+	if err != nil || sealed {
+		t.Fail()
+	}
+
+	sealer, _ := NewBackupSealer(SealOptions{Compression: CompressionZstd})
+	count, err := sealer.SealDir(backupPath)
+	if err != nil || count != 2 {
+		t.Fail()
+	}
+
+	sealed, err = IsSealedBackup(backupPath)
+	if err != nil || !sealed {
+		t.Fail()
+	}
+
+	stagePath, err := StageBackup(backupPath, dir+"/stage")
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	count, err = sealer.OpenDir(stagePath, sealer.Encoding())
+	if err != nil || count != 2 {
+		t.Fail()
+	}
+
+	b, err := ioutil.ReadFile(stagePath + "/data/a.txt")
+	if err != nil || string(b) != "hello" {
+		t.Fail()
+	}
+
+	info, err := os.Stat(stagePath + "/data/a.txt")
+	if err != nil || info.Mode().Perm() != 0640 {
+		t.Fail()
+	}
+}
+
+func TestOpenDirEncryptedBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(dir+"/hexkey.key", []byte(strings.Repeat("ab", 32)+"\n"), 0600)
+
+	for _, swapped := range []string{"plain", "compressed", ""} {
+		backupPath := dir + "/db_daily_1_100_" + swapped
+		os.MkdirAll(backupPath+"/data", 0755)
+		ioutil.WriteFile(backupPath+"/data/a.txt", []byte("hello"), 0640)
+
+		sealer, _ := NewBackupSealer(SealOptions{Compression: CompressionZstd, KeyFile: dir + "/hexkey.key"})
+		_, err := sealer.SealDir(backupPath)
+		if err != nil {
+			t.Logf("ERROR: " + err.Error())
+			t.Fail()
+			continue
+		}
+
+		// a file swapped into the backup tree after it was sealed, either plaintext or sealed without encryption
+		switch swapped {
+		case "plain":
+			ioutil.WriteFile(backupPath+"/data/b.txt", []byte("swapped"), 0644)
+		case "compressed":
+			ioutil.WriteFile(backupPath+"/data/b.txt", []byte("swapped"), 0644)
+			compressor, _ := NewBackupSealer(SealOptions{Compression: CompressionGzip})
+			compressor.SealFile(backupPath + "/data/b.txt")
+		}
+
+		count, err := sealer.OpenDir(backupPath, sealer.Encoding())
+		if swapped != "" && err == nil {
+			t.Logf("ERROR: encrypted backup with %s file opened", swapped)
+			t.Fail()
+		} else if swapped == "" && (err != nil || count != 1) {
+			t.Logf("ERROR: encrypted backup didn't open %v", err)
+			t.Fail()
+		}
+	}
+}
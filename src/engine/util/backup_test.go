@@ -32,3 +32,66 @@ func TestGetBackupsByPolicy(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestGetLatestBackup(t *testing.T) {
+	var backups []Backup
+	backups = append(backups, Backup{Name: "test", Policy: "daily", WorkflowId: "1", Epoch: 1})
+	backups = append(backups, Backup{Name: "test", Policy: "daily", WorkflowId: "3", Epoch: 3})
+	backups = append(backups, Backup{Name: "test", Policy: "weekly", WorkflowId: "4", Epoch: 4})
+	backups = append(backups, Backup{Name: "test", Policy: "daily", WorkflowId: "2", Epoch: 2})
+
+	backup, isFound := GetLatestBackup("daily", backups)
+	if !isFound || backup.WorkflowId != "3" {
+		t.Fail()
+	}
+
+	_, isFound = GetLatestBackup("monthly", backups)
+	if isFound {
+		t.Fail()
+	}
+}
+
+func TestGetBackupByWorkflowId(t *testing.T) {
+	var backups []Backup
+	backups = append(backups, Backup{Name: "test", Policy: "daily", WorkflowId: "1", Epoch: 1})
+	backups = append(backups, Backup{Name: "test", Policy: "weekly", WorkflowId: "2", Epoch: 2})
+
+	backup, isFound := GetBackupByWorkflowId("daily", "1", backups)
+	if !isFound || backup.Epoch != 1 {
+		t.Fail()
+	}
+
+	_, isFound = GetBackupByWorkflowId("daily", "2", backups)
+	if isFound {
+		t.Fail()
+	}
+}
+
+func TestGetDeletedBackups(t *testing.T) {
+	var before []Backup
+	for _, backupName := range []string{"mariadb_daily_1570000000000001_1570000000", "mariadb_daily_1570086400000001_1570086400", "mariadb_weekly_1570086400000002_1570086400"} {
+		backup, err := ParseBackupName(backupName)
+		if err != nil {
+			t.Logf("ERROR: %s", err.Error())
+			t.Fail()
+			return
+		}
+		before = append(before, backup)
+	}
+
+	after := before[1:]
+
+	deletedBackups := GetDeletedBackups(before, after)
+	if len(deletedBackups) != 1 || deletedBackups[0].WorkflowId != "1570000000000001" {
+		t.Logf("ERROR: Expected only backup of workflow [1570000000000001] deleted, got %v", deletedBackups)
+		t.Fail()
+	}
+
+	if len(GetDeletedBackups(before, before)) != 0 {
+		t.Fail()
+	}
+
+	if len(GetDeletedBackups(before, []Backup{})) != 3 {
+		t.Fail()
+	}
+}
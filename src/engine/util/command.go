@@ -13,12 +13,18 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
 func ExecuteCommand(args ...string) (result Result) {
+	return ExecuteCommandContext(context.Background(), args...)
+}
+
+// ExecuteCommandContext executes a command that is killed when ctx is cancelled
+func ExecuteCommandContext(ctx context.Context, args ...string) (result Result) {
 	baseCmd := args[0]
 	cmdArgs := args[1:]
 
@@ -27,7 +33,7 @@ func ExecuteCommand(args ...string) (result Result) {
 	message := SetMessage("CMD", s0)
 	messages = append(messages, message)
 
-	cmd := exec.Command(baseCmd, cmdArgs...)
+	cmd := exec.CommandContext(ctx, baseCmd, cmdArgs...)
 
 	stdoutStderrBytes, err := cmd.CombinedOutput()
 	var resultCode int
@@ -59,3 +65,8 @@ func ExecuteCommand(args ...string) (result Result) {
 
 	return result
 }
+
+// ShellQuote quotes a value so it is passed as a single word to sh -c
+func ShellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -13,9 +13,11 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"log"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestExecuteCommand(t *testing.T) {
@@ -30,3 +32,31 @@ func TestExecuteCommand(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestExecuteCommandContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := ExecuteCommandContext(ctx, "sleep", "10")
+	if result.Code == 0 || time.Since(start) > 5*time.Second {
+		t.Logf("ERROR: expected cancelled command to be killed, got %v", result)
+		t.Fail()
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	result := ExecuteCommand("/bin/sh", "-c", "echo "+ShellQuote("it's $(id);"))
+
+	var isQuoted bool
+	for _, message := range result.Messages {
+		if message.Level == "INFO" && strings.TrimSpace(message.Message) == "it's $(id);" {
+			isQuoted = true
+		}
+	}
+
+	if result.Code != 0 || !isQuoted {
+		t.Logf("ERROR: Quoted value was not passed as is, got %v", result)
+		t.Fail()
+	}
+}
@@ -15,6 +15,7 @@ package util
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/BurntSushi/toml"
 	"io/ioutil"
@@ -24,39 +25,60 @@ import (
 )
 
 type Config struct {
-	ProfileName              string             `json:"profileName,omitempty"`
-	ConfigName               string             `json:"configName,omitempty"`
-	WorkflowId               string             `json:"workflowId,omitempty"`
-	WorkflowTimestamp        int64              `json:"workflowTimestamp,omitempty"`
-	AppPlugin                string             `json:"appPlugin"`
-	StoragePlugin            string             `json:"storagePlugin"`
-	ArchivePlugin            string             `json:"archivePlugin"`
-	AutoDiscovery            bool               `json:"autoDiscovery"`
-	JobRetention             int                `json:"jobRetention"`
-	BackupRetentions         []BackupRetention  `json:"backupRetentions"`
-	ArchiveRetentions        []ArchiveRetention `json:"archiveRetentions"`
-	SelectedBackupPolicy     string             `json:"backupPolicy,omitmepty"`
-	SelectedBackupRetention  int                `json:"backupRetention,omitmepty"`
-	SelectedArchiveRetention int                `json:"archiveRetention,omitmepty"`
-	SelectedWorkflowId       int                `json:"selectedWorkflowId,omitmepty"`
-	PreAppQuiesceCmd         string             `json:"preAppQuiesceCmd,omitempty"`
-	AppQuiesceCmd            string             `json:"appQuiesceCmd,omitempty"`
-	PostAppQuiesceCmd        string             `json:"postAppQuiesceCmd,omitempty"`
-	BackupCreateCmd          string             `json:"backupCreateCmd,omitempty"`
-	BackupDeleteCmd          string             `json:"backupDeleteCmd,omitempty"`
-	ArchiveCreateCmd         string             `json:"archiveCreateCmd,omitempty"`
-	ArchiveDeleteCmd         string             `json:"archiveDeleteCmd,omitempty"`
-	PreAppUnquiesceCmd       string             `json:"preAppUnquiesceCmd,omitempty"`
-	AppUnquiesceCmd          string             `json:"appUnquiesceCmd,omitempty"`
-	PostAppUnquiesceCmd      string             `json:"postAppUnquiesceCmd,omitempty"`
-	PreAppRestoreCmd         string             `json:"preAppRestoreCmd,omitempty"`
-	RestoreCmd               string             `json:"restoreCmd,omitempty"`
-	PostAppRestoreCmd        string             `json:"postAppRestoreCmd,omitempty"`
-	SendTrapErrorCmd         string             `json:"sendTrapErrorCmd,omitempty"`
-	SendTrapSuccessCmd       string             `json:"sendTrapSuccessCmd,omitempty"`
-	AppPluginParameters      map[string]string  `json:"appPluginParameters,omitempty"`
-	StoragePluginParameters  map[string]string  `json:"storagePluginParameters,omitempty"`
-	ArchivePluginParameters  map[string]string  `json:"archivePluginParameters,omitempty"`
+	// Context is the request context of the app or storage service, it is cancelled when the workflow
+	// is cancelled and stops plugin processes and pod commands
+	Context                   context.Context          `json:"-" toml:"-"`
+	ProfileName               string                   `json:"profileName,omitempty"`
+	ConfigName                string                   `json:"configName,omitempty"`
+	WorkflowId                string                   `json:"workflowId,omitempty"`
+	WorkflowTimestamp         int64                    `json:"workflowTimestamp,omitempty"`
+	AppPlugin                 string                   `json:"appPlugin"`
+	StoragePlugin             string                   `json:"storagePlugin"`
+	ArchivePlugin             string                   `json:"archivePlugin"`
+	AutoDiscovery             bool                     `json:"autoDiscovery"`
+	JobRetention              int                      `json:"jobRetention"`
+	BackupRetentions          []BackupRetention        `json:"backupRetentions"`
+	ArchiveRetentions         []ArchiveRetention       `json:"archiveRetentions"`
+	SelectedBackupPolicy      string                   `json:"backupPolicy,omitmepty"`
+	SelectedBackupRetention   int                      `json:"backupRetention,omitmepty"`
+	SelectedArchiveRetention  int                      `json:"archiveRetention,omitmepty"`
+	SelectedWorkflowId        int64                    `json:"selectedWorkflowId,omitmepty"`
+	PreAppQuiesceCmd          string                   `json:"preAppQuiesceCmd,omitempty"`
+	AppQuiesceCmd             string                   `json:"appQuiesceCmd,omitempty"`
+	PostAppQuiesceCmd         string                   `json:"postAppQuiesceCmd,omitempty"`
+	BackupCreateCmd           string                   `json:"backupCreateCmd,omitempty"`
+	BackupDeleteCmd           string                   `json:"backupDeleteCmd,omitempty"`
+	ArchiveCreateCmd          string                   `json:"archiveCreateCmd,omitempty"`
+	ArchiveDeleteCmd          string                   `json:"archiveDeleteCmd,omitempty"`
+	PreAppUnquiesceCmd        string                   `json:"preAppUnquiesceCmd,omitempty"`
+	AppUnquiesceCmd           string                   `json:"appUnquiesceCmd,omitempty"`
+	PostAppUnquiesceCmd       string                   `json:"postAppUnquiesceCmd,omitempty"`
+	PreAppRestoreCmd          string                   `json:"preAppRestoreCmd,omitempty"`
+	RestoreCmd                string                   `json:"restoreCmd,omitempty"`
+	PostAppRestoreCmd         string                   `json:"postAppRestoreCmd,omitempty"`
+	SendTrapErrorCmd          string                   `json:"sendTrapErrorCmd,omitempty"`
+	SendTrapSuccessCmd        string                   `json:"sendTrapSuccessCmd,omitempty"`
+	Notifiers                 []Notifier               `json:"notifiers,omitempty"`
+	WorkflowCmd               string                   `json:"workflowCmd,omitempty"`
+	BackupWorkflow            []WorkflowStepDefinition `json:"backupWorkflow,omitempty"`
+	RestoreWorkflow           []WorkflowStepDefinition `json:"restoreWorkflow,omitempty"`
+	RestoreTarget             RestoreTarget            `json:"restoreTarget,omitempty"`
+	RestorePaths              []string                 `json:"restorePaths,omitempty"`
+	VerifyBackupBeforeRestore bool                     `json:"verifyBackupBeforeRestore,omitempty"`
+	BackupCompression         string                   `json:"backupCompression,omitempty"`
+	BackupEncryptionKeyFile   string                   `json:"backupEncryptionKeyFile,omitempty"`
+	VerifyWorkflow            []WorkflowStepDefinition `json:"verifyWorkflow,omitempty"`
+	VerifyTarget              RestoreTarget            `json:"verifyTarget,omitempty"`
+	VerifyCheckCmd            string                   `json:"verifyCheckCmd,omitempty"`
+	VerifyTeardownCmd         string                   `json:"verifyTeardownCmd,omitempty"`
+	StepPolicies              []StepPolicy             `json:"stepPolicies,omitempty"`
+	MaxQuiesceTime            int                      `json:"maxQuiesceTime,omitempty"`
+	MaxQueuedWorkflows        int                      `json:"maxQueuedWorkflows,omitempty"`
+	CoalesceQueuedWorkflows   bool                     `json:"coalesceQueuedWorkflows,omitempty"`
+	StorageHost               string                   `json:"storageHost,omitempty"`
+	AppPluginParameters       map[string]string        `json:"appPluginParameters,omitempty"`
+	StoragePluginParameters   map[string]string        `json:"storagePluginParameters,omitempty"`
+	ArchivePluginParameters   map[string]string        `json:"archivePluginParameters,omitempty"`
 }
 
 type ConfigResult struct {
@@ -70,13 +92,23 @@ type ConfigMapResult struct {
 }
 
 type BackupRetention struct {
-	Policy          string `json:"policy"`
-	RetentionNumber int    `json:"retentionNumber"`
+	Policy            string `json:"policy"`
+	RetentionNumber   int    `json:"retentionNumber"`
+	KeepAllHours      int    `json:"keepAllHours,omitempty"`
+	KeepDailyDays     int    `json:"keepDailyDays,omitempty"`
+	KeepWeeklyWeeks   int    `json:"keepWeeklyWeeks,omitempty"`
+	KeepMonthlyMonths int    `json:"keepMonthlyMonths,omitempty"`
+	MinAgeHours       int    `json:"minAgeHours,omitempty"`
 }
 
 type ArchiveRetention struct {
-	Policy          string `json:"policy"`
-	RetentionNumber int    `json:"retentionNumber"`
+	Policy            string `json:"policy"`
+	RetentionNumber   int    `json:"retentionNumber"`
+	KeepAllHours      int    `json:"keepAllHours,omitempty"`
+	KeepDailyDays     int    `json:"keepDailyDays,omitempty"`
+	KeepWeeklyWeeks   int    `json:"keepWeeklyWeeks,omitempty"`
+	KeepMonthlyMonths int    `json:"keepMonthlyMonths,omitempty"`
+	MinAgeHours       int    `json:"minAgeHours,omitempty"`
 }
 
 type PluginConfigMap struct {
@@ -240,10 +272,20 @@ func GetConfig(w http.ResponseWriter, r *http.Request) (Config, error) {
 	if err != nil {
 		return config, err
 	}
+	config.Context = r.Context()
 
 	return config, nil
 }
 
+// GetContext returns the request context of the config, configs not read from a request are never cancelled
+func (c Config) GetContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+
+	return c.Context
+}
+
 func GetPluginConfig(w http.ResponseWriter, r *http.Request) (map[string]string, error) {
 
 	var configMap map[string]string
@@ -14,6 +14,7 @@ package util
 
 import (
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -65,36 +66,101 @@ func GetBackupName(name, policy, workflowId, timestamp string) string {
 
 func GetRestoreSrcPath(config Config) (string, error) {
 	backupPath := config.StoragePluginParameters["BackupDestPath"] + "/" + config.ProfileName + "/" + config.ConfigName
-	backupNameSubString := config.StoragePluginParameters["BackupName"] + "_" + config.SelectedBackupPolicy + "_" + IntToString(config.SelectedWorkflowId)
 
-	fmt.Println("[DEBUG] restore path [" + backupPath + "] search string [" + backupNameSubString + "]")
+	return findBackupPath(backupPath, config.StoragePluginParameters["BackupName"], config.SelectedBackupPolicy, Int64ToString(config.SelectedWorkflowId))
+}
+
+func GetRestoreSrcPathFromMap(configMap map[string]string) (string, error) {
+	backupPath := configMap["BackupDestPath"] + "/" + configMap["ProfileName"] + "/" + configMap["ConfigName"]
+
+	return findBackupPath(backupPath, configMap["BackupName"], configMap["BackupPolicy"], configMap["SelectedWorkflowId"])
+}
+
+// GetRestoreDestPath returns where the storage plugin copies restored data, RestoreDestPath or /tmp/<workflowId>
+func GetRestoreDestPath(config Config) string {
+	if config.StoragePluginParameters["RestoreDestPath"] != "" {
+		return config.StoragePluginParameters["RestoreDestPath"]
+	}
+
+	return "/tmp/" + Int64ToString(config.SelectedWorkflowId)
+}
+
+func GetRestoreDestPathFromMap(configMap map[string]string) string {
+	if configMap["RestoreDestPath"] != "" {
+		return configMap["RestoreDestPath"]
+	}
+
+	return "/tmp/" + configMap["SelectedWorkflowId"]
+}
+
+// findBackupPath returns the backup matching name, policy and workflow id exactly, an empty path means no backup was found
+func findBackupPath(backupPath, name, policy, workflowId string) (string, error) {
+	GetServiceLogger().Debug("Restore path [" + backupPath + "] search name [" + name + "] policy [" + policy + "] workflow id [" + workflowId + "]")
 	files, err := ioutil.ReadDir(backupPath)
 	if err != nil {
 		return "", err
 	}
 	for _, f := range files {
-		if strings.Contains(f.Name(), backupNameSubString) {
+		if isBackupName(f.Name(), name, policy, workflowId) {
 			return backupPath + "/" + f.Name(), nil
 		}
 	}
 	return "", nil
 }
 
-func GetRestoreSrcPathFromMap(configMap map[string]string) (string, error) {
-	backupPath := configMap["BackupDestPath"] + "/" + configMap["ProfileName"] + "/" + configMap["ConfigName"]
-	backupNameSubString := configMap["BackupName"] + "_" + configMap["BackupPolicy"] + "_" + configMap["SelectedWorkflowId"]
+// GetRestoredBackupDir returns the directory a storage plugin restored the selected backup to within the
+// restore destination, fileNames is the listing of the restore destination
+func GetRestoredBackupDir(config Config, fileNames []string) (string, error) {
+	workflowId := Int64ToString(config.SelectedWorkflowId)
+	for _, fileName := range fileNames {
+		if isBackupName(fileName, config.StoragePluginParameters["BackupName"], config.SelectedBackupPolicy, workflowId) {
+			return fileName, nil
+		}
+	}
 
-	fmt.Println("[DEBUG] restore path [" + backupPath + "] search string [" + backupNameSubString + "]")
-	files, err := ioutil.ReadDir(backupPath)
+	return "", errors.New("Restored backup for workflow id [" + workflowId + "] policy [" + config.SelectedBackupPolicy + "] not found in restore path [" + GetRestoreDestPath(config) + "]")
+}
+
+func isBackupName(fileName, name, policy, workflowId string) bool {
+	backup, err := ParseBackupName(fileName)
 	if err != nil {
-		return "", err
+		return false
 	}
-	for _, f := range files {
-		if strings.Contains(f.Name(), backupNameSubString) {
-			return backupPath + "/" + f.Name(), nil
-		}
+
+	return backup.Name == name && backup.Policy == policy && backup.WorkflowId == workflowId
+}
+
+// ParseBackupName parses a backup or archive name of format name_policy_workflowId_epoch. The name itself may
+// contain underscores so the name is split from the right. Both legacy and time based workflow ids are numeric.
+func ParseBackupName(backupName string) (Backup, error) {
+	var backup Backup
+
+	parts := strings.Split(backupName, "_")
+	if len(parts) < 4 {
+		return backup, errors.New("Backup name [" + backupName + "] is not of format name_policy_workflowId_epoch")
 	}
-	return "", nil
+
+	count := len(parts)
+	backup.Name = strings.Join(parts[:count-3], "_")
+	backup.Policy = parts[count-3]
+	backup.WorkflowId = parts[count-2]
+
+	if backup.Name == "" || backup.Policy == "" {
+		return backup, errors.New("Backup name [" + backupName + "] is missing name or policy")
+	}
+
+	if _, err := strconv.ParseInt(backup.WorkflowId, 10, 64); err != nil {
+		return backup, errors.New("Backup name [" + backupName + "] has invalid workflow id [" + backup.WorkflowId + "]")
+	}
+
+	epoch, err := strconv.Atoi(parts[count-1])
+	if err != nil {
+		return backup, errors.New("Backup name [" + backupName + "] has invalid epoch [" + parts[count-1] + "]")
+	}
+	backup.Epoch = epoch
+	backup.Timestamp = ConvertEpoch(parts[count-1])
+
+	return backup, nil
 }
 
 func ConvertEpoch(epoch string) string {
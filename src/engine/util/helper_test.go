@@ -13,6 +13,8 @@ limitations under the License.
 package util
 
 import (
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 )
@@ -35,6 +37,61 @@ func TestGetBackupPath(t *testing.T) {
 	}
 }
 
+func TestParseBackupName(t *testing.T) {
+	backup, err := ParseBackupName("my_backup_daily_1571234567123456_1571234567")
+	if err != nil {
+		t.Fail()
+	}
+
+	if backup.Name != "my_backup" || backup.Policy != "daily" || backup.WorkflowId != "1571234567123456" || backup.Epoch != 1571234567 {
+		t.Fail()
+	}
+
+	legacyBackup, err := ParseBackupName("mybackup_weekly_777_1561234567")
+	if err != nil {
+		t.Fail()
+	}
+
+	if legacyBackup.Name != "mybackup" || legacyBackup.Policy != "weekly" || legacyBackup.WorkflowId != "777" {
+		t.Fail()
+	}
+
+	_, err = ParseBackupName("mybackup_daily_777_notanepoch")
+	if err == nil {
+		t.Fail()
+	}
+
+	_, err = ParseBackupName("mybackup_777_1561234567")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestGetRestoreSrcPathFromMap(t *testing.T) {
+	backupDestPath, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(backupDestPath)
+
+	configMap := getConfigMap()
+	configMap["BackupDestPath"] = backupDestPath
+	configMap["SelectedWorkflowId"] = "77"
+
+	backupDir := GetBackupDirFromMap(configMap)
+	CreateDir(backupDir+"/mybackup_daily_777_1561234567", 0755)
+	CreateDir(backupDir+"/mybackup_daily_77_1561234568", 0755)
+
+	restorePath, err := GetRestoreSrcPathFromMap(configMap)
+	if err != nil {
+		t.Fail()
+	}
+
+	if restorePath != backupDir+"/mybackup_daily_77_1561234568" {
+		t.Fail()
+	}
+}
+
 func getConfigMap() map[string]string {
 	configMap := make(map[string]string)
 
@@ -48,6 +105,46 @@ func getConfigMap() map[string]string {
 	return configMap
 }
 
+func TestGetRestoreDestPath(t *testing.T) {
+	var config Config
+	config.SelectedWorkflowId = 1234
+	config.StoragePluginParameters = map[string]string{}
+
+	if GetRestoreDestPath(config) != "/tmp/1234" {
+		t.Fail()
+	}
+
+	config.StoragePluginParameters["RestoreDestPath"] = "/restore"
+	if GetRestoreDestPath(config) != "/restore" {
+		t.Fail()
+	}
+
+	configMap := map[string]string{"SelectedWorkflowId": "1234"}
+	if GetRestoreDestPathFromMap(configMap) != "/tmp/1234" {
+		t.Fail()
+	}
+}
+
+func TestGetRestoredBackupDir(t *testing.T) {
+	var config Config
+	config.SelectedWorkflowId = 1234
+	config.SelectedBackupPolicy = "daily"
+	config.StoragePluginParameters = map[string]string{"BackupName": "mybackup", "RestoreDestPath": "/restore"}
+
+	fileNames := []string{"lost+found", "mybackup_daily_12345_1570000000", "mybackup_daily_1234_1560000000", "mybackup_weekly_1234_1560000000"}
+	restoreDir, err := GetRestoredBackupDir(config, fileNames)
+	if err != nil || restoreDir != "mybackup_daily_1234_1560000000" {
+		t.Logf("ERROR: Wrong restored backup dir [%s] %v", restoreDir, err)
+		t.Fail()
+	}
+
+	_, err = GetRestoredBackupDir(config, []string{})
+	if err == nil {
+		t.Logf("ERROR: Empty restore path should not return a restored backup dir")
+		t.Fail()
+	}
+}
+
 func TestCreateDeleteDir(t *testing.T) {
 	dir := "/tmp/foobar123"
 
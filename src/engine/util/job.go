@@ -24,11 +24,16 @@ type Jobs struct {
 }
 
 type Job struct {
-	Id        int    `json:"id,omitempty"`
+	Id        int64  `json:"id,omitempty"`
 	Status    string `json:"status,omitempty"`
 	Type      string `json:"type,omitempty"`
 	Policy    string `json:"policy"`
 	Timestamp string `json:"timestamp,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	Duration  int64  `json:"duration"`
+
+	VerifyResult string `json:"verifyResult,omitempty"`
 }
 
 func ListJobs(jobsDir string) ([]Job, error) {
@@ -43,7 +48,7 @@ func ListJobs(jobsDir string) ([]Job, error) {
 	})
 
 	for _, f := range files {
-		if !strings.Contains(f.Name(), "jobSchedule_") {
+		if f.IsDir() && !strings.Contains(f.Name(), "jobSchedule_") {
 			var job Job
 			workflowFile := jobsDir + "/" + f.Name() + "/workflow"
 
@@ -58,6 +63,10 @@ func ListJobs(jobsDir string) ([]Job, error) {
 			job.Type = workflow.Type
 			job.Policy = workflow.Policy
 			job.Timestamp = workflow.Timestamp
+			job.StartTime = workflow.StartTime
+			job.EndTime = workflow.EndTime
+			job.Duration = workflow.Duration
+			job.VerifyResult = workflow.VerifyResult
 
 			jobs = append(jobs, job)
 		}
@@ -88,7 +97,7 @@ func DeleteJobs(baseDir, profileName, configName string, jobRetention int) Resul
 
 	count := 1
 	for _, f := range files {
-		if strings.Contains(f.Name(), "jobSchedule_") {
+		if !f.IsDir() || strings.Contains(f.Name(), "jobSchedule_") {
 			continue
 		}
 
@@ -0,0 +1,220 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+type WorkflowLockResult struct {
+	Locks  []WorkflowLock `json:"locks,omitempty"`
+	Result Result         `json:"result,omitempty"`
+}
+
+type WorkflowLock struct {
+	ProfileName string `json:"profileName"`
+	ConfigName  string `json:"configName"`
+	Policy      string `json:"policy,omitempty"`
+	WorkflowId  int64  `json:"workflowId"`
+	Timestamp   int64  `json:"timestamp"`
+	Heartbeat   int64  `json:"heartbeat,omitempty"`
+}
+
+// LockManager serializes workflows per profile/config. Locks are persisted in the data dir
+// so they survive a restart. A running workflow refreshes its lock, a lock is stale if it wasn't
+// refreshed for maxAge or its owner workflow is no longer running.
+type LockManager struct {
+	mutex   sync.Mutex
+	dataDir string
+	maxAge  time.Duration
+}
+
+func NewLockManager(dataDir string, maxAge time.Duration) *LockManager {
+	return &LockManager{dataDir: dataDir, maxAge: maxAge}
+}
+
+func (lockManager *LockManager) Acquire(profileName, configName, policy string, workflowId int64) (WorkflowLock, error) {
+	lockManager.mutex.Lock()
+	defer lockManager.mutex.Unlock()
+
+	lock, err := lockManager.read(profileName, configName)
+	if err == nil && !lockManager.isStale(lock) {
+		return lock, errors.New("Another workflow id [" + Int64ToString(lock.WorkflowId) + "] is running under profile [" + profileName + "] config [" + configName + "] since [" + time.Unix(lock.Timestamp, 0).Format(time.RFC3339) + "]")
+	}
+
+	lock = WorkflowLock{}
+	lock.ProfileName = profileName
+	lock.ConfigName = configName
+	lock.Policy = policy
+	lock.WorkflowId = workflowId
+	lock.Timestamp = time.Now().Unix()
+
+	err = lockManager.write(lock)
+	if err != nil {
+		return lock, err
+	}
+
+	return lock, nil
+}
+
+// Refresh records that the owner workflow is still running, it fails if the lock is owned by another workflow
+func (lockManager *LockManager) Refresh(profileName, configName string, workflowId int64) error {
+	lockManager.mutex.Lock()
+	defer lockManager.mutex.Unlock()
+
+	lock, err := lockManager.read(profileName, configName)
+	if err != nil {
+		return err
+	}
+
+	if lock.WorkflowId != workflowId {
+		return errors.New("Lock for profile [" + profileName + "] config [" + configName + "] is owned by workflow id [" + Int64ToString(lock.WorkflowId) + "]")
+	}
+
+	lock.Heartbeat = time.Now().Unix()
+	return lockManager.write(lock)
+}
+
+// GetRefreshInterval returns how often a running workflow refreshes its lock, 0 if locks never become stale by age
+func (lockManager *LockManager) GetRefreshInterval() time.Duration {
+	return lockManager.maxAge / 4
+}
+
+// Release removes the lock only if it is owned by the given workflow
+func (lockManager *LockManager) Release(profileName, configName string, workflowId int64) error {
+	lockManager.mutex.Lock()
+	defer lockManager.mutex.Unlock()
+
+	lock, err := lockManager.read(profileName, configName)
+	if err != nil {
+		return nil
+	}
+
+	if lock.WorkflowId != workflowId {
+		return errors.New("Lock for profile [" + profileName + "] config [" + configName + "] is owned by workflow id [" + Int64ToString(lock.WorkflowId) + "]")
+	}
+
+	return lockManager.remove(profileName, configName)
+}
+
+func (lockManager *LockManager) ForceRelease(profileName, configName string) (WorkflowLock, error) {
+	lockManager.mutex.Lock()
+	defer lockManager.mutex.Unlock()
+
+	lock, err := lockManager.read(profileName, configName)
+	if err != nil {
+		return lock, errors.New("No lock exists for profile [" + profileName + "] config [" + configName + "]")
+	}
+
+	return lock, lockManager.remove(profileName, configName)
+}
+
+// List returns all locks, stale locks are expired and not returned
+func (lockManager *LockManager) List() ([]WorkflowLock, error) {
+	lockManager.mutex.Lock()
+	defer lockManager.mutex.Unlock()
+
+	var locks []WorkflowLock
+	profiles, err := DirectoryList(lockManager.dataDir)
+	if err != nil {
+		return locks, err
+	}
+
+	for _, profileName := range profiles {
+		configs, err := DirectoryList(lockManager.dataDir + "/" + profileName)
+		if err != nil {
+			return locks, err
+		}
+
+		for _, configName := range configs {
+			lock, err := lockManager.read(profileName, configName)
+			if err != nil {
+				continue
+			}
+
+			if lockManager.isStale(lock) {
+				err = lockManager.remove(profileName, configName)
+				if err != nil {
+					return locks, err
+				}
+				continue
+			}
+
+			locks = append(locks, lock)
+		}
+	}
+
+	return locks, nil
+}
+
+func (lockManager *LockManager) isStale(lock WorkflowLock) bool {
+	lastSeen := lock.Timestamp
+	if lock.Heartbeat > lastSeen {
+		lastSeen = lock.Heartbeat
+	}
+
+	if lockManager.maxAge > 0 && time.Since(time.Unix(lastSeen, 0)) > lockManager.maxAge {
+		return true
+	}
+
+	// owner workflow finished without releasing lock, a missing workflow means it is still starting
+	workflowFile := lockManager.dataDir + "/" + lock.ProfileName + "/" + lock.ConfigName + "/" + Int64ToString(lock.WorkflowId) + "/workflow"
+	workflow := &Workflow{}
+	err := ReadGob(workflowFile, &workflow)
+	if err == nil && workflow.Status != "RUNNING" {
+		return true
+	}
+
+	return false
+}
+
+func (lockManager *LockManager) getLockFile(profileName, configName string) string {
+	return lockManager.dataDir + "/" + profileName + "/" + configName + "/workflowLock"
+}
+
+func (lockManager *LockManager) read(profileName, configName string) (WorkflowLock, error) {
+	var lock WorkflowLock
+	err := ReadGob(lockManager.getLockFile(profileName, configName), &lock)
+	if err != nil {
+		return lock, err
+	}
+
+	return lock, nil
+}
+
+func (lockManager *LockManager) write(lock WorkflowLock) error {
+	err := CreateDir(lockManager.dataDir+"/"+lock.ProfileName+"/"+lock.ConfigName, 0755)
+	if err != nil {
+		return err
+	}
+
+	lockFile := lockManager.getLockFile(lock.ProfileName, lock.ConfigName)
+	err = WriteGob(lockFile+".tmp", lock)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(lockFile+".tmp", lockFile)
+}
+
+func (lockManager *LockManager) remove(profileName, configName string) error {
+	err := os.Remove(lockManager.getLockFile(profileName, configName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockManager(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dataDir)
+
+	lockManager := NewLockManager(dataDir, time.Hour)
+
+	_, err = lockManager.Acquire("default", "default", "daily", 1)
+	if err != nil {
+		t.Fail()
+	}
+
+	_, err = lockManager.Acquire("default", "default", "daily", 2)
+	if err == nil {
+		t.Fail()
+	}
+
+	locks, err := lockManager.List()
+	if err != nil || len(locks) != 1 || locks[0].WorkflowId != 1 {
+		t.Fail()
+	}
+
+	err = lockManager.Release("default", "default", 2)
+	if err == nil {
+		t.Fail()
+	}
+
+	err = lockManager.Release("default", "default", 1)
+	if err != nil {
+		t.Fail()
+	}
+
+	locks, err = lockManager.List()
+	if err != nil || len(locks) != 0 {
+		t.Fail()
+	}
+
+	// lock survives a restart
+	_, err = lockManager.Acquire("default", "default", "daily", 3)
+	if err != nil {
+		t.Fail()
+	}
+
+	lockManager = NewLockManager(dataDir, time.Hour)
+	_, err = lockManager.Acquire("default", "default", "daily", 4)
+	if err == nil {
+		t.Fail()
+	}
+
+	lock, err := lockManager.ForceRelease("default", "default")
+	if err != nil || lock.WorkflowId != 3 {
+		t.Fail()
+	}
+
+	_, err = lockManager.ForceRelease("default", "default")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestLockManagerStale(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dataDir)
+
+	lockManager := NewLockManager(dataDir, time.Hour)
+
+	_, err = lockManager.Acquire("default", "default", "daily", 1)
+	if err != nil {
+		t.Fail()
+	}
+
+	// owner workflow completed without releasing lock
+	workflow := &Workflow{}
+	workflow.Id = 1
+	SetWorkflowStatusEnd(workflow)
+	SerializeWorkflow(dataDir+"/default/default/1", workflow)
+
+	_, err = lockManager.Acquire("default", "default", "daily", 2)
+	if err != nil {
+		t.Fail()
+	}
+
+	lockManager = NewLockManager(dataDir, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	locks, err := lockManager.List()
+	if err != nil || len(locks) != 0 {
+		t.Fail()
+	}
+
+	jobs, err := ListJobs(dataDir + "/default/default")
+	if err != nil || len(jobs) != 1 {
+		t.Fail()
+	}
+}
+
+func TestLockManagerConcurrent(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dataDir)
+
+	lockManager := NewLockManager(dataDir, time.Hour)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	acquired := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			_, err := lockManager.Acquire("default", "default", "daily", id)
+			if err == nil {
+				mutex.Lock()
+				acquired++
+				mutex.Unlock()
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+
+	if acquired != 1 {
+		t.Fail()
+	}
+}
+
+func TestLockManagerRefresh(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dataDir)
+
+	lockManager := NewLockManager(dataDir, 2*time.Second)
+	lock, err := lockManager.Acquire("default", "default", "daily", 1)
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	// a workflow running longer than maxAge keeps its lock while it refreshes it
+	lock.Timestamp = time.Now().Add(-time.Hour).Unix()
+	lockManager.write(lock)
+	err = lockManager.Refresh("default", "default", 1)
+	if err != nil {
+		t.Fail()
+	}
+
+	_, err = lockManager.Acquire("default", "default", "daily", 2)
+	if err == nil {
+		t.Logf("ERROR: refreshed lock was taken by another workflow")
+		t.Fail()
+	}
+
+	if lockManager.Refresh("default", "default", 2) == nil {
+		t.Logf("ERROR: lock refreshed by workflow not owning it")
+		t.Fail()
+	}
+
+	lock, _ = lockManager.read("default", "default")
+	lock.Heartbeat = time.Now().Add(-time.Hour).Unix()
+	lockManager.write(lock)
+
+	_, err = lockManager.Acquire("default", "default", "daily", 2)
+	if err != nil {
+		t.Logf("ERROR: lock not refreshed for maxAge should be stale")
+		t.Fail()
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// RotatingFile is a log file that is rotated once it reaches its maximum size. Rotated files are named
+// <file>.1 (newest) to <file>.<maxFiles> (oldest), older files are removed.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	size     int64
+	file     *os.File
+}
+
+// NewRotatingFile opens or creates the log file, maxSize is in megabytes, defaults are 100 megabytes and 5 files
+func NewRotatingFile(path string, maxSize, maxFiles int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = 100
+	}
+
+	if maxFiles <= 0 {
+		maxFiles = 5
+	}
+
+	err := CreateDir(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &RotatingFile{path: path, maxSize: int64(maxSize) * 1024 * 1024, maxFiles: maxFiles}
+	err = r.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(r.path + "." + strconv.Itoa(r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		rotated := r.path + "." + strconv.Itoa(i)
+		if ExistsPath(rotated) {
+			if err := os.Rename(rotated, r.path+"."+strconv.Itoa(i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+
+	return r.open()
+}
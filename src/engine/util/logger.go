@@ -13,165 +13,347 @@ limitations under the License.
 package util
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// headers used to correlate app and storage service requests with the workflow step that sent them
+const WorkflowIdHeader = "X-Fossul-Workflow-Id"
+const StepIdHeader = "X-Fossul-Step-Id"
+
+var logLevels = map[string]int{"DEBUG": 0, "INFO": 1, "WARN": 2, "ERROR": 3}
+
+// LogSettings configures a service logger. Format is text or json, Level is DEBUG, INFO, WARN or ERROR. When
+// File is set logs are also written to the file, it is rotated once it reaches MaxSize megabytes and MaxFiles
+// rotated files are kept.
+type LogSettings struct {
+	Format   string
+	Level    string
+	File     string
+	MaxSize  int
+	MaxFiles int
+}
+
+// Logger is a leveled, structured logger, loggers created using With share the writer and are safe for
+// concurrent use
+type Logger struct {
+	out    *syncWriter
+	format string
+	level  int
+	fields []logField
+}
+
+type logField struct {
+	key   string
+	value string
+}
+
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.w.Write(p)
+}
+
+type loggerContextKey struct{}
+type workflowStepContextKey struct{}
+
+type workflowStep struct {
+	workflowId int64
+	stepId     int
+}
+
 var logger *log.Logger
 var once sync.Once
 
-func GetLoggerInstance() *log.Logger {
-	once.Do(func() {
-		logger = createLogger()
-	})
-	return logger
+var serviceLogger = NewLogger(os.Stdout, LogSettings{})
+var serviceLoggerMutex sync.RWMutex
+
+func NewLogger(w io.Writer, settings LogSettings) *Logger {
+	level, ok := logLevels[strings.ToUpper(settings.Level)]
+	if !ok {
+		level = logLevels["INFO"]
+	}
+
+	format := strings.ToLower(settings.Format)
+	if format != "json" {
+		format = "text"
+	}
+
+	return &Logger{out: &syncWriter{w: w}, format: format, level: level}
+}
+
+// GetLogSettings reads the log settings of a service from the <prefix>_LOG_FORMAT, _LOG_LEVEL, _LOG_FILE,
+// _LOG_MAX_SIZE and _LOG_MAX_FILES environment variables, debug sets the level to DEBUG if no level is set
+func GetLogSettings(prefix string, isDebug bool) LogSettings {
+	var settings LogSettings
+	settings.Format = os.Getenv(prefix + "_LOG_FORMAT")
+	settings.Level = os.Getenv(prefix + "_LOG_LEVEL")
+	settings.File = os.Getenv(prefix + "_LOG_FILE")
+	settings.MaxSize, _ = strconv.Atoi(os.Getenv(prefix + "_LOG_MAX_SIZE"))
+	settings.MaxFiles, _ = strconv.Atoi(os.Getenv(prefix + "_LOG_MAX_FILES"))
+
+	if settings.Level == "" && isDebug {
+		settings.Level = "DEBUG"
+	}
+
+	return settings
+}
+
+// NewServiceLogger creates the logger of a service, it writes to stdout and the log file if one is configured
+func NewServiceLogger(service string, settings LogSettings) (*Logger, error) {
+	var w io.Writer = os.Stdout
+	if settings.File != "" {
+		file, err := NewRotatingFile(settings.File, settings.MaxSize, settings.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		w = io.MultiWriter(os.Stdout, file)
+	}
+
+	return NewLogger(w, settings).With("service", service), nil
+}
+
+// SetServiceLogger sets the logger used by the service, output of the standard log package is also sent
+// through it so every line has the same format
+func SetServiceLogger(l *Logger) {
+	serviceLoggerMutex.Lock()
+	serviceLogger = l
+	serviceLoggerMutex.Unlock()
+
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{l})
+}
+
+func GetServiceLogger() *Logger {
+	serviceLoggerMutex.RLock()
+	defer serviceLoggerMutex.RUnlock()
+
+	return serviceLogger
+}
+
+// With returns a logger that adds the field to every line
+func (l *Logger) With(key, value string) *Logger {
+	fields := make([]logField, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+
+	return &Logger{out: l.out, format: l.format, level: l.level, fields: append(fields, logField{key, value})}
+}
+
+func (l *Logger) IsDebug() bool {
+	return l.level == logLevels["DEBUG"]
+}
+
+func (l *Logger) Debug(msg string) {
+	l.Log("DEBUG", msg)
+}
+
+func (l *Logger) Info(msg string) {
+	l.Log("INFO", msg)
+}
+
+func (l *Logger) Warn(msg string) {
+	l.Log("WARN", msg)
 }
 
-func createLogger() *log.Logger {
-	mw := io.MultiWriter(os.Stdout)
+func (l *Logger) Error(msg string) {
+	l.Log("ERROR", msg)
+}
+
+func (l *Logger) Log(level, msg string) {
+	if levelValue, ok := logLevels[level]; ok && levelValue < l.level {
+		return
+	}
+
+	l.out.Write(l.formatLine(time.Now(), level, msg))
+}
+
+func (l *Logger) formatLine(t time.Time, level, msg string) []byte {
+	var b strings.Builder
+	if l.format == "json" {
+		b.WriteString(`{"time":` + quoteJson(t.Format(time.RFC3339)) + `,"level":` + quoteJson(level) + `,"msg":` + quoteJson(msg))
+		for _, field := range l.fields {
+			b.WriteString("," + quoteJson(field.key) + ":" + quoteJson(field.value))
+		}
+		b.WriteString("}\n")
+
+		return []byte(b.String())
+	}
+
+	b.WriteString(t.Format(time.RFC3339) + " [" + level + "] " + msg)
+	for _, field := range l.fields {
+		b.WriteString(" " + field.key + "=" + field.value)
+	}
+	b.WriteString("\n")
+
+	return []byte(b.String())
+}
+
+func quoteJson(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// stdLogWriter logs lines of the standard log package, a leading level such as [ERROR] or ERROR sets the level
+type stdLogWriter struct {
+	logger *Logger
+}
+
+func (s stdLogWriter) Write(p []byte) (int, error) {
+	level, msg := parseLogLevel(strings.TrimRight(string(p), "\n"))
+	s.logger.Log(level, msg)
+
+	return len(p), nil
+}
+
+func parseLogLevel(line string) (string, string) {
+	for level := range logLevels {
+		for _, prefix := range []string{"[" + level + "]", level + " "} {
+			if strings.HasPrefix(line, prefix) {
+				return level, strings.TrimSpace(strings.TrimPrefix(line, prefix))
+			}
+		}
+	}
+
+	return "INFO", line
+}
 
-	return log.New(mw, "", 0)
+// WithWorkflowStep adds the workflow and step ids to ctx, requests sent with the context carry them as headers
+func WithWorkflowStep(ctx context.Context, workflowId int64, stepId int) context.Context {
+	return context.WithValue(ctx, workflowStepContextKey{}, workflowStep{workflowId, stepId})
 }
 
-func createMultiLogger() *log.Logger {
-	err := CreateDir(os.Getenv("GOBIN")+"/logs", 0755)
-	if err != nil {
-		log.Fatal(err)
+// SetWorkflowStepHeaders sets the workflow and step id headers from the request context
+func SetWorkflowStepHeaders(req *http.Request) {
+	if step, ok := req.Context().Value(workflowStepContextKey{}).(workflowStep); ok {
+		req.Header.Set(WorkflowIdHeader, Int64ToString(step.workflowId))
+		req.Header.Set(StepIdHeader, strconv.Itoa(step.stepId))
 	}
+}
+
+// RequestLogger returns the logger of an api request, it includes the workflow and step id of the request
+func RequestLogger(r *http.Request) *Logger {
+	return ContextLogger(r.Context())
+}
 
-	t := time.Now().Unix()
-	logfile := fmt.Sprintf("%s/logs/fossul_%d.conf", os.Getenv("GOBIN"), t)
-	file, err := os.OpenFile(logfile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		log.Fatal(err)
+// ContextLogger returns the logger of the api request ctx belongs to, the service logger outside of a request
+func ContextLogger(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerContextKey{}).(*Logger); ok {
+		return l
 	}
 
-	mw := io.MultiWriter(os.Stdout, file)
+	return GetServiceLogger()
+}
 
-	return log.New(mw, "", 0)
+// logPluginResult logs the messages of a plugin call with the logger of ctx so plugin output carries the
+// workflow and step id of the request, messages keep their level
+func logPluginResult(ctx context.Context, pluginType, plugin, action string, result Result) {
+	pluginLogger := ContextLogger(ctx).With("pluginType", pluginType).With("plugin", plugin).With("action", action)
+	for _, message := range result.Messages {
+		pluginLogger.Log(message.Level, message.Message)
+	}
+
+	if result.Code != 0 {
+		pluginLogger.Error("Plugin call failed with code [" + strconv.Itoa(result.Code) + "]")
+	}
+}
+
+// GetLoggerInstance returns the console logger used by the cli
+func GetLoggerInstance() *log.Logger {
+	once.Do(func() {
+		logger = log.New(os.Stdout, "", 0)
+	})
+	return logger
 }
 
 func LogMessageToConsole(l *log.Logger, message Message) {
-	//l.SetPrefix(time.Now().Format("2006-01-02 15:04:05") + " [" + message.Level + "] ")
-	l.SetPrefix(time.Now().Format(time.RFC3339) + " [" + message.Level + "] ")
-	l.Print(message.Message)
+	l.Print(time.Now().Format(time.RFC3339) + " [" + message.Level + "] " + message.Message)
 }
 
 func LogInfoMessage(l *log.Logger, message string) {
-	l.SetPrefix(time.Now().Format(time.RFC3339) + "[INFO]")
-	l.Print(message)
+	l.Print(time.Now().Format(time.RFC3339) + "[INFO]" + message)
 }
 
 func LogWarnMessage(l *log.Logger, message string) {
-	l.SetPrefix(time.Now().Format(time.RFC3339) + "[WARN]")
-	l.Print(message)
+	l.Print(time.Now().Format(time.RFC3339) + "[WARN]" + message)
 }
 
 func LogErrorMessage(l *log.Logger, message string) {
-	l.SetPrefix(time.Now().Format(time.RFC3339) + "[ERROR]")
-	l.Print(message)
+	l.Print(time.Now().Format(time.RFC3339) + "[ERROR]" + message)
 }
 
 func LogDebugMessage(l *log.Logger, message string) {
-	l.SetPrefix(time.Now().Format(time.RFC3339) + "[DEBUG]")
-	l.Print(message)
+	l.Print(time.Now().Format(time.RFC3339) + "[DEBUG]" + message)
 }
 
 func LogCmdMessage(l *log.Logger, message string) {
-	l.SetPrefix(time.Now().Format(time.RFC3339) + "[CMD]")
-	l.Print(message)
+	l.Print(time.Now().Format(time.RFC3339) + "[CMD]" + message)
 }
 
 func LogCommentMessage(l *log.Logger, message string) {
-	l.SetPrefix("########## ")
-	l.Print(message + " ##########")
+	l.Print("########## " + message + " ##########")
 }
 
 func LogResults(l *log.Logger, result []Result) {
 	for _, item := range result {
-		for _, line := range item.Messages {
-			if line.Level == "INFO" {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [INFO] ")
-				l.Print(line.Message)
-			} else if line.Level == "WARN" {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [WARN] ")
-				l.Print(line.Message)
-			} else if line.Level == "ERROR" {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [ERROR] ")
-				l.Print(line.Message)
-			} else if line.Level == "DEBUG" {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [DEBUG] ")
-				l.Print(line.Message)
-			} else if line.Level == "CMD" {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [CMD] ")
-				l.Print(line.Message)
-			} else if line.Level == "COMMENT" {
-				l.SetPrefix("########## ")
-				l.Print(line.Message + " ##########")
-			} else {
-				t := time.Unix(line.Timestamp, 0)
-				l.SetPrefix(t.String() + " [UNKNOWN] ")
-				l.Print(line.Message)
-			}
-		}
+		LogResult(l, item)
 	}
 }
 
 func LogResult(l *log.Logger, result Result) {
 	for _, line := range result.Messages {
-		if line.Level == "INFO" {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [INFO] ")
-			l.Print(line.Message)
-		} else if line.Level == "WARN" {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [WARN] ")
-			l.Print(line.Message)
-		} else if line.Level == "ERROR" {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [ERROR] ")
-			l.Print(line.Message)
-		} else if line.Level == "DEBUG" {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [DEBUG] ")
-			l.Print(line.Message)
-		} else if line.Level == "CMD" {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [CMD] ")
-			l.Print(line.Message)
-		} else if line.Level == "COMMENT" {
-			l.SetPrefix("########## ")
-			l.Print(line.Message + " ##########")
-		} else {
-			t := time.Unix(line.Timestamp, 0)
-			l.SetPrefix(t.String() + " [UNKNOWN] ")
-			l.Print(line.Message)
-		}
+		l.Print(formatResultMessage(line))
 	}
 }
 
+// formatResultMessage formats a result message for the console, comments are shown as banners
+func formatResultMessage(line Message) string {
+	if line.Level == "COMMENT" {
+		return "########## " + line.Message + " ##########"
+	}
+
+	level := line.Level
+	if !ExistsInArray([]string{"INFO", "WARN", "ERROR", "DEBUG", "CMD"}, level) {
+		level = "UNKNOWN"
+	}
+
+	t := time.Unix(line.Timestamp, 0)
+	return t.String() + " [" + level + "] " + line.Message
+}
+
+// LogApi logs each api request with its handler and duration, the request logger carries the workflow and
+// step id headers so every line logged for the request can be tied back to the workflow
 func LogApi(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
+		requestLogger := GetServiceLogger()
+		if workflowId := r.Header.Get(WorkflowIdHeader); workflowId != "" {
+			requestLogger = requestLogger.With("workflowId", workflowId)
+		}
+		if stepId := r.Header.Get(StepIdHeader); stepId != "" {
+			requestLogger = requestLogger.With("stepId", stepId)
+		}
+		if traceId := GetTraceId(r.Context()); traceId != "" {
+			requestLogger = requestLogger.With("traceId", traceId)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), loggerContextKey{}, requestLogger))
+
 		inner.ServeHTTP(w, r)
 
-		log.Printf(
-			"%s\t%s\t%s\t%s",
-			r.Method,
-			r.RequestURI,
-			name,
-			time.Since(start),
-		)
+		requestLogger.With("method", r.Method).With("uri", r.RequestURI).With("handler", name).With("duration", time.Since(start).String()).Info("Api request")
 	})
 }
@@ -0,0 +1,162 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoggerJson(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogSettings{Format: "json", Level: "WARN"}).With("service", "app")
+
+	logger.Info("not logged")
+	logger.With("workflowId", "1").Error("plugin \"failed\"")
+
+	var line map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fail()
+		return
+	}
+
+	if line["level"] != "ERROR" || line["msg"] != "plugin \"failed\"" || line["service"] != "app" || line["workflowId"] != "1" {
+		t.Fail()
+	}
+}
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, LogSettings{})
+	stepLogger := logger.With("stepId", "3")
+
+	logger.Debug("not logged")
+	logger.Info("first")
+	stepLogger.Warn("second")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "[INFO] first") || !strings.HasSuffix(lines[1], "[WARN] second stepId=3") {
+		t.Fail()
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	level, msg := parseLogLevel("[ERROR] Couldn't read workflow")
+	if level != "ERROR" || msg != "Couldn't read workflow" {
+		t.Fail()
+	}
+
+	level, msg = parseLogLevel("Starting server service")
+	if level != "INFO" || msg != "Starting server service" {
+		t.Fail()
+	}
+}
+
+func TestLogApiWorkflowStep(t *testing.T) {
+	var buf bytes.Buffer
+	SetServiceLogger(NewLogger(&buf, LogSettings{Format: "json"}))
+	defer SetServiceLogger(NewLogger(os.Stdout, LogSettings{}))
+
+	handler := LogApi(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RequestLogger(r).Info("handler")
+	}), "Backup")
+
+	req := httptest.NewRequest("POST", "/backup", nil)
+	req = req.WithContext(WithWorkflowStep(context.Background(), 1561234568, 4))
+	SetWorkflowStepHeaders(req)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal(buf.String())
+	}
+
+	for _, line := range lines {
+		if !strings.Contains(line, `"workflowId":"1561234568","stepId":"4"`) {
+			t.Fail()
+		}
+	}
+}
+
+func TestLogPluginResultWorkflowStep(t *testing.T) {
+	var buf bytes.Buffer
+	SetServiceLogger(NewLogger(&buf, LogSettings{Format: "json"}))
+	defer SetServiceLogger(NewLogger(os.Stdout, LogSettings{}))
+
+	handler := LogApi(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := SetResult(1, []Message{SetMessage("CMD", "Executing plugin"), SetMessage("ERROR", "Backup failed")})
+		logPluginResult(r.Context(), "storage", "sample-storage", "backup", result)
+	}), "Backup")
+
+	req := httptest.NewRequest("POST", "/backup", nil)
+	req = req.WithContext(WithWorkflowStep(context.Background(), 1561234568, 4))
+	SetWorkflowStepHeaders(req)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Logf("ERROR: expected 4 log lines got %s", buf.String())
+		t.Fail()
+		return
+	}
+
+	for _, line := range lines[:3] {
+		if !strings.Contains(line, `"workflowId":"1561234568","stepId":"4"`) || !strings.Contains(line, `"plugin":"sample-storage","action":"backup"`) {
+			t.Logf("ERROR: plugin log line without workflow step %s", line)
+			t.Fail()
+		}
+	}
+
+	if !strings.Contains(lines[1], `"level":"ERROR","msg":"Backup failed"`) {
+		t.Logf("ERROR: plugin message level not kept %s", lines[1])
+		t.Fail()
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dir)
+
+	file, err := NewRotatingFile(dir+"/logs/server.log", 1, 2)
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer file.Close()
+	file.maxSize = 10
+
+	for _, line := range []string{"line one\n", "line two\n", "line three\n", "line four\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fail()
+			return
+		}
+	}
+
+	current, _ := ioutil.ReadFile(dir + "/logs/server.log")
+	first, _ := ioutil.ReadFile(dir + "/logs/server.log.1")
+	second, _ := ioutil.ReadFile(dir + "/logs/server.log.2")
+
+	if string(current) != "line four\n" || string(first) != "line three\n" || string(second) != "line two\n" || ExistsPath(dir+"/logs/server.log.3") {
+		t.Fail()
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// manifests are kept next to the backups rather than inside them so a restore doesn't copy them to the application
+const backupManifestDir = ".manifests"
+
+// BackupManifest records what a storage plugin wrote to a backup so the backup can be verified later,
+// checksums of a sealed backup are of the compressed and encrypted files
+type BackupManifest struct {
+	BackupName    string         `json:"backupName"`
+	ProfileName   string         `json:"profileName"`
+	ConfigName    string         `json:"configName"`
+	Policy        string         `json:"policy"`
+	WorkflowId    string         `json:"workflowId"`
+	Plugin        string         `json:"plugin"`
+	PluginVersion string         `json:"pluginVersion"`
+	Namespace     string         `json:"namespace,omitempty"`
+	Pod           string         `json:"pod,omitempty"`
+	SourcePaths   []string       `json:"sourcePaths,omitempty"`
+	Timestamp     int64          `json:"timestamp"`
+	Compression   string         `json:"compression,omitempty"`
+	Encryption    string         `json:"encryption,omitempty"`
+	KeyId         string         `json:"keyId,omitempty"`
+	TotalSize     int64          `json:"totalSize"`
+	Files         []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// BackupVerification is the outcome of comparing a backup with its manifest
+type BackupVerification struct {
+	BackupName    string   `json:"backupName"`
+	IsManifest    bool     `json:"isManifest"`
+	VerifiedFiles int      `json:"verifiedFiles"`
+	MissingFiles  []string `json:"missingFiles,omitempty"`
+	CorruptFiles  []string `json:"corruptFiles,omitempty"`
+	ExtraFiles    []string `json:"extraFiles,omitempty"`
+}
+
+type BackupVerifyResult struct {
+	Verification BackupVerification `json:"verification,omitempty"`
+	Result       Result             `json:"result,omitempty"`
+}
+
+// IsValid is true if the backup has a manifest and every file in it is present and unchanged
+func (v BackupVerification) IsValid() bool {
+	return v.IsManifest && len(v.MissingFiles) == 0 && len(v.CorruptFiles) == 0
+}
+
+func GetBackupManifestPath(backupPath string) string {
+	return filepath.Join(filepath.Dir(backupPath), backupManifestDir, filepath.Base(backupPath)+".json")
+}
+
+// SetBackupManifest returns a manifest describing the backup of the config by the given plugin
+func SetBackupManifest(config Config, plugin Plugin, pod string, sourcePaths []string) BackupManifest {
+	var manifest BackupManifest
+	manifest.ProfileName = config.ProfileName
+	manifest.ConfigName = config.ConfigName
+	manifest.Policy = config.SelectedBackupPolicy
+	manifest.WorkflowId = config.WorkflowId
+	manifest.Plugin = plugin.Name
+	manifest.PluginVersion = plugin.Version
+	manifest.Namespace = config.StoragePluginParameters["Namespace"]
+	manifest.Pod = pod
+	manifest.SourcePaths = sourcePaths
+
+	return manifest
+}
+
+func SetBackupManifestFromMap(configMap map[string]string, plugin Plugin, pod string, sourcePaths []string) BackupManifest {
+	var manifest BackupManifest
+	manifest.ProfileName = configMap["ProfileName"]
+	manifest.ConfigName = configMap["ConfigName"]
+	manifest.Policy = configMap["BackupPolicy"]
+	manifest.WorkflowId = configMap["WorkflowId"]
+	manifest.Plugin = plugin.Name
+	manifest.PluginVersion = plugin.Version
+	manifest.Namespace = configMap["Namespace"]
+	manifest.Pod = pod
+	manifest.SourcePaths = sourcePaths
+
+	return manifest
+}
+
+// SetBackupManifestEncoding records the compression, encryption and key id of a sealed backup
+func SetBackupManifestEncoding(manifest BackupManifest, encoding BackupEncoding) BackupManifest {
+	manifest.Compression = encoding.Compression
+	manifest.Encryption = encoding.Encryption
+	manifest.KeyId = encoding.KeyId
+
+	return manifest
+}
+
+// WriteBackupManifest hashes every file of the backup and writes the manifest
+func WriteBackupManifest(backupPath string, manifest BackupManifest) (BackupManifest, error) {
+	return WriteStagedBackupManifest(backupPath, backupPath, manifest)
+}
+
+// WriteStagedBackupManifest hashes every file of a backup still in its stage dir and writes the manifest of
+// the backup path the stage dir is renamed to, so a backup is never in place without its manifest
+func WriteStagedBackupManifest(stagePath, backupPath string, manifest BackupManifest) (BackupManifest, error) {
+	files, err := hashBackupFiles(stagePath)
+	if err != nil {
+		return manifest, err
+	}
+
+	manifest.BackupName = filepath.Base(backupPath)
+	manifest.Timestamp = time.Now().Unix()
+	manifest.Files = files
+	manifest.TotalSize = 0
+	for _, file := range files {
+		manifest.TotalSize = manifest.TotalSize + file.Size
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	manifestPath := GetBackupManifestPath(backupPath)
+	err = CreateDir(filepath.Dir(manifestPath), 0755)
+	if err != nil {
+		return manifest, err
+	}
+
+	return manifest, ioutil.WriteFile(manifestPath, data, 0644)
+}
+
+func ReadBackupManifest(backupPath string) (BackupManifest, error) {
+	var manifest BackupManifest
+
+	data, err := ioutil.ReadFile(GetBackupManifestPath(backupPath))
+	if err != nil {
+		return manifest, err
+	}
+
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// GetBackupEncoding returns how a backup was sealed according to its manifest, a backup without manifest
+// has no encoding
+func GetBackupEncoding(backupPath string) (BackupEncoding, error) {
+	var encoding BackupEncoding
+
+	manifest, err := ReadBackupManifest(backupPath)
+	if os.IsNotExist(err) {
+		return encoding, nil
+	} else if err != nil {
+		return encoding, err
+	}
+
+	encoding.Compression = manifest.Compression
+	encoding.Encryption = manifest.Encryption
+	encoding.KeyId = manifest.KeyId
+
+	return encoding, nil
+}
+
+// DeleteBackupManifest removes the manifest of a deleted backup, a backup without manifest is not an error
+func DeleteBackupManifest(backupPath string) error {
+	err := os.Remove(GetBackupManifestPath(backupPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// VerifyBackup re-hashes the files of a backup and compares them with the manifest. Files that are missing
+// or whose size or hash changed are reported, as are files that are not in the manifest.
+func VerifyBackup(backupPath string) (BackupVerification, error) {
+	var verification BackupVerification
+	verification.BackupName = filepath.Base(backupPath)
+
+	manifest, err := ReadBackupManifest(backupPath)
+	if os.IsNotExist(err) {
+		return verification, nil
+	} else if err != nil {
+		return verification, err
+	}
+	verification.IsManifest = true
+
+	files, err := hashBackupFiles(backupPath)
+	if err != nil {
+		return verification, err
+	}
+
+	current := map[string]ManifestFile{}
+	for _, file := range files {
+		current[file.Path] = file
+	}
+
+	for _, expected := range manifest.Files {
+		file, ok := current[expected.Path]
+		if !ok {
+			verification.MissingFiles = append(verification.MissingFiles, expected.Path)
+			continue
+		}
+		delete(current, expected.Path)
+
+		if file.Size != expected.Size || file.Sha256 != expected.Sha256 {
+			verification.CorruptFiles = append(verification.CorruptFiles, expected.Path)
+			continue
+		}
+		verification.VerifiedFiles++
+	}
+
+	for path := range current {
+		verification.ExtraFiles = append(verification.ExtraFiles, path)
+	}
+	sort.Strings(verification.ExtraFiles)
+
+	return verification, nil
+}
+
+// GetBackupVerifyMessages describes a verification, every missing or corrupt file is reported as an error
+func GetBackupVerifyMessages(verification BackupVerification) []Message {
+	var messages []Message
+	if !verification.IsManifest {
+		return append(messages, SetMessage("WARN", "Backup ["+verification.BackupName+"] has no manifest, it can't be verified"))
+	}
+
+	for _, path := range verification.MissingFiles {
+		messages = append(messages, SetMessage("ERROR", "Backup file ["+path+"] is missing"))
+	}
+	for _, path := range verification.CorruptFiles {
+		messages = append(messages, SetMessage("ERROR", "Backup file ["+path+"] is corrupt, size or checksum changed"))
+	}
+	for _, path := range verification.ExtraFiles {
+		messages = append(messages, SetMessage("WARN", "Backup file ["+path+"] is not in the manifest"))
+	}
+
+	if verification.IsValid() {
+		messages = append(messages, SetMessage("INFO", "Backup ["+verification.BackupName+"] verified, ["+IntToString(verification.VerifiedFiles)+"] files match the manifest"))
+	} else {
+		messages = append(messages, SetMessage("ERROR", "Backup ["+verification.BackupName+"] verification failed, ["+IntToString(len(verification.MissingFiles))+"] missing and ["+IntToString(len(verification.CorruptFiles))+"] corrupt files"))
+	}
+
+	return messages
+}
+
+func hashBackupFiles(backupPath string) ([]ManifestFile, error) {
+	var files []ManifestFile
+
+	if !ExistsPath(backupPath) {
+		return files, errors.New("Backup [" + backupPath + "] does not exist")
+	}
+
+	err := filepath.Walk(backupPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(backupPath, path)
+		if err != nil {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, ManifestFile{Path: relPath, Size: info.Size(), Sha256: hash})
+		return nil
+	})
+
+	return files, err
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
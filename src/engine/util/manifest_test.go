@@ -0,0 +1,127 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/mariadb_daily_1_1558650000"
+	_ = os.MkdirAll(backupPath+"/data", 0755)
+	_ = ioutil.WriteFile(backupPath+"/data/ibdata1", []byte("ibdata"), 0644)
+	_ = ioutil.WriteFile(backupPath+"/data/redo.log", []byte("redo"), 0644)
+
+	verification, err := VerifyBackup(backupPath)
+	if err != nil || verification.IsManifest || verification.IsValid() {
+		t.Fail()
+	}
+
+	config := Config{ProfileName: "mariadb", ConfigName: "mariadb", SelectedBackupPolicy: "daily", WorkflowId: "1"}
+	manifest := SetBackupManifest(config, Plugin{Name: "container-basic", Version: "1.0.0"}, "mariadb-1", []string{"/var/lib/mysql"})
+	manifest, err = WriteBackupManifest(backupPath, manifest)
+	if err != nil || len(manifest.Files) != 2 || manifest.TotalSize != 10 {
+		t.Fail()
+		return
+	}
+
+	// manifest is kept outside of the backup
+	files, _ := ListBackupFiles(backupPath)
+	if len(files) != 3 || !ExistsPath(dir+"/.manifests/mariadb_daily_1_1558650000.json") {
+		t.Fail()
+	}
+
+	manifest, err = ReadBackupManifest(backupPath)
+	if err != nil || manifest.Pod != "mariadb-1" || manifest.Files[0].Path != "data/ibdata1" || len(manifest.Files[0].Sha256) != 64 {
+		t.Fail()
+	}
+
+	verification, err = VerifyBackup(backupPath)
+	if err != nil || !verification.IsValid() || verification.VerifiedFiles != 2 {
+		t.Fail()
+	}
+
+	_ = ioutil.WriteFile(backupPath+"/data/ibdata1", []byte("IBDATA"), 0644)
+	_ = os.Remove(backupPath + "/data/redo.log")
+	_ = ioutil.WriteFile(backupPath+"/data/new.log", []byte("new"), 0644)
+
+	verification, err = VerifyBackup(backupPath)
+	if err != nil || verification.IsValid() {
+		t.Fail()
+	}
+
+	if len(verification.CorruptFiles) != 1 || verification.CorruptFiles[0] != "data/ibdata1" {
+		t.Fail()
+	}
+
+	if len(verification.MissingFiles) != 1 || verification.MissingFiles[0] != "data/redo.log" {
+		t.Fail()
+	}
+
+	if len(verification.ExtraFiles) != 1 || verification.ExtraFiles[0] != "data/new.log" {
+		t.Fail()
+	}
+
+	if err := DeleteBackupManifest(backupPath); err != nil || ExistsPath(GetBackupManifestPath(backupPath)) {
+		t.Fail()
+	}
+
+	if err := DeleteBackupManifest(backupPath); err != nil {
+		t.Fail()
+	}
+}
+
+func TestWriteStagedBackupManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	backupPath := dir + "/mariadb_daily_1_1558650000"
+	stagePath := dir + "/.mariadb_daily_1_1558650000.partial"
+	_ = os.MkdirAll(stagePath+"/data", 0755)
+	_ = ioutil.WriteFile(stagePath+"/data/ibdata1", []byte("ibdata"), 0644)
+
+	config := Config{ProfileName: "mariadb", ConfigName: "mariadb", SelectedBackupPolicy: "daily", WorkflowId: "1"}
+	manifest := SetBackupManifest(config, Plugin{Name: "container-basic", Version: "1.0.0"}, "mariadb-1", []string{"/var/lib/mysql"})
+	manifest, err = WriteStagedBackupManifest(stagePath, backupPath, manifest)
+	if err != nil || len(manifest.Files) != 1 || manifest.BackupName != "mariadb_daily_1_1558650000" {
+		t.Logf("ERROR: unexpected staged manifest %v %v", manifest, err)
+		t.Fail()
+		return
+	}
+
+	// manifest is in place before the backup is
+	if ExistsPath(backupPath) || !ExistsPath(dir+"/.manifests/mariadb_daily_1_1558650000.json") {
+		t.Logf("ERROR: expected manifest of the backup path to be written from the stage dir")
+		t.Fail()
+	}
+
+	_ = os.Rename(stagePath, backupPath)
+	verification, err := VerifyBackup(backupPath)
+	if err != nil || !verification.IsManifest || !verification.IsValid() || verification.VerifiedFiles != 1 {
+		t.Logf("ERROR: backup renamed from stage dir doesn't verify %v %v", verification, err)
+		t.Fail()
+	}
+}
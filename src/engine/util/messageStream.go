@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const MessageStreamContentType = "application/x-ndjson"
+
+// MessageStreamLine is a line of a message stream. Long running calls such as backup and archive stream
+// every message of their result as it happens, the last line has the result.
+type MessageStreamLine struct {
+	Message *Message `json:"message,omitempty"`
+	Result  *Result  `json:"result,omitempty"`
+}
+
+type messageSinkKey struct{}
+
+// WithMessageSink returns a context whose messages are passed to sink as they happen
+func WithMessageSink(ctx context.Context, sink func(Message)) context.Context {
+	return context.WithValue(ctx, messageSinkKey{}, sink)
+}
+
+// SendMessage passes a message to the message sink of ctx, if there is one
+func SendMessage(ctx context.Context, message Message) {
+	if sink, ok := ctx.Value(messageSinkKey{}).(func(Message)); ok {
+		sink(message)
+	}
+}
+
+// ResultWriter writes the result of a request. If the client accepts a message stream, messages sent to the
+// context of the writer are written as they happen, followed by the result. The streamed messages must be
+// the first messages of the result, in order.
+type ResultWriter struct {
+	w        http.ResponseWriter
+	ctx      context.Context
+	mutex    sync.Mutex
+	isStream bool
+}
+
+func NewResultWriter(w http.ResponseWriter, r *http.Request) *ResultWriter {
+	rw := &ResultWriter{w: w, ctx: r.Context()}
+	if strings.Contains(r.Header.Get("Accept"), MessageStreamContentType) {
+		rw.isStream = true
+		rw.ctx = WithMessageSink(r.Context(), rw.writeMessage)
+		w.Header().Set("Content-Type", MessageStreamContentType)
+	}
+
+	return rw
+}
+
+// Context returns the request context, with a message sink if the client accepts a message stream
+func (rw *ResultWriter) Context() context.Context {
+	return rw.ctx
+}
+
+func (rw *ResultWriter) Write(result Result) {
+	if !rw.isStream {
+		json.NewEncoder(rw.w).Encode(result)
+		return
+	}
+
+	rw.writeLine(MessageStreamLine{Result: &result})
+}
+
+func (rw *ResultWriter) writeMessage(message Message) {
+	rw.writeLine(MessageStreamLine{Message: &message})
+}
+
+func (rw *ResultWriter) writeLine(line MessageStreamLine) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	json.NewEncoder(rw.w).Encode(line)
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// messageLineWriter turns plugin output into messages, every complete line is also sent to the sink of ctx
+type messageLineWriter struct {
+	ctx      context.Context
+	messages []Message
+	pending  []byte
+}
+
+func (lw *messageLineWriter) Write(p []byte) (int, error) {
+	lw.pending = append(lw.pending, p...)
+
+	for {
+		index := bytes.IndexByte(lw.pending, '\n')
+		if index < 0 {
+			break
+		}
+
+		lw.sendLine(string(lw.pending[:index]))
+		lw.pending = lw.pending[index+1:]
+	}
+
+	return len(p), nil
+}
+
+// Close sends the last line if the output didn't end with a newline
+func (lw *messageLineWriter) Close() {
+	if len(lw.pending) > 0 {
+		lw.sendLine(string(lw.pending))
+		lw.pending = nil
+	}
+}
+
+func (lw *messageLineWriter) sendLine(line string) {
+	for _, message := range SetMessages([]string{line}) {
+		lw.messages = append(lw.messages, message)
+		SendMessage(lw.ctx, message)
+	}
+}
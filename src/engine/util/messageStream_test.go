@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExecutePluginMessageSink(t *testing.T) {
+	var streamed []Message
+	ctx := WithMessageSink(context.Background(), func(message Message) {
+		streamed = append(streamed, message)
+	})
+
+	var config Config
+	result := ExecutePlugin(ctx, config, "storage", "/bin/sh", "-c", "echo INFO one; echo WARN two; printf 'ERROR three'")
+	if result.Code != 0 {
+		t.Logf("ERROR: plugin failed %v", result.Messages)
+		t.Fail()
+		return
+	}
+
+	if len(streamed) != 5 || len(result.Messages) != len(streamed) {
+		t.Logf("ERROR: expected 5 streamed messages, got %d of %d", len(streamed), len(result.Messages))
+		t.Fail()
+		return
+	}
+
+	// streamed messages are the messages of the result in order, the plugin status follows its output
+	for i, message := range streamed {
+		if message != result.Messages[i] {
+			t.Logf("ERROR: streamed message [%d] %v doesn't match result message %v", i, message, result.Messages[i])
+			t.Fail()
+		}
+	}
+
+	if streamed[1].Message != "one" || streamed[3].Level != "ERROR" || streamed[4].Level != "INFO" {
+		t.Logf("ERROR: unexpected messages %v", streamed)
+		t.Fail()
+	}
+}
+
+func TestResultWriterStream(t *testing.T) {
+	r := httptest.NewRequest("POST", "/backup", nil)
+	r.Header.Set("Accept", MessageStreamContentType)
+	w := httptest.NewRecorder()
+
+	resultWriter := NewResultWriter(w, r)
+	message := SetMessage("INFO", "copying")
+	SendMessage(resultWriter.Context(), message)
+	resultWriter.Write(SetResult(0, []Message{message, SetMessage("INFO", "done")}))
+
+	if w.Header().Get("Content-Type") != MessageStreamContentType {
+		t.Logf("ERROR: unexpected content type [%s]", w.Header().Get("Content-Type"))
+		t.Fail()
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Logf("ERROR: expected 2 lines, got %v", lines)
+		t.Fail()
+		return
+	}
+
+	var line MessageStreamLine
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil || line.Message == nil || line.Message.Message != "copying" {
+		t.Logf("ERROR: expected message line, got %s", lines[0])
+		t.Fail()
+	}
+
+	line = MessageStreamLine{}
+	if err := json.Unmarshal([]byte(lines[1]), &line); err != nil || line.Result == nil || len(line.Result.Messages) != 2 {
+		t.Logf("ERROR: expected result line, got %s", lines[1])
+		t.Fail()
+	}
+}
+
+func TestResultWriterNoStream(t *testing.T) {
+	r := httptest.NewRequest("POST", "/backup", nil)
+	w := httptest.NewRecorder()
+
+	resultWriter := NewResultWriter(w, r)
+	SendMessage(resultWriter.Context(), SetMessage("INFO", "copying"))
+	resultWriter.Write(SetResultMessage(0, "INFO", "done"))
+
+	var result Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || len(result.Messages) != 1 {
+		t.Logf("ERROR: expected a plain result, got %s", w.Body.String())
+		t.Fail()
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// workflow, step, quiesce and plugin durations range from seconds to hours
+var durationBuckets = prometheus.ExponentialBuckets(0.5, 2, 16)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fossul",
+		Name:      "http_requests_total",
+		Help:      "Number of API requests by service, handler, method and status code.",
+	}, []string{"service", "handler", "method", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fossul",
+		Name:      "http_request_duration_seconds",
+		Help:      "API request latency by service, handler and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "handler", "method"})
+
+	workflowsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "fossul",
+		Name:      "workflows_total",
+		Help:      "Number of finished workflows by type and status.",
+	}, []string{"type", "status"})
+
+	workflowStepDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fossul",
+		Name:      "workflow_step_duration_seconds",
+		Help:      "Workflow step duration by workflow type, step kind and status.",
+		Buckets:   durationBuckets,
+	}, []string{"type", "kind", "status"})
+
+	quiesceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fossul",
+		Name:      "quiesce_duration_seconds",
+		Help:      "Time the application stayed quiesced during a workflow.",
+		Buckets:   durationBuckets,
+	}, []string{"profile", "config"})
+
+	backupsCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "fossul",
+		Name:      "backups",
+		Help:      "Number of backups after retention by profile, config and policy.",
+	}, []string{"profile", "config", "policy"})
+
+	archivesCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "fossul",
+		Name:      "archives",
+		Help:      "Number of archives after retention by profile, config and policy.",
+	}, []string{"profile", "config", "policy"})
+
+	lastSuccessfulBackup = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "fossul",
+		Name:      "last_successful_backup_timestamp_seconds",
+		Help:      "Time of the last successful backup workflow by profile, config and policy.",
+	}, []string{"profile", "config", "policy"})
+
+	pluginCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "fossul",
+		Name:      "plugin_call_duration_seconds",
+		Help:      "Plugin call latency by plugin type, plugin, action and status.",
+		Buckets:   durationBuckets,
+	}, []string{"type", "plugin", "action", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, workflowsTotal, workflowStepDuration,
+		quiesceDuration, backupsCount, archivesCount, lastSuccessfulBackup, pluginCallDuration)
+}
+
+// MetricsHandler exposes the metrics in the prometheus text format
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	promhttp.Handler().ServeHTTP(w, r)
+}
+
+// statusRecorder captures the response status code, it is still a flusher so event streams keep working
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// InstrumentApi records request count and latency per service and route name
+func InstrumentApi(inner http.Handler, service, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		inner.ServeHTTP(recorder, r)
+
+		httpRequestsTotal.WithLabelValues(service, name, r.Method, strconv.Itoa(recorder.code)).Inc()
+		httpRequestDuration.WithLabelValues(service, name, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+func ObserveQuiesceDuration(config Config, duration time.Duration) {
+	quiesceDuration.WithLabelValues(config.ProfileName, config.ConfigName).Observe(duration.Seconds())
+}
+
+func SetBackupCount(config Config, count int) {
+	backupsCount.WithLabelValues(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy).Set(float64(count))
+}
+
+func SetArchiveCount(config Config, count int) {
+	archivesCount.WithLabelValues(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy).Set(float64(count))
+}
+
+func SetLastSuccessfulBackup(config Config) {
+	lastSuccessfulBackup.WithLabelValues(config.ProfileName, config.ConfigName, config.SelectedBackupPolicy).SetToCurrentTime()
+}
+
+func ObservePluginCall(pluginType, plugin, action string, resultCode int, start time.Time) {
+	pluginCallDuration.WithLabelValues(pluginType, plugin, action, getMetricStatus(resultCode)).Observe(time.Since(start).Seconds())
+}
+
+func observeWorkflow(workflow *Workflow) {
+	workflowsTotal.WithLabelValues(workflow.Type, workflow.Status).Inc()
+}
+
+func ObserveStepDuration(workflowType, kind, status string, start time.Time) {
+	workflowStepDuration.WithLabelValues(workflowType, kind, status).Observe(time.Since(start).Seconds())
+}
+
+func getMetricStatus(resultCode int) string {
+	if resultCode != 0 {
+		return "error"
+	}
+
+	return "success"
+}
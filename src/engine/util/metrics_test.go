@@ -0,0 +1,60 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrumentApi(t *testing.T) {
+	handler := InstrumentApi(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Fail()
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}), "test", "GetTest")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test", nil))
+
+	if testutil.ToFloat64(httpRequestsTotal.WithLabelValues("test", "GetTest", "GET", "404")) != 1 {
+		t.Fail()
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	var workflow Workflow
+	workflow.Type = "metricsTest"
+	SetWorkflowStatusStart(&workflow)
+	SetWorkflowStatusEnd(&workflow)
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.Contains(recorder.Body.String(), `fossul_workflows_total{status="COMPLETE",type="metricsTest"} 1`) {
+		t.Fail()
+	}
+}
+
+func TestGetPluginAction(t *testing.T) {
+	if getPluginAction([]string{"--backupList"}) != "backupList" {
+		t.Fail()
+	}
+
+	if getPluginAction([]string{}) != "" {
+		t.Fail()
+	}
+}
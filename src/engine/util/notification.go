@@ -0,0 +1,376 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var notifierTypes = []string{"webhook", "chat", "smtp"}
+var notificationEvents = []string{"success", "failure", "retention"}
+
+// Notifier delivers workflow notifications, Type is webhook, chat or smtp. Events selects which of success,
+// failure and retention are sent, failure only if not set. Template is a go text/template rendered with the
+// Notification, it replaces the json payload of a webhook, the text of a chat message or the body of an email.
+// A failed delivery is retried, RetryBackoff is in seconds and doubles after each failed attempt.
+type Notifier struct {
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Events       []string          `json:"events,omitempty"`
+	Url          string            `json:"url,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Template     string            `json:"template,omitempty"`
+	SmtpHost     string            `json:"smtpHost,omitempty"`
+	SmtpPort     string            `json:"smtpPort,omitempty"`
+	SmtpUser     string            `json:"smtpUser,omitempty"`
+	SmtpPassword string            `json:"smtpPassword,omitempty"`
+	From         string            `json:"from,omitempty"`
+	To           []string          `json:"to,omitempty"`
+	Retries      int               `json:"retries,omitempty"`
+	RetryBackoff int               `json:"retryBackoff,omitempty"`
+}
+
+// ProfileNotifiers are the notifiers of a profile, they apply to every config of the profile
+type ProfileNotifiers struct {
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+}
+
+type ProfileNotifiersResult struct {
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+	Result    Result     `json:"result,omitempty"`
+}
+
+// Notification is the payload sent to notifiers. FailedStep and Messages are set for failure events, the
+// deleted backups and archives for retention events.
+type Notification struct {
+	Event           string    `json:"event"`
+	ProfileName     string    `json:"profileName"`
+	ConfigName      string    `json:"configName"`
+	Policy          string    `json:"policy,omitempty"`
+	WorkflowId      int64     `json:"workflowId"`
+	WorkflowType    string    `json:"workflowType,omitempty"`
+	Status          string    `json:"status"`
+	Timestamp       string    `json:"timestamp"`
+	FailedStep      *Step     `json:"failedStep,omitempty"`
+	Messages        []Message `json:"messages,omitempty"`
+	DeletedBackups  []Backup  `json:"deletedBackups,omitempty"`
+	DeletedArchives []Archive `json:"deletedArchives,omitempty"`
+}
+
+// ReadProfileNotifiers reads the profile notifiers file, a profile without the file has no notifiers
+func ReadProfileNotifiers(filePath string) (ProfileNotifiers, error) {
+	var profileNotifiers ProfileNotifiers
+	if !ExistsPath(filePath) {
+		return profileNotifiers, nil
+	}
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return profileNotifiers, err
+	}
+
+	if _, err := toml.Decode(string(b), &profileNotifiers); err != nil {
+		return profileNotifiers, err
+	}
+
+	return profileNotifiers, nil
+}
+
+func WriteProfileNotifiers(filePath string, profileNotifiers ProfileNotifiers) error {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(profileNotifiers); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, buf.Bytes(), 0644)
+}
+
+func SetNotification(event string, config Config, workflow *Workflow) Notification {
+	var notification Notification
+	notification.Event = event
+	notification.ProfileName = config.ProfileName
+	notification.ConfigName = config.ConfigName
+	notification.Policy = config.SelectedBackupPolicy
+	notification.WorkflowId = workflow.Id
+	notification.WorkflowType = workflow.Type
+	notification.Timestamp = time.Now().Format(time.RFC3339)
+
+	switch event {
+	case "success":
+		notification.Status = "COMPLETE"
+	case "failure":
+		notification.Status = "ERROR"
+	default:
+		notification.Status = workflow.Status
+	}
+
+	return notification
+}
+
+func ValidateNotifiers(notifiers []Notifier) error {
+	for _, notifier := range notifiers {
+		if notifier.Name == "" {
+			return errors.New("Notifier name is required")
+		}
+
+		if !ExistsInArray(notifierTypes, notifier.Type) {
+			return errors.New("Notifier [" + notifier.Name + "] type [" + notifier.Type + "] is not supported, valid types are " + strings.Join(notifierTypes, ", "))
+		}
+
+		for _, event := range notifier.Events {
+			if !ExistsInArray(notificationEvents, event) {
+				return errors.New("Notifier [" + notifier.Name + "] event [" + event + "] is not supported, valid events are " + strings.Join(notificationEvents, ", "))
+			}
+		}
+
+		if notifier.Type == "smtp" {
+			if notifier.SmtpHost == "" || notifier.From == "" || len(notifier.To) == 0 {
+				return errors.New("Notifier [" + notifier.Name + "] of type smtp requires SmtpHost, From and To")
+			}
+		} else if notifier.Url == "" {
+			return errors.New("Notifier [" + notifier.Name + "] of type " + notifier.Type + " requires Url")
+		}
+
+		if notifier.Template != "" {
+			if _, err := parseNotificationTemplate(notifier); err != nil {
+				return errors.New("Notifier [" + notifier.Name + "] template is invalid! " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetNotifiersForEvent returns the notifiers subscribed to the event, notifiers without events only get failures
+func GetNotifiersForEvent(notifiers []Notifier, event string) []Notifier {
+	var eventNotifiers []Notifier
+	for _, notifier := range notifiers {
+		events := notifier.Events
+		if len(events) == 0 {
+			events = []string{"failure"}
+		}
+
+		if ExistsInArray(events, event) {
+			eventNotifiers = append(eventNotifiers, notifier)
+		}
+	}
+
+	return eventNotifiers
+}
+
+// MergeNotifiers appends the profile notifiers, a config notifier overrides a profile notifier with the same name
+func MergeNotifiers(configNotifiers, profileNotifiers []Notifier) []Notifier {
+	notifiers := configNotifiers
+	for _, profileNotifier := range profileNotifiers {
+		isOverridden := false
+		for _, configNotifier := range configNotifiers {
+			if configNotifier.Name == profileNotifier.Name {
+				isOverridden = true
+				break
+			}
+		}
+
+		if !isOverridden {
+			notifiers = append(notifiers, profileNotifier)
+		}
+	}
+
+	return notifiers
+}
+
+func GetNotificationSubject(notification Notification) string {
+	subject := "Fossul " + notification.WorkflowType + " workflow [" + Int64ToString(notification.WorkflowId) + "] profile [" + notification.ProfileName + "] config [" + notification.ConfigName + "]"
+
+	switch notification.Event {
+	case "success":
+		return subject + " completed successfully"
+	case "failure":
+		return subject + " failed"
+	}
+
+	return subject + " deleted expired backups"
+}
+
+// GetNotificationText is the plain text form of a notification used for chat messages and emails
+func GetNotificationText(notification Notification) string {
+	lines := []string{GetNotificationSubject(notification)}
+
+	if notification.Policy != "" {
+		lines = append(lines, "Policy: "+notification.Policy)
+	}
+
+	if notification.FailedStep != nil {
+		step := notification.FailedStep
+		failedStep := "Failed Step: " + IntToString(step.Id) + " " + step.Kind
+		if step.Plugin != "" {
+			failedStep = failedStep + " [" + step.Plugin + "]"
+		}
+		lines = append(lines, failedStep)
+	}
+
+	for _, backup := range notification.DeletedBackups {
+		lines = append(lines, "Deleted Backup: "+backup.Name)
+	}
+
+	for _, archive := range notification.DeletedArchives {
+		lines = append(lines, "Deleted Archive: "+archive.Name)
+	}
+
+	for _, msg := range notification.Messages {
+		lines = append(lines, msg.Level+" "+msg.Message)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// GetNotificationBody renders the payload for the notifier type, json for webhooks, a json text field for
+// chat and plain text for email
+func GetNotificationBody(notifier Notifier, notification Notification) ([]byte, error) {
+	var body []byte
+	var err error
+
+	if notifier.Template != "" {
+		body, err = renderNotificationTemplate(notifier, notification)
+		if err != nil {
+			return body, err
+		}
+	}
+
+	switch notifier.Type {
+	case "webhook":
+		if notifier.Template == "" {
+			return json.Marshal(notification)
+		}
+	case "chat":
+		text := GetNotificationText(notification)
+		if notifier.Template != "" {
+			text = string(body)
+		}
+
+		return json.Marshal(map[string]string{"text": text})
+	case "smtp":
+		if notifier.Template == "" {
+			body = []byte(GetNotificationText(notification))
+		}
+	}
+
+	return body, nil
+}
+
+// SendNotification delivers a notification, retrying according to the notifier retries and backoff
+func SendNotification(notifier Notifier, notification Notification) error {
+	body, err := GetNotificationBody(notifier, notification)
+	if err != nil {
+		return err
+	}
+
+	stepPolicy := StepPolicy{Retries: notifier.Retries, RetryBackoff: notifier.RetryBackoff}
+	for attempt := 1; attempt <= notifier.Retries+1; attempt++ {
+		if notifier.Type == "smtp" {
+			err = sendMail(notifier, GetNotificationSubject(notification), body)
+		} else {
+			err = postNotification(notifier, body)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt <= notifier.Retries {
+			time.Sleep(GetStepRetryBackoff(stepPolicy, attempt))
+		}
+	}
+
+	return err
+}
+
+func postNotification(notifier Notifier, body []byte) error {
+	req, err := http.NewRequest("POST", notifier.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range notifier.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("Http Status Error [" + resp.Status + "]")
+	}
+
+	return nil
+}
+
+func sendMail(notifier Notifier, subject string, body []byte) error {
+	port := notifier.SmtpPort
+	if port == "" {
+		port = "25"
+	}
+
+	var auth smtp.Auth
+	if notifier.SmtpUser != "" {
+		auth = smtp.PlainAuth("", notifier.SmtpUser, notifier.SmtpPassword, notifier.SmtpHost)
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString("From: " + notifier.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(notifier.To, ", ") + "\r\n")
+	msg.WriteString("Subject: " + subject + "\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.Write(body)
+
+	return smtp.SendMail(notifier.SmtpHost+":"+port, auth, notifier.From, notifier.To, msg.Bytes())
+}
+
+func parseNotificationTemplate(notifier Notifier) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"text": GetNotificationText,
+	}
+
+	return template.New(notifier.Name).Funcs(funcs).Parse(notifier.Template)
+}
+
+func renderNotificationTemplate(notifier Notifier, notification Notification) ([]byte, error) {
+	tmpl, err := parseNotificationTemplate(notifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, notification); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetNotifiersForEvent(t *testing.T) {
+	var notifiers []Notifier
+	notifiers = append(notifiers, Notifier{Name: "ops", Type: "webhook", Url: "http://localhost"})
+	notifiers = append(notifiers, Notifier{Name: "team", Type: "chat", Url: "http://localhost", Events: []string{"success", "retention"}})
+
+	failureNotifiers := GetNotifiersForEvent(notifiers, "failure")
+	if len(failureNotifiers) != 1 || failureNotifiers[0].Name != "ops" {
+		t.Fail()
+	}
+
+	retentionNotifiers := GetNotifiersForEvent(notifiers, "retention")
+	if len(retentionNotifiers) != 1 || retentionNotifiers[0].Name != "team" {
+		t.Fail()
+	}
+}
+
+func TestValidateNotifiers(t *testing.T) {
+	if ValidateNotifiers([]Notifier{{Name: "ops", Type: "webhook", Url: "http://localhost"}}) != nil {
+		t.Fail()
+	}
+
+	if ValidateNotifiers([]Notifier{{Name: "ops", Type: "pager", Url: "http://localhost"}}) == nil {
+		t.Fail()
+	}
+
+	if ValidateNotifiers([]Notifier{{Name: "ops", Type: "webhook", Url: "http://localhost", Events: []string{"started"}}}) == nil {
+		t.Fail()
+	}
+
+	if ValidateNotifiers([]Notifier{{Name: "mail", Type: "smtp", SmtpHost: "localhost"}}) == nil {
+		t.Fail()
+	}
+
+	if ValidateNotifiers([]Notifier{{Name: "ops", Type: "webhook", Url: "http://localhost", Template: "{{.Event"}}) == nil {
+		t.Fail()
+	}
+}
+
+func TestMergeNotifiers(t *testing.T) {
+	configNotifiers := []Notifier{{Name: "ops", Type: "webhook", Url: "http://config"}}
+	profileNotifiers := []Notifier{{Name: "ops", Type: "webhook", Url: "http://profile"}, {Name: "team", Type: "chat", Url: "http://profile"}}
+
+	notifiers := MergeNotifiers(configNotifiers, profileNotifiers)
+	if len(notifiers) != 2 || notifiers[0].Url != "http://config" || notifiers[1].Name != "team" {
+		t.Fail()
+	}
+}
+
+func TestGetNotificationBody(t *testing.T) {
+	var notification Notification
+	notification.Event = "failure"
+	notification.WorkflowId = 1561234568
+	notification.FailedStep = &Step{Id: 3, Kind: "backup", Plugin: "sample-storage"}
+	notification.Messages = []Message{{Level: "ERROR", Message: "backup failed"}}
+
+	body, err := GetNotificationBody(Notifier{Name: "ops", Type: "webhook"}, notification)
+	if err != nil || !strings.Contains(string(body), `"workflowId":1561234568`) {
+		t.Fail()
+	}
+
+	body, err = GetNotificationBody(Notifier{Name: "team", Type: "chat"}, notification)
+	var chat map[string]string
+	if err != nil || json.Unmarshal(body, &chat) != nil {
+		t.Fatal()
+	}
+
+	if !strings.Contains(chat["text"], "Failed Step: 3 backup [sample-storage]") || !strings.Contains(chat["text"], "ERROR backup failed") {
+		t.Fail()
+	}
+
+	body, err = GetNotificationBody(Notifier{Name: "ops", Type: "webhook", Template: `{"id":"{{.WorkflowId}}","step":{{json .FailedStep.Kind}}}`}, notification)
+	if err != nil || string(body) != `{"id":"1561234568","step":"backup"}` {
+		t.Fail()
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	attempts := 0
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ = ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	notifier := Notifier{Name: "ops", Type: "webhook", Url: server.URL, Headers: map[string]string{"X-Token": "secret"}, Retries: 1}
+	err := SendNotification(notifier, Notification{Event: "success", WorkflowId: 1})
+	if err != nil || attempts != 2 || !strings.Contains(string(body), `"event":"success"`) {
+		t.Fail()
+	}
+
+	notifier.Retries = 0
+	notifier.Headers = nil
+	err = SendNotification(notifier, Notification{Event: "success", WorkflowId: 1})
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestReadProfileNotifiers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(dir)
+
+	profileNotifiers, err := ReadProfileNotifiers(dir + "/notifiers.conf")
+	if err != nil || len(profileNotifiers.Notifiers) != 0 {
+		t.Fail()
+	}
+
+	profileNotifiers.Notifiers = []Notifier{{Name: "ops", Type: "webhook", Url: "http://localhost", Events: []string{"failure"}}}
+	err = WriteProfileNotifiers(dir+"/notifiers.conf", profileNotifiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profileNotifiers, err = ReadProfileNotifiers(dir + "/notifiers.conf")
+	if err != nil || len(profileNotifiers.Notifiers) != 1 || profileNotifiers.Notifiers[0].Url != "http://localhost" {
+		t.Fail()
+	}
+}
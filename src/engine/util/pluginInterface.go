@@ -13,9 +13,12 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"path/filepath"
 	"plugin"
+	"strings"
+	"time"
 )
 
 type AppPlugin interface {
@@ -42,6 +45,7 @@ type ArchivePlugin interface {
 	Archive(Config) Result
 	ArchiveDelete(Config) Result
 	ArchiveList(Config) Archives
+	ArchiveRestore(Config) Result
 	Info() Plugin
 }
 
@@ -62,6 +66,10 @@ func GetPluginPath(pluginName string) string {
 		path = "./plugins/app/mongo-dump.so"
 	case "container-basic.so":
 		path = "./plugins/storage/container-basic.so"
+	case "container-dedup.so":
+		path = "./plugins/storage/container-dedup.so"
+	case "csi-snapshot.so":
+		path = "./plugins/storage/csi-snapshot.so"
 	case "sample-app.so":
 		path = "./plugins/app/sample-app.so"
 	case "sample-storage.so":
@@ -71,14 +79,24 @@ func GetPluginPath(pluginName string) string {
 	case "aws.so":
 		path = "./plugins/archive/aws.so"
 	default:
-		fmt.Println("Native plugin [" + pluginName + "] does not exist, executing as basic plugin")
 		path = ""
 	}
 
 	return path
 }
 
-func GetAppInterface(path string) (AppPlugin, error) {
+// storage plugins whose backup dir doesn't hold the backup data, an archive of the backup dir can't be restored
+var nonArchivableStoragePlugins = []string{
+	"container-dedup",
+	"csi-snapshot",
+}
+
+// IsStoragePluginArchivable is true if archive plugins can archive the backups of a storage plugin
+func IsStoragePluginArchivable(pluginName string) bool {
+	return !ExistsInArray(nonArchivableStoragePlugins, strings.TrimSuffix(pluginName, ".so"))
+}
+
+func GetAppInterface(ctx context.Context, path string) (AppPlugin, error) {
 	plugin, err := plugin.Open(path)
 	if err != nil {
 		return nil, err
@@ -95,10 +113,10 @@ func GetAppInterface(path string) (AppPlugin, error) {
 		return nil, errors.New("Unexpected symbol type from module [ " + path + "], ensure plugin properly implements interface AppPlugin")
 	}
 
-	return appPlugin, nil
+	return timedAppPlugin{appPlugin, getPluginName(path), ctx}, nil
 }
 
-func GetStorageInterface(path string) (StoragePlugin, error) {
+func GetStorageInterface(ctx context.Context, path string) (StoragePlugin, error) {
 	plugin, err := plugin.Open(path)
 	if err != nil {
 		return nil, err
@@ -115,10 +133,10 @@ func GetStorageInterface(path string) (StoragePlugin, error) {
 		return nil, errors.New("Unexpected symbol type from module [ " + path + "], ensure plugin properly implements interface StoragePlugin")
 	}
 
-	return storagePlugin, nil
+	return timedStoragePlugin{storagePlugin, getPluginName(path), ctx}, nil
 }
 
-func GetArchiveInterface(path string) (ArchivePlugin, error) {
+func GetArchiveInterface(ctx context.Context, path string) (ArchivePlugin, error) {
 	plugin, err := plugin.Open(path)
 	if err != nil {
 		return nil, err
@@ -135,5 +153,172 @@ func GetArchiveInterface(path string) (ArchivePlugin, error) {
 		return nil, errors.New("Unexpected symbol type from module [ " + path + "], ensure plugin properly implements interface ArchivePlugin")
 	}
 
-	return archivePlugin, nil
+	return timedArchivePlugin{archivePlugin, getPluginName(path), ctx}, nil
+}
+
+func getPluginName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".so")
+}
+
+// timedAppPlugin, timedStoragePlugin and timedArchivePlugin record the latency of native plugin calls, log
+// their results with the request logger and trace them as children of the api request span
+type timedAppPlugin struct {
+	AppPlugin
+	name string
+	ctx  context.Context
+}
+
+func (p timedAppPlugin) Quiesce(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "app", p.name, "quiesce")
+	result := p.AppPlugin.Quiesce(config)
+	ObservePluginCall("app", p.name, "quiesce", result.Code, start)
+	logPluginResult(p.ctx, "app", p.name, "quiesce", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedAppPlugin) Unquiesce(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "app", p.name, "unquiesce")
+	result := p.AppPlugin.Unquiesce(config)
+	ObservePluginCall("app", p.name, "unquiesce", result.Code, start)
+	logPluginResult(p.ctx, "app", p.name, "unquiesce", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedAppPlugin) PreRestore(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "app", p.name, "preRestore")
+	result := p.AppPlugin.PreRestore(config)
+	ObservePluginCall("app", p.name, "preRestore", result.Code, start)
+	logPluginResult(p.ctx, "app", p.name, "preRestore", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedAppPlugin) PostRestore(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "app", p.name, "postRestore")
+	result := p.AppPlugin.PostRestore(config)
+	ObservePluginCall("app", p.name, "postRestore", result.Code, start)
+	logPluginResult(p.ctx, "app", p.name, "postRestore", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedAppPlugin) Discover(config Config) DiscoverResult {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "app", p.name, "discover")
+	discoverResult := p.AppPlugin.Discover(config)
+	ObservePluginCall("app", p.name, "discover", discoverResult.Result.Code, start)
+	logPluginResult(p.ctx, "app", p.name, "discover", discoverResult.Result)
+	EndSpan(span, discoverResult.Result.Code, nil)
+
+	return discoverResult
+}
+
+type timedStoragePlugin struct {
+	StoragePlugin
+	name string
+	ctx  context.Context
+}
+
+func (p timedStoragePlugin) Backup(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "storage", p.name, "backup")
+	result := p.StoragePlugin.Backup(config)
+	ObservePluginCall("storage", p.name, "backup", result.Code, start)
+	logPluginResult(p.ctx, "storage", p.name, "backup", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedStoragePlugin) Restore(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "storage", p.name, "restore")
+	result := p.StoragePlugin.Restore(config)
+	ObservePluginCall("storage", p.name, "restore", result.Code, start)
+	logPluginResult(p.ctx, "storage", p.name, "restore", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedStoragePlugin) BackupDelete(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "storage", p.name, "backupDelete")
+	result := p.StoragePlugin.BackupDelete(config)
+	ObservePluginCall("storage", p.name, "backupDelete", result.Code, start)
+	logPluginResult(p.ctx, "storage", p.name, "backupDelete", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedStoragePlugin) BackupList(config Config) Backups {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "storage", p.name, "backupList")
+	backups := p.StoragePlugin.BackupList(config)
+	ObservePluginCall("storage", p.name, "backupList", backups.Result.Code, start)
+	logPluginResult(p.ctx, "storage", p.name, "backupList", backups.Result)
+	EndSpan(span, backups.Result.Code, nil)
+
+	return backups
+}
+
+type timedArchivePlugin struct {
+	ArchivePlugin
+	name string
+	ctx  context.Context
+}
+
+func (p timedArchivePlugin) Archive(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "archive", p.name, "archive")
+	result := p.ArchivePlugin.Archive(config)
+	ObservePluginCall("archive", p.name, "archive", result.Code, start)
+	logPluginResult(p.ctx, "archive", p.name, "archive", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedArchivePlugin) ArchiveDelete(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "archive", p.name, "archiveDelete")
+	result := p.ArchivePlugin.ArchiveDelete(config)
+	ObservePluginCall("archive", p.name, "archiveDelete", result.Code, start)
+	logPluginResult(p.ctx, "archive", p.name, "archiveDelete", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
+}
+
+func (p timedArchivePlugin) ArchiveList(config Config) Archives {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "archive", p.name, "archiveList")
+	archives := p.ArchivePlugin.ArchiveList(config)
+	ObservePluginCall("archive", p.name, "archiveList", archives.Result.Code, start)
+	logPluginResult(p.ctx, "archive", p.name, "archiveList", archives.Result)
+	EndSpan(span, archives.Result.Code, nil)
+
+	return archives
+}
+
+func (p timedArchivePlugin) ArchiveRestore(config Config) Result {
+	start := time.Now()
+	_, span := startPluginSpan(p.ctx, "archive", p.name, "archiveRestore")
+	result := p.ArchivePlugin.ArchiveRestore(config)
+	ObservePluginCall("archive", p.name, "archiveRestore", result.Code, start)
+	logPluginResult(p.ctx, "archive", p.name, "archiveRestore", result)
+	EndSpan(span, result.Code, nil)
+
+	return result
 }
@@ -13,15 +13,18 @@ limitations under the License.
 package util
 
 import (
+	"context"
 	//	"log"
 	"os"
 	"os/exec"
 	"strings"
 	//	"reflect"
 	"fmt"
+	"path/filepath"
+	"time"
 )
 
-func ExecutePlugin(config Config, pluginType string, args ...string) (result Result) {
+func ExecutePlugin(ctx context.Context, config Config, pluginType string, args ...string) (result Result) {
 
 	baseCmd := args[0]
 	cmdArgs := args[1:]
@@ -30,8 +33,11 @@ func ExecutePlugin(config Config, pluginType string, args ...string) (result Res
 	s0 := fmt.Sprintf("Executing plugin [%s %s]", baseCmd, strings.Join(cmdArgs, " "))
 	message := SetMessage("CMD", s0)
 	messages = append(messages, message)
+	SendMessage(ctx, message)
 
-	cmd := exec.Command(baseCmd, cmdArgs...)
+	start := time.Now()
+	ctx, span := startPluginSpan(ctx, pluginType, filepath.Base(baseCmd), getPluginAction(cmdArgs))
+	cmd := exec.CommandContext(ctx, baseCmd, cmdArgs...)
 
 	if pluginType == "app" {
 		cmd = setBasePluginEnv(config, cmd)
@@ -39,71 +45,91 @@ func ExecutePlugin(config Config, pluginType string, args ...string) (result Res
 	} else if pluginType == "storage" {
 		cmd = setBasePluginEnv(config, cmd)
 		cmd = setStoragePluginEnv(config, cmd)
+	} else if pluginType == "archive" {
+		// archive plugins read backups from the storage plugin backup destination
+		cmd = setBasePluginEnv(config, cmd)
+		cmd = setStoragePluginEnv(config, cmd)
+		cmd = setArchivePluginEnv(config, cmd)
 	}
+	cmd = setTraceEnv(ctx, cmd)
+
+	// plugin output is passed to the message sink of ctx as it happens, the plugin status follows its output
+	outputWriter := &messageLineWriter{ctx: ctx}
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
 
 	var resultCode int
-	stdoutStderrBytes, err := cmd.CombinedOutput()
+	var statusMessages []Message
+	err := cmd.Run()
+	outputWriter.Close()
 	if err != nil {
 		s1 := fmt.Sprintf("Plugin command [%s %s] failed", baseCmd, strings.Join(cmdArgs, " "))
 		message := SetMessage("ERROR", s1)
-		messages = append(messages, message)
+		statusMessages = append(statusMessages, message)
 
 		s2 := fmt.Sprintf("Plugin command failed with [%s]", err.Error())
 		message = SetMessage("ERROR", s2)
-		messages = append(messages, message)
+		statusMessages = append(statusMessages, message)
 
 		resultCode = 1
 	} else {
 		s1 := fmt.Sprintf("Plugin command [%s %s] completed successfully", baseCmd, strings.Join(cmdArgs, " "))
 		message := SetMessage("INFO", s1)
-		messages = append(messages, message)
+		statusMessages = append(statusMessages, message)
 
 		resultCode = 0
 	}
 
-	output := string(stdoutStderrBytes)
-	outputArray := strings.Split(output, "\n")
-
 	//combine all messages
-	pluginMessages := SetMessages(outputArray)
-	for _, msg := range pluginMessages {
+	messages = append(messages, outputWriter.messages...)
+	for _, msg := range statusMessages {
 		messages = append(messages, msg)
+		SendMessage(ctx, msg)
 	}
 
 	result = SetResult(resultCode, messages)
+	ObservePluginCall(pluginType, filepath.Base(baseCmd), getPluginAction(cmdArgs), resultCode, start)
+	logPluginResult(ctx, pluginType, filepath.Base(baseCmd), getPluginAction(cmdArgs), result)
+	EndSpan(span, resultCode, err)
 
 	return result
 }
 
-func ExecutePluginSimple(config Config, pluginType string, args ...string) (result ResultSimple) {
+func ExecutePluginSimple(ctx context.Context, config Config, pluginType string, args ...string) (result ResultSimple) {
 
 	baseCmd := args[0]
 	cmdArgs := args[1:]
 
-	s := fmt.Sprintf("CMD executing plugin [%s %s]", baseCmd, strings.Join(cmdArgs, " "))
-	fmt.Println(s)
+	pluginLogger := ContextLogger(ctx).With("pluginType", pluginType).With("plugin", filepath.Base(baseCmd)).With("action", getPluginAction(cmdArgs))
+	s := fmt.Sprintf("Executing plugin [%s %s]", baseCmd, strings.Join(cmdArgs, " "))
+	pluginLogger.Log("CMD", s)
 
-	cmd := exec.Command(baseCmd, cmdArgs...)
+	start := time.Now()
+	ctx, span := startPluginSpan(ctx, pluginType, filepath.Base(baseCmd), getPluginAction(cmdArgs))
+	cmd := exec.CommandContext(ctx, baseCmd, cmdArgs...)
 	if pluginType == "app" {
 		cmd = setBasePluginEnv(config, cmd)
 		cmd = setAppPluginEnv(config, cmd)
 	} else if pluginType == "storage" {
 		cmd = setBasePluginEnv(config, cmd)
 		cmd = setStoragePluginEnv(config, cmd)
+	} else if pluginType == "archive" {
+		cmd = setBasePluginEnv(config, cmd)
+		cmd = setStoragePluginEnv(config, cmd)
+		cmd = setArchivePluginEnv(config, cmd)
 	}
+	cmd = setTraceEnv(ctx, cmd)
 
 	var resultCode int
 	stdoutStderrBytes, err := cmd.CombinedOutput()
 	if err != nil {
-		s := fmt.Sprintf("ERROR plugin command [%s %s] failed", baseCmd, strings.Join(cmdArgs, " "))
-		fmt.Println(s)
-		fmt.Println("ERROR plugin command failed with\n", err)
+		s := fmt.Sprintf("Plugin command [%s %s] failed with [%s]", baseCmd, strings.Join(cmdArgs, " "), err.Error())
+		pluginLogger.Error(s)
 		resultCode = 1
 	} else {
 		resultCode = 0
-		s := fmt.Sprintf("INFO plugin command [%s %s] completed successfully", baseCmd, strings.Join(cmdArgs, " "))
-		fmt.Println(s)
-
+		s := fmt.Sprintf("Plugin command [%s %s] completed successfully", baseCmd, strings.Join(cmdArgs, " "))
+		pluginLogger.Info(s)
 	}
 
 	output := string(stdoutStderrBytes)
@@ -111,10 +137,21 @@ func ExecutePluginSimple(config Config, pluginType string, args ...string) (resu
 
 	result.Code = resultCode
 	result.Messages = outputArray
+	ObservePluginCall(pluginType, filepath.Base(baseCmd), getPluginAction(cmdArgs), resultCode, start)
+	EndSpan(span, resultCode, err)
 
 	return result
 }
 
+// getPluginAction returns the basic plugin action, ex: --backup is backup
+func getPluginAction(cmdArgs []string) string {
+	if len(cmdArgs) == 0 {
+		return ""
+	}
+
+	return strings.TrimPrefix(cmdArgs[0], "--")
+}
+
 func setAppPluginEnv(config Config, cmd *exec.Cmd) *exec.Cmd {
 	for k, v := range config.AppPluginParameters {
 		cmd.Env = append(cmd.Env, k+"="+v)
@@ -145,14 +182,26 @@ func setBasePluginEnv(config Config, cmd *exec.Cmd) *exec.Cmd {
 	cmd.Env = append(cmd.Env, "ConfigName="+config.ConfigName)
 	cmd.Env = append(cmd.Env, "AutoDiscovery="+BoolToString(config.AutoDiscovery))
 	cmd.Env = append(cmd.Env, "WorkflowId="+config.WorkflowId)
-	cmd.Env = append(cmd.Env, "SelectedWorkflowId="+IntToString(config.SelectedWorkflowId))
+	cmd.Env = append(cmd.Env, "SelectedWorkflowId="+Int64ToString(config.SelectedWorkflowId))
 	cmd.Env = append(cmd.Env, "BackupPolicy="+config.SelectedBackupPolicy)
+	cmd.Env = append(cmd.Env, "RestorePaths="+strings.Join(config.RestorePaths, ","))
+	cmd.Env = append(cmd.Env, "BackupCompression="+config.BackupCompression)
+	cmd.Env = append(cmd.Env, "BackupEncryptionKeyFile="+config.BackupEncryptionKeyFile)
 
 	backupRetentionToString := IntToString(config.SelectedBackupRetention)
 	cmd.Env = append(cmd.Env, "BackupRetention="+backupRetentionToString)
 	archiveRetentionToString := IntToString(config.SelectedArchiveRetention)
 	cmd.Env = append(cmd.Env, "ArchiveRetention="+archiveRetentionToString)
 
+	backupRetentionRule, err := RetentionRuleToJson(GetBackupRetentionRule(config))
+	if err == nil {
+		cmd.Env = append(cmd.Env, "BackupRetentionRule="+backupRetentionRule)
+	}
+	archiveRetentionRule, err := RetentionRuleToJson(GetArchiveRetentionRule(config))
+	if err == nil {
+		cmd.Env = append(cmd.Env, "ArchiveRetentionRule="+archiveRetentionRule)
+	}
+
 	return cmd
 }
 
@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// RestoreTarget redirects a restore away from the configured application, ex: into a scratch namespace.
+// Namespace, ServiceName and ContainerName override the app and storage plugin parameters of the same
+// name, RestoreDestPath overrides the storage plugin restore destination and Database overrides the
+// database parameter of the app plugin (MysqlDb, PqDb or MongoDb), the original database is kept in the
+// SourceDatabase app plugin parameter and the original namespace in the SourceNamespace storage plugin parameter.
+type RestoreTarget struct {
+	Namespace       string `json:"namespace,omitempty"`
+	ServiceName     string `json:"serviceName,omitempty"`
+	ContainerName   string `json:"containerName,omitempty"`
+	RestoreDestPath string `json:"restoreDestPath,omitempty"`
+	Database        string `json:"database,omitempty"`
+}
+
+// RestoreRequest is the optional body of a restore request, RestorePaths selects the paths within the backup
+// to restore, by default the whole backup is restored
+type RestoreRequest struct {
+	RestoreTarget
+	RestorePaths []string `json:"restorePaths,omitempty"`
+}
+
+var restoreTargetParameters = []string{
+	"Namespace",
+	"ServiceName",
+	"ContainerName",
+}
+
+var restoreTargetDatabaseParameters = []string{
+	"MysqlDb",
+	"PqDb",
+	"MongoDb",
+}
+
+var restoreTargetDatabaseRegex = regexp.MustCompile(`^[A-Za-z0-9_$-]+$`)
+
+func ApplyRestoreTarget(config Config, target RestoreTarget) Config {
+	config.AppPluginParameters = copyParameters(config.AppPluginParameters)
+	config.StoragePluginParameters = copyParameters(config.StoragePluginParameters)
+
+	for _, parameter := range restoreTargetParameters {
+		value := getRestoreTargetParameter(target, parameter)
+		if value == "" {
+			continue
+		}
+
+		if parameter == "Namespace" {
+			config.StoragePluginParameters["SourceNamespace"] = config.StoragePluginParameters[parameter]
+		}
+
+		config.AppPluginParameters[parameter] = value
+		config.StoragePluginParameters[parameter] = value
+	}
+
+	if target.RestoreDestPath != "" {
+		config.StoragePluginParameters["RestoreDestPath"] = target.RestoreDestPath
+	}
+
+	if target.Database != "" {
+		for _, parameter := range restoreTargetDatabaseParameters {
+			if _, ok := config.AppPluginParameters[parameter]; ok {
+				config.AppPluginParameters["SourceDatabase"] = config.AppPluginParameters[parameter]
+				config.AppPluginParameters[parameter] = target.Database
+			}
+		}
+	}
+
+	return config
+}
+
+func IsRestoreTargetSet(target RestoreTarget) bool {
+	if target == (RestoreTarget{}) {
+		return false
+	}
+
+	return true
+}
+
+// GetRestoreTargetDescription returns the overridden target fields, ex: namespace=staging database=clone
+func GetRestoreTargetDescription(target RestoreTarget) string {
+	var fields []string
+	for _, parameter := range restoreTargetParameters {
+		value := getRestoreTargetParameter(target, parameter)
+		if value != "" {
+			fields = append(fields, strings.ToLower(parameter[:1])+parameter[1:]+"="+value)
+		}
+	}
+
+	if target.RestoreDestPath != "" {
+		fields = append(fields, "restoreDestPath="+target.RestoreDestPath)
+	}
+
+	if target.Database != "" {
+		fields = append(fields, "database="+target.Database)
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// ValidateRestoreTarget checks the overrides of a restore request, app plugins pass the database and restore
+// path to database clients in the pod so the database must be a plain name and the restore path absolute
+func ValidateRestoreTarget(target RestoreTarget) error {
+	if target.Database != "" && !restoreTargetDatabaseRegex.MatchString(target.Database) {
+		return errors.New("Restore target database [" + target.Database + "] may only contain letters, digits, _, $ and -")
+	}
+
+	if target.RestoreDestPath != "" {
+		cleanPath := filepath.Clean(target.RestoreDestPath)
+		if !filepath.IsAbs(cleanPath) || cleanPath == "/" || cleanPath != target.RestoreDestPath {
+			return errors.New("Restore target path [" + target.RestoreDestPath + "] must be a clean absolute path other than /")
+		}
+	}
+
+	return nil
+}
+
+// ValidateVerifyTarget makes sure a verify workflow can't restore over the configured application, the target
+// must override the namespace or service with a different value. Container and restore path alone still restore
+// into the application pod. If the app plugin restores a database the target must also override the database.
+func ValidateVerifyTarget(config Config) error {
+	target := config.VerifyTarget
+
+	err := ValidateRestoreTarget(target)
+	if err != nil {
+		return err
+	}
+
+	if !isVerifyTargetParameterChanged(config, "Namespace") && !isVerifyTargetParameterChanged(config, "ServiceName") {
+		return errors.New("Verify target must set a namespace or service that differs from the config")
+	}
+
+	for _, parameter := range restoreTargetDatabaseParameters {
+		database, ok := config.AppPluginParameters[parameter]
+		if !ok {
+			continue
+		}
+
+		if target.Database == "" || target.Database == database {
+			return errors.New("Verify target must set a database that differs from the config parameter [" + parameter + "]")
+		}
+	}
+
+	return nil
+}
+
+func isVerifyTargetParameterChanged(config Config, parameter string) bool {
+	value := getRestoreTargetParameter(config.VerifyTarget, parameter)
+	if value == "" {
+		return false
+	}
+
+	return value != config.AppPluginParameters[parameter] && value != config.StoragePluginParameters[parameter]
+}
+
+func getRestoreTargetParameter(target RestoreTarget, parameter string) string {
+	switch parameter {
+	case "Namespace":
+		return target.Namespace
+	case "ServiceName":
+		return target.ServiceName
+	case "ContainerName":
+		return target.ContainerName
+	}
+
+	return ""
+}
+
+func copyParameters(parameters map[string]string) map[string]string {
+	parametersCopy := make(map[string]string)
+	for k, v := range parameters {
+		parametersCopy[k] = v
+	}
+
+	return parametersCopy
+}
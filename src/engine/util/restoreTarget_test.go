@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"testing"
+)
+
+func TestApplyRestoreTarget(t *testing.T) {
+	var config Config
+	config.AppPluginParameters = map[string]string{"Namespace": "prod", "ServiceName": "mariadb"}
+	config.StoragePluginParameters = map[string]string{"Namespace": "prod", "ServiceName": "mariadb"}
+
+	var target RestoreTarget
+	target.Namespace = "scratch"
+	target.RestoreDestPath = "/tmp/verify"
+
+	targetConfig := ApplyRestoreTarget(config, target)
+	if targetConfig.AppPluginParameters["Namespace"] != "scratch" || targetConfig.StoragePluginParameters["Namespace"] != "scratch" {
+		t.Fail()
+	}
+
+	if targetConfig.AppPluginParameters["ServiceName"] != "mariadb" || targetConfig.StoragePluginParameters["RestoreDestPath"] != "/tmp/verify" {
+		t.Fail()
+	}
+
+	if targetConfig.StoragePluginParameters["SourceNamespace"] != "prod" {
+		t.Fail()
+	}
+
+	// original config must not be changed
+	if config.AppPluginParameters["Namespace"] != "prod" {
+		t.Fail()
+	}
+}
+
+func TestApplyRestoreTargetDatabase(t *testing.T) {
+	var config Config
+	config.AppPluginParameters = map[string]string{"MysqlDb": "prod"}
+	config.StoragePluginParameters = map[string]string{}
+
+	var target RestoreTarget
+	target.Database = "clone"
+
+	targetConfig := ApplyRestoreTarget(config, target)
+	if targetConfig.AppPluginParameters["MysqlDb"] != "clone" || targetConfig.AppPluginParameters["SourceDatabase"] != "prod" {
+		t.Fail()
+	}
+
+	// database parameters of other plugins must not be added
+	if _, ok := targetConfig.AppPluginParameters["PqDb"]; ok {
+		t.Fail()
+	}
+
+	if GetRestoreTargetDescription(target) != "database=clone" {
+		t.Fail()
+	}
+}
+
+func TestIsRestoreTargetSet(t *testing.T) {
+	var target RestoreTarget
+	if IsRestoreTargetSet(target) {
+		t.Fail()
+	}
+
+	target.ServiceName = "mariadb-staging"
+	if !IsRestoreTargetSet(target) {
+		t.Fail()
+	}
+}
+
+func TestValidateVerifyTarget(t *testing.T) {
+	var config Config
+	config.AppPluginParameters = map[string]string{"Namespace": "prod"}
+
+	if ValidateVerifyTarget(config) == nil {
+		t.Fail()
+	}
+
+	config.VerifyTarget.Namespace = "prod"
+	if ValidateVerifyTarget(config) == nil {
+		t.Fail()
+	}
+
+	config.VerifyTarget.Namespace = "scratch"
+	if ValidateVerifyTarget(config) != nil {
+		t.Fail()
+	}
+}
+
+func TestValidateRestoreTarget(t *testing.T) {
+	var target RestoreTarget
+	target.Database = "clone_db"
+	target.RestoreDestPath = "/var/lib/restore"
+	if ValidateRestoreTarget(target) != nil {
+		t.Fail()
+	}
+
+	target.Database = "clone; drop database prod"
+	if ValidateRestoreTarget(target) == nil {
+		t.Logf("ERROR: Restore target database with shell characters should be rejected")
+		t.Fail()
+	}
+
+	target.Database = ""
+	for _, path := range []string{"/", "restore", "/restore/../etc", "/restore/"} {
+		target.RestoreDestPath = path
+		if ValidateRestoreTarget(target) == nil {
+			t.Logf("ERROR: Restore target path [%s] should be rejected", path)
+			t.Fail()
+		}
+	}
+}
+
+func TestValidateVerifyTargetSamePod(t *testing.T) {
+	var config Config
+	config.AppPluginParameters = map[string]string{"Namespace": "prod", "ServiceName": "postgres", "PqDb": "sampledb"}
+	config.StoragePluginParameters = map[string]string{"Namespace": "prod", "ServiceName": "postgres", "RestoreDestPath": "/var/lib/pgsql/restore"}
+
+	config.VerifyTarget.ContainerName = "sidecar"
+	config.VerifyTarget.RestoreDestPath = "/tmp/verify"
+	config.VerifyTarget.Database = "verifydb"
+	if ValidateVerifyTarget(config) == nil {
+		t.Logf("ERROR: Verify target changing only container and restore path should be rejected")
+		t.Fail()
+	}
+
+	config.VerifyTarget.Namespace = "postgres-verify"
+	config.VerifyTarget.Database = ""
+	if ValidateVerifyTarget(config) == nil {
+		t.Logf("ERROR: Verify target of a database app plugin without database should be rejected")
+		t.Fail()
+	}
+
+	config.VerifyTarget.Database = "sampledb"
+	if ValidateVerifyTarget(config) == nil {
+		t.Logf("ERROR: Verify target restoring into the source database should be rejected")
+		t.Fail()
+	}
+
+	config.VerifyTarget.Database = "verifydb"
+	if ValidateVerifyTarget(config) != nil {
+		t.Logf("ERROR: Verify target in another namespace and database should be accepted")
+		t.Fail()
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetentionRule decides which backups or archives of a policy are kept. A backup is kept if any rule keeps
+// it: it is one of the newest RetentionNumber backups, younger than KeepAllHours, or the newest backup of a
+// day, week or month within the last KeepDailyDays, KeepWeeklyWeeks or KeepMonthlyMonths. MinAgeHours is
+// applied last, a backup younger than MinAgeHours is never expired whatever the other rules decide.
+// Days, weeks and months are calendar periods in UTC, see GetRetentionTime, weeks start on monday.
+type RetentionRule struct {
+	RetentionNumber   int `json:"retentionNumber"`
+	KeepAllHours      int `json:"keepAllHours,omitempty"`
+	KeepDailyDays     int `json:"keepDailyDays,omitempty"`
+	KeepWeeklyWeeks   int `json:"keepWeeklyWeeks,omitempty"`
+	KeepMonthlyMonths int `json:"keepMonthlyMonths,omitempty"`
+	MinAgeHours       int `json:"minAgeHours,omitempty"`
+}
+
+// IsTimeBased returns true if the rule keeps backups by age and not only by count, MinAgeHours only guards
+// backups from the other rules and doesn't make a rule time based
+func (r RetentionRule) IsTimeBased() bool {
+	return r.KeepAllHours > 0 || r.KeepDailyDays > 0 || r.KeepWeeklyWeeks > 0 || r.KeepMonthlyMonths > 0
+}
+
+func (r RetentionRule) String() string {
+	var rules []string
+	if r.RetentionNumber >= 0 {
+		rules = append(rules, "last "+IntToString(r.RetentionNumber))
+	}
+	if r.KeepAllHours > 0 {
+		rules = append(rules, "all "+IntToString(r.KeepAllHours)+"h")
+	}
+	if r.KeepDailyDays > 0 {
+		rules = append(rules, "daily "+IntToString(r.KeepDailyDays)+"d")
+	}
+	if r.KeepWeeklyWeeks > 0 {
+		rules = append(rules, "weekly "+IntToString(r.KeepWeeklyWeeks)+"w")
+	}
+	if r.KeepMonthlyMonths > 0 {
+		rules = append(rules, "monthly "+IntToString(r.KeepMonthlyMonths)+"m")
+	}
+	if r.MinAgeHours > 0 {
+		rules = append(rules, "min age "+IntToString(r.MinAgeHours)+"h")
+	}
+
+	return strings.Join(rules, ", ")
+}
+
+func (r BackupRetention) GetRule() RetentionRule {
+	return RetentionRule{
+		RetentionNumber:   r.RetentionNumber,
+		KeepAllHours:      r.KeepAllHours,
+		KeepDailyDays:     r.KeepDailyDays,
+		KeepWeeklyWeeks:   r.KeepWeeklyWeeks,
+		KeepMonthlyMonths: r.KeepMonthlyMonths,
+		MinAgeHours:       r.MinAgeHours,
+	}
+}
+
+func (r ArchiveRetention) GetRule() RetentionRule {
+	return RetentionRule{
+		RetentionNumber:   r.RetentionNumber,
+		KeepAllHours:      r.KeepAllHours,
+		KeepDailyDays:     r.KeepDailyDays,
+		KeepWeeklyWeeks:   r.KeepWeeklyWeeks,
+		KeepMonthlyMonths: r.KeepMonthlyMonths,
+		MinAgeHours:       r.MinAgeHours,
+	}
+}
+
+// GetBackupRetentionRule returns the backup retention rule of the selected policy, configs without the policy
+// fall back to the selected retention number
+func GetBackupRetentionRule(config Config) RetentionRule {
+	for _, retention := range config.BackupRetentions {
+		if retention.Policy == config.SelectedBackupPolicy {
+			return retention.GetRule()
+		}
+	}
+
+	return RetentionRule{RetentionNumber: config.SelectedBackupRetention}
+}
+
+// GetArchiveRetentionRule returns the archive retention rule of the selected policy, configs without the policy
+// fall back to the selected retention number
+func GetArchiveRetentionRule(config Config) RetentionRule {
+	for _, retention := range config.ArchiveRetentions {
+		if retention.Policy == config.SelectedBackupPolicy {
+			return retention.GetRule()
+		}
+	}
+
+	return RetentionRule{RetentionNumber: config.SelectedArchiveRetention}
+}
+
+// RetentionRuleToJson is used to pass a retention rule to basic plugins
+func RetentionRuleToJson(rule RetentionRule) (string, error) {
+	b, err := json.Marshal(rule)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func JsonToRetentionRule(ruleJson string) (RetentionRule, error) {
+	var rule RetentionRule
+	err := json.Unmarshal([]byte(ruleJson), &rule)
+	if err != nil {
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// GetRetentionTime returns the time retention is evaluated at. It is UTC so the storage service and the
+// workflow plan of the server bucket backups into the same days, weeks and months.
+func GetRetentionTime() time.Time {
+	return time.Now().UTC()
+}
+
+// GetExpiredBackupsByRule returns the backups of a policy that retention deletes, newest first
+func GetExpiredBackupsByRule(policy string, rule RetentionRule, backups []Backup, now time.Time) []Backup {
+	var expiredBackups []Backup
+	backupsByPolicy := GetBackupsByPolicy(policy, backups)
+
+	var epochs []int
+	for _, backup := range backupsByPolicy {
+		epochs = append(epochs, backup.Epoch)
+	}
+
+	for _, i := range getExpiredIndexes(rule, epochs, now) {
+		expiredBackups = append(expiredBackups, backupsByPolicy[i])
+	}
+
+	return expiredBackups
+}
+
+// GetExpiredArchivesByRule returns the archives of a policy that retention deletes, newest first
+func GetExpiredArchivesByRule(policy string, rule RetentionRule, archives []Archive, now time.Time) []Archive {
+	var expiredArchives []Archive
+	archivesByPolicy := GetArchivesByPolicy(policy, archives)
+
+	var epochs []int
+	for _, archive := range archivesByPolicy {
+		epochs = append(epochs, archive.Epoch)
+	}
+
+	for _, i := range getExpiredIndexes(rule, epochs, now) {
+		expiredArchives = append(expiredArchives, archivesByPolicy[i])
+	}
+
+	return expiredArchives
+}
+
+// getExpiredIndexes applies a retention rule to the epochs of a policy and returns the indexes of the
+// expired ones, newest first. A rule without a valid retention number or time based rule expires nothing.
+func getExpiredIndexes(rule RetentionRule, epochs []int, now time.Time) []int {
+	var expiredIndexes []int
+	if rule.RetentionNumber < 0 && !rule.IsTimeBased() {
+		return expiredIndexes
+	}
+
+	indexes := make([]int, len(epochs))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return epochs[indexes[i]] > epochs[indexes[j]]
+	})
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	thisWeek := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	months := make(map[string]bool)
+
+	for rank, i := range indexes {
+		t := time.Unix(int64(epochs[i]), 0).In(now.Location())
+		age := now.Sub(t)
+
+		// the newest backup of a period represents it, later backups of the period are not considered
+		day := t.Format("2006-01-02")
+		isNewestOfDay := !days[day]
+		days[day] = true
+
+		year, week := t.ISOWeek()
+		weekKey := IntToString(year) + "-" + IntToString(week)
+		isNewestOfWeek := !weeks[weekKey]
+		weeks[weekKey] = true
+
+		month := t.Format("2006-01")
+		isNewestOfMonth := !months[month]
+		months[month] = true
+
+		isKept := rank < rule.RetentionNumber
+		isKept = isKept || (rule.KeepAllHours > 0 && age < time.Duration(rule.KeepAllHours)*time.Hour)
+		isKept = isKept || (rule.KeepDailyDays > 0 && isNewestOfDay && !t.Before(today.AddDate(0, 0, -(rule.KeepDailyDays-1))))
+		isKept = isKept || (rule.KeepWeeklyWeeks > 0 && isNewestOfWeek && !t.Before(thisWeek.AddDate(0, 0, -7*(rule.KeepWeeklyWeeks-1))))
+		isKept = isKept || (rule.KeepMonthlyMonths > 0 && isNewestOfMonth && !t.Before(thisMonth.AddDate(0, -(rule.KeepMonthlyMonths-1), 0)))
+
+		// min age vetoes expiry decided by the rules above
+		if !isKept && rule.MinAgeHours > 0 && age < time.Duration(rule.MinAgeHours)*time.Hour {
+			continue
+		}
+
+		if !isKept {
+			expiredIndexes = append(expiredIndexes, i)
+		}
+	}
+
+	return expiredIndexes
+}
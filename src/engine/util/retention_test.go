@@ -0,0 +1,150 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// getHourlyBackups returns a backup every 6 hours for the given number of days before now, oldest first
+func getHourlyBackups(now time.Time, days int) []Backup {
+	var backups []Backup
+	for i := days * 4; i >= 0; i-- {
+		epoch := int(now.Add(-time.Duration(i*6) * time.Hour).Unix())
+		backups = append(backups, Backup{Name: "test", Policy: "daily", WorkflowId: IntToString(days*4 - i), Epoch: epoch})
+	}
+
+	return backups
+}
+
+func TestRetentionRuleCount(t *testing.T) {
+	now := time.Date(2019, 6, 18, 12, 0, 0, 0, time.UTC)
+	backups := getHourlyBackups(now, 2)
+
+	expiredBackups := GetExpiredBackupsByRule("daily", RetentionRule{RetentionNumber: 3}, backups, now)
+	if len(expiredBackups) != len(backups)-3 || expiredBackups[0].WorkflowId != "5" {
+		t.Logf("ERROR: expected %d expired backups newest first, got %v", len(backups)-3, expiredBackups)
+		t.Fail()
+	}
+
+	if len(GetExpiredBackupsByRule("daily", RetentionRule{RetentionNumber: -1}, backups, now)) != 0 {
+		t.Logf("ERROR: a rule without retention should expire nothing")
+		t.Fail()
+	}
+}
+
+func TestRetentionRuleGFS(t *testing.T) {
+	now := time.Date(2019, 6, 18, 12, 0, 0, 0, time.UTC)
+	backups := getHourlyBackups(now, 400)
+	rule := RetentionRule{KeepAllHours: 48, KeepDailyDays: 14, KeepWeeklyWeeks: 8, KeepMonthlyMonths: 12}
+
+	kept := make(map[int]bool)
+	for _, backup := range backups {
+		kept[backup.Epoch] = true
+	}
+	for _, backup := range GetExpiredBackupsByRule("daily", rule, backups, now) {
+		delete(kept, backup.Epoch)
+	}
+
+	for epoch := range kept {
+		backupTime := time.Unix(int64(epoch), 0).UTC()
+		if now.Sub(backupTime) > 366*24*time.Hour {
+			t.Logf("ERROR: backup %s older than a year was kept", backupTime)
+			t.Fail()
+		}
+	}
+
+	// 8 backups younger than 48h, 11 more dailies, 5 more weeklies and 11 more monthlies
+	if len(kept) != 8+11+5+11 {
+		t.Logf("ERROR: expected %d kept backups, got %d", 8+11+5+11, len(kept))
+		t.Fail()
+	}
+
+	// the newest backup of a day is kept, 2019-06-10 18:00 is the last backup of that day
+	if !kept[int(time.Date(2019, 6, 10, 18, 0, 0, 0, time.UTC).Unix())] || kept[int(time.Date(2019, 6, 10, 12, 0, 0, 0, time.UTC).Unix())] {
+		t.Logf("ERROR: expected newest backup of the day to be kept")
+		t.Fail()
+	}
+}
+
+func TestRetentionRuleMinAge(t *testing.T) {
+	now := time.Date(2019, 6, 18, 12, 0, 0, 0, time.UTC)
+	backups := getHourlyBackups(now, 2)
+
+	expiredBackups := GetExpiredBackupsByRule("daily", RetentionRule{RetentionNumber: 0, MinAgeHours: 24}, backups, now)
+	for _, backup := range expiredBackups {
+		if now.Sub(time.Unix(int64(backup.Epoch), 0)) < 24*time.Hour {
+			t.Logf("ERROR: backup younger than min age expired %v", backup)
+			t.Fail()
+		}
+	}
+
+	if len(expiredBackups) != 5 {
+		t.Logf("ERROR: expected 5 expired backups, got %d", len(expiredBackups))
+		t.Fail()
+	}
+}
+
+func TestRetentionRuleMinAgeVeto(t *testing.T) {
+	now := time.Date(2019, 6, 18, 12, 0, 0, 0, time.UTC)
+	backups := []Backup{
+		{Name: "test", Policy: "daily", WorkflowId: "1", Epoch: int(now.Add(-2 * time.Hour).Unix())},
+		{Name: "test", Policy: "daily", WorkflowId: "2", Epoch: int(now.Add(-1 * time.Hour).Unix())},
+	}
+
+	expiredBackups := GetExpiredBackupsByRule("daily", RetentionRule{RetentionNumber: 1, MinAgeHours: 24}, backups, now)
+	if len(expiredBackups) != 0 {
+		t.Logf("ERROR: expected both backups younger than min age to survive, got %v expired", expiredBackups)
+		t.Fail()
+	}
+
+	// min age alone is not a retention rule, nothing expires without a retention number or time based rule
+	expiredBackups = GetExpiredBackupsByRule("daily", RetentionRule{RetentionNumber: -1, MinAgeHours: 24}, getHourlyBackups(now, 2), now)
+	if len(expiredBackups) != 0 {
+		t.Logf("ERROR: expected no expired backups for min age only rule, got %d", len(expiredBackups))
+		t.Fail()
+	}
+}
+
+func TestGetBackupRetentionRule(t *testing.T) {
+	var config Config
+	config.SelectedBackupPolicy = "daily"
+	config.SelectedBackupRetention = 3
+	config.BackupRetentions = []BackupRetention{{Policy: "daily", RetentionNumber: 5, KeepDailyDays: 14}}
+
+	rule := GetBackupRetentionRule(config)
+	if rule.RetentionNumber != 5 || rule.KeepDailyDays != 14 || !rule.IsTimeBased() {
+		t.Logf("ERROR: unexpected rule %v", rule)
+		t.Fail()
+	}
+
+	config.SelectedBackupPolicy = "weekly"
+	rule = GetBackupRetentionRule(config)
+	if rule.RetentionNumber != 3 || rule.IsTimeBased() || rule.String() != "last 3" {
+		t.Logf("ERROR: expected fallback to selected retention, got %v", rule)
+		t.Fail()
+	}
+
+	ruleJson, err := RetentionRuleToJson(GetBackupRetentionRule(Config{SelectedBackupPolicy: "daily", BackupRetentions: config.BackupRetentions}))
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	rule, err = JsonToRetentionRule(ruleJson)
+	if err != nil || rule.RetentionNumber != 5 || rule.KeepDailyDays != 14 {
+		t.Logf("ERROR: unexpected rule %v from json %s", rule, ruleJson)
+		t.Fail()
+	}
+}
@@ -14,6 +14,7 @@ package util
 
 import (
 	"encoding/json"
+	"errors"
 	"gopkg.in/robfig/cron.v3"
 	"net/http"
 )
@@ -29,10 +30,13 @@ type JobSchedule struct {
 	ProfileName  string       `json:"profileName"`
 	ConfigName   string       `json:"configName"`
 	BackupPolicy string       `json:"backupPolicy"`
+	WorkflowType string       `json:"workflowType,omitempty"`
 }
 
+// CronSchedule WorkflowType is backup or verify, defaults to backup
 type CronSchedule struct {
-	Value string `json:"value,omitempty"`
+	Value        string `json:"value,omitempty"`
+	WorkflowType string `json:"workflowType,omitempty"`
 }
 
 func GetCronSchedule(w http.ResponseWriter, r *http.Request) (CronSchedule, error) {
@@ -66,3 +70,21 @@ func GetCronScheduleId(w http.ResponseWriter, r *http.Request) (cron.EntryID, er
 
 	return cronScheduleId, nil
 }
+
+// GetScheduleWorkflowType returns the workflow type started by a schedule, schedules without a type run backups
+func GetScheduleWorkflowType(workflowType string) string {
+	if workflowType == "" {
+		return "backup"
+	}
+
+	return workflowType
+}
+
+func ValidateScheduleWorkflowType(workflowType string) error {
+	workflowType = GetScheduleWorkflowType(workflowType)
+	if workflowType != "backup" && workflowType != "verify" {
+		return errors.New("Schedule workflow type [" + workflowType + "] is not supported, valid options are backup or verify")
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"time"
+)
+
+// maxStepRetryBackoff caps the doubling retry backoff
+const maxStepRetryBackoff = 10 * time.Minute
+
+// StepPolicy controls timeout and retries for a workflow step kind, Timeout and RetryBackoff are in seconds.
+// The backoff doubles after each failed attempt up to 10 minutes.
+type StepPolicy struct {
+	Kind         string `json:"kind"`
+	Timeout      int    `json:"timeout,omitempty"`
+	Retries      int    `json:"retries,omitempty"`
+	RetryBackoff int    `json:"retryBackoff,omitempty"`
+}
+
+func GetStepPolicy(kind string, stepPolicies []StepPolicy) StepPolicy {
+	for _, stepPolicy := range stepPolicies {
+		if stepPolicy.Kind == kind {
+			return stepPolicy
+		}
+	}
+
+	var stepPolicy StepPolicy
+	stepPolicy.Kind = kind
+
+	return stepPolicy
+}
+
+func GetStepTimeout(stepPolicy StepPolicy) time.Duration {
+	return time.Duration(stepPolicy.Timeout) * time.Second
+}
+
+// GetStepRetryBackoff returns how long to wait before the next attempt, attempt starts at 1
+func GetStepRetryBackoff(stepPolicy StepPolicy, attempt int) time.Duration {
+	if stepPolicy.RetryBackoff <= 0 || attempt < 1 {
+		return 0
+	}
+
+	backoff := time.Duration(stepPolicy.RetryBackoff) * time.Second
+	for i := 1; i < attempt && backoff < maxStepRetryBackoff; i++ {
+		backoff = backoff * 2
+	}
+
+	if backoff > maxStepRetryBackoff {
+		return maxStepRetryBackoff
+	}
+
+	return backoff
+}
+
+// IsStepIdempotent returns true for step kinds that can run again while an aborted attempt may still be running
+// on the app or storage service. Other kinds are only retried when the service reported the failure.
+func IsStepIdempotent(kind string) bool {
+	switch kind {
+	case "discover", "unquiesce", "backupRetention", "archiveRetention", "backupVerify", "jobRetention", "notify":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetStepPolicy(t *testing.T) {
+	blob := `
+MaxQuiesceTime = 300
+
+[[StepPolicies]]
+Kind = "backup"
+Timeout = 3600
+Retries = 2
+RetryBackoff = 10
+`
+	config, err := decodeConfig(blob)
+	if err != nil {
+		t.Fail()
+	}
+
+	if config.MaxQuiesceTime != 300 {
+		t.Fail()
+	}
+
+	stepPolicy := GetStepPolicy("backup", config.StepPolicies)
+	if stepPolicy.Retries != 2 || GetStepTimeout(stepPolicy) != time.Hour {
+		t.Fail()
+	}
+
+	if GetStepRetryBackoff(stepPolicy, 1) != 10*time.Second {
+		t.Fail()
+	}
+
+	if GetStepRetryBackoff(stepPolicy, 3) != 40*time.Second {
+		t.Fail()
+	}
+
+	if GetStepRetryBackoff(stepPolicy, 20) != maxStepRetryBackoff {
+		t.Fail()
+	}
+
+	stepPolicy = GetStepPolicy("archive", config.StepPolicies)
+	if stepPolicy.Retries != 0 || GetStepTimeout(stepPolicy) != 0 || GetStepRetryBackoff(stepPolicy, 1) != 0 {
+		t.Fail()
+	}
+}
+
+func TestIsStepIdempotent(t *testing.T) {
+	if IsStepIdempotent("backup") || IsStepIdempotent("restore") || IsStepIdempotent("command") {
+		t.Fail()
+	}
+
+	if !IsStepIdempotent("unquiesce") || !IsStepIdempotent("backupRetention") {
+		t.Fail()
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const tracerName = "fossul"
+
+// TraceSettings configures the span exporter of a service. Exporter is none, otlp or file, Endpoint is the
+// OTLP/HTTP collector url and File is where the file exporter writes spans, one JSON document per span.
+type TraceSettings struct {
+	Exporter string
+	Endpoint string
+	File     string
+}
+
+func GetTraceSettings(prefix string) TraceSettings {
+	var settings TraceSettings
+	settings.Exporter = os.Getenv(prefix + "_TRACE_EXPORTER")
+	settings.Endpoint = os.Getenv(prefix + "_TRACE_ENDPOINT")
+	settings.File = os.Getenv(prefix + "_TRACE_FILE")
+
+	return settings
+}
+
+// InitTracing sets the global tracer provider of a service, when no exporter is configured spans are not
+// recorded. Trace context is always propagated so a service without an exporter does not break a trace.
+// The returned function flushes and stops the exporter.
+func InitTracing(service string, settings TraceSettings) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch settings.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if settings.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(settings.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case "file":
+		if settings.File == "" {
+			return nil, errors.New("Trace exporter [file] requires a trace file")
+		}
+		var file *os.File
+		file, err = os.OpenFile(settings.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, errors.New("Trace exporter [" + settings.Exporter + "] is not supported, valid exporters are none, otlp or file")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(semconv.ServiceName("fossul-" + service))
+
+	// the file exporter is meant for testing, spans are written as they end
+	var processor sdktrace.TracerProviderOption
+	if settings.Exporter == "file" {
+		processor = sdktrace.WithSyncer(exporter)
+	} else {
+		processor = sdktrace.WithBatcher(exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(processor, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span that is a child of the span in ctx
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartWorkflowSpan starts the root span of a workflow, all step spans are children of it
+func StartWorkflowSpan(ctx context.Context, config Config, workflow *Workflow) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "workflow "+workflow.Type, trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("fossul.workflow.id", Int64ToString(workflow.Id)),
+			attribute.String("fossul.workflow.type", workflow.Type),
+			attribute.String("fossul.profile", config.ProfileName),
+			attribute.String("fossul.config", config.ConfigName),
+			attribute.String("fossul.policy", config.SelectedBackupPolicy),
+		))
+}
+
+// StartStepSpan starts the span of a workflow step, it is a child of the workflow span in ctx
+func StartStepSpan(ctx context.Context, step Step) (context.Context, trace.Span) {
+	return StartSpan(ctx, "step "+step.Kind,
+		attribute.Int("fossul.step.id", step.Id),
+		attribute.String("fossul.step.kind", step.Kind),
+		attribute.String("fossul.step.plugin", step.Plugin),
+	)
+}
+
+func EndStepSpan(span trace.Span, status string, resultCode int, err error) {
+	span.SetAttributes(attribute.String("fossul.step.status", status))
+	EndSpan(span, resultCode, err)
+}
+
+func startPluginSpan(ctx context.Context, pluginType, plugin, action string) (context.Context, trace.Span) {
+	return StartSpan(ctx, "plugin "+action,
+		attribute.String("fossul.plugin.type", pluginType),
+		attribute.String("fossul.plugin.name", plugin),
+		attribute.String("fossul.plugin.action", action),
+	)
+}
+
+// EndSpan sets the span status from the result of the traced call and ends it
+func EndSpan(span trace.Span, resultCode int, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if resultCode != 0 {
+		span.SetStatus(codes.Error, "result code "+strconv.Itoa(resultCode))
+	}
+
+	span.End()
+}
+
+// GetTraceId returns the trace id of the span in ctx or an empty string if there is none
+func GetTraceId(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
+
+// SetTraceHeaders propagates the span of the request context to the service receiving the request
+func SetTraceHeaders(req *http.Request) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
+
+// setTraceEnv propagates the span in ctx to a basic plugin using the TRACEPARENT environment variable
+func setTraceEnv(ctx context.Context, cmd *exec.Cmd) *exec.Cmd {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if traceParent := carrier.Get("traceparent"); traceParent != "" {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, "TRACEPARENT="+traceParent)
+	}
+
+	return cmd
+}
+
+// TraceApi starts a server span for every api request, it continues the trace of the calling service
+func TraceApi(inner http.Handler, service, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, service+" "+name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		inner.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.code))
+		if recorder.code >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.code))
+		}
+	})
+}
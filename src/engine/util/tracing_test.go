@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestTracePropagation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	shutdown, err := InitTracing("server", TraceSettings{Exporter: "file", File: dir + "/traces.json"})
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	workflow := &Workflow{Id: 1, Type: "backup"}
+	ctx, span := StartWorkflowSpan(context.Background(), Config{ProfileName: "default", ConfigName: "default"}, workflow)
+	stepCtx, stepSpan := StartStepSpan(ctx, Step{Id: 2, Kind: "backup"})
+
+	var serverTraceId string
+	server := httptest.NewServer(TraceApi(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverTraceId = GetTraceId(r.Context())
+	}), "storage", "Backup"))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/backup", nil)
+	req = req.WithContext(stepCtx)
+	SetTraceHeaders(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fail()
+		return
+	}
+	resp.Body.Close()
+
+	traceId := GetTraceId(ctx)
+	if traceId == "" || serverTraceId != traceId || GetTraceId(stepCtx) != traceId {
+		t.Fail()
+	}
+
+	cmd := setTraceEnv(stepCtx, exec.Command("true"))
+	if !strings.Contains(strings.Join(cmd.Env, " "), "TRACEPARENT=00-"+traceId) {
+		t.Fail()
+	}
+
+	EndStepSpan(stepSpan, "COMPLETE", 0, nil)
+	EndSpan(span, 0, nil)
+	if err := shutdown(context.Background()); err != nil {
+		t.Fail()
+		return
+	}
+
+	traces, err := ioutil.ReadFile(dir + "/traces.json")
+	if err != nil {
+		t.Fail()
+		return
+	}
+
+	for _, name := range []string{"workflow backup", "step backup", "storage Backup"} {
+		if !strings.Contains(string(traces), "\"Name\":\""+name+"\"") {
+			t.Fail()
+		}
+	}
+}
+
+func TestInitTracingInvalidExporter(t *testing.T) {
+	if _, err := InitTracing("server", TraceSettings{Exporter: "jaeger"}); err == nil {
+		t.Fail()
+	}
+
+	if _, err := InitTracing("server", TraceSettings{Exporter: "file"}); err == nil {
+		t.Fail()
+	}
+}
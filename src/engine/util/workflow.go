@@ -15,22 +15,34 @@ package util
 import (
 	"encoding/json"
 	"log"
-	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// Workflow StartTime and EndTime are epoch seconds, Duration is in seconds
 type Workflow struct {
-	Id        int    `json:"id"`
+	Id        int64  `json:"id"`
 	Status    string `json:"status"`
 	Type      string `json:"type"`
 	Policy    string `json:"policy"`
 	Timestamp string `json:"timestamp,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	Duration  int64  `json:"duration"`
+	Quiesced  bool   `json:"quiesced,omitempty"`
 	Steps     []Step `json:"steps,omitempty"`
+
+	// verify workflows restore into a target that is torn down, VerifyResult is PASSED or FAILED
+	TargetRestored bool   `json:"targetRestored,omitempty"`
+	VerifyResult   string `json:"verifyResult,omitempty"`
+
+	// workflows migrated from a legacy random id keep it, their backups are still named with it
+	LegacyId int64 `json:"legacyId,omitempty"`
 }
 
 type WorkflowResult struct {
-	Id     int    `json:"id"`
+	Id     int64  `json:"id"`
 	Result Result `json:"result,omitempty"`
 }
 
@@ -39,18 +51,54 @@ type WorkflowStatusResult struct {
 	Result   Result   `json:"result,omitempty"`
 }
 
+// Step Kind is the workflow step definition kind, Plugin the plugin called by the step and Host the
+// hostname of the service executing the step. StartTime and EndTime are epoch seconds, Duration is in seconds.
 type Step struct {
-	Id     int    `json:"id"`
-	Status string `json:"status"`
-	Label  string `json:"label,omitempty"`
+	Id        int    `json:"id"`
+	Status    string `json:"status"`
+	Label     string `json:"label,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Plugin    string `json:"plugin,omitempty"`
+	Host      string `json:"host,omitempty"`
+	StartTime int64  `json:"startTime,omitempty"`
+	EndTime   int64  `json:"endTime,omitempty"`
+	Duration  int64  `json:"duration"`
 }
 
-func GetWorkflowId() int {
-	rand.Seed(time.Now().UnixNano())
-	id := rand.Intn(10000)
+var workflowIdMutex sync.Mutex
+var lastWorkflowId int64
+
+// GetWorkflowId returns a unique, time sortable workflow id, the epoch in microseconds. Ids requested
+// within the same microsecond are incremented so they never collide.
+func GetWorkflowId() int64 {
+	workflowIdMutex.Lock()
+	defer workflowIdMutex.Unlock()
+
+	id := GetWorkflowIdFromTime(time.Now())
+	if id <= lastWorkflowId {
+		id = lastWorkflowId + 1
+	}
+	lastWorkflowId = id
+
 	return id
 }
 
+func GetWorkflowIdFromTime(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+// GetBackupWorkflowId returns the workflow id the backups of a workflow are named with, that is the legacy
+// id for workflows migrated from legacy random ids
+func GetBackupWorkflowId(jobsDir string, workflowId int64) int64 {
+	workflow := &Workflow{}
+	err := ReadGob(jobsDir+"/"+Int64ToString(workflowId)+"/workflow", &workflow)
+	if err != nil || workflow.LegacyId == 0 {
+		return workflowId
+	}
+
+	return workflow.LegacyId
+}
+
 func CreateStep(workflow *Workflow) Step {
 	id := len(workflow.Steps)
 
@@ -58,6 +106,7 @@ func CreateStep(workflow *Workflow) Step {
 	step.Id = id
 	step.Status = "RUNNING"
 	step.Label = "Step " + IntToString(id)
+	step.StartTime = GetTimestamp()
 
 	return step
 }
@@ -69,28 +118,80 @@ func CreateCommentStep(workflow *Workflow) Step {
 	step.Id = id
 	step.Status = "COMPLETE"
 	step.Label = "Step " + IntToString(id)
+	step.Kind = "comment"
+	step.StartTime = GetTimestamp()
+	step.EndTime = step.StartTime
 
 	return step
 }
 
 func SetStepComplete(workflow *Workflow, step Step) {
 	workflow.Steps[step.Id].Status = "COMPLETE"
+	setStepEnd(workflow, step)
 }
 
 func SetStepError(workflow *Workflow, step Step) {
 	workflow.Steps[step.Id].Status = "ERROR"
+	setStepEnd(workflow, step)
+}
+
+func SetStepCancelled(workflow *Workflow, step Step) {
+	workflow.Steps[step.Id].Status = "CANCELLED"
+	setStepEnd(workflow, step)
+}
+
+func setStepEnd(workflow *Workflow, step Step) {
+	workflow.Steps[step.Id].EndTime = GetTimestamp()
+	workflow.Steps[step.Id].Duration = workflow.Steps[step.Id].EndTime - workflow.Steps[step.Id].StartTime
 }
 
 func SetWorkflowStatusStart(workflow *Workflow) {
 	workflow.Status = "RUNNING"
+	workflow.StartTime = GetTimestamp()
+}
+
+func SetWorkflowStatusQueued(workflow *Workflow) {
+	workflow.Status = "QUEUED"
 }
 
 func SetWorkflowStatusEnd(workflow *Workflow) {
 	workflow.Status = "COMPLETE"
+	setWorkflowEnd(workflow)
 }
 
 func SetWorkflowStatusError(workflow *Workflow) {
 	workflow.Status = "ERROR"
+	setWorkflowEnd(workflow)
+}
+
+func SetWorkflowStatusCancelled(workflow *Workflow) {
+	workflow.Status = "CANCELLED"
+	setWorkflowEnd(workflow)
+}
+
+func SetWorkflowStatusAborted(workflow *Workflow) {
+	workflow.Status = "ABORTED"
+	setWorkflowEnd(workflow)
+}
+
+// setWorkflowEnd records the end time, a workflow that never started (ex: cancelled while queued) has no duration.
+// A verify workflow passes only if it completed, a cancelled verify has no result.
+func setWorkflowEnd(workflow *Workflow) {
+	workflow.EndTime = GetTimestamp()
+	if workflow.StartTime != 0 {
+		workflow.Duration = workflow.EndTime - workflow.StartTime
+	}
+
+	if workflow.Type == "verify" {
+		switch workflow.Status {
+		case "COMPLETE":
+			workflow.VerifyResult = "PASSED"
+		case "ERROR", "ABORTED":
+			workflow.VerifyResult = "FAILED"
+		}
+	}
+
+	observeWorkflow(workflow)
 }
 
 func SerializeWorkflow(resultsDir string, workflow *Workflow) {
@@ -118,6 +219,29 @@ func SerializeWorkflowStepResults(resultsDir string, stepId int, results Result)
 	}
 }
 
+// SerializeWorkflowConfig saves the config a workflow was started with, it is needed to recover the workflow
+func SerializeWorkflowConfig(resultsDir string, config Config) {
+	err := CreateDir(resultsDir, 0755)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	err = WriteGob(resultsDir+"/config", config)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func ReadWorkflowConfig(resultsDir string) (Config, error) {
+	var config Config
+	err := ReadGob(resultsDir+"/config", &config)
+	if err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
 func SetWorkflowStep(workflow *Workflow, step Step) {
 	steps := workflow.Steps
 	steps = append(steps, step)
@@ -0,0 +1,315 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"errors"
+)
+
+// WorkflowStepDefinition describes a single typed step of a workflow. Steps are executed
+// in order by the server. Command steps either run one of the configured hook commands
+// (Hook set to the config field name, ex: PreAppQuiesceCmd) or a custom command (Cmd)
+// on the app or storage service.
+type WorkflowStepDefinition struct {
+	Kind      string `json:"kind"`
+	Hook      string `json:"hook,omitempty"`
+	Cmd       string `json:"cmd,omitempty"`
+	Service   string `json:"service,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	OnlyIf    string `json:"onlyIf,omitempty"`
+	OnFailure string `json:"onFailure,omitempty"`
+}
+
+var workflowStepKinds = []string{
+	"comment",
+	"discover",
+	"command",
+	"quiesce",
+	"unquiesce",
+	"backup",
+	"backupRetention",
+	"archive",
+	"archiveRetention",
+	"preRestore",
+	"archiveRestore",
+	"backupVerify",
+	"restore",
+	"postRestore",
+	"jobRetention",
+	"notify",
+}
+
+// workflowStepHooks maps each hook to the service that executes its command
+var workflowStepHooks = map[string]string{
+	"PreAppQuiesceCmd":    "app",
+	"AppQuiesceCmd":       "app",
+	"PostAppQuiesceCmd":   "app",
+	"BackupCreateCmd":     "storage",
+	"PreAppUnquiesceCmd":  "app",
+	"AppUnquiesceCmd":     "app",
+	"PostAppUnquiesceCmd": "app",
+	"BackupDeleteCmd":     "storage",
+	"ArchiveCreateCmd":    "storage",
+	"ArchiveDeleteCmd":    "storage",
+	"PreAppRestoreCmd":    "app",
+	"RestoreCmd":          "storage",
+	"PostAppRestoreCmd":   "app",
+	"VerifyCheckCmd":      "app",
+	"VerifyTeardownCmd":   "app",
+}
+
+var workflowStepServices = []string{
+	"app",
+	"storage",
+}
+
+func GetDefaultBackupWorkflow() []WorkflowStepDefinition {
+	var steps []WorkflowStepDefinition
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Application Discovery", OnlyIf: "discover"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "discover"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Application Quiesce"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PreAppQuiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "AppQuiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "quiesce"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PostAppQuiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Backup"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "BackupCreateCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "backup"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Application Unquiesce"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PreAppUnquiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "AppUnquiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "unquiesce"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PostAppUnquiesceCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Backup Retention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "BackupDeleteCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "backupRetention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Archive"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "ArchiveCreateCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "archive"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Archive Retention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "ArchiveDeleteCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "archiveRetention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "jobRetention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Sending Notifications"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "notify"})
+
+	return steps
+}
+
+func GetDefaultRestoreWorkflow() []WorkflowStepDefinition {
+	var steps []WorkflowStepDefinition
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Application Pre Restore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PreAppRestoreCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "preRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Restore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "RestoreCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "archiveRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "backupVerify"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "restore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Application Post Restore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "PostAppRestoreCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "postRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "jobRetention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Sending Notifications"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "notify"})
+
+	return steps
+}
+
+// GetDefaultVerifyWorkflow restores into the verify target using only plugins, restore commands are skipped
+// as they are configured for the application itself
+func GetDefaultVerifyWorkflow() []WorkflowStepDefinition {
+	var steps []WorkflowStepDefinition
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Restore Into Verify Target"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "preRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "archiveRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "backupVerify"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "restore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "postRestore"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Verify Check"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "VerifyCheckCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Performing Verify Target Teardown"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "command", Hook: "VerifyTeardownCmd"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "jobRetention"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "comment", Comment: "Sending Notifications"})
+	steps = append(steps, WorkflowStepDefinition{Kind: "notify"})
+
+	return steps
+}
+
+// GetWorkflowDefinition returns the workflow definition configured for the given workflow
+// type, falling back to the built-in default definition if the config doesn't override it
+func GetWorkflowDefinition(config Config, workflowType string) ([]WorkflowStepDefinition, error) {
+	var steps []WorkflowStepDefinition
+
+	switch workflowType {
+	case "backup":
+		steps = config.BackupWorkflow
+		if len(steps) == 0 {
+			steps = GetDefaultBackupWorkflow()
+		}
+	case "restore":
+		steps = config.RestoreWorkflow
+		if len(steps) == 0 {
+			steps = GetDefaultRestoreWorkflow()
+		}
+	case "verify":
+		steps = config.VerifyWorkflow
+		if len(steps) == 0 {
+			steps = GetDefaultVerifyWorkflow()
+		}
+	default:
+		return steps, errors.New("Workflow type [" + workflowType + "] is not supported")
+	}
+
+	err := ValidateWorkflowDefinition(steps)
+	if err != nil {
+		return steps, err
+	}
+
+	err = validateWorkflowArchive(config, steps)
+	if err != nil {
+		return steps, err
+	}
+
+	return steps, nil
+}
+
+// validateWorkflowArchive rejects archive steps for storage plugins that keep backup data outside of the backup dir
+func validateWorkflowArchive(config Config, steps []WorkflowStepDefinition) error {
+	if config.ArchivePlugin == "" || IsStoragePluginArchivable(config.StoragePlugin) {
+		return nil
+	}
+
+	for i, step := range steps {
+		if step.Kind == "archive" || step.Kind == "archiveRestore" {
+			return errors.New("Workflow step [" + IntToString(i) + "] archives backups of storage plugin [" + config.StoragePlugin + "] which can't be archived, remove ArchivePlugin from the config")
+		}
+	}
+
+	return nil
+}
+
+func ValidateWorkflowDefinition(steps []WorkflowStepDefinition) error {
+	for i, step := range steps {
+		stepNumber := IntToString(i)
+
+		if !ExistsInArray(workflowStepKinds, step.Kind) {
+			return errors.New("Workflow step [" + stepNumber + "] has invalid kind [" + step.Kind + "]")
+		}
+
+		if step.OnFailure != "" && step.OnFailure != "abort" && step.OnFailure != "continue" {
+			return errors.New("Workflow step [" + stepNumber + "] has invalid onFailure [" + step.OnFailure + "], valid options are abort or continue")
+		}
+
+		switch step.Kind {
+		case "comment":
+			if step.Comment == "" {
+				return errors.New("Workflow step [" + stepNumber + "] of kind comment requires a comment")
+			}
+
+			if step.OnlyIf != "" && (step.OnlyIf == "comment" || step.OnlyIf == "command" || !ExistsInArray(workflowStepKinds, step.OnlyIf)) {
+				return errors.New("Workflow step [" + stepNumber + "] has invalid onlyIf [" + step.OnlyIf + "], it must be a plugin step kind")
+			}
+		case "command":
+			if step.Hook == "" && step.Cmd == "" {
+				return errors.New("Workflow step [" + stepNumber + "] of kind command requires a hook or cmd")
+			}
+
+			if step.Hook != "" && step.Cmd != "" {
+				return errors.New("Workflow step [" + stepNumber + "] of kind command can't have both a hook and cmd")
+			}
+
+			if _, ok := workflowStepHooks[step.Hook]; step.Hook != "" && !ok {
+				return errors.New("Workflow step [" + stepNumber + "] has invalid hook [" + step.Hook + "]")
+			}
+
+			if step.Cmd != "" && !ExistsInArray(workflowStepServices, step.Service) {
+				return errors.New("Workflow step [" + stepNumber + "] has invalid service [" + step.Service + "], valid options are app or storage")
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetWorkflowStepService returns the service (app, storage or server) that executes a step
+func GetWorkflowStepService(step WorkflowStepDefinition) string {
+	switch step.Kind {
+	case "discover", "quiesce", "unquiesce", "preRestore", "postRestore":
+		return "app"
+	case "backup", "backupRetention", "backupVerify", "restore", "archive", "archiveRetention", "archiveRestore":
+		return "storage"
+	case "jobRetention", "notify":
+		return "server"
+	case "command":
+		if step.Hook != "" {
+			return workflowStepHooks[step.Hook]
+		}
+		return step.Service
+	}
+
+	return ""
+}
+
+// GetWorkflowStepPlugin returns the configured plugin a step calls, command steps don't use a plugin
+func GetWorkflowStepPlugin(config Config, step WorkflowStepDefinition) string {
+	switch step.Kind {
+	case "discover", "quiesce", "unquiesce", "preRestore", "postRestore":
+		return config.AppPlugin
+	case "backup", "backupRetention", "restore":
+		return config.StoragePlugin
+	case "archive", "archiveRetention", "archiveRestore":
+		return config.ArchivePlugin
+	}
+
+	return ""
+}
+
+// GetWorkflowHookCmd returns the command configured for a hook, an empty command means the step is skipped
+func GetWorkflowHookCmd(config Config, hook string) string {
+	switch hook {
+	case "PreAppQuiesceCmd":
+		return config.PreAppQuiesceCmd
+	case "AppQuiesceCmd":
+		return config.AppQuiesceCmd
+	case "PostAppQuiesceCmd":
+		return config.PostAppQuiesceCmd
+	case "BackupCreateCmd":
+		return config.BackupCreateCmd
+	case "PreAppUnquiesceCmd":
+		return config.PreAppUnquiesceCmd
+	case "AppUnquiesceCmd":
+		return config.AppUnquiesceCmd
+	case "PostAppUnquiesceCmd":
+		return config.PostAppUnquiesceCmd
+	case "BackupDeleteCmd":
+		return config.BackupDeleteCmd
+	case "ArchiveCreateCmd":
+		return config.ArchiveCreateCmd
+	case "ArchiveDeleteCmd":
+		return config.ArchiveDeleteCmd
+	case "PreAppRestoreCmd":
+		return config.PreAppRestoreCmd
+	case "RestoreCmd":
+		return config.RestoreCmd
+	case "PostAppRestoreCmd":
+		return config.PostAppRestoreCmd
+	case "VerifyCheckCmd":
+		return config.VerifyCheckCmd
+	case "VerifyTeardownCmd":
+		return config.VerifyTeardownCmd
+	}
+
+	return ""
+}
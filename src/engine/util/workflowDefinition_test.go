@@ -0,0 +1,245 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"testing"
+)
+
+func TestGetWorkflowDefinitionDefault(t *testing.T) {
+	var config Config
+
+	steps, err := GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(steps) != len(GetDefaultBackupWorkflow()) {
+		t.Fail()
+	}
+
+	steps, err = GetWorkflowDefinition(config, "restore")
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(steps) != len(GetDefaultRestoreWorkflow()) {
+		t.Fail()
+	}
+
+	steps, err = GetWorkflowDefinition(config, "verify")
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(steps) != len(GetDefaultVerifyWorkflow()) {
+		t.Fail()
+	}
+
+	_, err = GetWorkflowDefinition(config, "foo")
+	if err == nil {
+		t.Fail()
+	}
+}
+
+func TestGetWorkflowDefinitionOverride(t *testing.T) {
+	blob := `
+AppPlugin = "sample-app"
+AppQuiesceCmd = "echo,quiesce"
+
+[[BackupWorkflow]]
+Kind = "command"
+Hook = "AppQuiesceCmd"
+
+[[BackupWorkflow]]
+Kind = "command"
+Cmd = "echo,hello"
+Service = "app"
+OnFailure = "continue"
+
+[[BackupWorkflow]]
+Kind = "backup"
+`
+	config, err := decodeConfig(blob)
+	if err != nil {
+		t.Fail()
+	}
+
+	steps, err := GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(steps) != 3 {
+		t.Fail()
+	}
+
+	if steps[1].Cmd != "echo,hello" || steps[1].OnFailure != "continue" {
+		t.Fail()
+	}
+
+	if GetWorkflowHookCmd(config, steps[0].Hook) != "echo,quiesce" {
+		t.Fail()
+	}
+}
+
+func TestGetDefaultBackupWorkflowDiscovery(t *testing.T) {
+	steps := GetDefaultBackupWorkflow()
+	if steps[0].Kind != "comment" || steps[0].Comment != "Performing Application Discovery" || steps[0].OnlyIf != "discover" {
+		t.Logf("ERROR: expected discovery comment as first step, got %+v", steps[0])
+		t.Fail()
+	}
+
+	if steps[1].Kind != "discover" {
+		t.Logf("ERROR: expected discover as second step, got %s", steps[1].Kind)
+		t.Fail()
+	}
+
+	if ValidateWorkflowDefinition(steps) != nil {
+		t.Fail()
+	}
+}
+
+func TestValidateWorkflowDefinition(t *testing.T) {
+	var steps []WorkflowStepDefinition
+	steps = append(steps, WorkflowStepDefinition{Kind: "foo"})
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "command", Hook: "FooCmd"}}
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "command", Cmd: "echo,foo", Service: "server"}}
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "backup", OnFailure: "retry"}}
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "comment"}}
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "comment", Comment: "foo", OnlyIf: "command"}}
+	if ValidateWorkflowDefinition(steps) == nil {
+		t.Fail()
+	}
+
+	steps = []WorkflowStepDefinition{{Kind: "command", Cmd: "echo,foo", Service: "storage"}, {Kind: "backup", OnFailure: "abort"}}
+	if ValidateWorkflowDefinition(steps) != nil {
+		t.Fail()
+	}
+}
+
+func TestGetWorkflowDefinitionArchive(t *testing.T) {
+	var config Config
+	config.StoragePlugin = "container-dedup.so"
+
+	_, err := GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		t.Fail()
+	}
+
+	config.ArchivePlugin = "aws.so"
+	_, err = GetWorkflowDefinition(config, "backup")
+	if err == nil {
+		t.Logf("ERROR: Archive steps of non archivable storage plugin [container-dedup.so] should be rejected")
+		t.Fail()
+	}
+
+	config.StoragePlugin = "container-basic.so"
+	_, err = GetWorkflowDefinition(config, "backup")
+	if err != nil {
+		t.Fail()
+	}
+}
+
+func TestGetWorkflowStepService(t *testing.T) {
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "quiesce"}) != "app" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "archive"}) != "storage" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "jobRetention"}) != "server" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "command", Hook: "BackupCreateCmd"}) != "storage" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "command", Hook: "PreAppRestoreCmd"}) != "app" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepService(WorkflowStepDefinition{Kind: "command", Cmd: "echo,foo", Service: "storage"}) != "storage" {
+		t.Fail()
+	}
+}
+
+func TestGetWorkflowStepPlugin(t *testing.T) {
+	var config Config
+	config.AppPlugin = "sample-app"
+	config.StoragePlugin = "sample-storage"
+	config.ArchivePlugin = "sample-archive"
+
+	if GetWorkflowStepPlugin(config, WorkflowStepDefinition{Kind: "discover"}) != "sample-app" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepPlugin(config, WorkflowStepDefinition{Kind: "restore"}) != "sample-storage" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepPlugin(config, WorkflowStepDefinition{Kind: "archiveRetention"}) != "sample-archive" {
+		t.Fail()
+	}
+
+	if GetWorkflowStepPlugin(config, WorkflowStepDefinition{Kind: "command", Hook: "AppQuiesceCmd"}) != "" {
+		t.Fail()
+	}
+}
+
+func TestEncodeConfigWorkflowDefinition(t *testing.T) {
+	var config Config
+	config.BackupWorkflow = GetDefaultBackupWorkflow()
+
+	buf, err := EncodeConfig(config)
+	if err != nil {
+		t.Fail()
+	}
+
+	decodedConfig, err := decodeConfig(buf.String())
+	if err != nil {
+		t.Fail()
+	}
+
+	if len(decodedConfig.BackupWorkflow) != len(config.BackupWorkflow) {
+		t.Fail()
+	}
+
+	buf, err = EncodeConfig(Config{})
+	if err != nil {
+		t.Fail()
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+// WorkflowEvent is a workflow, step status or step message change streamed to clients following a workflow.
+// Message events have the position of the message in the step results as index.
+type WorkflowEvent struct {
+	Type       string   `json:"type"`
+	WorkflowId int64    `json:"workflowId"`
+	Status     string   `json:"status,omitempty"`
+	StepId     int      `json:"stepId"`
+	Label      string   `json:"label,omitempty"`
+	Index      int      `json:"index"`
+	Message    *Message `json:"message,omitempty"`
+}
+
+func IsWorkflowStatusTerminal(status string) bool {
+	switch status {
+	case "COMPLETE", "ERROR", "CANCELLED", "ABORTED":
+		return true
+	}
+
+	return false
+}
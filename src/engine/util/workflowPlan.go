@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+type WorkflowPlanResult struct {
+	Plan   WorkflowPlan `json:"plan,omitempty"`
+	Result Result       `json:"result,omitempty"`
+}
+
+// WorkflowPlan describes what a workflow would do without executing it. ExpiredBackups and ExpiredArchives
+// are deleted by retention, RestoreBackup is the backup a restore workflow would restore.
+type WorkflowPlan struct {
+	Type            string        `json:"type"`
+	Policy          string        `json:"policy"`
+	Config          Config        `json:"config"`
+	Steps           []PlannedStep `json:"steps,omitempty"`
+	ExpiredBackups  []Backup      `json:"expiredBackups,omitempty"`
+	ExpiredArchives []Archive     `json:"expiredArchives,omitempty"`
+	RestoreBackup   *Backup       `json:"restoreBackup,omitempty"`
+}
+
+// PlannedStep is a workflow step as it would be executed, Command is the hook or custom command split into its
+// arguments. Notifiers are the notifiers the step would send to. Steps that don't apply to the config, ex: plugin
+// isn't configured, are marked skipped.
+type PlannedStep struct {
+	Kind       string     `json:"kind"`
+	Hook       string     `json:"hook,omitempty"`
+	Command    []string   `json:"command,omitempty"`
+	Notifiers  []string   `json:"notifiers,omitempty"`
+	Service    string     `json:"service,omitempty"`
+	Host       string     `json:"host,omitempty"`
+	Plugin     string     `json:"plugin,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	OnFailure  string     `json:"onFailure,omitempty"`
+	StepPolicy StepPolicy `json:"stepPolicy"`
+	Skipped    bool       `json:"skipped,omitempty"`
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2019 The Fossul Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+type WorkflowQueueResult struct {
+	Workflows []QueuedWorkflow `json:"workflows,omitempty"`
+	Result    Result           `json:"result,omitempty"`
+}
+
+// QueuedWorkflow is a workflow admitted by the server workflow queue, either RUNNING or QUEUED. Position is
+// the place in the queue of a QUEUED workflow starting at 1, Timestamp is when it was queued or started.
+type QueuedWorkflow struct {
+	WorkflowId  int64  `json:"workflowId"`
+	ProfileName string `json:"profileName"`
+	ConfigName  string `json:"configName"`
+	Type        string `json:"type"`
+	Policy      string `json:"policy,omitempty"`
+	Status      string `json:"status"`
+	StorageHost string `json:"storageHost,omitempty"`
+	Position    int    `json:"position,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
@@ -13,7 +13,9 @@ limitations under the License.
 package util
 
 import (
+	"io/ioutil"
 	"log"
+	"os"
 	"regexp"
 	"testing"
 )
@@ -38,7 +40,7 @@ func TestWorkflow(t *testing.T) {
 	log.Println(workflow)
 
 	re := regexp.MustCompile(`\d+`)
-	match := re.FindStringSubmatch(IntToString(workflow.Id))
+	match := re.FindStringSubmatch(Int64ToString(workflow.Id))
 
 	if len(match) == 0 {
 		t.Fail()
@@ -59,4 +61,123 @@ func TestWorkflow(t *testing.T) {
 	if workflow.Steps[2].Status != "ERROR" {
 		t.Fail()
 	}
+
+	if workflow.StartTime == 0 || workflow.Steps[0].StartTime == 0 || workflow.Steps[0].EndTime != 0 {
+		t.Fail()
+	}
+
+	if workflow.Steps[1].EndTime < workflow.Steps[1].StartTime || workflow.Steps[1].Duration != workflow.Steps[1].EndTime-workflow.Steps[1].StartTime {
+		t.Fail()
+	}
+
+	SetWorkflowStatusEnd(workflow)
+	if workflow.EndTime < workflow.StartTime || workflow.Duration != workflow.EndTime-workflow.StartTime {
+		t.Fail()
+	}
+}
+
+func TestVerifyResult(t *testing.T) {
+	workflow := &Workflow{}
+	workflow.Type = "verify"
+	SetWorkflowStatusStart(workflow)
+	SetWorkflowStatusEnd(workflow)
+	if workflow.VerifyResult != "PASSED" {
+		t.Fail()
+	}
+
+	workflow = &Workflow{}
+	workflow.Type = "verify"
+	SetWorkflowStatusStart(workflow)
+	SetWorkflowStatusError(workflow)
+	if workflow.VerifyResult != "FAILED" {
+		t.Fail()
+	}
+
+	workflow = &Workflow{}
+	workflow.Type = "backup"
+	SetWorkflowStatusEnd(workflow)
+	if workflow.VerifyResult != "" {
+		t.Fail()
+	}
+}
+
+func TestGetWorkflowIdUnique(t *testing.T) {
+	ids := make(map[int64]bool)
+
+	var lastId int64
+	for i := 0; i < 1000; i++ {
+		id := GetWorkflowId()
+		if ids[id] || id <= lastId {
+			t.Fail()
+		}
+
+		ids[id] = true
+		lastId = id
+	}
+}
+
+func TestIsWorkflowStatusTerminal(t *testing.T) {
+	if IsWorkflowStatusTerminal("RUNNING") {
+		t.Fail()
+	}
+
+	for _, status := range []string{"COMPLETE", "ERROR", "CANCELLED", "ABORTED"} {
+		if !IsWorkflowStatusTerminal(status) {
+			t.Fail()
+		}
+	}
+}
+
+func TestSerializeWorkflowConfig(t *testing.T) {
+	resultsDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+	}
+	defer os.RemoveAll(resultsDir)
+
+	var config Config
+	config.ProfileName = "default"
+	config.AppPluginParameters = map[string]string{"Namespace": "foo"}
+
+	SerializeWorkflowConfig(resultsDir, config)
+
+	savedConfig, err := ReadWorkflowConfig(resultsDir)
+	if err != nil {
+		t.Fail()
+	}
+
+	if savedConfig.ProfileName != "default" || savedConfig.AppPluginParameters["Namespace"] != "foo" {
+		t.Fail()
+	}
+}
+
+func TestGetBackupWorkflowId(t *testing.T) {
+	jobsDir, err := ioutil.TempDir("", "fossul")
+	if err != nil {
+		t.Fail()
+		return
+	}
+	defer os.RemoveAll(jobsDir)
+
+	workflow := &Workflow{}
+	workflow.Id = GetWorkflowId()
+	workflow.LegacyId = 1234
+	CreateDir(jobsDir+"/"+Int64ToString(workflow.Id), 0755)
+	err = WriteGob(jobsDir+"/"+Int64ToString(workflow.Id)+"/workflow", workflow)
+	if err != nil {
+		t.Logf("ERROR: %s", err.Error())
+		t.Fail()
+		return
+	}
+
+	if GetBackupWorkflowId(jobsDir, workflow.Id) != 1234 {
+		t.Logf("ERROR: Migrated workflow [%d] should map to legacy id [1234]", workflow.Id)
+		t.Fail()
+	}
+
+	id := GetWorkflowId()
+	if GetBackupWorkflowId(jobsDir, id) != id {
+		t.Logf("ERROR: Unknown workflow [%d] should keep its id", id)
+		t.Fail()
+	}
 }